@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	// * Activity analytics accept any IANA time zone, with or without a
+	// * zoneinfo database on the host
+	_ "time/tzdata"
 
 	_ "github.com/KOFI-GYIMAH/github-monitor/docs"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/config"
@@ -14,8 +18,11 @@ import (
 	"github.com/KOFI-GYIMAH/github-monitor/internal/github"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/handler"
 	md "github.com/KOFI-GYIMAH/github-monitor/internal/middleware"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/queue"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/worker"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
 	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -31,31 +38,48 @@ func main() {
 		logger.SetLevel(logger.LevelDebug)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	// * Load configuration
 	cfg, err := config.LoadConfiguration()
 	if err != nil {
 		logger.Error("Failed to load config: %v", err)
 	}
 
-	// * Initialize PostgreSQL database
-	database, err := db.NewPostgresDB(cfg.DBURL)
+	// * Initialize database (Postgres or SQLite, depending on DB_PATH)
+	database, err := db.Open(cfg.DBURL, cfg.DBReplicaURLs...)
 	if err != nil {
 		logger.Error("Failed to initialize database: %v", err)
 	}
 	defer database.Close()
 
-	// * Run migrations
+	// * Run migrations, refusing a dirty schema or one newer than this binary
 	if err := database.Migrate(); err != nil {
 		logger.Error("Failed to run migrations: %v", err)
-	} else {
-		logger.Info("Successfully ran migrations 🎉")
+		os.Exit(1)
 	}
+	logger.Info("Successfully ran migrations 🎉")
 
 	// * Initialize GitHub client
 	githubClient := github.NewClient(cfg.GitHubToken)
 
 	// * Create services
 	repoService := service.NewRepositoryService(githubClient, database)
+	retentionService := service.NewRetentionService(database)
+	authorService := service.NewAuthorService(database)
+	auditService := service.NewAuditService(database)
+	analyticsService := service.NewAnalyticsService(database)
+	changelogService := service.NewChangelogService(githubClient, database)
+
+	jobSettings, err := cfg.JobSettings()
+	if err != nil {
+		logger.Error("Invalid job configuration: %v", err)
+		os.Exit(1)
+	}
+	jobService := service.NewJobService(database, jobSettings.RetryDelay)
+	jobService.OnFailure(models.JobReset, repoService.FailResetJob)
 
 	// * Parse sync interval
 	syncInterval, err := time.ParseDuration(cfg.SyncInterval)
@@ -63,9 +87,47 @@ func main() {
 		logger.Error("Invalid sync interval: %v", err)
 	}
 
+	// * The prune ticker panics on a non-positive interval
+	pruneInterval, err := time.ParseDuration(cfg.PruneInterval)
+	if err != nil || pruneInterval <= 0 {
+		logger.Error("Invalid prune interval %q: must be a positive duration such as 24h", cfg.PruneInterval)
+		os.Exit(1)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// * Make sure this month's commit partition exists before syncing
+	if err := retentionService.MaintainPartitions(ctx); err != nil {
+		logger.Error("Failed to maintain commit partitions: %v", err)
+	}
+
+	// * Run queued jobs; every replica claims from the same job queue
+	hostname, _ := os.Hostname()
+	runner := worker.NewJobRunner(jobService, fmt.Sprintf("%s-%d", hostname, os.Getpid()), jobSettings.Concurrency)
+	runner.Handle(models.JobSync, worker.SyncJobHandler(repoService))
+	runner.Handle(models.JobReset, repoService.RunResetJob)
+	runner.Handle(models.JobResolveAuthors, func(ctx context.Context, job *models.Job) error {
+		_, err := authorService.ResolveAll(ctx)
+		return err
+	})
+	runnerDone := make(chan struct{})
+	go func() {
+		defer close(runnerDone)
+		runner.Run(ctx)
+	}()
+
+	// * Resolve authors of commits stored before identities existed, once
+	// * however many replicas start together
+	_, err = jobService.Enqueue(ctx, &models.Job{
+		Kind:      models.JobResolveAuthors,
+		DedupeKey: string(models.JobResolveAuthors),
+		Priority:  service.JobPriorityLow,
+	}, struct{}{})
+	if err != nil {
+		logger.Error("Failed to queue commit author resolution: %v", err)
+	}
+
 	// * List repositories from DB
 	repositories, err := repoService.ListAllRepositories(ctx)
 	if err != nil {
@@ -81,38 +143,66 @@ func main() {
 			os.Exit(1)
 		}
 
-		// * Sync the default repo
+		// * Sync the default repo, unless a replica starting alongside this
+		// * one is already syncing it
 		logger.Info("Syncing default repository: %s/%s", owner, name)
-		if err := repoService.SyncRepository(ctx, owner, name, time.Time{}); err != nil {
+		err = repoService.SyncRepository(ctx, models.SyncTriggerWorker, owner, name, time.Time{})
+		if errors.Reference(err) == "SYNC_IN_PROGRESS" {
+			logger.Info("Default repository is being synced by another replica")
+		} else if err != nil {
 			logger.Error("Failed to sync default repository: %v", err)
 			os.Exit(1)
 		}
+	}
 
-		// * Reload repositories after syncing
-		repositories, err = repoService.ListAllRepositories(ctx)
+	// * One replica leads: only it schedules syncs and prunes. Leadership is
+	// * settled before the first syncs are scheduled.
+	leader := worker.NewLeader(database, "scheduler")
+	leader.Check(ctx)
+	go leader.Run(ctx)
+
+	// * Scheduled syncs go to the database job queue, shared by the replicas,
+	// * or with RabbitMQ configured to the worker binary (cmd/worker)
+	sync := worker.JobSync(jobService, jobSettings.MaxAttempts)
+	if cfg.RabbitMQURL != "" {
+		queueConfig, err := cfg.QueueConfig()
 		if err != nil {
-			logger.Error("Failed to reload repositories from DB: %v", err)
+			logger.Error("Invalid job queue configuration: %v", err)
 			os.Exit(1)
 		}
+		jobs := queue.New(queueConfig)
+		defer jobs.Close()
+
+		sync = worker.QueueSync(jobs)
+		logger.Info("Publishing scheduled syncs to RabbitMQ")
 	}
 
-	for _, repo := range repositories {
-		owner, name, err := config.ParseRepository(repo.Name)
-		if err != nil {
-			logger.Error("Invalid repository format: %v", err)
-			os.Exit(1)
-		}
-		w := worker.NewSyncWorker(repoService, syncInterval, owner, name)
-		go w.Run(ctx)
+	// * Every replica runs sync workers so a new leader takes over at once,
+	// * and keeps them in line with repositories changed through the others
+	workers := worker.NewSyncManager(ctx, repoService, worker.LeaderOnly(leader, sync), syncInterval)
+	if err := workers.Reconcile(ctx); err != nil {
+		logger.Error("Failed to start sync workers: %v", err)
+		os.Exit(1)
 	}
+	go workers.Watch(time.Minute)
+
+	// * Enforce retention policies in the background
+	go worker.NewPruneWorker(retentionService, leader, pruneInterval).Run(ctx)
 
 	// * Create API server
-	apiHandler := handler.NewRepositoryHandler(ctx, repoService)
+	apiHandler := handler.NewRepositoryHandler(repoService, workers)
 	router := mux.NewRouter()
 	router.Use(md.LoggingMiddleware)
+	router.Use(md.ActorMiddleware)
 	api := router.PathPrefix("/api/v1").Subrouter()
 
 	apiHandler.RegisterRoutes(api)
+	handler.NewRetentionHandler(retentionService).RegisterRoutes(api)
+	handler.NewAuthorHandler(authorService).RegisterRoutes(api)
+	handler.NewAuditHandler(auditService).RegisterRoutes(api)
+	handler.NewAnalyticsHandler(analyticsService).RegisterRoutes(api)
+	handler.NewChangelogHandler(changelogService).RegisterRoutes(api)
+	handler.NewJobHandler(jobService).RegisterRoutes(api)
 	router.PathPrefix("/api/v1/swagger/").Handler(httpSwagger.WrapHandler)
 
 	port := os.Getenv("SERVER_PORT")
@@ -138,5 +228,8 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
+	// * Running jobs are handed back to the queue for another replica
 	logger.Info("Shutting down...")
+	cancel()
+	<-runnerDone
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/config"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  up          apply every pending migration
+  down [N]    roll back the N most recent migrations (default 1)
+  goto V      migrate up or down to version V (0 rolls back everything)
+  version     print the applied and latest known schema versions
+  force V     mark version V as applied and clean without running it (-1 for none)`
+
+// * runMigrate implements the migrate subcommand and returns the exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	url, err := config.LoadDatabaseURL()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	store, err := db.Open(url)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer store.Close()
+
+	migrator, err := store.Migrator()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer migrator.Close()
+
+	if err := migrateCommand(migrator, args[0], args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func migrateCommand(migrator *db.Migrator, command string, args []string) error {
+	// * Commands take at most one integer argument; parse it up front so
+	// * usage errors never touch the schema
+	var n int
+	hasArg := len(args) > 0
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments\n%s", migrateUsage)
+	}
+	if hasArg {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			return fmt.Errorf("%q is not a number\n%s", args[0], migrateUsage)
+		}
+	}
+
+	switch command {
+	case "up":
+		if hasArg {
+			return fmt.Errorf("up takes no arguments\n%s", migrateUsage)
+		}
+		if err := migrator.Up(); err != nil {
+			return err
+		}
+
+	case "down":
+		if !hasArg {
+			n = 1
+		}
+		if err := migrator.Down(n); err != nil {
+			return err
+		}
+
+	case "goto":
+		if !hasArg || n < 0 {
+			return fmt.Errorf("goto needs a version\n%s", migrateUsage)
+		}
+		if err := migrator.Goto(uint(n)); err != nil {
+			return err
+		}
+
+	case "force":
+		if !hasArg {
+			return fmt.Errorf("force needs a version\n%s", migrateUsage)
+		}
+		if err := migrator.Force(n); err != nil {
+			return err
+		}
+
+	case "version":
+		if hasArg {
+			return fmt.Errorf("version takes no arguments\n%s", migrateUsage)
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", command, migrateUsage)
+	}
+
+	v, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	dirty := ""
+	if v.Dirty {
+		dirty = " (dirty)"
+	}
+	fmt.Printf("schema version %d%s, latest known %d\n", v.Version, dirty, v.Latest)
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/config"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/github"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/queue"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/worker"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * The worker binary consumes the sync jobs that API nodes publish to
+// * RabbitMQ, so syncing scales separately from serving the API
+func main() {
+	if os.Getenv("DEBUG") == "true" {
+		logger.SetLevel(logger.LevelDebug)
+	}
+
+	// * Load configuration
+	cfg, err := config.LoadConfiguration()
+	if err != nil {
+		logger.Error("Failed to load config: %v", err)
+		os.Exit(1)
+	}
+
+	queueConfig, err := cfg.QueueConfig()
+	if err != nil {
+		logger.Error("Invalid job queue configuration: %v", err)
+		os.Exit(1)
+	}
+
+	// * Initialize database (Postgres or SQLite, depending on DB_PATH)
+	database, err := db.Open(cfg.DBURL, cfg.DBReplicaURLs...)
+	if err != nil {
+		logger.Error("Failed to initialize database: %v", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	// * Refuse a dirty schema or one newer than this binary
+	if err := database.Migrate(); err != nil {
+		logger.Error("Failed to run migrations: %v", err)
+		os.Exit(1)
+	}
+
+	repoService := service.NewRepositoryService(github.NewClient(cfg.GitHubToken), database)
+
+	jobs := queue.New(queueConfig)
+	defer jobs.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		worker.NewQueueWorker(repoService, jobs).Run(ctx)
+	}()
+	logger.Info("Consuming sync jobs with up to %d at a time", queueConfig.Prefetch)
+
+	// * Wait for termination signal
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	// * Interrupted jobs are requeued for another worker
+	logger.Info("Shutting down...")
+	cancel()
+	<-done
+}
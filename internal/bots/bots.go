@@ -0,0 +1,102 @@
+package bots
+
+import (
+	"fmt"
+	"strings"
+)
+
+// * Account is what is known about a commit author when classifying it.
+// * Type is the GitHub account type, e.g. "User" or "Bot", when known.
+type Account struct {
+	Login string
+	Name  string
+	Email string
+	Type  string
+}
+
+// * Rules are the configured allow and deny lists. A pattern matches an
+// * account's login, name or email, ignoring case, and * matches any run of
+// * characters, so "release-bot" or "*@ci.example.com" work as written.
+type Rules struct {
+	// * Allow lists people whose accounts look like bots
+	Allow []string `json:"allow"`
+	// * Deny lists automation accounts that do not look like bots, such as
+	// * release bots committing as a plain user
+	Deny []string `json:"deny"`
+}
+
+// * Validate rejects blank patterns, which would otherwise match nothing
+func (r Rules) Validate() error {
+	for _, list := range []struct {
+		name     string
+		patterns []string
+	}{{"allow", r.Allow}, {"deny", r.Deny}} {
+		for i, pattern := range list.patterns {
+			if strings.TrimSpace(strings.ReplaceAll(pattern, "*", "")) == "" {
+				return fmt.Errorf("%s pattern %d matches every account or none", list.name, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// * IsBot classifies a: the allow list wins over the deny list, which wins
+// * over Detect
+func (r Rules) IsBot(a Account) bool {
+	if matchAny(r.Allow, a) {
+		return false
+	}
+	if matchAny(r.Deny, a) {
+		return true
+	}
+	return Detect(a)
+}
+
+// * Detect recognises bots by what GitHub tells us: the Bot account type, or
+// * the [bot] suffix GitHub Apps such as dependabot and renovate commit under
+func Detect(a Account) bool {
+	if strings.EqualFold(a.Type, "Bot") {
+		return true
+	}
+	email, _, _ := strings.Cut(a.Email, "@")
+	for _, s := range []string{a.Login, a.Name, email} {
+		if strings.HasSuffix(strings.ToLower(strings.TrimSpace(s)), "[bot]") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, a Account) bool {
+	for _, pattern := range patterns {
+		for _, s := range []string{a.Login, a.Name, a.Email} {
+			if s != "" && match(strings.ToLower(strings.TrimSpace(pattern)), strings.ToLower(s)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// * match reports whether s matches pattern, where * is the only wildcard
+func match(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, last)
+}
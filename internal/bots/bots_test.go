@@ -0,0 +1,56 @@
+package bots
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	for _, a := range []Account{
+		{Login: "dependabot[bot]"},
+		{Name: "renovate[bot]", Email: "29139614+renovate[bot]@users.noreply.github.com"},
+		{Name: "github-actions", Email: "41898282+github-actions[bot]@users.noreply.github.com"},
+		{Login: "some-app", Type: "Bot"},
+	} {
+		assert.True(t, Detect(a), "%+v", a)
+	}
+
+	for _, a := range []Account{
+		{Login: "alice", Name: "Alice", Email: "alice@example.com", Type: "User"},
+		{Name: "Bot Builder", Email: "robot@example.com"},
+	} {
+		assert.False(t, Detect(a), "%+v", a)
+	}
+}
+
+func TestRules_IsBot(t *testing.T) {
+	rules := Rules{
+		Allow: []string{"human[bot]"},
+		Deny:  []string{"release-bot", "*@ci.example.com", "deploy*runner"},
+	}
+
+	assert.True(t, rules.IsBot(Account{Login: "Release-Bot"}))
+	assert.True(t, rules.IsBot(Account{Name: "Jenkins", Email: "jenkins@CI.example.com"}))
+	assert.True(t, rules.IsBot(Account{Name: "deploy-eu-runner"}))
+	assert.True(t, rules.IsBot(Account{Login: "dependabot[bot]"}))
+
+	// * The allow list overrides both the deny list and detection
+	assert.False(t, rules.IsBot(Account{Login: "human[bot]", Type: "Bot"}))
+	assert.False(t, rules.IsBot(Account{Name: "deploy-runner-eu"}))
+	assert.False(t, rules.IsBot(Account{Email: "alice@example.com"}))
+}
+
+func TestRules_Validate(t *testing.T) {
+	assert.NoError(t, Rules{Deny: []string{"*bot"}}.Validate())
+	assert.Error(t, Rules{Deny: []string{" "}}.Validate())
+	assert.Error(t, Rules{Allow: []string{"**"}}.Validate())
+}
+
+func TestMatch(t *testing.T) {
+	assert.True(t, match("a*b*c", "abc"))
+	assert.True(t, match("a*b*c", "a-b-b-c"))
+	assert.False(t, match("a*a", "a"))
+	assert.False(t, match("*ab*b", "ab"))
+	assert.True(t, match("*", ""))
+}
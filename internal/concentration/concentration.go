@@ -0,0 +1,72 @@
+package concentration
+
+import (
+	"cmp"
+	"slices"
+)
+
+// * The functions here measure how concentrated commits are among authors.
+// * They take one commit count per author; authors without commits in the
+// * window are not known and so are never part of the measure.
+
+// * BusFactor is the smallest number of authors whose commits add up to at
+// * least threshold, a fraction in (0, 1], of all commits. It is 0 without
+// * commits.
+func BusFactor(counts []int, threshold float64) int {
+	total := sum(counts)
+	if total == 0 {
+		return 0
+	}
+
+	covered := 0
+	for i, n := range descending(counts) {
+		covered += n
+		if float64(covered) >= threshold*float64(total) {
+			return i + 1
+		}
+	}
+	return len(counts)
+}
+
+// * Gini is the Gini coefficient of counts: 0 when every author made as
+// * many commits, approaching 1 as one author makes all of them
+func Gini(counts []int) float64 {
+	total := sum(counts)
+	if total == 0 {
+		return 0
+	}
+
+	sorted := descending(counts)
+	slices.Reverse(sorted)
+
+	var weighted float64
+	for i, n := range sorted {
+		weighted += float64(i+1) * float64(n)
+	}
+	size := float64(len(sorted))
+	return 2*weighted/(size*float64(total)) - (size+1)/size
+}
+
+// * TopShare is the fraction of all commits made by the n busiest authors
+func TopShare(counts []int, n int) float64 {
+	total := sum(counts)
+	if total == 0 {
+		return 0
+	}
+	sorted := descending(counts)
+	return float64(sum(sorted[:min(n, len(sorted))])) / float64(total)
+}
+
+func descending(counts []int) []int {
+	sorted := slices.Clone(counts)
+	slices.SortFunc(sorted, func(a, b int) int { return cmp.Compare(b, a) })
+	return sorted
+}
+
+func sum(counts []int) int {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
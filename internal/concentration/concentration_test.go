@@ -0,0 +1,30 @@
+package concentration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusFactor(t *testing.T) {
+	assert.Equal(t, 0, BusFactor(nil, 0.5))
+	assert.Equal(t, 1, BusFactor([]int{60, 30, 10}, 0.5))
+	assert.Equal(t, 2, BusFactor([]int{10, 60, 30}, 0.8))
+	assert.Equal(t, 3, BusFactor([]int{10, 60, 30}, 1))
+	assert.Equal(t, 2, BusFactor([]int{25, 25, 25, 25}, 0.5))
+}
+
+func TestGini(t *testing.T) {
+	assert.Equal(t, 0.0, Gini(nil))
+	assert.InDelta(t, 0, Gini([]int{5, 5, 5, 5}), 1e-9)
+	assert.InDelta(t, 0, Gini([]int{42}), 1e-9)
+	// * One of four authors makes every commit
+	assert.InDelta(t, 0.75, Gini([]int{0, 0, 0, 12}), 1e-9)
+	assert.InDelta(t, 0.25, Gini([]int{1, 3}), 1e-9)
+}
+
+func TestTopShare(t *testing.T) {
+	assert.Equal(t, 0.0, TopShare(nil, 3))
+	assert.InDelta(t, 0.9, TopShare([]int{10, 60, 30}, 2), 1e-9)
+	assert.InDelta(t, 1, TopShare([]int{10, 60, 30}, 5), 1e-9)
+}
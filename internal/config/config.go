@@ -4,8 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/queue"
 	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
 	"github.com/joho/godotenv"
 )
@@ -13,8 +17,14 @@ import (
 type Config struct {
 	GitHubToken       string
 	DBURL             string
+	DBReplicaURLs     []string
 	SyncInterval      string
+	PruneInterval     string
 	DefaultRepository string
+	RabbitMQURL       string
+	SyncMaxAttempts   string
+	SyncRetryDelay    string
+	WorkerConcurrency string
 }
 
 // * LoadConfiguration reads the configuration from the .env file and returns a pointer to a Config
@@ -25,7 +35,13 @@ func LoadConfiguration() (*Config, error) {
 		GitHubToken:       os.Getenv("GITHUB_TOKEN"),
 		DBURL:             os.Getenv("DB_PATH"),
 		SyncInterval:      os.Getenv("SYNC_INTERVAL"),
+		PruneInterval:     os.Getenv("PRUNE_INTERVAL"),
 		DefaultRepository: os.Getenv("DEFAULT_REPOSITORY"),
+		DBReplicaURLs:     splitList(os.Getenv("DB_REPLICA_URLS")),
+		RabbitMQURL:       os.Getenv("RABBITMQ_URL"),
+		SyncMaxAttempts:   os.Getenv("SYNC_MAX_ATTEMPTS"),
+		SyncRetryDelay:    os.Getenv("SYNC_RETRY_DELAY"),
+		WorkerConcurrency: os.Getenv("WORKER_CONCURRENCY"),
 	}
 
 	if cfg.GitHubToken == "" {
@@ -41,6 +57,22 @@ func LoadConfiguration() (*Config, error) {
 		cfg.SyncInterval = "1h"
 	}
 
+	if cfg.PruneInterval == "" {
+		cfg.PruneInterval = "24h"
+	}
+
+	if cfg.SyncMaxAttempts == "" {
+		cfg.SyncMaxAttempts = "5"
+	}
+
+	if cfg.SyncRetryDelay == "" {
+		cfg.SyncRetryDelay = "1m"
+	}
+
+	if cfg.WorkerConcurrency == "" {
+		cfg.WorkerConcurrency = "4"
+	}
+
 	if cfg.DefaultRepository == "" {
 		logger.Warn("No default repository specified. Using 'chromium/chromium' as default")
 		cfg.DefaultRepository = "chromium/chromium"
@@ -50,6 +82,86 @@ func LoadConfiguration() (*Config, error) {
 	return cfg, nil
 }
 
+// * JobSettings control background jobs on both the RabbitMQ and the
+// * database job queue
+type JobSettings struct {
+	// * MaxAttempts is how many times a sync job may fail before it is given up
+	MaxAttempts int
+	// * RetryDelay is the wait before a failed job is retried; the database
+	// * queue doubles it with every further failure
+	RetryDelay time.Duration
+	// * Concurrency is how many jobs one process runs at once. Each sync holds
+	// * a lock connection throughout, next to the one kept by the leader, so
+	// * it stays below db.LockPoolSize.
+	Concurrency int
+}
+
+// * JobSettings parses the background job settings
+func (c *Config) JobSettings() (JobSettings, error) {
+	attempts, err := strconv.Atoi(c.SyncMaxAttempts)
+	if err != nil || attempts < 1 {
+		return JobSettings{}, fmt.Errorf("invalid SYNC_MAX_ATTEMPTS %q", c.SyncMaxAttempts)
+	}
+
+	delay, err := time.ParseDuration(c.SyncRetryDelay)
+	if err != nil || delay < 0 {
+		return JobSettings{}, fmt.Errorf("invalid SYNC_RETRY_DELAY %q", c.SyncRetryDelay)
+	}
+
+	concurrency, err := strconv.Atoi(c.WorkerConcurrency)
+	if err != nil || concurrency < 1 {
+		return JobSettings{}, fmt.Errorf("invalid WORKER_CONCURRENCY %q", c.WorkerConcurrency)
+	}
+	if concurrency >= db.LockPoolSize {
+		return JobSettings{}, fmt.Errorf("WORKER_CONCURRENCY %d leaves no lock connection for the leader; use at most %d", concurrency, db.LockPoolSize-1)
+	}
+
+	return JobSettings{MaxAttempts: attempts, RetryDelay: delay, Concurrency: concurrency}, nil
+}
+
+// * QueueConfig returns the RabbitMQ job queue settings, or an error when
+// * RABBITMQ_URL is unset or a setting does not parse
+func (c *Config) QueueConfig() (queue.Config, error) {
+	if c.RabbitMQURL == "" {
+		return queue.Config{}, errors.New("RABBITMQ_URL is required")
+	}
+
+	settings, err := c.JobSettings()
+	if err != nil {
+		return queue.Config{}, err
+	}
+
+	return queue.Config{
+		URL:         c.RabbitMQURL,
+		MaxAttempts: settings.MaxAttempts,
+		RetryDelay:  settings.RetryDelay,
+		Prefetch:    settings.Concurrency,
+	}, nil
+}
+
+// * LoadDatabaseURL reads only DB_PATH, for commands such as migrate that
+// * never talk to GitHub
+func LoadDatabaseURL() (string, error) {
+	_ = godotenv.Load(".env")
+
+	url := os.Getenv("DB_PATH")
+	if url == "" {
+		return "", errors.New("DB_PATH is required")
+	}
+	return url, nil
+}
+
+// * splitList parses a comma-separated environment value, dropping blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // * ParseRepository takes a string in the format owner/name and returns the
 // * owner and name as two separate strings. If the string does not match
 // * the expected format, an error is returned.
@@ -0,0 +1,44 @@
+package conventional
+
+import (
+	"regexp"
+	"strings"
+)
+
+// * Commit holds the Conventional Commits fields of a message header such
+// * as "feat(api)!: drop v1 routes"
+type Commit struct {
+	// * Type is lower-cased, so "Fix:" and "fix:" group together
+	Type     string `json:"type"`
+	Scope    string `json:"scope,omitempty"`
+	Breaking bool   `json:"breaking"`
+	Subject  string `json:"subject"`
+}
+
+// * header matches "type(scope)!: subject"; the scope and the ! are optional
+var header = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*)(?:\(([^()]*)\))?(!)?: +(\S.*)$`)
+
+// * Parse reads the Conventional Commits fields of message, reporting
+// * whether it follows the convention. A commit is breaking when its header
+// * carries a ! or a later line starts with a BREAKING CHANGE footer.
+func Parse(message string) (Commit, bool) {
+	first, rest, _ := strings.Cut(message, "\n")
+	m := header.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		return Commit{}, false
+	}
+
+	c := Commit{
+		Type:     strings.ToLower(m[1]),
+		Scope:    strings.TrimSpace(m[2]),
+		Breaking: m[3] == "!",
+		Subject:  strings.TrimSpace(m[4]),
+	}
+	for _, line := range strings.Split(rest, "\n") {
+		if strings.HasPrefix(line, "BREAKING CHANGE:") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			c.Breaking = true
+			break
+		}
+	}
+	return c, true
+}
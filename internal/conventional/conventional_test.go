@@ -0,0 +1,45 @@
+package conventional
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for message, want := range map[string]Commit{
+		"feat: add changelogs":                   {Type: "feat", Subject: "add changelogs"},
+		"Fix(api): handle empty pages\n\nbody":   {Type: "fix", Scope: "api", Subject: "handle empty pages"},
+		"refactor(db)!: drop the v1 schema":      {Type: "refactor", Scope: "db", Breaking: true, Subject: "drop the v1 schema"},
+		"chore!: bump Go":                        {Type: "chore", Breaking: true, Subject: "bump Go"},
+		"build(deps-dev): bump testify\r\n":      {Type: "build", Scope: "deps-dev", Subject: "bump testify"},
+		"feat: new API\n\nBREAKING CHANGE: gone": {Type: "feat", Breaking: true, Subject: "new API"},
+		"perf: faster\n\nBREAKING-CHANGE: yes":   {Type: "perf", Breaking: true, Subject: "faster"},
+	} {
+		got, ok := Parse(message)
+		assert.True(t, ok, message)
+		assert.Equal(t, want, got, message)
+	}
+}
+
+func TestParse_RejectsOtherMessages(t *testing.T) {
+	for _, message := range []string{
+		"Merge pull request #12 from owner/branch",
+		"Update README.md",
+		"feat:missing space",
+		"feat: ",
+		"feat(api: unclosed scope",
+		"feat(a)(b): two scopes",
+		"WIP feat: not at the start",
+		"",
+	} {
+		_, ok := Parse(message)
+		assert.False(t, ok, message)
+	}
+}
+
+func TestParse_BreakingFooterMustBeUpperCase(t *testing.T) {
+	c, ok := Parse("fix: typo\n\nbreaking change: not a footer")
+	assert.True(t, ok)
+	assert.False(t, c.Breaking)
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+func analyticsError(title, detail string, err error) error {
+	return errors.New("DB_ANALYTICS_ERROR", title, detail, err, errors.LevelError)
+}
+
+// * buildActivityQuery returns the query counting the commits selected by
+// * filter, grouped by bucketExpr and, when broken down, by author and
+// * repository. Its rows are shaped for queryActivity. bucketExpr may refer
+// * to the args it is given, which are numbered from $1. UTC buckets are
+// * whole UTC days, so they are summed from commit_daily_stats instead.
+func buildActivityQuery(filter models.ActivityFilter, bucketExpr string, bucketArgs []any, normalize func(time.Time) time.Time) (string, []any) {
+	if fromDailyStats(filter) {
+		return buildDailyStatsActivityQuery(filter)
+	}
+
+	where, args := commitScope(slices.Clone(bucketArgs), filter.Repositories, filter.Since, filter.Until, filter.ExcludeBots, normalize)
+
+	author, join := "0, ''", ""
+	if filter.ByAuthor {
+		author = "COALESCE(a.id, 0), COALESCE(a.name, c.author_name)"
+		join = " LEFT JOIN authors a ON a.id = c.author_id"
+	}
+	repository := "''"
+	if filter.ByRepository {
+		repository = "r.name"
+	}
+
+	return `
+		SELECT ` + bucketExpr + `, ` + author + `, ` + repository + `, COUNT(*)
+		FROM commits c
+		JOIN repositories r ON c.repository_id = r.id` + join + `
+		WHERE ` + where + `
+		GROUP BY 1, 2, 3, 4`, args
+}
+
+// * fromDailyStats reports whether the activity of filter can be read from
+// * commit_daily_stats: its buckets, and so its bounds, are whole UTC days.
+// * Other time zones split UTC days between buckets and scan commits.
+func fromDailyStats(filter models.ActivityFilter) bool {
+	return filter.Location == time.UTC && utcMidnight(filter.Since) && utcMidnight(filter.Until)
+}
+
+func utcMidnight(t time.Time) bool {
+	return t.IsZero() || t.Equal(t.UTC().Truncate(24*time.Hour))
+}
+
+// * buildDailyStatsActivityQuery is buildActivityQuery over the daily stats,
+// * one row per day for queryActivity to fold into buckets. Like top
+// * authors, commits not yet resolved count under their recorded name.
+func buildDailyStatsActivityQuery(filter models.ActivityFilter) (string, []any) {
+	where := "r.status <> 'deleted'"
+	var args []any
+	if !filter.Since.IsZero() {
+		args = append(args, filter.Since.UTC().Format(time.DateOnly))
+		where += fmt.Sprintf(" AND s.day >= $%d", len(args))
+	}
+	if !filter.Until.IsZero() {
+		args = append(args, filter.Until.UTC().Format(time.DateOnly))
+		where += fmt.Sprintf(" AND s.day < $%d", len(args))
+	}
+	if len(filter.Repositories) > 0 {
+		placeholders := make([]string, len(filter.Repositories))
+		for i, name := range filter.Repositories {
+			args = append(args, name)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		where += " AND r.name IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if filter.ExcludeBots {
+		where += " AND NOT EXISTS (SELECT 1 FROM authors b WHERE b.id = s.author_id AND b.is_bot)"
+	}
+
+	author, join := "0, ''", ""
+	if filter.ByAuthor {
+		author = "COALESCE(a.id, 0), COALESCE(a.name, s.author_name)"
+		join = " LEFT JOIN authors a ON a.id = s.author_id"
+	}
+	repository := "''"
+	if filter.ByRepository {
+		repository = "r.name"
+	}
+
+	return `
+		SELECT s.day, ` + author + `, ` + repository + `, SUM(s.commit_count)
+		FROM commit_daily_stats s
+		JOIN repositories r ON s.repository_id = r.id` + join + `
+		WHERE ` + where + `
+		GROUP BY 1, 2, 3, 4`, args
+}
+
+// * bucketTime scans the time of an activity row: a timestamp, or a
+// * YYYY-MM-DD day as SQLite stores commit_daily_stats.day
+type bucketTime struct {
+	time.Time
+}
+
+func (b *bucketTime) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		b.Time = v
+		return nil
+	case string:
+		return b.parse(v)
+	case []byte:
+		return b.parse(string(v))
+	}
+	return fmt.Errorf("cannot scan %T into an activity bucket", src)
+}
+
+func (b *bucketTime) parse(s string) error {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return err
+	}
+	b.Time = t
+	return nil
+}
+
+// * queryActivity runs the activity query and folds its rows into buckets,
+// * moving the time of each row to the start of its bucket with start
+func queryActivity(ctx context.Context, q queryer, query string, args []any, start func(time.Time) time.Time) ([]models.ActivityCount, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, analyticsError("Failed to query commit activity", "Could not count commits per interval", err)
+	}
+	defer rows.Close()
+
+	counter := newActivityCounter()
+	for rows.Next() {
+		var t bucketTime
+		var c models.ActivityCount
+		if err := rows.Scan(&t, &c.AuthorID, &c.AuthorName, &c.Repository, &c.Commits); err != nil {
+			return nil, analyticsError("Failed to scan commit activity", "Error while scanning commit activity row", err)
+		}
+		c.Start = start(t.Time)
+		counter.add(c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, analyticsError("Failed to process commit activity", "Error while processing commit activity rows", err)
+	}
+
+	return counter.counts(), nil
+}
+
+type activityKey struct {
+	start      int64
+	authorID   int
+	name       string
+	repository string
+}
+
+// * activityCounter sums commit counts per bucket, author and repository
+type activityCounter struct {
+	totals map[activityKey]*models.ActivityCount
+}
+
+func newActivityCounter() *activityCounter {
+	return &activityCounter{totals: make(map[activityKey]*models.ActivityCount)}
+}
+
+func (a *activityCounter) add(c models.ActivityCount) {
+	k := activityKey{start: c.Start.Unix(), authorID: c.AuthorID, name: c.AuthorName, repository: c.Repository}
+	if total, ok := a.totals[k]; ok {
+		total.Commits += c.Commits
+		return
+	}
+	a.totals[k] = &c
+}
+
+// * counts returns the sums oldest bucket first, then busiest author first
+func (a *activityCounter) counts() []models.ActivityCount {
+	results := make([]models.ActivityCount, 0, len(a.totals))
+	for _, c := range a.totals {
+		results = append(results, *c)
+	}
+
+	slices.SortFunc(results, func(a, b models.ActivityCount) int {
+		if c := a.Start.Compare(b.Start); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Commits, a.Commits); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.AuthorName, b.AuthorName); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Repository, b.Repository)
+	})
+	return results
+}
+
+// * wallClock reads the date and time of t as a wall clock time in loc,
+// * for timestamps without a time zone
+func wallClock(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * insertSQLAudit appends entry to audit_log and fills in its ID and time
+func insertSQLAudit(ctx context.Context, q queryer, entry *models.AuditEntry, normalize func(time.Time) time.Time) error {
+	entry.CreatedAt = normalize(time.Now())
+
+	// * Bound as text; lib/pq would send []byte as bytea
+	var params any
+	if len(entry.Parameters) > 0 {
+		params = string(entry.Parameters)
+	}
+
+	err := q.QueryRowContext(ctx, `
+		INSERT INTO audit_log (actor, action, repository_name, parameters, outcome, error_reference, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), $7)
+		RETURNING id
+	`, entry.Actor, entry.Action, entry.RepositoryName, params, string(entry.Outcome), entry.ErrorReference, entry.CreatedAt).
+		Scan(&entry.ID)
+	if err != nil {
+		return errors.New(
+			"DB_AUDIT_ERROR",
+			"Failed to record audit entry",
+			fmt.Sprintf("Could not record %s by %s", entry.Action, entry.Actor),
+			err,
+			errors.LevelError,
+		)
+	}
+	return nil
+}
+
+// * querySQLAuditLog backs GetAuditLog for both SQL implementations
+func querySQLAuditLog(ctx context.Context, q queryer, filter models.AuditFilter, normalize func(time.Time) time.Time) ([]models.AuditEntry, error) {
+	where := " WHERE 1 = 1"
+	var args []any
+
+	add := func(clause string, arg any) {
+		args = append(args, arg)
+		where += fmt.Sprintf(clause, len(args))
+	}
+	if filter.Actor != "" {
+		add(" AND actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		add(" AND action = $%d", filter.Action)
+	}
+	if filter.RepositoryName != "" {
+		add(" AND repository_name = $%d", filter.RepositoryName)
+	}
+	if filter.Outcome != "" {
+		add(" AND outcome = $%d", string(filter.Outcome))
+	}
+	if filter.Since != nil {
+		add(" AND created_at >= $%d", normalize(*filter.Since))
+	}
+	if filter.Until != nil {
+		add(" AND created_at <= $%d", normalize(*filter.Until))
+	}
+	if filter.BeforeID > 0 {
+		add(" AND id < $%d", filter.BeforeID)
+	}
+
+	query := `
+		SELECT id, actor, action, COALESCE(repository_name, ''), parameters, outcome,
+					COALESCE(error_reference, ''), created_at
+		FROM audit_log` + where + `
+		ORDER BY id DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New(
+			"DB_AUDIT_ERROR",
+			"Failed to query audit log",
+			"Could not fetch audit entries",
+			err,
+			errors.LevelError,
+		)
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var params []byte
+		err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.RepositoryName, &params, &e.Outcome, &e.ErrorReference, &e.CreatedAt)
+		if err != nil {
+			return nil, errors.New(
+				"DB_AUDIT_ERROR",
+				"Failed to scan audit entry",
+				"Error while scanning audit row",
+				err,
+				errors.LevelError,
+			)
+		}
+		if len(params) > 0 {
+			e.Parameters = json.RawMessage(params)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(
+			"DB_AUDIT_ERROR",
+			"Failed to process audit log",
+			"Error while processing audit rows",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,747 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/mailmap"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * authorStore is the storage the identity resolution below needs. The SQL
+// * engines share sqlAuthorStore; MemoryDB implements it over its state.
+type authorStore interface {
+	loadMailmap(ctx context.Context) (mailmap.Map, error)
+	replaceMailmap(ctx context.Context, m mailmap.Map) error
+	loadBotRules(ctx context.Context) (bots.Rules, error)
+	replaceBotRules(ctx context.Context, rules bots.Rules) error
+	// * findIdentity returns nil when no author has the identity yet
+	findIdentity(ctx context.Context, kind, value string) (*models.AuthorIdentity, error)
+	// * addIdentity attaches an identity to authorID. When another
+	// * transaction attached it first, the identity it stored is returned.
+	addIdentity(ctx context.Context, authorID int, kind, value string) (*models.AuthorIdentity, error)
+	authorIdentities(ctx context.Context, authorID int) ([]models.AuthorIdentity, error)
+	// * moveIdentities hands identities, and the commits resolved through
+	// * them, to another author
+	moveIdentities(ctx context.Context, identityIDs []int, authorID int) error
+	createAuthor(ctx context.Context, author *models.Author) error
+	// * updateAuthor leaves empty fields unchanged
+	updateAuthor(ctx context.Context, id int, name, email string) error
+	deleteAuthor(ctx context.Context, id int) error
+	getAuthor(ctx context.Context, id int) (*models.Author, error)
+	// * listAuthors returns every author, without identities
+	listAuthors(ctx context.Context) ([]models.Author, error)
+	setAuthorBot(ctx context.Context, id int, bot bool) error
+	reassignCommits(ctx context.Context, fromAuthorID, toAuthorID int) error
+	unresolvedCommits(ctx context.Context, limit int) ([]models.Commit, error)
+	setCommitAuthor(ctx context.Context, commitID, authorID, identityID int) error
+}
+
+// * identityKey is one way a commit author can be recognised
+type identityKey struct {
+	kind  string
+	value string
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// * commitIdentityKeys lists the identities of a commit, most trusted first.
+// * The name is only used when the commit has neither a GitHub user nor an
+// * email, since different people routinely share a name.
+func commitIdentityKeys(c *models.Commit) []identityKey {
+	var keys []identityKey
+	if c.AuthorGitHubID > 0 {
+		keys = append(keys, identityKey{models.IdentityGitHubID, strconv.FormatInt(c.AuthorGitHubID, 10)})
+	}
+	if email := normalizeEmail(c.AuthorEmail); email != "" {
+		keys = append(keys, identityKey{models.IdentityEmail, email})
+	}
+	if len(keys) == 0 {
+		name := cmp.Or(strings.TrimSpace(c.AuthorName), strings.TrimSpace(c.AuthorLogin), "unknown")
+		keys = append(keys, identityKey{models.IdentityName, strings.ToLower(name)})
+	}
+	return keys
+}
+
+// * authorResolver assigns commits to authors. It caches identities, so use
+// * one per transaction.
+type authorResolver struct {
+	store   authorStore
+	mailmap mailmap.Map
+	bots    bots.Rules
+	cache   map[identityKey]*models.AuthorIdentity
+}
+
+func newAuthorResolver(ctx context.Context, store authorStore) (*authorResolver, error) {
+	m, err := store.loadMailmap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := store.loadBotRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &authorResolver{store: store, mailmap: m, bots: rules, cache: make(map[identityKey]*models.AuthorIdentity)}, nil
+}
+
+// * authorAccount is what the bot rules see of an author
+func authorAccount(a *models.Author) bots.Account {
+	return bots.Account{Login: a.Login, Name: a.Name, Email: a.Email, Type: a.Type}
+}
+
+func (r *authorResolver) find(ctx context.Context, key identityKey) (*models.AuthorIdentity, error) {
+	if identity, ok := r.cache[key]; ok {
+		return identity, nil
+	}
+
+	identity, err := r.store.findIdentity(ctx, key.kind, key.value)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		r.cache[key] = identity
+	}
+	return identity, nil
+}
+
+// * resolve sets AuthorID and AuthorIdentityID on c. The first known identity
+// * decides the author, then the mailmap's canonical email; otherwise a new
+// * author is created. Unknown identities are attached to that author. Two
+// * existing authors are never merged here, only through MergeAuthors.
+func (r *authorResolver) resolve(ctx context.Context, c *models.Commit) error {
+	keys := commitIdentityKeys(c)
+	candidates := keys
+
+	properName, properEmail, mapped := r.mailmap.Lookup(c.AuthorName, c.AuthorEmail)
+	if mapped {
+		if email := normalizeEmail(properEmail); email != "" && email != normalizeEmail(c.AuthorEmail) {
+			candidates = append(slices.Clone(keys), identityKey{models.IdentityEmail, email})
+		}
+	}
+
+	found := make([]*models.AuthorIdentity, len(candidates))
+	authorID := 0
+	for i, key := range candidates {
+		identity, err := r.find(ctx, key)
+		if err != nil {
+			return err
+		}
+		found[i] = identity
+		if identity != nil && authorID == 0 {
+			authorID = identity.AuthorID
+		}
+	}
+
+	created := false
+	if authorID == 0 {
+		author := &models.Author{
+			Name:      cmp.Or(strings.TrimSpace(properName), c.AuthorLogin, properEmail, "unknown"),
+			Email:     properEmail,
+			Login:     c.AuthorLogin,
+			Type:      c.AuthorType,
+			CreatedAt: time.Now(),
+		}
+		author.Bot = r.bots.IsBot(authorAccount(author))
+		if err := r.store.createAuthor(ctx, author); err != nil {
+			return err
+		}
+		authorID = author.ID
+		created = true
+	}
+
+	for i, key := range candidates {
+		if found[i] != nil {
+			continue
+		}
+		identity, err := r.store.addIdentity(ctx, authorID, key.kind, key.value)
+		if err != nil {
+			return err
+		}
+		// * A concurrent sync met the same person first. The author made
+		// * above is then a duplicate and folds into theirs; an author found
+		// * through another identity is kept, as when both were known.
+		if identity.AuthorID != authorID && created {
+			if err := absorbAuthor(ctx, r.store, identity.AuthorID, authorID); err != nil {
+				return err
+			}
+			for _, f := range found[:i] {
+				if f != nil && f.AuthorID == authorID {
+					f.AuthorID = identity.AuthorID
+				}
+			}
+			authorID = identity.AuthorID
+			created = false
+		}
+		r.cache[key] = identity
+		found[i] = identity
+	}
+
+	identityID := found[0].ID
+	c.AuthorID = &authorID
+	c.AuthorIdentityID = &identityID
+	return nil
+}
+
+// * resolveCommits resolves every commit in commits in place
+func resolveCommits(ctx context.Context, store authorStore, commits []models.Commit) error {
+	r, err := newAuthorResolver(ctx, store)
+	if err != nil {
+		return err
+	}
+	for i := range commits {
+		if err := r.resolve(ctx, &commits[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * resolveCommitAuthors backfills up to batchSize commits stored without an
+// * author and reports how many it resolved
+func resolveCommitAuthors(ctx context.Context, store authorStore, batchSize int) (int, error) {
+	commits, err := store.unresolvedCommits(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	if err := resolveCommits(ctx, store, commits); err != nil {
+		return 0, err
+	}
+
+	for _, c := range commits {
+		if err := store.setCommitAuthor(ctx, c.ID, *c.AuthorID, *c.AuthorIdentityID); err != nil {
+			return 0, err
+		}
+	}
+	return len(commits), nil
+}
+
+func invalidAuthorChange(title, detail string) error {
+	return errors.New("INVALID_AUTHOR_CHANGE", title, detail, nil, errors.LevelError)
+}
+
+// * absorbAuthor moves everything source owns to target and deletes source
+func absorbAuthor(ctx context.Context, store authorStore, targetID, sourceID int) error {
+	identities, err := store.authorIdentities(ctx, sourceID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, len(identities))
+	for i, identity := range identities {
+		ids[i] = identity.ID
+	}
+	if err := store.moveIdentities(ctx, ids, targetID); err != nil {
+		return err
+	}
+	if err := store.reassignCommits(ctx, sourceID, targetID); err != nil {
+		return err
+	}
+	return store.deleteAuthor(ctx, sourceID)
+}
+
+// * mergeAuthors folds every source author into target
+func mergeAuthors(ctx context.Context, store authorStore, targetID int, sourceIDs []int) (*models.Author, error) {
+	if len(sourceIDs) == 0 {
+		return nil, invalidAuthorChange("Nothing to merge", "At least one source author is required")
+	}
+	if _, err := store.getAuthor(ctx, targetID); err != nil {
+		return nil, err
+	}
+
+	for _, sourceID := range slices.Compact(slices.Sorted(slices.Values(sourceIDs))) {
+		if sourceID == targetID {
+			return nil, invalidAuthorChange("Cannot merge an author into itself", fmt.Sprintf("Author %d is both the target and a source", targetID))
+		}
+		if _, err := store.getAuthor(ctx, sourceID); err != nil {
+			return nil, err
+		}
+		if err := absorbAuthor(ctx, store, targetID, sourceID); err != nil {
+			return nil, err
+		}
+	}
+
+	return store.getAuthor(ctx, targetID)
+}
+
+// * splitAuthor moves identityIDs off authorID onto a new author. Commits
+// * follow the identity they were resolved through, and later commits with
+// * those identities resolve to the new author.
+func splitAuthor(ctx context.Context, store authorStore, authorID int, identityIDs []int, name string) (*models.Author, error) {
+	author, err := store.getAuthor(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(identityIDs) == 0 {
+		return nil, invalidAuthorChange("Nothing to split", "At least one identity is required")
+	}
+	identityIDs = slices.Compact(slices.Sorted(slices.Values(identityIDs)))
+	if len(identityIDs) >= len(author.Identities) {
+		return nil, invalidAuthorChange("Cannot split off every identity", fmt.Sprintf("Author %d must keep at least one identity", authorID))
+	}
+
+	var first *models.AuthorIdentity
+	for _, id := range identityIDs {
+		i := slices.IndexFunc(author.Identities, func(identity models.AuthorIdentity) bool { return identity.ID == id })
+		if i < 0 {
+			return nil, invalidAuthorChange("Unknown identity", fmt.Sprintf("Identity %d does not belong to author %d", id, authorID))
+		}
+		if first == nil {
+			first = &author.Identities[i]
+		}
+	}
+
+	split := &models.Author{Name: cmp.Or(strings.TrimSpace(name), first.Value), CreatedAt: time.Now()}
+	if first.Kind == models.IdentityEmail {
+		split.Email = first.Value
+	}
+	if err := store.createAuthor(ctx, split); err != nil {
+		return nil, err
+	}
+	if err := store.moveIdentities(ctx, identityIDs, split.ID); err != nil {
+		return nil, err
+	}
+
+	return store.getAuthor(ctx, split.ID)
+}
+
+// * applyMailmap stores m and applies it to authors already resolved: the
+// * author owning a commit email is merged into the author owning its proper
+// * email, and takes the proper name. Entries that also name the commit
+// * author only affect commits resolved from now on, since identities are
+// * keyed by email and moving one would take every name at that address.
+func applyMailmap(ctx context.Context, store authorStore, m mailmap.Map) error {
+	if err := store.replaceMailmap(ctx, m); err != nil {
+		return err
+	}
+
+	for _, e := range m {
+		if e.CommitName != "" {
+			continue
+		}
+
+		source, err := store.findIdentity(ctx, models.IdentityEmail, normalizeEmail(e.CommitEmail))
+		if err != nil {
+			return err
+		}
+		if source == nil {
+			continue
+		}
+
+		targetID := source.AuthorID
+		if proper := normalizeEmail(e.ProperEmail); proper != "" && proper != normalizeEmail(e.CommitEmail) {
+			target, err := store.findIdentity(ctx, models.IdentityEmail, proper)
+			if err != nil {
+				return err
+			}
+			if target == nil {
+				if _, err := store.addIdentity(ctx, source.AuthorID, models.IdentityEmail, proper); err != nil {
+					return err
+				}
+			} else if target.AuthorID != source.AuthorID {
+				targetID = target.AuthorID
+				if err := absorbAuthor(ctx, store, targetID, source.AuthorID); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := store.updateAuthor(ctx, targetID, e.ProperName, e.ProperEmail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * applyBotRules stores rules and reclassifies every author under them
+func applyBotRules(ctx context.Context, store authorStore, rules bots.Rules) error {
+	if err := store.replaceBotRules(ctx, rules); err != nil {
+		return err
+	}
+
+	authors, err := store.listAuthors(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range authors {
+		bot := rules.IsBot(authorAccount(&authors[i]))
+		if bot == authors[i].Bot {
+			continue
+		}
+		if err := store.setAuthorBot(ctx, authors[i].ID, bot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func authorNotFound(id int) error {
+	return errors.New(
+		"DB_AUTHOR_NOT_FOUND",
+		"Author not found",
+		fmt.Sprintf("Author %d does not exist", id),
+		sql.ErrNoRows,
+		errors.LevelInfo,
+	)
+}
+
+func authorError(detail string, err error) error {
+	return errors.New("DB_AUTHOR_ERROR", "Failed to update authors", detail, err, errors.LevelError)
+}
+
+// * sqlAuthorStore backs both SQL engines; normalize is applied to bound
+// * timestamps as elsewhere
+type sqlAuthorStore struct {
+	q         execQueryer
+	normalize func(time.Time) time.Time
+}
+
+func (s sqlAuthorStore) loadMailmap(ctx context.Context) (mailmap.Map, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT proper_name, proper_email, commit_name, commit_email
+		FROM mailmap_entries
+		ORDER BY id`)
+	if err != nil {
+		return nil, authorError("Could not load the mailmap", err)
+	}
+	defer rows.Close()
+
+	var m mailmap.Map
+	for rows.Next() {
+		var e mailmap.Entry
+		if err := rows.Scan(&e.ProperName, &e.ProperEmail, &e.CommitName, &e.CommitEmail); err != nil {
+			return nil, authorError("Could not scan mailmap entry", err)
+		}
+		m = append(m, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, authorError("Could not read the mailmap", err)
+	}
+	return m, nil
+}
+
+func (s sqlAuthorStore) replaceMailmap(ctx context.Context, m mailmap.Map) error {
+	if _, err := s.q.ExecContext(ctx, `DELETE FROM mailmap_entries`); err != nil {
+		return authorError("Could not clear the mailmap", err)
+	}
+	for _, e := range m {
+		_, err := s.q.ExecContext(ctx, `
+			INSERT INTO mailmap_entries (proper_name, proper_email, commit_name, commit_email)
+			VALUES ($1, $2, $3, $4)
+		`, e.ProperName, e.ProperEmail, e.CommitName, e.CommitEmail)
+		if err != nil {
+			return authorError(fmt.Sprintf("Could not store mailmap entry for '%s'", e.CommitEmail), err)
+		}
+	}
+	return nil
+}
+
+func (s sqlAuthorStore) loadBotRules(ctx context.Context) (bots.Rules, error) {
+	rows, err := s.q.QueryContext(ctx, `SELECT pattern, bot FROM bot_rules ORDER BY id`)
+	if err != nil {
+		return bots.Rules{}, authorError("Could not load the bot rules", err)
+	}
+	defer rows.Close()
+
+	var rules bots.Rules
+	for rows.Next() {
+		var pattern string
+		var bot bool
+		if err := rows.Scan(&pattern, &bot); err != nil {
+			return bots.Rules{}, authorError("Could not scan bot rule", err)
+		}
+		if bot {
+			rules.Deny = append(rules.Deny, pattern)
+		} else {
+			rules.Allow = append(rules.Allow, pattern)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return bots.Rules{}, authorError("Could not read the bot rules", err)
+	}
+	return rules, nil
+}
+
+func (s sqlAuthorStore) replaceBotRules(ctx context.Context, rules bots.Rules) error {
+	if _, err := s.q.ExecContext(ctx, `DELETE FROM bot_rules`); err != nil {
+		return authorError("Could not clear the bot rules", err)
+	}
+	for _, list := range []struct {
+		patterns []string
+		bot      bool
+	}{{rules.Allow, false}, {rules.Deny, true}} {
+		for _, pattern := range list.patterns {
+			_, err := s.q.ExecContext(ctx, `INSERT INTO bot_rules (pattern, bot) VALUES ($1, $2)`, pattern, list.bot)
+			if err != nil {
+				return authorError(fmt.Sprintf("Could not store bot rule '%s'", pattern), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s sqlAuthorStore) findIdentity(ctx context.Context, kind, value string) (*models.AuthorIdentity, error) {
+	identity := models.AuthorIdentity{Kind: kind, Value: value}
+	err := s.q.QueryRowContext(ctx, `
+		SELECT id, author_id FROM author_identities WHERE kind = $1 AND value = $2
+	`, kind, value).Scan(&identity.ID, &identity.AuthorID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, authorError(fmt.Sprintf("Could not look up %s identity '%s'", kind, value), err)
+	}
+	return &identity, nil
+}
+
+func (s sqlAuthorStore) addIdentity(ctx context.Context, authorID int, kind, value string) (*models.AuthorIdentity, error) {
+	identity := models.AuthorIdentity{AuthorID: authorID, Kind: kind, Value: value}
+	err := s.q.QueryRowContext(ctx, `
+		INSERT INTO author_identities (author_id, kind, value) VALUES ($1, $2, $3)
+		ON CONFLICT (kind, value) DO NOTHING
+		RETURNING id
+	`, authorID, kind, value).Scan(&identity.ID)
+	if err == sql.ErrNoRows {
+		// * Postgres waits for the transaction holding the row, so it is
+		// * committed and visible by now
+		existing, err := s.findIdentity(ctx, kind, value)
+		if err == nil && existing == nil {
+			err = fmt.Errorf("identity vanished after a conflicting insert")
+		}
+		if err != nil {
+			return nil, authorError(fmt.Sprintf("Could not add %s identity '%s' to author %d", kind, value, authorID), err)
+		}
+		return existing, nil
+	}
+	if err != nil {
+		return nil, authorError(fmt.Sprintf("Could not add %s identity '%s' to author %d", kind, value, authorID), err)
+	}
+	return &identity, nil
+}
+
+func (s sqlAuthorStore) authorIdentities(ctx context.Context, authorID int) ([]models.AuthorIdentity, error) {
+	return queryAuthorIdentities(ctx, s.q, `
+		SELECT id, author_id, kind, value FROM author_identities WHERE author_id = $1 ORDER BY id
+	`, authorID)
+}
+
+func (s sqlAuthorStore) moveIdentities(ctx context.Context, identityIDs []int, authorID int) error {
+	for _, id := range identityIDs {
+		if _, err := s.q.ExecContext(ctx, `UPDATE author_identities SET author_id = $1 WHERE id = $2`, authorID, id); err != nil {
+			return authorError(fmt.Sprintf("Could not move identity %d to author %d", id, authorID), err)
+		}
+		if _, err := s.q.ExecContext(ctx, `UPDATE commits SET author_id = $1 WHERE author_identity_id = $2`, authorID, id); err != nil {
+			return authorError(fmt.Sprintf("Could not move commits of identity %d to author %d", id, authorID), err)
+		}
+	}
+	return nil
+}
+
+func (s sqlAuthorStore) createAuthor(ctx context.Context, author *models.Author) error {
+	err := s.q.QueryRowContext(ctx, `
+		INSERT INTO authors (name, email, login, account_type, is_bot, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		RETURNING id
+	`, author.Name, author.Email, author.Login, author.Type, author.Bot, s.normalize(author.CreatedAt)).Scan(&author.ID)
+	if err != nil {
+		return authorError(fmt.Sprintf("Could not create author '%s'", author.Name), err)
+	}
+	return nil
+}
+
+func (s sqlAuthorStore) updateAuthor(ctx context.Context, id int, name, email string) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE authors
+		SET name = COALESCE(NULLIF($2, ''), name), email = COALESCE(NULLIF($3, ''), email)
+		WHERE id = $1
+	`, id, name, email)
+	if err != nil {
+		return authorError(fmt.Sprintf("Could not update author %d", id), err)
+	}
+	return nil
+}
+
+func (s sqlAuthorStore) deleteAuthor(ctx context.Context, id int) error {
+	if _, err := s.q.ExecContext(ctx, `DELETE FROM authors WHERE id = $1`, id); err != nil {
+		return authorError(fmt.Sprintf("Could not delete author %d", id), err)
+	}
+	return nil
+}
+
+func (s sqlAuthorStore) getAuthor(ctx context.Context, id int) (*models.Author, error) {
+	return getSQLAuthor(ctx, s.q, id)
+}
+
+func (s sqlAuthorStore) listAuthors(ctx context.Context) ([]models.Author, error) {
+	return querySQLAuthors(ctx, s.q, selectAuthors+` ORDER BY id`)
+}
+
+func (s sqlAuthorStore) setAuthorBot(ctx context.Context, id int, bot bool) error {
+	if _, err := s.q.ExecContext(ctx, `UPDATE authors SET is_bot = $1 WHERE id = $2`, bot, id); err != nil {
+		return authorError(fmt.Sprintf("Could not classify author %d", id), err)
+	}
+	return nil
+}
+
+func (s sqlAuthorStore) reassignCommits(ctx context.Context, fromAuthorID, toAuthorID int) error {
+	if _, err := s.q.ExecContext(ctx, `UPDATE commits SET author_id = $1 WHERE author_id = $2`, toAuthorID, fromAuthorID); err != nil {
+		return authorError(fmt.Sprintf("Could not move commits of author %d to author %d", fromAuthorID, toAuthorID), err)
+	}
+	return nil
+}
+
+func (s sqlAuthorStore) unresolvedCommits(ctx context.Context, limit int) ([]models.Commit, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT id, author_name, author_email, COALESCE(author_login, ''), COALESCE(author_github_id, 0)
+		FROM commits
+		WHERE author_id IS NULL
+		ORDER BY id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, authorError("Could not list unresolved commits", err)
+	}
+	defer rows.Close()
+
+	var commits []models.Commit
+	for rows.Next() {
+		var c models.Commit
+		if err := rows.Scan(&c.ID, &c.AuthorName, &c.AuthorEmail, &c.AuthorLogin, &c.AuthorGitHubID); err != nil {
+			return nil, authorError("Could not scan unresolved commit", err)
+		}
+		commits = append(commits, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, authorError("Could not read unresolved commits", err)
+	}
+	return commits, nil
+}
+
+func (s sqlAuthorStore) setCommitAuthor(ctx context.Context, commitID, authorID, identityID int) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE commits SET author_id = $1, author_identity_id = $2 WHERE id = $3
+	`, authorID, identityID, commitID)
+	if err != nil {
+		return authorError(fmt.Sprintf("Could not set the author of commit %d", commitID), err)
+	}
+	return nil
+}
+
+func queryAuthorIdentities(ctx context.Context, q queryer, query string, args ...any) ([]models.AuthorIdentity, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New("DB_AUTHOR_ERROR", "Failed to fetch author identities", "Could not query author identities", err, errors.LevelError)
+	}
+	defer rows.Close()
+
+	var identities []models.AuthorIdentity
+	for rows.Next() {
+		var identity models.AuthorIdentity
+		if err := rows.Scan(&identity.ID, &identity.AuthorID, &identity.Kind, &identity.Value); err != nil {
+			return nil, errors.New("DB_AUTHOR_ERROR", "Failed to scan author identity", "Error while scanning author identity row", err, errors.LevelError)
+		}
+		identities = append(identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("DB_AUTHOR_ERROR", "Failed to process author identities", "Error while processing author identity rows", err, errors.LevelError)
+	}
+	return identities, nil
+}
+
+const selectAuthors = `
+	SELECT id, name, COALESCE(email, ''), COALESCE(login, ''), COALESCE(account_type, ''), is_bot, created_at
+	FROM authors`
+
+func getSQLAuthor(ctx context.Context, q queryer, id int) (*models.Author, error) {
+	var a models.Author
+	err := q.QueryRowContext(ctx, selectAuthors+` WHERE id = $1`, id).
+		Scan(&a.ID, &a.Name, &a.Email, &a.Login, &a.Type, &a.Bot, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, authorNotFound(id)
+	}
+	if err != nil {
+		return nil, errors.New(
+			"DB_AUTHOR_ERROR",
+			"Failed to fetch author",
+			fmt.Sprintf("Could not fetch author %d", id),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	a.Identities, err = queryAuthorIdentities(ctx, q, `
+		SELECT id, author_id, kind, value FROM author_identities WHERE author_id = $1 ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// * getSQLAuthors lists authors by ID with their identities, limit <= 0
+// * meaning all of them
+func getSQLAuthors(ctx context.Context, q queryer, limit int) ([]models.Author, error) {
+	query := selectAuthors + ` ORDER BY id`
+	var args []any
+	if limit > 0 {
+		query += ` LIMIT $1`
+		args = append(args, limit)
+	}
+
+	authors, err := querySQLAuthors(ctx, q, query, args...)
+	if err != nil || len(authors) == 0 {
+		return authors, err
+	}
+
+	index := make(map[int]int, len(authors))
+	for i, a := range authors {
+		index[a.ID] = i
+	}
+
+	identities, err := queryAuthorIdentities(ctx, q, `
+		SELECT id, author_id, kind, value FROM author_identities
+		WHERE author_id BETWEEN $1 AND $2
+		ORDER BY id
+	`, authors[0].ID, authors[len(authors)-1].ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		if i, ok := index[identity.AuthorID]; ok {
+			authors[i].Identities = append(authors[i].Identities, identity)
+		}
+	}
+	return authors, nil
+}
+
+// * querySQLAuthors runs a selectAuthors query, leaving identities out
+func querySQLAuthors(ctx context.Context, q queryer, query string, args ...any) ([]models.Author, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New("DB_AUTHOR_ERROR", "Failed to fetch authors", "Could not query authors", err, errors.LevelError)
+	}
+	defer rows.Close()
+
+	var authors []models.Author
+	for rows.Next() {
+		var a models.Author
+		if err := rows.Scan(&a.ID, &a.Name, &a.Email, &a.Login, &a.Type, &a.Bot, &a.CreatedAt); err != nil {
+			return nil, errors.New("DB_AUTHOR_ERROR", "Failed to scan author", "Error while scanning author row", err, errors.LevelError)
+		}
+		authors = append(authors, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("DB_AUTHOR_ERROR", "Failed to process authors", "Error while processing author rows", err, errors.LevelError)
+	}
+	return authors, nil
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * commitBatchSize keeps multi-row inserts well below the bind parameter
+// * limits of both Postgres (65535) and SQLite (32766)
+const commitBatchSize = 1000
+
+// * commitColumns is the number of bind parameters per inserted commit row
+const commitColumns = 16
+
+// * buildInsertCommitsQuery returns a multi-row INSERT for batch that skips
+// * commits already stored for the repository. Author dates are bound through
+// * normalize, which may drop their zone, so the UTC offset they were
+// * reported with is stored alongside.
+func buildInsertCommitsQuery(batch []models.Commit, normalize func(time.Time) time.Time) (string, []any) {
+	var b strings.Builder
+	b.WriteString(`
+		INSERT INTO commits (
+			sha, repository_id, message, author_name, author_email, author_date, commit_url,
+			author_login, author_github_id, author_id, author_identity_id, author_utc_offset,
+			conventional_type, conventional_scope, conventional_breaking, conventional_subject
+		) VALUES `)
+
+	args := make([]any, 0, len(batch)*commitColumns)
+	for i, c := range batch {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for col := range commitColumns {
+			if col > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "$%d", i*commitColumns+col+1)
+		}
+		b.WriteString(")")
+		_, offset := c.AuthorDate.Zone()
+		args = append(args,
+			c.SHA, c.RepositoryID, c.Message, c.AuthorName, c.AuthorEmail, normalize(c.AuthorDate), c.CommitURL,
+			c.AuthorLogin, c.AuthorGitHubID, c.AuthorID, c.AuthorIdentityID, offset,
+		)
+		args = append(args, conventionalArgs(c.Message)...)
+	}
+	// * No conflict target: on partitioned Postgres the unique key also
+	// * covers author_date, which a SHA fixes anyway
+	b.WriteString(" ON CONFLICT DO NOTHING")
+
+	return b.String(), args
+}
+
+// * insertSQLCommits resolves the author of every commit and inserts them in
+// * batches. It backs InsertCommit and the Tx variants of both SQL engines;
+// * q should be a transaction so authors and commits land together.
+func insertSQLCommits(ctx context.Context, q execQueryer, commits []models.Commit, normalize func(time.Time) time.Time) (models.InsertResult, error) {
+	var result models.InsertResult
+
+	commits = slices.Clone(commits)
+	if err := resolveCommits(ctx, sqlAuthorStore{q: q, normalize: normalize}, commits); err != nil {
+		return result, err
+	}
+
+	for start := 0; start < len(commits); start += commitBatchSize {
+		batch := commits[start:min(start+commitBatchSize, len(commits))]
+		query, args := buildInsertCommitsQuery(batch, normalize)
+
+		res, err := q.ExecContext(ctx, query, args...)
+		if err != nil {
+			return result, errors.New(
+				"DB_COMMIT_ERROR",
+				"Failed to insert commits",
+				fmt.Sprintf("Could not insert batch of %d commits", len(batch)),
+				err,
+				errors.LevelError,
+			)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return result, errors.New(
+				"DB_COMMIT_ERROR",
+				"Failed to count inserted commits",
+				"Could not read affected rows for commit batch",
+				err,
+				errors.LevelError,
+			)
+		}
+
+		result.Inserted += int(affected)
+		result.Skipped += len(batch) - int(affected)
+	}
+
+	return result, nil
+}
+
+// * insertSQLCommit inserts a single commit and copies its resolved author back
+func insertSQLCommit(ctx context.Context, q execQueryer, commit *models.Commit, normalize func(time.Time) time.Time) error {
+	commits := []models.Commit{*commit}
+	if err := resolveCommits(ctx, sqlAuthorStore{q: q, normalize: normalize}, commits); err != nil {
+		return err
+	}
+	commit.AuthorID, commit.AuthorIdentityID = commits[0].AuthorID, commits[0].AuthorIdentityID
+
+	query, args := buildInsertCommitsQuery(commits, normalize)
+	if _, err := q.ExecContext(ctx, query, args...); err != nil {
+		return errors.New(
+			"DB_COMMIT_ERROR",
+			"Failed to insert commit",
+			fmt.Sprintf("Could not insert commit '%s' for repository '%d'", commit.SHA, commit.RepositoryID),
+			err,
+			errors.LevelError,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/conventional"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * conventionalArgs binds the Conventional Commits columns of an inserted
+// * commit. conventional_breaking is never NULL for parsed commits, which
+// * tells them apart from those stored before parsing.
+func conventionalArgs(message string) []any {
+	c, ok := conventional.Parse(message)
+	if !ok {
+		return []any{nil, nil, false, nil}
+	}
+	return []any{c.Type, c.Scope, c.Breaking, c.Subject}
+}
+
+// * newChangelogCommit parses the message of c
+func newChangelogCommit(c models.Commit) models.ChangelogCommit {
+	entry := models.ChangelogCommit{
+		ID:         c.ID,
+		SHA:        c.SHA,
+		AuthorName: c.AuthorName,
+		AuthorDate: c.AuthorDate,
+		CommitURL:  c.CommitURL,
+		Subject:    firstLine(c.Message),
+	}
+	if parsed, ok := conventional.Parse(c.Message); ok {
+		entry.Conventional = &parsed
+	}
+	return entry
+}
+
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return strings.TrimSpace(line)
+}
+
+// * buildChangelogQuery returns the query for the commits of repoName
+// * selected by filter, newest first
+func buildChangelogQuery(repoName string, filter models.ChangelogFilter, normalize func(time.Time) time.Time) (string, []any) {
+	where := "r.name = $1"
+	args := []any{repoName}
+
+	if !filter.Since.IsZero() {
+		args = append(args, normalize(filter.Since))
+		where += fmt.Sprintf(" AND c.author_date >= $%d", len(args))
+	}
+	if !filter.Until.IsZero() {
+		args = append(args, normalize(filter.Until))
+		where += fmt.Sprintf(" AND c.author_date < $%d", len(args))
+	}
+	if after := filter.After; after != nil {
+		args = append(args, normalize(after.AuthorDate), after.ID)
+		where += fmt.Sprintf(" AND (c.author_date, c.id) > ($%d, $%d)", len(args)-1, len(args))
+	}
+	if through := filter.Through; through != nil {
+		args = append(args, normalize(through.AuthorDate), through.ID)
+		where += fmt.Sprintf(" AND (c.author_date, c.id) <= ($%d, $%d)", len(args)-1, len(args))
+	}
+
+	return `
+		SELECT c.id, c.sha, c.message, c.author_name, c.author_date, c.commit_url,
+					c.conventional_type, c.conventional_scope, c.conventional_breaking, c.conventional_subject
+		FROM commits c
+		JOIN repositories r ON c.repository_id = r.id
+		WHERE ` + where + `
+		ORDER BY c.author_date DESC, c.id DESC`, args
+}
+
+// * queryChangelogCommits runs GetChangelogCommits for the SQL-backed
+// * implementations. Commits stored before they were parsed are parsed here.
+func queryChangelogCommits(ctx context.Context, q queryer, repoName string, filter models.ChangelogFilter, normalize func(time.Time) time.Time) ([]models.ChangelogCommit, error) {
+	query, args := buildChangelogQuery(repoName, filter, normalize)
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New(
+			"DB_COMMIT_ERROR",
+			"Failed to query changelog commits",
+			fmt.Sprintf("Could not fetch the changelog commits of repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+	defer rows.Close()
+
+	var commits []models.ChangelogCommit
+	for rows.Next() {
+		var c models.Commit
+		var kind, scope, subject sql.NullString
+		var breaking sql.NullBool
+		if err := rows.Scan(&c.ID, &c.SHA, &c.Message, &c.AuthorName, &c.AuthorDate, &c.CommitURL,
+			&kind, &scope, &breaking, &subject); err != nil {
+			return nil, errors.New("DB_COMMIT_ERROR", "Failed to scan changelog commit", "Error while scanning changelog commit row", err, errors.LevelError)
+		}
+
+		entry := newChangelogCommit(c)
+		if breaking.Valid {
+			entry.Conventional = nil
+			if kind.Valid {
+				entry.Conventional = &conventional.Commit{
+					Type:     kind.String,
+					Scope:    scope.String,
+					Breaking: breaking.Bool,
+					Subject:  subject.String,
+				}
+			}
+		}
+		commits = append(commits, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("DB_COMMIT_ERROR", "Failed to process changelog commits", "Error while processing changelog commit rows", err, errors.LevelError)
+	}
+	return commits, nil
+}
+
+// * queryCommitBySHA finds the commit of repoName whose SHA starts with
+// * prefix, failing when none or several do
+func queryCommitBySHA(ctx context.Context, q queryer, repoName, prefix string) (*models.Commit, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT c.id, c.sha, c.repository_id, c.message, c.author_name, c.author_email,
+					c.author_date, c.commit_url, COALESCE(c.author_login, ''), c.author_id
+		FROM commits c
+		JOIN repositories r ON c.repository_id = r.id
+		WHERE r.name = $1 AND c.sha LIKE $2
+		ORDER BY c.sha
+		LIMIT 2`, repoName, strings.ToLower(prefix)+"%")
+	if err != nil {
+		return nil, errors.New(
+			"DB_COMMIT_ERROR",
+			"Failed to find commit",
+			fmt.Sprintf("Could not look up commit '%s' of repository '%s'", prefix, repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+	defer rows.Close()
+
+	var found []models.Commit
+	for rows.Next() {
+		var c models.Commit
+		if err := rows.Scan(&c.ID, &c.SHA, &c.RepositoryID, &c.Message, &c.AuthorName,
+			&c.AuthorEmail, &c.AuthorDate, &c.CommitURL, &c.AuthorLogin, &c.AuthorID); err != nil {
+			return nil, errors.New("DB_COMMIT_ERROR", "Failed to scan commit", "Error while scanning commit row", err, errors.LevelError)
+		}
+		found = append(found, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("DB_COMMIT_ERROR", "Failed to process commits", "Error while processing commit rows", err, errors.LevelError)
+	}
+	return oneCommit(repoName, prefix, found)
+}
+
+// * oneCommit returns the only commit of found, which matched prefix
+func oneCommit(repoName, prefix string, found []models.Commit) (*models.Commit, error) {
+	switch len(found) {
+	case 0:
+		return nil, errors.New(
+			"COMMIT_NOT_FOUND",
+			"Commit not found",
+			fmt.Sprintf("No commit of repository '%s' starting with '%s' is stored", repoName, prefix),
+			nil,
+			errors.LevelInfo,
+		)
+	case 1:
+		return &found[0], nil
+	}
+	return nil, errors.New(
+		"AMBIGUOUS_COMMIT",
+		"Ambiguous commit",
+		fmt.Sprintf("Several commits of repository '%s' start with '%s'; give more of the SHA", repoName, prefix),
+		nil,
+		errors.LevelError,
+	)
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * Store is a models.Database that owns a connection and its schema
+type Store interface {
+	models.Database
+	Migrate() error
+	Migrator() (*Migrator, error)
+	Close() error
+}
+
+// * Open picks the database implementation from the URL scheme.
+// * sqlite://<path> opens a SQLite file (sqlite://:memory: for a throwaway
+// * database), memory:// keeps everything in process memory, and anything
+// * else is handed to the Postgres driver. replicaURLs are Postgres read
+// * replicas and are ignored by the other implementations.
+func Open(url string, replicaURLs ...string) (Store, error) {
+	if strings.HasPrefix(url, "memory://") {
+		warnReplicasIgnored(replicaURLs)
+		return NewMemoryDB(), nil
+	}
+
+	for _, scheme := range []string{"sqlite://", "sqlite3://"} {
+		if path, ok := strings.CutPrefix(url, scheme); ok {
+			warnReplicasIgnored(replicaURLs)
+			sqliteDB, err := NewSQLiteDB(path)
+			if err != nil {
+				return nil, err
+			}
+			return sqliteDB, nil
+		}
+	}
+
+	postgresDB, err := NewPostgresDB(url, replicaURLs...)
+	if err != nil {
+		return nil, err
+	}
+	return postgresDB, nil
+}
+
+func warnReplicasIgnored(replicaURLs []string) {
+	if len(replicaURLs) > 0 {
+		logger.Warn("read replicas are only supported with Postgres; ignoring %d replica URL(s)", len(replicaURLs))
+	}
+}
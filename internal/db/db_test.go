@@ -0,0 +1,900 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/conventional"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/mailmap"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listCommits(t *testing.T, d models.Database, repoName string, filter models.CommitFilter) []models.Commit {
+	t.Helper()
+
+	page, err := d.GetCommits(context.Background(), repoName, filter)
+	require.NoError(t, err)
+	return page.Commits
+}
+
+// * assertKeysetPagination walks every page of a repository holding commits
+// * that share author dates, which is where keyset pagination goes wrong
+func assertKeysetPagination(t *testing.T, d models.Database, repoID int) {
+	t.Helper()
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 7 {
+		require.NoError(t, d.InsertCommit(ctx, &models.Commit{
+			SHA:          fmt.Sprintf("sha%d", i),
+			RepositoryID: repoID,
+			Message:      "m",
+			AuthorDate:   base.Add(time.Duration(i/2) * time.Hour),
+			CommitURL:    "url",
+		}))
+	}
+
+	var seen []string
+	filter := models.CommitFilter{Limit: 3, WithTotal: true}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5, "pagination did not terminate")
+
+		page, err := d.GetCommits(ctx, "test/repo", filter)
+		require.NoError(t, err)
+		require.NotNil(t, page.Total)
+		assert.Equal(t, 7, *page.Total)
+
+		for _, c := range page.Commits {
+			seen = append(seen, c.SHA)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+
+		// * Round-trip through the opaque token like an API client would
+		cursor, err := models.DecodeCommitCursor(page.NextCursor.Encode())
+		require.NoError(t, err)
+		filter.After = cursor
+	}
+
+	assert.Equal(t, []string{"sha6", "sha5", "sha4", "sha3", "sha2", "sha1", "sha0"}, seen)
+
+	// * Page numbers still work for older clients
+	commits := listCommits(t, d, "test/repo", models.CommitFilter{Limit: 3, Offset: 6})
+	require.Len(t, commits, 1)
+	assert.Equal(t, "sha0", commits[0].SHA)
+}
+
+// * assertRetention seeds one commit per day, several sharing a date, and
+// * checks preview and batched pruning agree on what a policy expires
+func assertRetention(t *testing.T, d models.Database, repoID int) {
+	t.Helper()
+	ctx := context.Background()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 10 {
+		require.NoError(t, d.InsertCommit(ctx, &models.Commit{
+			SHA:          fmt.Sprintf("sha%d", i),
+			RepositoryID: repoID,
+			Message:      "m",
+			AuthorDate:   now.AddDate(0, 0, -(i/2)-1),
+			CommitURL:    "url",
+		}))
+	}
+
+	_, err := d.GetRetentionPolicy(ctx, "test/repo")
+	assert.Error(t, err)
+	_, err = d.SetRetentionPolicy(ctx, "missing/repo", models.RetentionPolicy{})
+	assert.Error(t, err)
+
+	// * The count limit splits a pair of commits sharing a date
+	maxAge, maxCommits := 4, 3
+	saved, err := d.SetRetentionPolicy(ctx, "test/repo", models.RetentionPolicy{MaxCommits: &maxCommits})
+	require.NoError(t, err)
+	assert.Equal(t, repoID, saved.RepositoryID)
+	assert.Nil(t, saved.MaxAgeDays)
+
+	preview, err := d.PreviewPrune(ctx, "test/repo", *saved, now)
+	require.NoError(t, err)
+	assert.Equal(t, 7, preview.Commits)
+	assert.True(t, now.AddDate(0, 0, -5).Equal(*preview.OldestDate))
+	assert.True(t, now.AddDate(0, 0, -2).Equal(*preview.NewestDate))
+
+	// * Either limit expires a commit
+	_, err = d.SetRetentionPolicy(ctx, "test/repo", models.RetentionPolicy{MaxAgeDays: &maxAge, MaxCommits: &maxCommits})
+	require.NoError(t, err)
+	policies, err := d.GetRetentionPolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+	assert.Equal(t, "test/repo", policies[0].RepositoryName)
+	assert.Equal(t, maxAge, *policies[0].MaxAgeDays)
+
+	preview, err = d.PreviewPrune(ctx, "test/repo", policies[0], now)
+	require.NoError(t, err)
+	assert.Equal(t, 7, preview.Commits)
+	require.NotNil(t, preview.Cutoff)
+
+	total := 0
+	for {
+		deleted, err := d.PruneCommits(ctx, "test/repo", policies[0], now, 3)
+		require.NoError(t, err)
+		total += deleted
+		if deleted < 3 {
+			break
+		}
+	}
+	assert.Equal(t, 7, total)
+
+	commits := listCommits(t, d, "test/repo", models.CommitFilter{})
+	var shas []string
+	for _, c := range commits {
+		shas = append(shas, c.SHA)
+	}
+	assert.Equal(t, []string{"sha1", "sha0", "sha3"}, shas)
+
+	preview, err = d.PreviewPrune(ctx, "test/repo", policies[0], now)
+	require.NoError(t, err)
+	assert.Zero(t, preview.Commits)
+	assert.Nil(t, preview.OldestDate)
+}
+
+// * topAuthors returns commit counts keyed by author name
+func topAuthors(t *testing.T, d models.Database) map[string]models.AuthorCommitCount {
+	t.Helper()
+
+	authors, err := d.GetTopAuthors(context.Background(), "test/repo", 100, true)
+	require.NoError(t, err)
+	byName := make(map[string]models.AuthorCommitCount)
+	for _, a := range authors {
+		byName[a.AuthorName] = a
+	}
+	return byName
+}
+
+// * assertBots classifies authors as they are created, then reclassifies
+// * them under allow and deny lists, checking what each view leaves out
+func assertBots(t *testing.T, d models.Database, repoID int) {
+	t.Helper()
+	ctx := context.Background()
+
+	insert := func(sha string, githubID int64, login, accountType, name, email string) {
+		t.Helper()
+		require.NoError(t, d.InsertCommit(ctx, &models.Commit{
+			SHA:            sha,
+			RepositoryID:   repoID,
+			Message:        "fix the build",
+			AuthorName:     name,
+			AuthorEmail:    email,
+			AuthorLogin:    login,
+			AuthorGitHubID: githubID,
+			AuthorType:     accountType,
+			AuthorDate:     time.Now(),
+			CommitURL:      "url",
+		}))
+	}
+
+	insert("h1", 1, "alice", "User", "Alice", "alice@example.com")
+	insert("d1", 2, "dependabot[bot]", "Bot", "dependabot[bot]", "49699333+dependabot[bot]@users.noreply.github.com")
+	insert("d2", 2, "dependabot[bot]", "Bot", "dependabot[bot]", "49699333+dependabot[bot]@users.noreply.github.com")
+	insert("a1", 3, "some-app", "Bot", "Some App", "app@example.com")
+	insert("r1", 0, "", "", "Release Bot", "release@ci.example.com")
+
+	names := func(includeBots bool) []string {
+		t.Helper()
+		authors, err := d.GetTopAuthors(ctx, "test/repo", 10, includeBots)
+		require.NoError(t, err)
+		var names []string
+		for _, a := range authors {
+			names = append(names, a.AuthorName)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"Alice", "Release Bot"}, names(false))
+	assert.Equal(t, []string{"dependabot[bot]", "Alice", "Release Bot", "Some App"}, names(true))
+
+	commits := listCommits(t, d, "test/repo", models.CommitFilter{ExcludeBots: true})
+	assert.Len(t, commits, 2)
+	assert.Len(t, listCommits(t, d, "test/repo", models.CommitFilter{}), 5)
+
+	q, err := search.Parse("build")
+	require.NoError(t, err)
+	results, err := d.SearchCommits(ctx, models.CommitSearch{Query: q, Limit: 10, ExcludeBots: true})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	// * The deny list catches the release bot; the allow list overrides the
+	// * account type of the app
+	rules := bots.Rules{Allow: []string{"some-app"}, Deny: []string{"*@ci.example.com"}}
+	require.NoError(t, d.SetBotRules(ctx, rules))
+	stored, err := d.GetBotRules(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, rules, stored)
+
+	assert.Equal(t, []string{"Alice", "Some App"}, names(false))
+
+	authors, err := d.GetAuthors(ctx, 0)
+	require.NoError(t, err)
+	bot := make(map[string]bool)
+	for _, a := range authors {
+		bot[a.Name] = a.Bot
+	}
+	assert.Equal(t, map[string]bool{"Alice": false, "dependabot[bot]": true, "Some App": false, "Release Bot": true}, bot)
+
+	// * Authors created later are classified under the stored rules
+	insert("r2", 0, "", "", "Deploy", "deploy@ci.example.com")
+	assert.Equal(t, []string{"Alice", "Some App"}, names(false))
+}
+
+// * assertActivity buckets commits of two repositories by UTC day and by
+// * day in New York, where the commits around midnight UTC fall a day early
+func assertActivity(t *testing.T, d models.Database, repoID, otherRepoID int) {
+	t.Helper()
+	ctx := context.Background()
+
+	insert := func(sha string, repoID int, githubID int64, name, accountType, date string) {
+		t.Helper()
+		authorDate, err := time.Parse(time.RFC3339, date)
+		require.NoError(t, err)
+		require.NoError(t, d.InsertCommit(ctx, &models.Commit{
+			SHA:            sha,
+			RepositoryID:   repoID,
+			Message:        "m",
+			AuthorName:     name,
+			AuthorEmail:    strings.ToLower(name) + "@example.com",
+			AuthorGitHubID: githubID,
+			AuthorType:     accountType,
+			AuthorDate:     authorDate,
+			CommitURL:      "url",
+		}))
+	}
+
+	insert("a1", repoID, 1, "Alice", "User", "2024-03-10T23:30:00Z")
+	insert("b1", repoID, 2, "Bob", "User", "2024-03-11T01:00:00Z")
+	insert("d1", repoID, 3, "dependabot[bot]", "Bot", "2024-03-11T05:00:00Z")
+	insert("a2", repoID, 1, "Alice", "User", "2024-03-11T10:00:00Z")
+	insert("c1", otherRepoID, 4, "Carol", "User", "2024-03-11T12:00:00Z")
+	insert("a3", repoID, 1, "Alice", "User", "2024-03-12T00:00:00Z")
+
+	activity := func(filter models.ActivityFilter) []string {
+		t.Helper()
+		if filter.Location == nil {
+			filter.Location = time.UTC
+		}
+		filter.Interval = models.ActivityDay
+		filter.Since = time.Date(2024, 3, 10, 0, 0, 0, 0, filter.Location)
+		filter.Until = time.Date(2024, 3, 12, 0, 0, 0, 0, filter.Location)
+
+		counts, err := d.GetCommitActivity(ctx, filter)
+		require.NoError(t, err)
+		var got []string
+		for _, c := range counts {
+			got = append(got, fmt.Sprintf("%s %s %d", c.Start.Format(time.RFC3339), c.AuthorName, c.Commits))
+		}
+		return got
+	}
+
+	assert.Equal(t, []string{
+		"2024-03-10T00:00:00Z  1",
+		"2024-03-11T00:00:00Z  3",
+	}, activity(models.ActivityFilter{Repositories: []string{"test/repo"}}))
+
+	assert.Equal(t, []string{
+		"2024-03-10T00:00:00Z  1",
+		"2024-03-11T00:00:00Z  3",
+	}, activity(models.ActivityFilter{ExcludeBots: true}))
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"2024-03-10T00:00:00-05:00 Alice 1",
+		"2024-03-10T00:00:00-05:00 Bob 1",
+		"2024-03-11T00:00:00-04:00 Alice 2",
+		"2024-03-11T00:00:00-04:00 dependabot[bot] 1",
+	}, activity(models.ActivityFilter{Repositories: []string{"test/repo"}, Location: newYork, ByAuthor: true}))
+
+	// * Zero bounds leave the range open
+	counts, err := d.GetCommitActivity(ctx, models.ActivityFilter{
+		Interval:     models.ActivityMonth,
+		Location:     time.UTC,
+		ByRepository: true,
+	})
+	require.NoError(t, err)
+	repos := make(map[string]int)
+	for _, c := range counts {
+		repos[c.Repository] += c.Commits
+	}
+	assert.Equal(t, map[string]int{"test/repo": 5, "test/other": 1}, repos)
+}
+
+// * assertPunchCard places commits reported with different UTC offsets on
+// * the card in UTC, in New York and in their authors' own offsets
+func assertPunchCard(t *testing.T, d models.Database, repoID int) {
+	t.Helper()
+	ctx := context.Background()
+
+	insert := func(sha string, githubID int64, name, date string) *models.Commit {
+		t.Helper()
+		authorDate, err := time.Parse(time.RFC3339, date)
+		require.NoError(t, err)
+		commit := &models.Commit{
+			SHA:            sha,
+			RepositoryID:   repoID,
+			Message:        "m",
+			AuthorName:     name,
+			AuthorGitHubID: githubID,
+			AuthorDate:     authorDate,
+			CommitURL:      "url",
+		}
+		require.NoError(t, d.InsertCommit(ctx, commit))
+		return commit
+	}
+
+	alice := insert("a1", 1, "Alice", "2024-03-11T22:30:00+02:00")
+	insert("b1", 2, "Bob", "2024-03-16T09:15:00-07:00")
+	insert("a2", 1, "Alice", "2024-03-11T01:00:00Z")
+
+	card := func(filter models.PunchCardFilter) map[string]int {
+		t.Helper()
+		if filter.Location == nil {
+			filter.Location = time.UTC
+		}
+		counts, err := d.GetPunchCard(ctx, filter)
+		require.NoError(t, err)
+		got := make(map[string]int)
+		for day, hours := range counts {
+			for hour, n := range hours {
+				if n > 0 {
+					got[fmt.Sprintf("%s %02d", models.PunchCardDays[day][:3], hour)] = n
+				}
+			}
+		}
+		return got
+	}
+
+	assert.Equal(t, map[string]int{"Mon 20": 1, "Sat 16": 1, "Mon 01": 1}, card(models.PunchCardFilter{}))
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"Mon 16": 1, "Sat 12": 1, "Sun 21": 1}, card(models.PunchCardFilter{Location: newYork}))
+
+	assert.Equal(t, map[string]int{"Mon 22": 1, "Sat 09": 1, "Mon 01": 1}, card(models.PunchCardFilter{AuthorOffset: true}))
+	assert.Equal(t, map[string]int{"Mon 22": 1, "Mon 01": 1}, card(models.PunchCardFilter{AuthorOffset: true, AuthorID: *alice.AuthorID}))
+	assert.Equal(t, map[string]int{"Mon 20": 1}, card(models.PunchCardFilter{
+		Repositories: []string{"test/repo"},
+		Since:        time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC),
+		Until:        time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC),
+	}))
+}
+
+// * assertAuthors resolves identities by GitHub ID and email, then applies a
+// * mailmap, a split and a merge and checks the top authors follow each step
+func assertAuthors(t *testing.T, d models.Database, repoID int) {
+	t.Helper()
+	ctx := context.Background()
+
+	insert := func(sha string, githubID int64, name, email string) {
+		t.Helper()
+		require.NoError(t, d.InsertCommit(ctx, &models.Commit{
+			SHA:            sha,
+			RepositoryID:   repoID,
+			Message:        "m",
+			AuthorName:     name,
+			AuthorEmail:    email,
+			AuthorGitHubID: githubID,
+			AuthorDate:     time.Now(),
+			CommitURL:      "url",
+		}))
+	}
+
+	// * The GitHub ID links both of Alice's emails; email matching ignores case
+	insert("a1", 100, "Alice", "alice@work.example")
+	insert("a2", 100, "Alice", "alice@home.example")
+	insert("a3", 0, "A. Smith", "ALICE@home.example")
+	insert("b1", 0, "Bob", "bob@example.com")
+	insert("b2", 0, "Bob", "bob@laptop.local")
+
+	top := topAuthors(t, d)
+	require.Len(t, top, 2)
+	alice := top["Alice"]
+	assert.Equal(t, 3, alice.CommitCount)
+	assert.Equal(t, 1, top["Bob"].CommitCount)
+
+	author, err := d.GetAuthor(ctx, alice.AuthorID)
+	require.NoError(t, err)
+	require.Len(t, author.Identities, 3)
+	assert.Equal(t, models.AuthorIdentity{ID: author.Identities[0].ID, AuthorID: alice.AuthorID, Kind: models.IdentityGitHubID, Value: "100"}, author.Identities[0])
+
+	// * The mailmap merges Bob's laptop identity into his main one, including
+	// * commits synced afterwards
+	m, err := mailmap.Parse("Robert <bob@example.com> <bob@laptop.local>")
+	require.NoError(t, err)
+	require.NoError(t, d.SetMailmap(ctx, m))
+	insert("b3", 0, "Bob", "bob@laptop.local")
+
+	stored, err := d.GetMailmap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, m, stored)
+
+	top = topAuthors(t, d)
+	require.Len(t, top, 2)
+	assert.Equal(t, 3, top["Robert"].CommitCount)
+
+	// * Splitting off the home email takes the commit resolved through it;
+	// * the one resolved through the GitHub ID stays
+	var home models.AuthorIdentity
+	for _, identity := range author.Identities {
+		if identity.Value == "alice@home.example" {
+			home = identity
+		}
+	}
+	_, err = d.SplitAuthor(ctx, alice.AuthorID, []int{author.Identities[0].ID, author.Identities[1].ID, home.ID}, "")
+	assert.Error(t, err)
+	_, err = d.SplitAuthor(ctx, alice.AuthorID, []int{-1}, "")
+	assert.Error(t, err)
+
+	split, err := d.SplitAuthor(ctx, alice.AuthorID, []int{home.ID}, "A. Smith")
+	require.NoError(t, err)
+	assert.Equal(t, "A. Smith", split.Name)
+	require.Len(t, split.Identities, 1)
+
+	top = topAuthors(t, d)
+	assert.Equal(t, 2, top["Alice"].CommitCount)
+	assert.Equal(t, 1, top["A. Smith"].CommitCount)
+
+	// * Merging folds the split author back in and deletes it
+	_, err = d.MergeAuthors(ctx, alice.AuthorID, []int{alice.AuthorID})
+	assert.Error(t, err)
+
+	merged, err := d.MergeAuthors(ctx, alice.AuthorID, []int{split.ID})
+	require.NoError(t, err)
+	assert.Len(t, merged.Identities, 3)
+
+	_, err = d.GetAuthor(ctx, split.ID)
+	assert.Error(t, err)
+	assert.Equal(t, 3, topAuthors(t, d)["Alice"].CommitCount)
+
+	authors, err := d.GetAuthors(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, authors, 2)
+}
+
+// * assertRepositoryLifecycle archives, soft-deletes, restores and purges a
+// * repository holding one matching commit
+func assertRepositoryLifecycle(t *testing.T, d models.Database, repoID int) {
+	t.Helper()
+	ctx := context.Background()
+
+	require.NoError(t, d.InsertCommit(ctx, &models.Commit{
+		SHA: "abc", RepositoryID: repoID, Message: "fix parser", AuthorDate: time.Now(), CommitURL: "url",
+	}))
+	q, err := search.Parse("parser")
+	require.NoError(t, err)
+	searchAll := func() []models.CommitSearchResult {
+		results, err := d.SearchCommits(ctx, models.CommitSearch{Query: q, Limit: 10})
+		require.NoError(t, err)
+		return results
+	}
+
+	repo, err := d.SetRepositoryStatus(ctx, "test/repo", models.RepositoryArchived)
+	require.NoError(t, err)
+	assert.Equal(t, models.RepositoryArchived, repo.Status)
+	require.NotNil(t, repo.StatusChangedAt)
+
+	// * Syncs stop at the upsert; the commits stay readable
+	err = d.UpsertRepository(ctx, &models.Repository{Name: "test/repo", URL: "url"})
+	assert.ErrorContains(t, err, "DB_REPOSITORY_INACTIVE")
+	assert.Len(t, listCommits(t, d, "test/repo", models.CommitFilter{}), 1)
+	assert.Len(t, searchAll(), 1)
+
+	repos, err := d.GetAllRepositories(ctx)
+	require.NoError(t, err)
+	require.Len(t, repos, 1)
+	assert.Equal(t, models.RepositoryArchived, repos[0].Status)
+
+	// * Soft-deleted repositories leave listings and search
+	_, err = d.SetRepositoryStatus(ctx, "test/repo", models.RepositoryDeleted)
+	require.NoError(t, err)
+	repos, err = d.GetAllRepositories(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, repos)
+	assert.Empty(t, searchAll())
+
+	repo, err = d.SetRepositoryStatus(ctx, "test/repo", models.RepositoryActive)
+	require.NoError(t, err)
+	assert.Equal(t, models.RepositoryActive, repo.Status)
+	require.NoError(t, d.UpsertRepository(ctx, &models.Repository{Name: "test/repo", URL: "url"}))
+	assert.Len(t, searchAll(), 1)
+
+	_, err = d.SetRepositoryStatus(ctx, "missing/repo", models.RepositoryArchived)
+	assert.ErrorContains(t, err, "DB_REPOSITORY_NOT_FOUND")
+
+	// * Purging takes the commits with it
+	require.NoError(t, d.DeleteRepository(ctx, "test/repo"))
+	_, err = d.GetRepository(ctx, "test/repo")
+	assert.Error(t, err)
+	assert.Empty(t, searchAll())
+	assert.ErrorContains(t, d.DeleteRepository(ctx, "test/repo"), "DB_REPOSITORY_NOT_FOUND")
+}
+
+// * assertAuditLog records entries in and out of transactions and pages
+// * through them with filters
+func assertAuditLog(t *testing.T, d models.Database) {
+	t.Helper()
+	ctx := context.Background()
+
+	require.NoError(t, d.RecordAudit(ctx, &models.AuditEntry{
+		Actor: "alice", Action: models.AuditRepositoryReset, RepositoryName: "test/repo",
+		Parameters: []byte(`{"since":"2024-01-01T00:00:00Z"}`), Outcome: models.AuditSuccess,
+	}))
+	require.NoError(t, d.RecordAudit(ctx, &models.AuditEntry{
+		Actor: "bob", Action: models.AuditRepositoryPurge, RepositoryName: "test/repo",
+		Outcome: models.AuditFailure, ErrorReference: "DB_REPOSITORY_NOT_FOUND",
+	}))
+
+	// * An entry written in a rolled back transaction is gone with it
+	err := d.WithTransaction(ctx, func(tx *sql.Tx) error {
+		require.NoError(t, d.RecordAuditTx(ctx, tx, &models.AuditEntry{
+			Actor: "carol", Action: models.AuditMailmapSet, Outcome: models.AuditSuccess,
+		}))
+		return fmt.Errorf("boom")
+	})
+	require.Error(t, err)
+
+	entries, err := d.GetAuditLog(ctx, models.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "bob", entries[0].Actor)
+	assert.Equal(t, "DB_REPOSITORY_NOT_FOUND", entries[0].ErrorReference)
+	assert.Nil(t, entries[0].Parameters)
+	assert.JSONEq(t, `{"since":"2024-01-01T00:00:00Z"}`, string(entries[1].Parameters))
+	assert.False(t, entries[1].CreatedAt.IsZero())
+
+	entries, err = d.GetAuditLog(ctx, models.AuditFilter{RepositoryName: "test/repo", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "bob", entries[0].Actor)
+
+	entries, err = d.GetAuditLog(ctx, models.AuditFilter{BeforeID: entries[0].ID, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "alice", entries[0].Actor)
+
+	hourAgo := time.Now().Add(-time.Hour)
+	entries, err = d.GetAuditLog(ctx, models.AuditFilter{Outcome: models.AuditSuccess, Action: models.AuditRepositoryReset, Until: &hourAgo})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// * assertSyncRuns starts and finishes runs and reads them back per
+// * repository and as a failures view
+func assertSyncRuns(t *testing.T, d models.Database) {
+	t.Helper()
+	ctx := context.Background()
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ok := &models.SyncRun{RepositoryName: "test/repo", Trigger: models.SyncTriggerWorker, StartedAt: time.Now()}
+	require.NoError(t, d.StartSyncRun(ctx, ok))
+	require.NotZero(t, ok.ID)
+
+	failed := &models.SyncRun{RepositoryName: "other/repo", Trigger: models.SyncTriggerAPI, Since: &since, StartedAt: time.Now()}
+	require.NoError(t, d.StartSyncRun(ctx, failed))
+
+	// * A run that was never finished stays visible as running
+	running := &models.SyncRun{RepositoryName: "test/repo", Trigger: models.SyncTriggerWebhook, StartedAt: time.Now()}
+	require.NoError(t, d.StartSyncRun(ctx, running))
+
+	finishedAt := time.Now()
+	ok.FinishedAt = &finishedAt
+	ok.Status = models.SyncSuccess
+	ok.PagesFetched, ok.APICalls, ok.CommitsSeen, ok.CommitsInserted = 2, 3, 150, 120
+	require.NoError(t, d.FinishSyncRun(ctx, ok))
+
+	failed.FinishedAt = &finishedAt
+	failed.Status = models.SyncFailure
+	failed.ErrorReference = "GITHUB_API_ERROR"
+	failed.ErrorMessage = "rate limited"
+	require.NoError(t, d.FinishSyncRun(ctx, failed))
+
+	runs, err := d.GetSyncRuns(ctx, models.SyncRunFilter{RepositoryName: "test/repo"})
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, models.SyncRunning, runs[0].Status)
+	assert.Nil(t, runs[0].FinishedAt)
+	assert.Equal(t, models.SyncTriggerWebhook, runs[0].Trigger)
+	assert.Equal(t, models.SyncSuccess, runs[1].Status)
+	assert.Equal(t, models.SyncTriggerWorker, runs[1].Trigger)
+	assert.Nil(t, runs[1].Since)
+	assert.NotNil(t, runs[1].FinishedAt)
+	assert.Equal(t, []int{2, 3, 150, 120},
+		[]int{runs[1].PagesFetched, runs[1].APICalls, runs[1].CommitsSeen, runs[1].CommitsInserted})
+
+	runs, err = d.GetSyncRuns(ctx, models.SyncRunFilter{Status: models.SyncFailure})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "other/repo", runs[0].RepositoryName)
+	assert.Equal(t, "GITHUB_API_ERROR", runs[0].ErrorReference)
+	assert.Equal(t, "rate limited", runs[0].ErrorMessage)
+	require.NotNil(t, runs[0].Since)
+	assert.True(t, since.Equal(*runs[0].Since))
+
+	runs, err = d.GetSyncRuns(ctx, models.SyncRunFilter{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, running.ID, runs[0].ID)
+
+	runs, err = d.GetSyncRuns(ctx, models.SyncRunFilter{BeforeID: running.ID, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, failed.ID, runs[0].ID)
+}
+
+// * assertResetJobs creates a reset job and moves it through its phases
+func assertResetJobs(t *testing.T, d models.Database) {
+	t.Helper()
+	ctx := context.Background()
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	job := &models.ResetJob{RepositoryName: "test/repo", Since: &since, Actor: "alice", Status: models.ResetQueued}
+	require.NoError(t, d.CreateResetJob(ctx, job))
+	require.NotZero(t, job.ID)
+	assert.False(t, job.CreatedAt.IsZero())
+
+	got, err := d.GetResetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ResetQueued, got.Status)
+	require.NotNil(t, got.Since)
+	assert.True(t, since.Equal(*got.Since))
+	assert.Nil(t, got.StartedAt)
+
+	run := &models.SyncRun{RepositoryName: "test/repo", Trigger: models.SyncTriggerAPI, StartedAt: time.Now()}
+	require.NoError(t, d.StartSyncRun(ctx, run))
+
+	now := time.Now()
+	job.StartedAt, job.FinishedAt = &now, &now
+	job.Status = models.ResetFailed
+	job.CommitsDeleted, job.CommitsInserted = 12, 0
+	job.SyncRunID = &run.ID
+	job.ErrorReference, job.ErrorMessage = "GITHUB_API_ERROR", "rate limited"
+	require.NoError(t, d.UpdateResetJob(ctx, job))
+
+	got, err = d.GetResetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ResetFailed, got.Status)
+	assert.True(t, got.Status.Done())
+	assert.Equal(t, 12, got.CommitsDeleted)
+	assert.Equal(t, "GITHUB_API_ERROR", got.ErrorReference)
+	assert.Equal(t, "rate limited", got.ErrorMessage)
+	require.NotNil(t, got.SyncRunID)
+	assert.Equal(t, run.ID, *got.SyncRunID)
+	assert.NotNil(t, got.StartedAt)
+	assert.NotNil(t, got.FinishedAt)
+
+	_, err = d.GetResetJob(ctx, job.ID+1)
+	assert.ErrorContains(t, err, "DB_RESET_JOB_NOT_FOUND")
+	assert.ErrorContains(t, d.UpdateResetJob(ctx, &models.ResetJob{ID: job.ID + 1, Status: models.ResetSyncing}), "DB_RESET_JOB_NOT_FOUND")
+}
+
+func TestOpenSelectsImplementationByScheme(t *testing.T) {
+	store, err := Open("memory://")
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryDB{}, store)
+
+	store, err = Open("sqlite://:memory:")
+	require.NoError(t, err)
+	assert.IsType(t, &SQLiteDB{}, store)
+	assert.NoError(t, store.Close())
+}
+
+func assertJobQueue(t *testing.T, d models.Database) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	lease := now.Add(time.Minute)
+
+	enqueue := func(kind models.JobKind, key string, priority int, runAt time.Time) *models.Job {
+		job := &models.Job{Kind: kind, Payload: []byte(`{"owner":"test"}`), DedupeKey: key, Priority: priority, RunAt: runAt, MaxAttempts: 3}
+		ok, err := d.EnqueueJob(ctx, job)
+		require.NoError(t, err)
+		require.True(t, ok)
+		return job
+	}
+
+	sync := enqueue(models.JobSync, "sync:test/repo", 0, now.Add(-time.Minute))
+	reset := enqueue(models.JobReset, "", 10, now.Add(-time.Second))
+	later := enqueue(models.JobSync, "sync:test/later", 0, now.Add(time.Hour))
+	assert.Equal(t, models.JobQueued, sync.Status)
+
+	// * A pending job with the same key swallows the duplicate
+	dup := &models.Job{Kind: models.JobSync, DedupeKey: "sync:test/repo", RunAt: now, MaxAttempts: 3}
+	ok, err := d.EnqueueJob(ctx, dup)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Zero(t, dup.ID)
+
+	// * Highest priority first, then oldest run_at; future jobs wait
+	got, err := d.ClaimJob(ctx, "w1", now, lease)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, reset.ID, got.ID)
+	assert.Equal(t, models.JobRunning, got.Status)
+	assert.Equal(t, 1, got.Attempts)
+	assert.Equal(t, "w1", got.LockedBy)
+
+	got, err = d.ClaimJob(ctx, "w2", now, lease)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, sync.ID, got.ID)
+	assert.JSONEq(t, `{"owner":"test"}`, string(got.Payload))
+	assert.Equal(t, "sync:test/repo", got.DedupeKey)
+
+	got, err = d.ClaimJob(ctx, "w3", now, lease)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	// * Only the holder may settle a job
+	assert.ErrorContains(t, d.CompleteJob(ctx, sync.ID, "w1"), "DB_JOB_LOCK_LOST")
+	require.NoError(t, d.CompleteJob(ctx, reset.ID, "w1"))
+	assert.ErrorContains(t, d.CompleteJob(ctx, reset.ID, "w1"), "DB_JOB_LOCK_LOST")
+
+	// * The key is free again while the sync is retried later
+	retryAt := now.Add(30 * time.Second)
+	require.NoError(t, d.FailJob(ctx, sync.ID, "w2", "rate limited", &retryAt))
+	ok, err = d.EnqueueJob(ctx, &models.Job{Kind: models.JobSync, DedupeKey: "sync:test/repo", RunAt: now, MaxAttempts: 3})
+	require.NoError(t, err)
+	assert.False(t, ok, "a queued retry still holds the key")
+
+	got, err = d.ClaimJob(ctx, "w2", retryAt, retryAt.Add(time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, sync.ID, got.ID)
+	assert.Equal(t, 2, got.Attempts)
+	assert.Equal(t, "rate limited", got.LastError)
+
+	// * Deferring a job hands back the attempt its claim counted
+	require.NoError(t, d.DeferJob(ctx, sync.ID, "w2", retryAt))
+	got, err = d.ClaimJob(ctx, "w2", retryAt, retryAt.Add(time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, 2, got.Attempts)
+
+	// * An expired lock is claimed by another worker; extending it then fails
+	expired := retryAt.Add(2 * time.Minute)
+	got, err = d.ClaimJob(ctx, "w3", expired, expired.Add(time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, sync.ID, got.ID)
+	assert.Equal(t, 3, got.Attempts)
+	assert.ErrorContains(t, d.ExtendJob(ctx, sync.ID, "w2", expired.Add(time.Hour)), "DB_JOB_LOCK_LOST")
+	require.NoError(t, d.ExtendJob(ctx, sync.ID, "w3", expired.Add(time.Hour)))
+
+	got, err = d.ClaimJob(ctx, "w4", expired.Add(2*time.Minute), expired.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, got, "the extended lock still holds")
+
+	require.NoError(t, d.FailJob(ctx, sync.ID, "w3", "not found", nil))
+	ok, err = d.EnqueueJob(ctx, &models.Job{Kind: models.JobSync, DedupeKey: "sync:test/repo", RunAt: now, MaxAttempts: 3})
+	require.NoError(t, err)
+	assert.True(t, ok, "a failed job frees the key")
+
+	jobs, err := d.GetJobs(ctx, models.JobFilter{Status: models.JobFailed})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, sync.ID, jobs[0].ID)
+	assert.Equal(t, "not found", jobs[0].LastError)
+	assert.NotNil(t, jobs[0].FinishedAt)
+	assert.Empty(t, jobs[0].LockedBy)
+
+	jobs, err = d.GetJobs(ctx, models.JobFilter{Kind: models.JobSync, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Greater(t, jobs[0].ID, later.ID)
+	assert.Equal(t, later.ID, jobs[1].ID)
+
+	jobs, err = d.GetJobs(ctx, models.JobFilter{BeforeID: later.ID})
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, models.JobSucceeded, jobs[0].Status)
+}
+
+func assertLocks(t *testing.T, d models.Database) {
+	t.Helper()
+	ctx := context.Background()
+
+	lock, err := d.TryLock(ctx, "sync:test/repo")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	assert.True(t, lock.Held(ctx))
+
+	// * The name is taken until released; other names are not
+	taken, err := d.TryLock(ctx, "sync:test/repo")
+	require.NoError(t, err)
+	assert.Nil(t, taken)
+
+	other, err := d.TryLock(ctx, "sync:test/other")
+	require.NoError(t, err)
+	require.NotNil(t, other)
+	require.NoError(t, other.Release())
+
+	require.NoError(t, lock.Release())
+	require.NoError(t, lock.Release())
+	assert.False(t, lock.Held(ctx))
+
+	lock, err = d.TryLock(ctx, "sync:test/repo")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	require.NoError(t, lock.Release())
+}
+
+// * assertChangelog reads commits with their Conventional Commits fields,
+// * bounded by date and by stored commit, and finds commits by SHA prefix
+func assertChangelog(t *testing.T, d models.Database, repoID int) {
+	t.Helper()
+	ctx := context.Background()
+
+	insert := func(sha, message, date string) {
+		t.Helper()
+		authorDate, err := time.Parse(time.RFC3339, date)
+		require.NoError(t, err)
+		require.NoError(t, d.InsertCommit(ctx, &models.Commit{
+			SHA:          sha,
+			RepositoryID: repoID,
+			Message:      message,
+			AuthorName:   "Alice",
+			AuthorEmail:  "alice@example.com",
+			AuthorDate:   authorDate,
+			CommitURL:    "url",
+		}))
+	}
+	insert("aaa111", "chore: release 1.0", "2024-03-01T10:00:00Z")
+	insert("bbb222", "feat(api)!: drop v1\n\nBREAKING CHANGE: v1 is gone", "2024-03-02T10:00:00Z")
+	insert("bbb333", "Update README", "2024-03-03T10:00:00Z")
+	insert("ccc444", "fix: off by one", "2024-03-03T10:00:00Z")
+
+	shas := func(filter models.ChangelogFilter) []string {
+		t.Helper()
+		commits, err := d.GetChangelogCommits(ctx, "test/repo", filter)
+		require.NoError(t, err)
+		var got []string
+		for _, c := range commits {
+			got = append(got, c.SHA)
+		}
+		return got
+	}
+
+	commits, err := d.GetChangelogCommits(ctx, "test/repo", models.ChangelogFilter{})
+	require.NoError(t, err)
+	require.Len(t, commits, 4)
+	assert.Equal(t, []string{"ccc444", "bbb333", "bbb222", "aaa111"}, shas(models.ChangelogFilter{}))
+	assert.Equal(t, "Update README", commits[1].Subject)
+	assert.Nil(t, commits[1].Conventional)
+	require.NotNil(t, commits[2].Conventional)
+	assert.Equal(t, conventional.Commit{Type: "feat", Scope: "api", Breaking: true, Subject: "drop v1"}, *commits[2].Conventional)
+
+	assert.Equal(t, []string{"bbb222"}, shas(models.ChangelogFilter{
+		Since: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+	}))
+
+	from, err := d.FindCommit(ctx, "test/repo", "aaa")
+	require.NoError(t, err)
+	to, err := d.FindCommit(ctx, "test/repo", "BBB3")
+	require.NoError(t, err)
+	assert.Equal(t, "bbb333", to.SHA)
+	assert.Equal(t, []string{"bbb333", "bbb222"}, shas(models.ChangelogFilter{
+		After:   &models.CommitCursor{AuthorDate: from.AuthorDate, ID: from.ID},
+		Through: &models.CommitCursor{AuthorDate: to.AuthorDate, ID: to.ID},
+	}))
+
+	_, err = d.FindCommit(ctx, "test/repo", "bbb")
+	assert.ErrorContains(t, err, "AMBIGUOUS_COMMIT")
+	_, err = d.FindCommit(ctx, "test/repo", "ddd")
+	assert.ErrorContains(t, err, "COMMIT_NOT_FOUND")
+}
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * jobColumns are scanned by scanJob, in order
+const jobColumns = `id, kind, payload, COALESCE(dedupe_key, ''), priority, status, run_at, attempts,
+	max_attempts, COALESCE(locked_by, ''), locked_until, last_error, created_at, finished_at`
+
+func jobError(title, detail string, err error) error {
+	return errors.New("DB_JOB_ERROR", title, detail, err, errors.LevelError)
+}
+
+// * jobLockLost is returned when a worker settles a job it no longer holds:
+// * its lock expired and another worker claimed the job
+func jobLockLost(id int, worker string) error {
+	return errors.New(
+		"DB_JOB_LOCK_LOST",
+		"Job lock lost",
+		fmt.Sprintf("Job %d is no longer running under worker '%s'", id, worker),
+		nil,
+		errors.LevelWarning,
+	)
+}
+
+func scanJob(row interface{ Scan(...any) error }) (*models.Job, error) {
+	var job models.Job
+	var payload []byte
+	err := row.Scan(&job.ID, &job.Kind, &payload, &job.DedupeKey, &job.Priority, &job.Status, &job.RunAt,
+		&job.Attempts, &job.MaxAttempts, &job.LockedBy, &job.LockedUntil, &job.LastError, &job.CreatedAt,
+		&job.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = payload
+	return &job, nil
+}
+
+// * enqueueSQLJob stores job as queued and fills in its ID. It reports false,
+// * storing nothing, when a job with the same dedupe key is still pending.
+func enqueueSQLJob(ctx context.Context, q queryer, job *models.Job, normalize func(time.Time) time.Time) (bool, error) {
+	job.Status = models.JobQueued
+	job.CreatedAt = normalize(time.Now())
+	job.RunAt = normalize(job.RunAt)
+	if len(job.Payload) == 0 {
+		job.Payload = []byte("{}")
+	}
+
+	err := q.QueryRowContext(ctx, `
+		INSERT INTO jobs (kind, payload, dedupe_key, priority, status, run_at, max_attempts, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
+		ON CONFLICT (dedupe_key) WHERE status IN ('queued', 'running') DO NOTHING
+		RETURNING id
+	`, string(job.Kind), string(job.Payload), job.DedupeKey, job.Priority, string(job.Status),
+		job.RunAt, job.MaxAttempts, job.CreatedAt).Scan(&job.ID)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, jobError("Failed to enqueue job", fmt.Sprintf("Could not enqueue a %s job", job.Kind), err)
+	}
+	return true, nil
+}
+
+// * claimSQLJob locks the most urgent due job for worker until lockedUntil,
+// * counting the attempt. Queued jobs are due at run_at, running ones once
+// * their lock has expired. lock is appended to the inner SELECT so Postgres
+// * skips rows other workers are claiming. It returns nil when nothing is due.
+func claimSQLJob(ctx context.Context, q queryer, worker string, now, lockedUntil time.Time, lock string,
+	normalize func(time.Time) time.Time) (*models.Job, error) {
+	job, err := scanJob(q.QueryRowContext(ctx, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_by = $1, locked_until = $2
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = 'queued' AND run_at <= $3) OR (status = 'running' AND locked_until <= $3)
+			ORDER BY priority DESC, run_at, id
+			LIMIT 1
+			`+lock+`
+		)
+		RETURNING `+jobColumns,
+		worker, normalize(lockedUntil), normalize(now)))
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, jobError("Failed to claim job", fmt.Sprintf("Could not claim a job for worker '%s'", worker), err)
+	}
+	return job, nil
+}
+
+// * settleSQLJob applies set to job id if worker still holds it
+func settleSQLJob(ctx context.Context, q execQueryer, id int, worker, set string, args ...any) error {
+	args = append(args, id, worker)
+	res, err := q.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE jobs
+		SET %s
+		WHERE id = $%d AND status = 'running' AND locked_by = $%d
+	`, set, len(args)-1, len(args)), args...)
+	if err != nil {
+		return jobError("Failed to update job", fmt.Sprintf("Could not update job %d", id), err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return jobError("Failed to update job", fmt.Sprintf("Could not tell whether job %d was updated", id), err)
+	}
+	if n == 0 {
+		return jobLockLost(id, worker)
+	}
+	return nil
+}
+
+// * extendSQLJob keeps a running job locked until lockedUntil
+func extendSQLJob(ctx context.Context, q execQueryer, id int, worker string, lockedUntil time.Time, normalize func(time.Time) time.Time) error {
+	return settleSQLJob(ctx, q, id, worker, "locked_until = $1", normalize(lockedUntil))
+}
+
+// * completeSQLJob marks a running job succeeded
+func completeSQLJob(ctx context.Context, q execQueryer, id int, worker string, normalize func(time.Time) time.Time) error {
+	return settleSQLJob(ctx, q, id, worker,
+		"status = 'succeeded', locked_by = NULL, locked_until = NULL, finished_at = $1", normalize(time.Now()))
+}
+
+// * failSQLJob records message against a running job and queues it again at
+// * retryAt, or marks it failed for good when retryAt is nil
+func failSQLJob(ctx context.Context, q execQueryer, id int, worker, message string, retryAt *time.Time, normalize func(time.Time) time.Time) error {
+	if retryAt == nil {
+		return settleSQLJob(ctx, q, id, worker,
+			"status = 'failed', locked_by = NULL, locked_until = NULL, last_error = $1, finished_at = $2",
+			message, normalize(time.Now()))
+	}
+	return settleSQLJob(ctx, q, id, worker,
+		"status = 'queued', locked_by = NULL, locked_until = NULL, last_error = $1, run_at = $2",
+		message, normalize(*retryAt))
+}
+
+// * deferSQLJob queues a running job again at runAt, handing back the
+// * attempt its claim counted
+func deferSQLJob(ctx context.Context, q execQueryer, id int, worker string, runAt time.Time, normalize func(time.Time) time.Time) error {
+	return settleSQLJob(ctx, q, id, worker,
+		"status = 'queued', locked_by = NULL, locked_until = NULL, attempts = attempts - 1, run_at = $1",
+		normalize(runAt))
+}
+
+// * querySQLJobs backs GetJobs for both SQL implementations
+func querySQLJobs(ctx context.Context, q queryer, filter models.JobFilter) ([]models.Job, error) {
+	where := " WHERE 1 = 1"
+	var args []any
+
+	add := func(clause string, arg any) {
+		args = append(args, arg)
+		where += fmt.Sprintf(clause, len(args))
+	}
+	if filter.Status != "" {
+		add(" AND status = $%d", string(filter.Status))
+	}
+	if filter.Kind != "" {
+		add(" AND kind = $%d", string(filter.Kind))
+	}
+	if filter.BeforeID > 0 {
+		add(" AND id < $%d", filter.BeforeID)
+	}
+
+	query := `SELECT ` + jobColumns + ` FROM jobs` + where + ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, jobError("Failed to query jobs", "Could not fetch jobs", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, jobError("Failed to scan job", "Error while scanning job row", err)
+		}
+		jobs = append(jobs, *job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, jobError("Failed to process jobs", "Error while processing job rows", err)
+	}
+	return jobs, nil
+}
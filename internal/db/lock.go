@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+func lockError(title, name string, err error) error {
+	return errors.New("DB_LOCK_ERROR", title, fmt.Sprintf("Could not use lock '%s'", name), err, errors.LevelError)
+}
+
+// * LockPoolSize caps the connections Postgres advisory locks are held on,
+// * and so how many locks one process holds at once: the leader lock and one
+// * sync lock per repository being synced. Taking a lock beyond it waits for
+// * another to be released.
+const LockPoolSize = 16
+
+// * advisoryLock is a Postgres session-level advisory lock. It lives on a
+// * connection of its own, taken out of the lock pool until Release, because
+// * the lock belongs to the session that took it.
+type advisoryLock struct {
+	conn *sql.Conn
+	name string
+	once sync.Once
+}
+
+// * tryAdvisoryLock takes the advisory lock keyed by a hash of name without
+// * waiting, returning nil when another session holds it
+func tryAdvisoryLock(ctx context.Context, db *sql.DB, name string) (models.Lock, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, lockError("Failed to take lock", name, err)
+	}
+
+	var ok bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtextextended($1, 0))`, name).Scan(&ok)
+	if err != nil {
+		// * The lock may have been taken before the error, so the session
+		// * is not handed back to the pool
+		discardConn(conn)
+		return nil, lockError("Failed to take lock", name, err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, nil
+	}
+	return &advisoryLock{conn: conn, name: name}, nil
+}
+
+// * Held pings the lock's connection; the session, and so the lock, ends
+// * with it
+func (l *advisoryLock) Held(ctx context.Context) bool {
+	return l.conn.PingContext(ctx) == nil
+}
+
+func (l *advisoryLock) Release() error {
+	var err error
+	l.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err = l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtextextended($1, 0))`, l.name)
+		if err != nil {
+			// * Close hands the connection back to the pool with its session,
+			// * and the lock, still alive; discarding it ends the session
+			discardConn(l.conn)
+		} else {
+			err = l.conn.Close()
+		}
+		if err != nil {
+			err = lockError("Failed to release lock", l.name, err)
+		}
+	})
+	return err
+}
+
+// * discardConn closes conn for good instead of returning it to the pool,
+// * ending its session and any advisory locks the session still holds
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+	conn.Close()
+}
+
+// * processLocks backs TryLock on engines only ever used by one process,
+// * where an in-process lock is enough
+type processLocks struct {
+	mu   sync.Mutex
+	held map[string]struct{}
+}
+
+func (p *processLocks) tryLock(name string) models.Lock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.held[name]; ok {
+		return nil
+	}
+	if p.held == nil {
+		p.held = make(map[string]struct{})
+	}
+	p.held[name] = struct{}{}
+	return &processLock{locks: p, name: name}
+}
+
+type processLock struct {
+	locks    *processLocks
+	name     string
+	once     sync.Once
+	released atomic.Bool
+}
+
+func (l *processLock) Held(ctx context.Context) bool { return !l.released.Load() }
+
+func (l *processLock) Release() error {
+	l.once.Do(func() {
+		l.released.Store(true)
+		l.locks.mu.Lock()
+		defer l.locks.mu.Unlock()
+		delete(l.locks.held, l.name)
+	})
+	return nil
+}
@@ -0,0 +1,1560 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/mailmap"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * memoryState is everything MemoryDB stores. Transactions work on a clone,
+// * so a failed transaction leaves no trace.
+type memoryState struct {
+	repositories map[string]*models.Repository
+	commits      map[int][]models.Commit
+	shas         map[int]map[string]struct{}
+	retention    map[int]models.RetentionPolicy
+	// * authors are stored without their identities
+	authors        map[int]models.Author
+	identities     map[int]models.AuthorIdentity
+	mailmap        mailmap.Map
+	botRules       bots.Rules
+	nextRepoID     int
+	nextCommitID   int
+	nextAuthorID   int
+	nextIdentityID int
+	nextAuditID    int
+	audit          []models.AuditEntry
+	nextSyncRunID  int
+	syncRuns       []models.SyncRun
+	resetJobs      []models.ResetJob
+	jobs           []models.Job
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{
+		repositories:   make(map[string]*models.Repository),
+		commits:        make(map[int][]models.Commit),
+		shas:           make(map[int]map[string]struct{}),
+		retention:      make(map[int]models.RetentionPolicy),
+		authors:        make(map[int]models.Author),
+		identities:     make(map[int]models.AuthorIdentity),
+		nextRepoID:     1,
+		nextCommitID:   1,
+		nextAuthorID:   1,
+		nextIdentityID: 1,
+	}
+}
+
+func (s *memoryState) clone() *memoryState {
+	c := &memoryState{
+		repositories:   make(map[string]*models.Repository, len(s.repositories)),
+		commits:        make(map[int][]models.Commit, len(s.commits)),
+		shas:           make(map[int]map[string]struct{}, len(s.shas)),
+		retention:      maps.Clone(s.retention),
+		authors:        maps.Clone(s.authors),
+		identities:     maps.Clone(s.identities),
+		mailmap:        slices.Clone(s.mailmap),
+		botRules:       bots.Rules{Allow: slices.Clone(s.botRules.Allow), Deny: slices.Clone(s.botRules.Deny)},
+		audit:          slices.Clone(s.audit),
+		syncRuns:       slices.Clone(s.syncRuns),
+		resetJobs:      slices.Clone(s.resetJobs),
+		jobs:           slices.Clone(s.jobs),
+		nextRepoID:     s.nextRepoID,
+		nextCommitID:   s.nextCommitID,
+		nextAuthorID:   s.nextAuthorID,
+		nextIdentityID: s.nextIdentityID,
+		nextAuditID:    s.nextAuditID,
+		nextSyncRunID:  s.nextSyncRunID,
+	}
+	for name, repo := range s.repositories {
+		c.repositories[name] = copyRepository(repo)
+	}
+	for repoID, commits := range s.commits {
+		c.commits[repoID] = slices.Clone(commits)
+	}
+	for repoID, shas := range s.shas {
+		c.shas[repoID] = maps.Clone(shas)
+	}
+	return c
+}
+
+func copyRepository(repo *models.Repository) *models.Repository {
+	r := *repo
+	if repo.LastCommitFetchedAt != nil {
+		t := *repo.LastCommitFetchedAt
+		r.LastCommitFetchedAt = &t
+	}
+	if repo.StatusChangedAt != nil {
+		t := *repo.StatusChangedAt
+		r.StatusChangedAt = &t
+	}
+	return &r
+}
+
+func (s *memoryState) repositoryByID(id int) *models.Repository {
+	for _, repo := range s.repositories {
+		if repo.ID == id {
+			return repo
+		}
+	}
+	return nil
+}
+
+// * MemoryDB is a thread-safe, non-persistent models.Database for tests and
+// * demo mode (DB_PATH=memory://). Transactions are serialized with each
+// * other, but not with non-transactional writes: a transaction works on a
+// * clone and replays its writes onto the committed state on commit, so
+// * heartbeats and audit writes go on while a sync holds one open.
+type MemoryDB struct {
+	txMu    sync.Mutex
+	writeMu sync.Mutex
+	mu      sync.RWMutex
+	state   *memoryState
+	txs     map[*sql.Tx]*memoryTx
+	locks   processLocks
+}
+
+// * memoryTx is an open transaction: the working copy its reads and writes
+// * see, and the writes to replay on commit
+type memoryTx struct {
+	state  *memoryState
+	writes []func(s *memoryState) error
+}
+
+func NewMemoryDB() *MemoryDB {
+	logger.Info("using in-memory database, data will not survive a restart")
+	return &MemoryDB{
+		state: newMemoryState(),
+		txs:   make(map[*sql.Tx]*memoryTx),
+	}
+}
+
+func (m *MemoryDB) Migrate() error { return nil }
+func (m *MemoryDB) Close() error   { return nil }
+
+// * Migrator always fails; an in-memory database has no versioned schema
+func (m *MemoryDB) Migrator() (*Migrator, error) {
+	return nil, errors.New(
+		"DB_MIGRATION_ERROR",
+		"Migrations are not supported",
+		"The in-memory database has no schema to migrate",
+		nil,
+		errors.LevelError,
+	)
+}
+
+// * read runs fn against the committed state
+func (m *MemoryDB) read(fn func(s *memoryState)) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fn(m.state)
+}
+
+// * write runs fn against the committed state outside of any transaction
+func (m *MemoryDB) write(fn func(s *memoryState) error) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(m.state)
+}
+
+// * writeTx runs fn against the working copy owned by tx and keeps it to
+// * replay on commit, so fn must only write through s and its arguments
+func (m *MemoryDB) writeTx(tx *sql.Tx, fn func(s *memoryState) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	open, ok := m.txs[tx]
+	if !ok {
+		return errors.New(
+			"DB_TRANSACTION_ERROR",
+			"Unknown transaction",
+			"Transaction is not active on this database",
+			sql.ErrTxDone,
+			errors.LevelError,
+		)
+	}
+	if err := fn(open.state); err != nil {
+		return err
+	}
+	open.writes = append(open.writes, fn)
+	return nil
+}
+
+// * WithTransaction runs fn on a clone of the state, holding no write lock
+// * meanwhile. On commit the writes fn made are replayed onto the committed
+// * state, taking in whatever was written since the clone.
+func (m *MemoryDB) WithTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return errors.New(
+			"DB_TRANSACTION_ERROR",
+			"Failed to begin transaction",
+			"Could not start database transaction",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	// * The handle is only ever used as a key; no sql.Tx method is called on it
+	tx := &sql.Tx{}
+
+	open := &memoryTx{}
+	m.mu.Lock()
+	open.state = m.state.clone()
+	m.txs[tx] = open
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.txs, tx)
+		m.mu.Unlock()
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return m.commit(open)
+}
+
+// * commit replays the writes of open onto a clone of the committed state
+// * and swaps it in, leaving no trace when one of them now fails
+func (m *MemoryDB) commit(open *memoryTx) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(open.writes) == 0 {
+		return nil
+	}
+	next := m.state.clone()
+	for _, write := range open.writes {
+		if err := write(next); err != nil {
+			return errors.New(
+				"DB_TRANSACTION_ERROR",
+				"Failed to commit transaction",
+				"A write of the transaction conflicts with one committed since it began",
+				err,
+				errors.LevelError,
+			)
+		}
+	}
+	m.state = next
+	return nil
+}
+
+func upsertRepository(s *memoryState, repo *models.Repository) error {
+	existing, ok := s.repositories[repo.Name]
+	if !ok {
+		existing = &models.Repository{ID: s.nextRepoID, Name: repo.Name, CreatedAt: repo.CreatedAt, Status: models.RepositoryActive}
+		s.nextRepoID++
+		s.repositories[repo.Name] = existing
+	}
+	if existing.Status != models.RepositoryActive {
+		return repositoryInactive(repo.Name)
+	}
+
+	existing.Description = repo.Description
+	existing.URL = repo.URL
+	existing.Language = repo.Language
+	existing.ForksCount = repo.ForksCount
+	existing.StarsCount = repo.StarsCount
+	existing.OpenIssuesCount = repo.OpenIssuesCount
+	existing.WatchersCount = repo.WatchersCount
+	existing.UpdatedAt = repo.UpdatedAt
+
+	repo.ID = existing.ID
+	repo.Status = existing.Status
+	repo.LastCommitFetchedAt = nil
+	if existing.LastCommitFetchedAt != nil {
+		t := *existing.LastCommitFetchedAt
+		repo.LastCommitFetchedAt = &t
+	}
+	return nil
+}
+
+func updateRepository(s *memoryState, repo *models.Repository) {
+	existing := s.repositoryByID(repo.ID)
+	if existing == nil {
+		return
+	}
+
+	existing.LastCommitFetchedAt = nil
+	if repo.LastCommitFetchedAt != nil {
+		t := *repo.LastCommitFetchedAt
+		existing.LastCommitFetchedAt = &t
+	}
+}
+
+// * insertCommit resolves the author of commit and stores it unless its SHA
+// * is already known for the repository, and reports whether it was stored
+func insertCommit(ctx context.Context, s *memoryState, r *authorResolver, commit *models.Commit) (bool, error) {
+	if s.repositoryByID(commit.RepositoryID) == nil {
+		return false, fmt.Errorf("repository %d does not exist", commit.RepositoryID)
+	}
+
+	shas, ok := s.shas[commit.RepositoryID]
+	if !ok {
+		shas = make(map[string]struct{})
+		s.shas[commit.RepositoryID] = shas
+	}
+	if _, seen := shas[commit.SHA]; seen {
+		return false, nil
+	}
+	if err := r.resolve(ctx, commit); err != nil {
+		return false, err
+	}
+	shas[commit.SHA] = struct{}{}
+
+	c := *commit
+	c.ID = s.nextCommitID
+	s.nextCommitID++
+	s.commits[commit.RepositoryID] = append(s.commits[commit.RepositoryID], c)
+	return true, nil
+}
+
+func (m *MemoryDB) UpsertRepository(ctx context.Context, repo *models.Repository) error {
+	return m.write(func(s *memoryState) error {
+		return upsertRepository(s, repo)
+	})
+}
+
+func (m *MemoryDB) GetRepository(ctx context.Context, name string) (*models.Repository, error) {
+	var repo *models.Repository
+	m.read(func(s *memoryState) {
+		if r, ok := s.repositories[name]; ok {
+			repo = copyRepository(r)
+		}
+	})
+
+	if repo == nil {
+		return nil, repositoryNotFound(name)
+	}
+
+	return repo, nil
+}
+
+func (m *MemoryDB) GetAllRepositories(ctx context.Context) ([]*models.Repository, error) {
+	var repos []*models.Repository
+	m.read(func(s *memoryState) {
+		for _, r := range s.repositories {
+			if r.Status != models.RepositoryDeleted {
+				repos = append(repos, copyRepository(r))
+			}
+		}
+	})
+
+	slices.SortFunc(repos, func(a, b *models.Repository) int { return cmp.Compare(a.ID, b.ID) })
+	return repos, nil
+}
+
+func (m *MemoryDB) UpdateRepository(ctx context.Context, repo *models.Repository) error {
+	return m.write(func(s *memoryState) error {
+		updateRepository(s, repo)
+		return nil
+	})
+}
+
+func setRepositoryStatus(s *memoryState, name string, status models.RepositoryStatus) (*models.Repository, error) {
+	r, ok := s.repositories[name]
+	if !ok {
+		return nil, repositoryNotFound(name)
+	}
+
+	now := time.Now()
+	r.Status = status
+	r.StatusChangedAt = &now
+	return copyRepository(r), nil
+}
+
+func deleteRepository(s *memoryState, name string) error {
+	repo, ok := s.repositories[name]
+	if !ok {
+		return repositoryNotFound(name)
+	}
+
+	delete(s.repositories, name)
+	delete(s.commits, repo.ID)
+	delete(s.shas, repo.ID)
+	delete(s.retention, repo.ID)
+	return nil
+}
+
+func resetRepository(s *memoryState, repoName string, since time.Time) (int, error) {
+	repo, ok := s.repositories[repoName]
+	if !ok {
+		return 0, repositoryNotFound(repoName)
+	}
+
+	var kept []models.Commit
+	for _, c := range s.commits[repo.ID] {
+		if c.AuthorDate.Before(since) {
+			kept = append(kept, c)
+		} else {
+			delete(s.shas[repo.ID], c.SHA)
+		}
+	}
+	deleted := len(s.commits[repo.ID]) - len(kept)
+	s.commits[repo.ID] = kept
+
+	repo.LastCommitFetchedAt = nil
+	if !since.IsZero() {
+		repo.LastCommitFetchedAt = &since
+	}
+	return deleted, nil
+}
+
+func recordAudit(s *memoryState, entry *models.AuditEntry) {
+	s.nextAuditID++
+	entry.ID = s.nextAuditID
+	entry.CreatedAt = time.Now()
+	s.audit = append(s.audit, *entry)
+}
+
+func (m *MemoryDB) SetRepositoryStatus(ctx context.Context, name string, status models.RepositoryStatus) (*models.Repository, error) {
+	var repo *models.Repository
+	err := m.write(func(s *memoryState) error {
+		var err error
+		repo, err = setRepositoryStatus(s, name, status)
+		return err
+	})
+	return repo, err
+}
+
+func (m *MemoryDB) DeleteRepository(ctx context.Context, name string) error {
+	return m.write(func(s *memoryState) error {
+		return deleteRepository(s, name)
+	})
+}
+
+func (m *MemoryDB) ResetRepository(ctx context.Context, repoName string, since time.Time) (int, error) {
+	var deleted int
+	err := m.write(func(s *memoryState) error {
+		var err error
+		deleted, err = resetRepository(s, repoName, since)
+		return err
+	})
+	return deleted, err
+}
+
+func (m *MemoryDB) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	return m.write(func(s *memoryState) error {
+		recordAudit(s, entry)
+		return nil
+	})
+}
+
+func (m *MemoryDB) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+	m.read(func(s *memoryState) {
+		for _, e := range slices.Backward(s.audit) {
+			if filter.Limit > 0 && len(entries) == filter.Limit {
+				break
+			}
+			if matchesAuditFilter(e, filter) {
+				entries = append(entries, e)
+			}
+		}
+	})
+	return entries, nil
+}
+
+func matchesAuditFilter(e models.AuditEntry, f models.AuditFilter) bool {
+	switch {
+	case f.Actor != "" && e.Actor != f.Actor,
+		f.Action != "" && e.Action != f.Action,
+		f.RepositoryName != "" && e.RepositoryName != f.RepositoryName,
+		f.Outcome != "" && e.Outcome != f.Outcome,
+		f.Since != nil && e.CreatedAt.Before(*f.Since),
+		f.Until != nil && e.CreatedAt.After(*f.Until),
+		f.BeforeID > 0 && e.ID >= f.BeforeID:
+		return false
+	}
+	return true
+}
+
+func (m *MemoryDB) StartSyncRun(ctx context.Context, run *models.SyncRun) error {
+	return m.write(func(s *memoryState) error {
+		s.nextSyncRunID++
+		run.ID = s.nextSyncRunID
+		run.Status = models.SyncRunning
+		s.syncRuns = append(s.syncRuns, *run)
+		return nil
+	})
+}
+
+func (m *MemoryDB) FinishSyncRun(ctx context.Context, run *models.SyncRun) error {
+	return m.write(func(s *memoryState) error {
+		i := slices.IndexFunc(s.syncRuns, func(r models.SyncRun) bool { return r.ID == run.ID })
+		if i < 0 {
+			return errors.New(
+				"DB_SYNC_RUN_ERROR",
+				"Failed to record sync run",
+				fmt.Sprintf("Sync run %d of '%s' was never started", run.ID, run.RepositoryName),
+				nil,
+				errors.LevelError,
+			)
+		}
+		s.syncRuns[i] = *run
+		return nil
+	})
+}
+
+func (m *MemoryDB) GetSyncRuns(ctx context.Context, filter models.SyncRunFilter) ([]models.SyncRun, error) {
+	var runs []models.SyncRun
+	m.read(func(s *memoryState) {
+		for _, r := range slices.Backward(s.syncRuns) {
+			if filter.Limit > 0 && len(runs) == filter.Limit {
+				break
+			}
+			switch {
+			case filter.RepositoryName != "" && r.RepositoryName != filter.RepositoryName,
+				filter.Status != "" && r.Status != filter.Status,
+				filter.BeforeID > 0 && r.ID >= filter.BeforeID:
+				continue
+			}
+			runs = append(runs, r)
+		}
+	})
+	return runs, nil
+}
+
+func (m *MemoryDB) InsertCommit(ctx context.Context, commit *models.Commit) error {
+	return m.write(func(s *memoryState) error {
+		r, err := newAuthorResolver(ctx, memoryAuthorStore{s})
+		if err != nil {
+			return err
+		}
+		if _, err := insertCommit(ctx, s, r, commit); err != nil {
+			return errors.New(
+				"DB_COMMIT_ERROR",
+				"Failed to insert commit",
+				fmt.Sprintf("Could not insert commit '%s' for repository '%d'", commit.SHA, commit.RepositoryID),
+				err,
+				errors.LevelError,
+			)
+		}
+		return nil
+	})
+}
+
+func (m *MemoryDB) GetCommits(ctx context.Context, repoName string, filter models.CommitFilter) (*models.CommitPage, error) {
+	var commits []models.Commit
+	m.read(func(s *memoryState) {
+		repo, ok := s.repositories[repoName]
+		if !ok {
+			return
+		}
+
+		for _, c := range s.commits[repo.ID] {
+			if filter.Since != nil && c.AuthorDate.Before(*filter.Since) {
+				continue
+			}
+			if filter.Until != nil && c.AuthorDate.After(*filter.Until) {
+				continue
+			}
+			if filter.ExcludeBots && s.isBot(c) {
+				continue
+			}
+			commits = append(commits, c)
+		}
+	})
+
+	slices.SortFunc(commits, func(a, b models.Commit) int {
+		if c := b.AuthorDate.Compare(a.AuthorDate); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	page := &models.CommitPage{}
+	if filter.WithTotal {
+		total := len(commits)
+		page.Total = &total
+	}
+
+	if after := filter.After; after != nil {
+		commits = slices.DeleteFunc(commits, func(c models.Commit) bool {
+			if c.AuthorDate.Equal(after.AuthorDate) {
+				return c.ID >= after.ID
+			}
+			return c.AuthorDate.After(after.AuthorDate)
+		})
+	} else if filter.Limit > 0 && filter.Offset > 0 {
+		commits = commits[min(filter.Offset, len(commits)):]
+	}
+
+	if filter.Limit > 0 && len(commits) > filter.Limit {
+		commits = commits[:filter.Limit]
+		last := commits[len(commits)-1]
+		page.NextCursor = &models.CommitCursor{AuthorDate: last.AuthorDate, ID: last.ID}
+	}
+
+	page.Commits = commits
+	return page, nil
+}
+
+func (m *MemoryDB) GetTopAuthors(ctx context.Context, repoName string, limit int, includeBots bool) ([]models.AuthorCommitCount, error) {
+	type key struct {
+		id   int
+		name string
+	}
+	counts := make(map[key]int)
+	m.read(func(s *memoryState) {
+		repo, ok := s.repositories[repoName]
+		if !ok {
+			return
+		}
+		for _, c := range s.commits[repo.ID] {
+			if !includeBots && s.isBot(c) {
+				continue
+			}
+			k := key{name: c.AuthorName}
+			if c.AuthorID != nil {
+				if author, ok := s.authors[*c.AuthorID]; ok {
+					k = key{id: author.ID, name: author.Name}
+				}
+			}
+			counts[k]++
+		}
+	})
+
+	var results []models.AuthorCommitCount
+	for k, n := range counts {
+		results = append(results, models.AuthorCommitCount{AuthorID: k.id, AuthorName: k.name, CommitCount: n})
+	}
+
+	slices.SortFunc(results, func(a, b models.AuthorCommitCount) int {
+		if c := cmp.Compare(b.CommitCount, a.CommitCount); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.AuthorName, b.AuthorName)
+	})
+	if limit >= 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (m *MemoryDB) CreateResetJob(ctx context.Context, job *models.ResetJob) error {
+	return m.write(func(s *memoryState) error {
+		job.ID = len(s.resetJobs) + 1
+		job.CreatedAt = time.Now()
+		s.resetJobs = append(s.resetJobs, *job)
+		return nil
+	})
+}
+
+func (m *MemoryDB) UpdateResetJob(ctx context.Context, job *models.ResetJob) error {
+	return m.write(func(s *memoryState) error {
+		if job.ID < 1 || job.ID > len(s.resetJobs) {
+			return resetJobNotFound(job.ID)
+		}
+		s.resetJobs[job.ID-1] = *job
+		return nil
+	})
+}
+
+func (m *MemoryDB) GetResetJob(ctx context.Context, id int) (*models.ResetJob, error) {
+	var job *models.ResetJob
+	m.read(func(s *memoryState) {
+		if id >= 1 && id <= len(s.resetJobs) {
+			j := s.resetJobs[id-1]
+			job = &j
+		}
+	})
+	if job == nil {
+		return nil, resetJobNotFound(id)
+	}
+	return job, nil
+}
+
+// * TryLock takes an in-process lock
+func (m *MemoryDB) TryLock(ctx context.Context, name string) (models.Lock, error) {
+	return m.locks.tryLock(name), nil
+}
+
+// * EnqueueJob queues job unless a job with its dedupe key is pending
+func (m *MemoryDB) EnqueueJob(ctx context.Context, job *models.Job) (bool, error) {
+	enqueued := false
+	err := m.write(func(s *memoryState) error {
+		if job.DedupeKey != "" && slices.ContainsFunc(s.jobs, func(j models.Job) bool {
+			return j.DedupeKey == job.DedupeKey && (j.Status == models.JobQueued || j.Status == models.JobRunning)
+		}) {
+			return nil
+		}
+		job.ID = len(s.jobs) + 1
+		job.Status = models.JobQueued
+		job.CreatedAt = time.Now()
+		if len(job.Payload) == 0 {
+			job.Payload = []byte("{}")
+		}
+		s.jobs = append(s.jobs, *job)
+		enqueued = true
+		return nil
+	})
+	return enqueued, err
+}
+
+// * ClaimJob locks the next due job for worker, ordered like the SQL queue
+func (m *MemoryDB) ClaimJob(ctx context.Context, worker string, now, lockedUntil time.Time) (*models.Job, error) {
+	var claimed *models.Job
+	err := m.write(func(s *memoryState) error {
+		next := -1
+		for i, j := range s.jobs {
+			due := (j.Status == models.JobQueued && !j.RunAt.After(now)) ||
+				(j.Status == models.JobRunning && !j.LockedUntil.After(now))
+			if !due {
+				continue
+			}
+			if next < 0 || cmp.Or(
+				cmp.Compare(s.jobs[next].Priority, j.Priority),
+				j.RunAt.Compare(s.jobs[next].RunAt),
+			) < 0 {
+				next = i
+			}
+		}
+		if next < 0 {
+			return nil
+		}
+
+		j := &s.jobs[next]
+		j.Status = models.JobRunning
+		j.Attempts++
+		j.LockedBy = worker
+		until := lockedUntil
+		j.LockedUntil = &until
+		c := *j
+		claimed = &c
+		return nil
+	})
+	return claimed, err
+}
+
+// * settleJob applies fn to job id if worker still holds it
+func (m *MemoryDB) settleJob(id int, worker string, fn func(j *models.Job)) error {
+	return m.write(func(s *memoryState) error {
+		if id < 1 || id > len(s.jobs) || s.jobs[id-1].Status != models.JobRunning || s.jobs[id-1].LockedBy != worker {
+			return jobLockLost(id, worker)
+		}
+		fn(&s.jobs[id-1])
+		return nil
+	})
+}
+
+func (m *MemoryDB) ExtendJob(ctx context.Context, id int, worker string, lockedUntil time.Time) error {
+	return m.settleJob(id, worker, func(j *models.Job) {
+		j.LockedUntil = &lockedUntil
+	})
+}
+
+func (m *MemoryDB) CompleteJob(ctx context.Context, id int, worker string) error {
+	return m.settleJob(id, worker, func(j *models.Job) {
+		now := time.Now()
+		j.Status = models.JobSucceeded
+		j.LockedBy, j.LockedUntil = "", nil
+		j.FinishedAt = &now
+	})
+}
+
+func (m *MemoryDB) FailJob(ctx context.Context, id int, worker, message string, retryAt *time.Time) error {
+	return m.settleJob(id, worker, func(j *models.Job) {
+		j.LockedBy, j.LockedUntil = "", nil
+		j.LastError = message
+		if retryAt == nil {
+			now := time.Now()
+			j.Status = models.JobFailed
+			j.FinishedAt = &now
+			return
+		}
+		j.Status = models.JobQueued
+		j.RunAt = *retryAt
+	})
+}
+
+func (m *MemoryDB) DeferJob(ctx context.Context, id int, worker string, runAt time.Time) error {
+	return m.settleJob(id, worker, func(j *models.Job) {
+		j.LockedBy, j.LockedUntil = "", nil
+		j.Status = models.JobQueued
+		j.Attempts--
+		j.RunAt = runAt
+	})
+}
+
+func (m *MemoryDB) GetJobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	var jobs []models.Job
+	m.read(func(s *memoryState) {
+		for _, j := range slices.Backward(s.jobs) {
+			if filter.Limit > 0 && len(jobs) == filter.Limit {
+				break
+			}
+			switch {
+			case filter.Status != "" && j.Status != filter.Status,
+				filter.Kind != "" && j.Kind != filter.Kind,
+				filter.BeforeID > 0 && j.ID >= filter.BeforeID:
+				continue
+			}
+			jobs = append(jobs, j)
+		}
+	})
+	return jobs, nil
+}
+
+// * RebuildDailyStats has nothing to rebuild: GetTopAuthors counts commits
+// * directly. It reports the number of buckets the SQL engines would write.
+func (m *MemoryDB) RebuildDailyStats(ctx context.Context, repoName string) (int, error) {
+	type bucket struct {
+		repoID   int
+		day      string
+		authorID int
+		name     string
+	}
+	buckets := make(map[bucket]struct{})
+	var err error
+	m.read(func(s *memoryState) {
+		repoIDs := make([]int, 0, len(s.repositories))
+		for name, repo := range s.repositories {
+			if repoName == "" || name == repoName {
+				repoIDs = append(repoIDs, repo.ID)
+			}
+		}
+		if repoName != "" && len(repoIDs) == 0 {
+			err = repositoryNotFound(repoName)
+			return
+		}
+		for _, repoID := range repoIDs {
+			for _, c := range s.commits[repoID] {
+				b := bucket{repoID: repoID, day: c.AuthorDate.UTC().Format(time.DateOnly), name: c.AuthorName}
+				if c.AuthorID != nil {
+					b.authorID, b.name = *c.AuthorID, ""
+				}
+				buckets[b] = struct{}{}
+			}
+		}
+	})
+	return len(buckets), err
+}
+
+// * SearchCommits scans every stored message; fine for tests and demos
+func (m *MemoryDB) GetCommitActivity(ctx context.Context, filter models.ActivityFilter) ([]models.ActivityCount, error) {
+	counter := newActivityCounter()
+	m.read(func(s *memoryState) {
+		for name, repo := range s.repositories {
+			if len(filter.Repositories) > 0 && !slices.Contains(filter.Repositories, name) {
+				continue
+			}
+			if repo.Status == models.RepositoryDeleted {
+				continue
+			}
+
+			for _, c := range s.commits[repo.ID] {
+				if c.AuthorDate.Before(filter.Since) {
+					continue
+				}
+				if !filter.Until.IsZero() && !c.AuthorDate.Before(filter.Until) {
+					continue
+				}
+				if filter.ExcludeBots && s.isBot(c) {
+					continue
+				}
+
+				count := models.ActivityCount{Start: filter.Interval.Start(c.AuthorDate.In(filter.Location)), Commits: 1}
+				if filter.ByRepository {
+					count.Repository = name
+				}
+				if filter.ByAuthor {
+					count.AuthorName = c.AuthorName
+					if c.AuthorID != nil {
+						if author, ok := s.authors[*c.AuthorID]; ok {
+							count.AuthorID, count.AuthorName = author.ID, author.Name
+						}
+					}
+				}
+				counter.add(count)
+			}
+		}
+	})
+	return counter.counts(), nil
+}
+
+// * FindCommit looks a commit up by SHA or by a prefix of it
+func (m *MemoryDB) FindCommit(ctx context.Context, repoName, shaPrefix string) (*models.Commit, error) {
+	var found []models.Commit
+	m.read(func(s *memoryState) {
+		if repo, ok := s.repositories[repoName]; ok {
+			for _, c := range s.commits[repo.ID] {
+				if strings.HasPrefix(c.SHA, strings.ToLower(shaPrefix)) && len(found) < 2 {
+					found = append(found, c)
+				}
+			}
+		}
+	})
+	return oneCommit(repoName, shaPrefix, found)
+}
+
+// * GetChangelogCommits parses messages as they are read; nothing is stored
+// * that a restart would need
+func (m *MemoryDB) GetChangelogCommits(ctx context.Context, repoName string, filter models.ChangelogFilter) ([]models.ChangelogCommit, error) {
+	var commits []models.ChangelogCommit
+	m.read(func(s *memoryState) {
+		repo, ok := s.repositories[repoName]
+		if !ok {
+			return
+		}
+
+		for _, c := range s.commits[repo.ID] {
+			if c.AuthorDate.Before(filter.Since) {
+				continue
+			}
+			if !filter.Until.IsZero() && !c.AuthorDate.Before(filter.Until) {
+				continue
+			}
+			if filter.After != nil && compareCursor(c, *filter.After) <= 0 {
+				continue
+			}
+			if filter.Through != nil && compareCursor(c, *filter.Through) > 0 {
+				continue
+			}
+			commits = append(commits, newChangelogCommit(c))
+		}
+	})
+
+	slices.SortFunc(commits, func(a, b models.ChangelogCommit) int {
+		if c := b.AuthorDate.Compare(a.AuthorDate); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return commits, nil
+}
+
+// * compareCursor orders c against cursor by (author_date, id)
+func compareCursor(c models.Commit, cursor models.CommitCursor) int {
+	if n := c.AuthorDate.Compare(cursor.AuthorDate); n != 0 {
+		return n
+	}
+	return cmp.Compare(c.ID, cursor.ID)
+}
+
+func (m *MemoryDB) GetPunchCard(ctx context.Context, filter models.PunchCardFilter) (models.PunchCardCounts, error) {
+	var counts models.PunchCardCounts
+	m.read(func(s *memoryState) {
+		for name, repo := range s.repositories {
+			if len(filter.Repositories) > 0 && !slices.Contains(filter.Repositories, name) {
+				continue
+			}
+			if repo.Status == models.RepositoryDeleted {
+				continue
+			}
+
+			for _, c := range s.commits[repo.ID] {
+				if !filter.Since.IsZero() && c.AuthorDate.Before(filter.Since) {
+					continue
+				}
+				if !filter.Until.IsZero() && !c.AuthorDate.Before(filter.Until) {
+					continue
+				}
+				if filter.AuthorID != 0 && (c.AuthorID == nil || *c.AuthorID != filter.AuthorID) {
+					continue
+				}
+				if filter.ExcludeBots && s.isBot(c) {
+					continue
+				}
+
+				_, offset := c.AuthorDate.Zone()
+				counts.Add(punchCardTime(filter, c.AuthorDate, offset), 1)
+			}
+		}
+	})
+	return counts, nil
+}
+
+func (m *MemoryDB) SearchCommits(ctx context.Context, search models.CommitSearch) ([]models.CommitSearchResult, error) {
+	var results []models.CommitSearchResult
+	m.read(func(s *memoryState) {
+		for name, repo := range s.repositories {
+			if len(search.Repositories) > 0 && !slices.Contains(search.Repositories, name) {
+				continue
+			}
+			if repo.Status == models.RepositoryDeleted {
+				continue
+			}
+
+			for _, c := range s.commits[repo.ID] {
+				if search.ExcludeBots && s.isBot(c) {
+					continue
+				}
+				score := search.Query.Match(c.Message)
+				if score == 0 {
+					continue
+				}
+				results = append(results, models.CommitSearchResult{
+					Commit:         c,
+					RepositoryName: name,
+					Rank:           float64(score),
+					Snippet:        search.Query.Highlight(c.Message),
+				})
+			}
+		}
+	})
+
+	slices.SortFunc(results, func(a, b models.CommitSearchResult) int {
+		if c := cmp.Compare(b.Rank, a.Rank); c != 0 {
+			return c
+		}
+		return b.AuthorDate.Compare(a.AuthorDate)
+	})
+
+	if search.Limit > 0 && len(results) > search.Limit {
+		results = results[:search.Limit]
+	}
+	return results, nil
+}
+
+func (m *MemoryDB) SetRetentionPolicy(ctx context.Context, repoName string, policy models.RetentionPolicy) (*models.RetentionPolicy, error) {
+	var saved models.RetentionPolicy
+	err := m.write(func(s *memoryState) error {
+		repo, ok := s.repositories[repoName]
+		if !ok {
+			return repositoryNotFound(repoName)
+		}
+
+		saved = models.RetentionPolicy{
+			RepositoryID:   repo.ID,
+			RepositoryName: repo.Name,
+			MaxAgeDays:     policy.MaxAgeDays,
+			MaxCommits:     policy.MaxCommits,
+			UpdatedAt:      time.Now(),
+		}
+		s.retention[repo.ID] = saved
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (m *MemoryDB) GetRetentionPolicy(ctx context.Context, repoName string) (*models.RetentionPolicy, error) {
+	var policy *models.RetentionPolicy
+	m.read(func(s *memoryState) {
+		if repo, ok := s.repositories[repoName]; ok {
+			if p, ok := s.retention[repo.ID]; ok {
+				policy = &p
+			}
+		}
+	})
+
+	if policy == nil {
+		return nil, errors.New(
+			"DB_RETENTION_NOT_FOUND",
+			"Retention policy not found",
+			fmt.Sprintf("Repository '%s' has no retention policy", repoName),
+			sql.ErrNoRows,
+			errors.LevelInfo,
+		)
+	}
+	return policy, nil
+}
+
+func (m *MemoryDB) GetRetentionPolicies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	var policies []models.RetentionPolicy
+	m.read(func(s *memoryState) {
+		policies = slices.Collect(maps.Values(s.retention))
+	})
+
+	slices.SortFunc(policies, func(a, b models.RetentionPolicy) int { return cmp.Compare(a.RepositoryName, b.RepositoryName) })
+	return policies, nil
+}
+
+// * expiredCommits returns the commits of repoID that policy expires at now,
+// * oldest first
+func expiredCommits(s *memoryState, repoID int, policy models.RetentionPolicy, now time.Time) []models.Commit {
+	commits := slices.Clone(s.commits[repoID])
+	slices.SortFunc(commits, func(a, b models.Commit) int {
+		if c := b.AuthorDate.Compare(a.AuthorDate); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	cutoff := policy.Cutoff(now)
+	var expired []models.Commit
+	for i, c := range commits {
+		if (policy.MaxCommits != nil && i >= *policy.MaxCommits) || (cutoff != nil && c.AuthorDate.Before(*cutoff)) {
+			expired = append(expired, c)
+		}
+	}
+
+	slices.Reverse(expired)
+	return expired
+}
+
+func (m *MemoryDB) PreviewPrune(ctx context.Context, repoName string, policy models.RetentionPolicy, now time.Time) (*models.PrunePreview, error) {
+	var preview *models.PrunePreview
+	var expired []models.Commit
+	m.read(func(s *memoryState) {
+		repo, ok := s.repositories[repoName]
+		if !ok {
+			return
+		}
+
+		policy.RepositoryID = repo.ID
+		policy.RepositoryName = repo.Name
+		preview = &models.PrunePreview{RepositoryName: repoName, Policy: policy, Cutoff: policy.Cutoff(now)}
+		expired = expiredCommits(s, repo.ID, policy, now)
+	})
+
+	if preview == nil {
+		return nil, repositoryNotFound(repoName)
+	}
+
+	preview.Commits = len(expired)
+	if len(expired) > 0 {
+		oldest, newest := expired[0].AuthorDate, expired[0].AuthorDate
+		for _, c := range expired {
+			if c.AuthorDate.Before(oldest) {
+				oldest = c.AuthorDate
+			}
+			if c.AuthorDate.After(newest) {
+				newest = c.AuthorDate
+			}
+		}
+		preview.OldestDate = &oldest
+		preview.NewestDate = &newest
+	}
+	return preview, nil
+}
+
+func (m *MemoryDB) PruneCommits(ctx context.Context, repoName string, policy models.RetentionPolicy, now time.Time, batchSize int) (int, error) {
+	deleted := 0
+	err := m.write(func(s *memoryState) error {
+		repo, ok := s.repositories[repoName]
+		if !ok {
+			return repositoryNotFound(repoName)
+		}
+
+		expired := expiredCommits(s, repo.ID, policy, now)
+		if len(expired) > batchSize {
+			expired = expired[:batchSize]
+		}
+
+		ids := make(map[int]struct{}, len(expired))
+		for _, c := range expired {
+			ids[c.ID] = struct{}{}
+			delete(s.shas[repo.ID], c.SHA)
+		}
+		s.commits[repo.ID] = slices.DeleteFunc(s.commits[repo.ID], func(c models.Commit) bool {
+			_, ok := ids[c.ID]
+			return ok
+		})
+
+		deleted = len(expired)
+		return nil
+	})
+	return deleted, err
+}
+
+// * MemoryDB has no partitions; retention deletes commits one by one
+func (m *MemoryDB) MaintainPartitions(ctx context.Context, now time.Time) error { return nil }
+func (m *MemoryDB) DropExpiredPartitions(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+// * Transaction versions of methods for use with WithTransaction. Their
+// * writes are replayed on commit, so each keeps the arguments it was called
+// * with rather than whatever the caller has changed them to since.
+func (m *MemoryDB) UpsertRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
+	in := *repo
+	return m.writeTx(tx, func(s *memoryState) error {
+		*repo = in
+		return upsertRepository(s, repo)
+	})
+}
+
+// * RefreshRepositoryTx updates an active repository like
+// * UpsertRepositoryTx, but never creates one
+func (m *MemoryDB) RefreshRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
+	in := *repo
+	return m.writeTx(tx, func(s *memoryState) error {
+		*repo = in
+		if _, ok := s.repositories[repo.Name]; !ok {
+			return repositoryInactive(repo.Name)
+		}
+		return upsertRepository(s, repo)
+	})
+}
+
+func (m *MemoryDB) InsertCommitTx(ctx context.Context, tx *sql.Tx, commit *models.Commit) error {
+	in := *commit
+	return m.writeTx(tx, func(s *memoryState) error {
+		*commit = in
+		r, err := newAuthorResolver(ctx, memoryAuthorStore{s})
+		if err != nil {
+			return err
+		}
+		if _, err := insertCommit(ctx, s, r, commit); err != nil {
+			return errors.New(
+				"DB_COMMIT_ERROR",
+				"Failed to insert commit in transaction",
+				fmt.Sprintf("Could not insert commit '%s' in transaction", commit.SHA),
+				err,
+				errors.LevelError,
+			)
+		}
+		return nil
+	})
+}
+
+func (m *MemoryDB) InsertCommitsTx(ctx context.Context, tx *sql.Tx, commits []models.Commit) (models.InsertResult, error) {
+	var result models.InsertResult
+	commits = slices.Clone(commits)
+	err := m.writeTx(tx, func(s *memoryState) error {
+		result = models.InsertResult{}
+		r, err := newAuthorResolver(ctx, memoryAuthorStore{s})
+		if err != nil {
+			return err
+		}
+		for i := range commits {
+			c := commits[i]
+			inserted, err := insertCommit(ctx, s, r, &c)
+			if err != nil {
+				return errors.New(
+					"DB_COMMIT_ERROR",
+					"Failed to insert commits in transaction",
+					fmt.Sprintf("Could not insert commit '%s' in transaction", c.SHA),
+					err,
+					errors.LevelError,
+				)
+			}
+			if inserted {
+				result.Inserted++
+			} else {
+				result.Skipped++
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (m *MemoryDB) UpdateRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
+	in := copyRepository(repo)
+	return m.writeTx(tx, func(s *memoryState) error {
+		updateRepository(s, in)
+		return nil
+	})
+}
+
+func (m *MemoryDB) ResetRepositoryTx(ctx context.Context, tx *sql.Tx, repoName string, since time.Time) (int, error) {
+	var deleted int
+	err := m.writeTx(tx, func(s *memoryState) error {
+		var err error
+		deleted, err = resetRepository(s, repoName, since)
+		return err
+	})
+	return deleted, err
+}
+
+func (m *MemoryDB) SetRepositoryStatusTx(ctx context.Context, tx *sql.Tx, name string, status models.RepositoryStatus) (*models.Repository, error) {
+	var repo *models.Repository
+	err := m.writeTx(tx, func(s *memoryState) error {
+		var err error
+		repo, err = setRepositoryStatus(s, name, status)
+		return err
+	})
+	return repo, err
+}
+
+func (m *MemoryDB) DeleteRepositoryTx(ctx context.Context, tx *sql.Tx, name string) error {
+	return m.writeTx(tx, func(s *memoryState) error {
+		return deleteRepository(s, name)
+	})
+}
+
+func (m *MemoryDB) RecordAuditTx(ctx context.Context, tx *sql.Tx, entry *models.AuditEntry) error {
+	in := *entry
+	return m.writeTx(tx, func(s *memoryState) error {
+		*entry = in
+		recordAudit(s, entry)
+		return nil
+	})
+}
+
+// * memoryAuthorStore is the authorStore over a memoryState; it never fails
+type memoryAuthorStore struct {
+	s *memoryState
+}
+
+func (a memoryAuthorStore) loadMailmap(ctx context.Context) (mailmap.Map, error) {
+	return slices.Clone(a.s.mailmap), nil
+}
+
+func (a memoryAuthorStore) replaceMailmap(ctx context.Context, m mailmap.Map) error {
+	a.s.mailmap = slices.Clone(m)
+	return nil
+}
+
+func (a memoryAuthorStore) loadBotRules(ctx context.Context) (bots.Rules, error) {
+	return bots.Rules{Allow: slices.Clone(a.s.botRules.Allow), Deny: slices.Clone(a.s.botRules.Deny)}, nil
+}
+
+func (a memoryAuthorStore) replaceBotRules(ctx context.Context, rules bots.Rules) error {
+	a.s.botRules = bots.Rules{Allow: slices.Clone(rules.Allow), Deny: slices.Clone(rules.Deny)}
+	return nil
+}
+
+func (a memoryAuthorStore) findIdentity(ctx context.Context, kind, value string) (*models.AuthorIdentity, error) {
+	for _, identity := range a.s.identities {
+		if identity.Kind == kind && identity.Value == value {
+			return &identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a memoryAuthorStore) addIdentity(ctx context.Context, authorID int, kind, value string) (*models.AuthorIdentity, error) {
+	if existing, _ := a.findIdentity(ctx, kind, value); existing != nil {
+		return existing, nil
+	}
+	identity := models.AuthorIdentity{ID: a.s.nextIdentityID, AuthorID: authorID, Kind: kind, Value: value}
+	a.s.nextIdentityID++
+	a.s.identities[identity.ID] = identity
+	return &identity, nil
+}
+
+func (a memoryAuthorStore) authorIdentities(ctx context.Context, authorID int) ([]models.AuthorIdentity, error) {
+	var identities []models.AuthorIdentity
+	for _, identity := range a.s.identities {
+		if identity.AuthorID == authorID {
+			identities = append(identities, identity)
+		}
+	}
+	slices.SortFunc(identities, func(x, y models.AuthorIdentity) int { return cmp.Compare(x.ID, y.ID) })
+	return identities, nil
+}
+
+// * updateCommits applies fn to every stored commit
+func (a memoryAuthorStore) updateCommits(fn func(c *models.Commit)) {
+	for _, commits := range a.s.commits {
+		for i := range commits {
+			fn(&commits[i])
+		}
+	}
+}
+
+func (a memoryAuthorStore) moveIdentities(ctx context.Context, identityIDs []int, authorID int) error {
+	for _, id := range identityIDs {
+		identity := a.s.identities[id]
+		identity.AuthorID = authorID
+		a.s.identities[id] = identity
+	}
+	a.updateCommits(func(c *models.Commit) {
+		if c.AuthorIdentityID != nil && slices.Contains(identityIDs, *c.AuthorIdentityID) {
+			id := authorID
+			c.AuthorID = &id
+		}
+	})
+	return nil
+}
+
+func (a memoryAuthorStore) createAuthor(ctx context.Context, author *models.Author) error {
+	author.ID = a.s.nextAuthorID
+	a.s.nextAuthorID++
+	a.s.authors[author.ID] = *author
+	return nil
+}
+
+func (a memoryAuthorStore) updateAuthor(ctx context.Context, id int, name, email string) error {
+	author, ok := a.s.authors[id]
+	if !ok {
+		return nil
+	}
+	author.Name = cmp.Or(name, author.Name)
+	author.Email = cmp.Or(email, author.Email)
+	a.s.authors[id] = author
+	return nil
+}
+
+func (a memoryAuthorStore) deleteAuthor(ctx context.Context, id int) error {
+	delete(a.s.authors, id)
+	maps.DeleteFunc(a.s.identities, func(_ int, identity models.AuthorIdentity) bool { return identity.AuthorID == id })
+	a.updateCommits(func(c *models.Commit) {
+		if c.AuthorID != nil && *c.AuthorID == id {
+			c.AuthorID = nil
+		}
+	})
+	return nil
+}
+
+func (a memoryAuthorStore) getAuthor(ctx context.Context, id int) (*models.Author, error) {
+	author, ok := a.s.authors[id]
+	if !ok {
+		return nil, authorNotFound(id)
+	}
+	author.Identities, _ = a.authorIdentities(ctx, id)
+	return &author, nil
+}
+
+func (a memoryAuthorStore) listAuthors(ctx context.Context) ([]models.Author, error) {
+	var authors []models.Author
+	for _, id := range slices.Sorted(maps.Keys(a.s.authors)) {
+		authors = append(authors, a.s.authors[id])
+	}
+	return authors, nil
+}
+
+func (a memoryAuthorStore) setAuthorBot(ctx context.Context, id int, bot bool) error {
+	if author, ok := a.s.authors[id]; ok {
+		author.Bot = bot
+		a.s.authors[id] = author
+	}
+	return nil
+}
+
+// * isBot reports whether c was authored by an author classified as a bot
+func (s *memoryState) isBot(c models.Commit) bool {
+	return c.AuthorID != nil && s.authors[*c.AuthorID].Bot
+}
+
+func (a memoryAuthorStore) reassignCommits(ctx context.Context, fromAuthorID, toAuthorID int) error {
+	a.updateCommits(func(c *models.Commit) {
+		if c.AuthorID != nil && *c.AuthorID == fromAuthorID {
+			id := toAuthorID
+			c.AuthorID = &id
+		}
+	})
+	return nil
+}
+
+func (a memoryAuthorStore) unresolvedCommits(ctx context.Context, limit int) ([]models.Commit, error) {
+	var commits []models.Commit
+	a.updateCommits(func(c *models.Commit) {
+		if c.AuthorID == nil {
+			commits = append(commits, *c)
+		}
+	})
+	slices.SortFunc(commits, func(x, y models.Commit) int { return cmp.Compare(x.ID, y.ID) })
+	if len(commits) > limit {
+		commits = commits[:limit]
+	}
+	return commits, nil
+}
+
+func (a memoryAuthorStore) setCommitAuthor(ctx context.Context, commitID, authorID, identityID int) error {
+	a.updateCommits(func(c *models.Commit) {
+		if c.ID == commitID {
+			c.AuthorID, c.AuthorIdentityID = &authorID, &identityID
+		}
+	})
+	return nil
+}
+
+func (m *MemoryDB) GetAuthors(ctx context.Context, limit int) ([]models.Author, error) {
+	var authors []models.Author
+	m.read(func(s *memoryState) {
+		store := memoryAuthorStore{s}
+		for _, id := range slices.Sorted(maps.Keys(s.authors)) {
+			if limit > 0 && len(authors) == limit {
+				break
+			}
+			author, _ := store.getAuthor(ctx, id)
+			authors = append(authors, *author)
+		}
+	})
+	return authors, nil
+}
+
+func (m *MemoryDB) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	var author *models.Author
+	var err error
+	m.read(func(s *memoryState) {
+		author, err = memoryAuthorStore{s}.getAuthor(ctx, id)
+	})
+	return author, err
+}
+
+// * authorWrite runs fn in a transaction so a rejected change leaves no trace
+func (m *MemoryDB) authorWrite(ctx context.Context, fn func(store memoryAuthorStore) error) error {
+	return m.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return m.writeTx(tx, func(s *memoryState) error {
+			return fn(memoryAuthorStore{s})
+		})
+	})
+}
+
+func (m *MemoryDB) MergeAuthors(ctx context.Context, targetID int, sourceIDs []int) (*models.Author, error) {
+	var author *models.Author
+	err := m.authorWrite(ctx, func(store memoryAuthorStore) error {
+		var err error
+		author, err = mergeAuthors(ctx, store, targetID, sourceIDs)
+		return err
+	})
+	return author, err
+}
+
+func (m *MemoryDB) SplitAuthor(ctx context.Context, authorID int, identityIDs []int, name string) (*models.Author, error) {
+	var author *models.Author
+	err := m.authorWrite(ctx, func(store memoryAuthorStore) error {
+		var err error
+		author, err = splitAuthor(ctx, store, authorID, identityIDs, name)
+		return err
+	})
+	return author, err
+}
+
+func (m *MemoryDB) GetMailmap(ctx context.Context) (mailmap.Map, error) {
+	var mm mailmap.Map
+	m.read(func(s *memoryState) {
+		mm = slices.Clone(s.mailmap)
+	})
+	return mm, nil
+}
+
+func (m *MemoryDB) SetMailmap(ctx context.Context, mm mailmap.Map) error {
+	return m.authorWrite(ctx, func(store memoryAuthorStore) error {
+		return applyMailmap(ctx, store, mm)
+	})
+}
+
+func (m *MemoryDB) GetBotRules(ctx context.Context) (bots.Rules, error) {
+	var rules bots.Rules
+	m.read(func(s *memoryState) {
+		rules, _ = memoryAuthorStore{s}.loadBotRules(ctx)
+	})
+	return rules, nil
+}
+
+func (m *MemoryDB) SetBotRules(ctx context.Context, rules bots.Rules) error {
+	return m.authorWrite(ctx, func(store memoryAuthorStore) error {
+		return applyBotRules(ctx, store, rules)
+	})
+}
+
+func (m *MemoryDB) ResolveCommitAuthors(ctx context.Context, batchSize int) (int, error) {
+	resolved := 0
+	err := m.authorWrite(ctx, func(store memoryAuthorStore) error {
+		var err error
+		resolved, err = resolveCommitAuthors(ctx, store, batchSize)
+		return err
+	})
+	return resolved, err
+}
@@ -0,0 +1,366 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_UpsertAndGetRepository(t *testing.T) {
+	m := NewMemoryDB()
+	ctx := context.Background()
+
+	repo := &models.Repository{Name: "test/repo", URL: "url"}
+	require.NoError(t, m.UpsertRepository(ctx, repo))
+	assert.Equal(t, 1, repo.ID)
+
+	repo.StarsCount = 7
+	require.NoError(t, m.UpsertRepository(ctx, repo))
+	assert.Equal(t, 1, repo.ID)
+
+	got, err := m.GetRepository(ctx, "test/repo")
+	require.NoError(t, err)
+	assert.Equal(t, 7, got.StarsCount)
+
+	// * Returned values are copies
+	got.StarsCount = 100
+	again, err := m.GetRepository(ctx, "test/repo")
+	require.NoError(t, err)
+	assert.Equal(t, 7, again.StarsCount)
+
+	_, err = m.GetRepository(ctx, "missing/repo")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestMemory_GetCommitsAndTopAuthors(t *testing.T) {
+	m := NewMemoryDB()
+	ctx := context.Background()
+
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(ctx, repo))
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, author := range []string{"alice", "bob", "alice"} {
+		require.NoError(t, m.InsertCommit(ctx, &models.Commit{
+			SHA:          fmt.Sprintf("sha%d", i),
+			RepositoryID: repo.ID,
+			AuthorName:   author,
+			AuthorDate:   base.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	// * Duplicate SHAs are ignored
+	require.NoError(t, m.InsertCommit(ctx, &models.Commit{SHA: "sha0", RepositoryID: repo.ID}))
+
+	commits := listCommits(t, m, "test/repo", models.CommitFilter{})
+	require.Len(t, commits, 3)
+	assert.Equal(t, "sha2", commits[0].SHA)
+
+	since := base.Add(30 * time.Minute)
+	commits = listCommits(t, m, "test/repo", models.CommitFilter{Since: &since})
+	assert.Len(t, commits, 2)
+
+	authors, err := m.GetTopAuthors(ctx, "test/repo", 1, true)
+	require.NoError(t, err)
+	assert.Equal(t, []models.AuthorCommitCount{{AuthorID: 1, AuthorName: "alice", CommitCount: 2}}, authors)
+}
+
+func TestMemory_WithTransactionRollsBackOnError(t *testing.T) {
+	m := NewMemoryDB()
+	ctx := context.Background()
+
+	err := m.WithTransaction(ctx, func(tx *sql.Tx) error {
+		repo := &models.Repository{Name: "test/repo"}
+		if err := m.UpsertRepositoryTx(ctx, tx, repo); err != nil {
+			return err
+		}
+		if err := m.InsertCommitTx(ctx, tx, &models.Commit{SHA: "abc", RepositoryID: repo.ID}); err != nil {
+			return err
+		}
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	repos, err := m.GetAllRepositories(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, repos)
+
+	err = m.WithTransaction(ctx, func(tx *sql.Tx) error {
+		repo := &models.Repository{Name: "test/repo"}
+		if err := m.UpsertRepositoryTx(ctx, tx, repo); err != nil {
+			return err
+		}
+
+		// * Uncommitted writes are not visible outside the transaction
+		_, err := m.GetRepository(ctx, "test/repo")
+		assert.Error(t, err)
+
+		return m.InsertCommitTx(ctx, tx, &models.Commit{SHA: "abc", RepositoryID: repo.ID})
+	})
+	require.NoError(t, err)
+
+	commits := listCommits(t, m, "test/repo", models.CommitFilter{})
+	assert.Len(t, commits, 1)
+}
+
+func TestMemory_WithTransactionRollsBackOnPanic(t *testing.T) {
+	m := NewMemoryDB()
+	ctx := context.Background()
+
+	assert.Panics(t, func() {
+		_ = m.WithTransaction(ctx, func(tx *sql.Tx) error {
+			_ = m.UpsertRepositoryTx(ctx, tx, &models.Repository{Name: "test/repo"})
+			panic("boom")
+		})
+	})
+
+	repos, err := m.GetAllRepositories(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, repos)
+
+	// * The database is still usable after the panic
+	require.NoError(t, m.UpsertRepository(ctx, &models.Repository{Name: "test/repo"}))
+}
+
+func TestMemory_TxMethodsRejectUnknownTransaction(t *testing.T) {
+	m := NewMemoryDB()
+
+	err := m.UpsertRepositoryTx(context.Background(), &sql.Tx{}, &models.Repository{Name: "test/repo"})
+	assert.ErrorIs(t, err, sql.ErrTxDone)
+}
+
+func TestMemory_ConcurrentTransactions(t *testing.T) {
+	m := NewMemoryDB()
+	ctx := context.Background()
+
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(ctx, repo))
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := m.WithTransaction(ctx, func(tx *sql.Tx) error {
+				return m.InsertCommitTx(ctx, tx, &models.Commit{SHA: fmt.Sprintf("sha%d", i), RepositoryID: repo.ID})
+			})
+			assert.NoError(t, err)
+			_, _ = m.GetCommits(ctx, "test/repo", models.CommitFilter{})
+		}()
+	}
+	wg.Wait()
+
+	commits := listCommits(t, m, "test/repo", models.CommitFilter{})
+	assert.Len(t, commits, 20)
+}
+
+func TestMemory_WritesDuringTransaction(t *testing.T) {
+	m := NewMemoryDB()
+	ctx := context.Background()
+	now := time.Now()
+
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(ctx, repo))
+	_, err := m.EnqueueJob(ctx, &models.Job{Kind: models.JobSync, RunAt: now, MaxAttempts: 1})
+	require.NoError(t, err)
+	job, err := m.ClaimJob(ctx, "worker", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, job)
+
+	lockedUntil := now.Add(time.Hour)
+	err = m.WithTransaction(ctx, func(tx *sql.Tx) error {
+		if err := m.InsertCommitTx(ctx, tx, &models.Commit{SHA: "a", RepositoryID: repo.ID}); err != nil {
+			return err
+		}
+
+		// * A heartbeat from another goroutine goes through while the
+		// * transaction is open
+		done := make(chan error, 1)
+		go func() { done <- m.ExtendJob(ctx, job.ID, "worker", lockedUntil) }()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("heartbeat blocked by the open transaction")
+		}
+
+		return m.RecordAuditTx(ctx, tx, &models.AuditEntry{Action: models.AuditRepositoryAdd, RepositoryName: "test/repo"})
+	})
+	require.NoError(t, err)
+
+	// * Committing keeps both the heartbeat and the transaction's writes
+	jobs, err := m.GetJobs(ctx, models.JobFilter{})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.NotNil(t, jobs[0].LockedUntil)
+	assert.True(t, lockedUntil.Equal(*jobs[0].LockedUntil))
+	assert.Len(t, listCommits(t, m, "test/repo", models.CommitFilter{}), 1)
+	entries, err := m.GetAuditLog(ctx, models.AuditFilter{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestMemory_InsertCommitsTx(t *testing.T) {
+	m := NewMemoryDB()
+	ctx := context.Background()
+
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(ctx, repo))
+	require.NoError(t, m.InsertCommit(ctx, &models.Commit{SHA: "a", RepositoryID: repo.ID}))
+
+	var result models.InsertResult
+	err := m.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		result, err = m.InsertCommitsTx(ctx, tx, []models.Commit{
+			{SHA: "a", RepositoryID: repo.ID},
+			{SHA: "b", RepositoryID: repo.ID},
+			{SHA: "b", RepositoryID: repo.ID},
+			{SHA: "c", RepositoryID: repo.ID},
+		})
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.InsertResult{Inserted: 2, Skipped: 2}, result)
+}
+
+func TestMemory_GetCommitsKeysetPagination(t *testing.T) {
+	m := NewMemoryDB()
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(context.Background(), repo))
+
+	assertKeysetPagination(t, m, repo.ID)
+}
+
+func TestMemory_SearchCommits(t *testing.T) {
+	m := NewMemoryDB()
+	ctx := context.Background()
+
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(ctx, repo))
+	for i, msg := range []string{"Fix crash on startup", "Fix crash, then fix it again", "Add feature"} {
+		require.NoError(t, m.InsertCommit(ctx, &models.Commit{SHA: fmt.Sprintf("sha%d", i), RepositoryID: repo.ID, Message: msg}))
+	}
+
+	q, err := search.Parse("fix crash")
+	require.NoError(t, err)
+
+	results, err := m.SearchCommits(ctx, models.CommitSearch{Query: q, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "sha1", results[0].SHA)
+	assert.Equal(t, "<mark>Fix</mark> <mark>crash</mark> on startup", results[1].Snippet)
+
+	results, err = m.SearchCommits(ctx, models.CommitSearch{Query: q, Repositories: []string{"other/repo"}, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestMemory_Retention(t *testing.T) {
+	m := NewMemoryDB()
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(context.Background(), repo))
+
+	assertRetention(t, m, repo.ID)
+}
+
+func TestMemory_Authors(t *testing.T) {
+	m := NewMemoryDB()
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(context.Background(), repo))
+
+	assertAuthors(t, m, repo.ID)
+}
+
+func TestMemory_Bots(t *testing.T) {
+	m := NewMemoryDB()
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(context.Background(), repo))
+
+	assertBots(t, m, repo.ID)
+}
+
+func TestMemory_Activity(t *testing.T) {
+	m := NewMemoryDB()
+	repo := &models.Repository{Name: "test/repo"}
+	other := &models.Repository{Name: "test/other"}
+	require.NoError(t, m.UpsertRepository(context.Background(), repo))
+	require.NoError(t, m.UpsertRepository(context.Background(), other))
+
+	assertActivity(t, m, repo.ID, other.ID)
+}
+
+func TestMemory_PunchCard(t *testing.T) {
+	m := NewMemoryDB()
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(context.Background(), repo))
+
+	assertPunchCard(t, m, repo.ID)
+}
+
+func TestMemory_RebuildDailyStats(t *testing.T) {
+	m := NewMemoryDB()
+	ctx := context.Background()
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(ctx, repo))
+
+	// * Two authors on one day and one of them again the next
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, c := range []struct {
+		name string
+		date time.Time
+	}{{"alice", day}, {"bob", day}, {"alice", day.Add(time.Hour)}, {"alice", day.AddDate(0, 0, 1)}} {
+		require.NoError(t, m.InsertCommit(ctx, &models.Commit{
+			SHA: fmt.Sprintf("c%d", i), RepositoryID: repo.ID, AuthorName: c.name, AuthorDate: c.date,
+		}))
+	}
+
+	n, err := m.RebuildDailyStats(ctx, "test/repo")
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	_, err = m.RebuildDailyStats(ctx, "missing/repo")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestMemory_RepositoryLifecycle(t *testing.T) {
+	m := NewMemoryDB()
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(context.Background(), repo))
+
+	assertRepositoryLifecycle(t, m, repo.ID)
+}
+
+func TestMemory_AuditLog(t *testing.T) {
+	assertAuditLog(t, NewMemoryDB())
+}
+
+func TestMemory_SyncRuns(t *testing.T) {
+	assertSyncRuns(t, NewMemoryDB())
+}
+
+func TestMemory_ResetJobs(t *testing.T) {
+	assertResetJobs(t, NewMemoryDB())
+}
+
+func TestMemory_JobQueue(t *testing.T) {
+	assertJobQueue(t, NewMemoryDB())
+}
+
+func TestMemory_Locks(t *testing.T) {
+	assertLocks(t, NewMemoryDB())
+}
+
+func TestMemory_Changelog(t *testing.T) {
+	m := NewMemoryDB()
+	repo := &models.Repository{Name: "test/repo"}
+	require.NoError(t, m.UpsertRepository(context.Background(), repo))
+
+	assertChangelog(t, m, repo.ID)
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// * Migrator moves a database between the schema versions embedded in the
+// * binary. Close it when done; the database connection stays open.
+type Migrator struct {
+	m      *migrate.Migrate
+	latest uint
+}
+
+// * SchemaVersion is where a database's schema stands relative to the
+// * migrations this binary ships with
+type SchemaVersion struct {
+	Version uint
+	Dirty   bool
+	Latest  uint
+}
+
+func newMigrator(migrations fs.FS, dir, dbName string, driver database.Driver) (*Migrator, error) {
+	src, err := iofs.New(migrations, dir)
+	if err != nil {
+		driver.Close()
+		return nil, errors.New(
+			"DB_MIGRATION_ERROR",
+			"Failed to load migrations",
+			"Could not read the migrations embedded in the binary",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	latest, err := latestVersion(src)
+	if err != nil {
+		driver.Close()
+		return nil, errors.New(
+			"DB_MIGRATION_ERROR",
+			"Failed to load migrations",
+			"Could not find the newest embedded migration",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, dbName, driver)
+	if err != nil {
+		driver.Close()
+		return nil, errors.New(
+			"DB_MIGRATION_ERROR",
+			"Failed to create migration instance",
+			"Could not create migration instance with database",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return &Migrator{m: m, latest: latest}, nil
+}
+
+func latestVersion(src source.Driver) (uint, error) {
+	v, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(v)
+		if stderrors.Is(err, os.ErrNotExist) {
+			return v, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		v = next
+	}
+}
+
+func migrationError(title string, err error) error {
+	return errors.New(
+		"DB_MIGRATION_ERROR",
+		title,
+		err.Error(),
+		err,
+		errors.LevelError,
+	)
+}
+
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+	return dbErr
+}
+
+// * Version reports the applied schema version; 0 means nothing is applied
+func (mg *Migrator) Version() (SchemaVersion, error) {
+	v, dirty, err := mg.m.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return SchemaVersion{}, migrationError("Failed to read schema version", err)
+	}
+	return SchemaVersion{Version: v, Dirty: dirty, Latest: mg.latest}, nil
+}
+
+// * Check refuses a schema this binary cannot safely run against: one left
+// * dirty by a failed migration, or one migrated by a newer release
+func (mg *Migrator) Check() error {
+	v, err := mg.Version()
+	if err != nil {
+		return err
+	}
+
+	if v.Dirty {
+		return errors.New(
+			"DB_SCHEMA_DIRTY",
+			"Database schema is dirty",
+			fmt.Sprintf("Migration %d failed part way; fix the schema by hand, then run 'migrate force %d' (or the previous version)", v.Version, v.Version),
+			nil,
+			errors.LevelFatal,
+		)
+	}
+
+	if v.Version > v.Latest {
+		return errors.New(
+			"DB_SCHEMA_TOO_NEW",
+			"Database schema is newer than this binary",
+			fmt.Sprintf("Schema is at version %d but this binary only knows up to %d; upgrade the binary or migrate down with a newer one", v.Version, v.Latest),
+			nil,
+			errors.LevelFatal,
+		)
+	}
+
+	return nil
+}
+
+// * Up applies every pending migration
+func (mg *Migrator) Up() error {
+	if err := mg.m.Up(); err != nil && err != migrate.ErrNoChange {
+		return migrationError("Failed to run migrations", err)
+	}
+	return nil
+}
+
+// * Down rolls back the n most recent migrations
+func (mg *Migrator) Down(n int) error {
+	if n < 1 {
+		return migrationError("Failed to roll back migrations", fmt.Errorf("number of migrations to roll back must be positive, got %d", n))
+	}
+	if err := mg.m.Steps(-n); err != nil && err != migrate.ErrNoChange {
+		return migrationError("Failed to roll back migrations", err)
+	}
+	return nil
+}
+
+// * Goto migrates up or down to version; 0 rolls back everything
+func (mg *Migrator) Goto(version uint) error {
+	if version > mg.latest {
+		return migrationError("Failed to migrate", fmt.Errorf("version %d is newer than the latest embedded migration %d", version, mg.latest))
+	}
+
+	var err error
+	if version == 0 {
+		err = mg.m.Down()
+	} else {
+		err = mg.m.Migrate(version)
+	}
+	if err != nil && err != migrate.ErrNoChange {
+		return migrationError("Failed to migrate", err)
+	}
+	return nil
+}
+
+// * Force records version as applied and clean without running anything,
+// * for recovering from a dirty schema. -1 means no version.
+func (mg *Migrator) Force(version int) error {
+	if err := mg.m.Force(version); err != nil {
+		return migrationError("Failed to force schema version", err)
+	}
+	return nil
+}
+
+// * checkAndMigrate is the startup path shared by the SQL implementations
+func checkAndMigrate(mg *Migrator, err error) error {
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	if err := mg.Check(); err != nil {
+		return err
+	}
+	return mg.Up()
+}
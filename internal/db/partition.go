@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+	"github.com/lib/pq"
+)
+
+// * partitionMonthsAhead is how many months after the current one get a
+// * partition in advance, so new commits never land in commits_default
+const partitionMonthsAhead = 3
+
+// * commitPartitionName matches the partitions create_commit_partition makes
+var commitPartitionName = regexp.MustCompile(`^commits_(\d{4})_(\d{2})$`)
+
+// * commitPartition is one monthly partition of commits, covering
+// * [lower, upper) in UTC
+type commitPartition struct {
+	name  string
+	lower time.Time
+	upper time.Time
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func partitionError(title, detail string, err error) error {
+	return errors.New("DB_PARTITION_ERROR", title, detail, err, errors.LevelError)
+}
+
+// * MaintainPartitions creates the partitions for the current month and the
+// * next partitionMonthsAhead, then gives every month found in
+// * commits_default a partition of its own, which moves its rows there
+func (p *PostgresDB) MaintainPartitions(ctx context.Context, now time.Time) error {
+	var months []time.Time
+	for i := range partitionMonthsAhead + 1 {
+		months = append(months, monthStart(now).AddDate(0, i, 0))
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT DISTINCT date_trunc('month', author_date AT TIME ZONE 'UTC') FROM commits_default
+	`)
+	if err != nil {
+		return partitionError("Failed to inspect default partition", "Could not list months stored in commits_default", err)
+	}
+	defer rows.Close()
+
+	stray := 0
+	for rows.Next() {
+		var month time.Time
+		if err := rows.Scan(&month); err != nil {
+			return partitionError("Failed to inspect default partition", "Error while scanning month row", err)
+		}
+		months = append(months, monthStart(month))
+		stray++
+	}
+	if err := rows.Err(); err != nil {
+		return partitionError("Failed to inspect default partition", "Error while processing month rows", err)
+	}
+
+	for _, month := range months {
+		if _, err := p.db.ExecContext(ctx, `SELECT create_commit_partition($1)`, month); err != nil {
+			return partitionError(
+				"Failed to create partition",
+				fmt.Sprintf("Could not create the commits partition for %s", month.Format("2006-01")),
+				err,
+			)
+		}
+	}
+
+	if stray > 0 {
+		logger.Info("Moved commits for %d months out of the default partition", stray)
+	}
+	return nil
+}
+
+// * commitPartitions lists the monthly partitions of commits, oldest first
+func commitPartitions(ctx context.Context, q queryer) ([]commitPartition, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'commits'::regclass
+		ORDER BY c.relname
+	`)
+	if err != nil {
+		return nil, partitionError("Failed to list partitions", "Could not list the partitions of commits", err)
+	}
+	defer rows.Close()
+
+	var partitions []commitPartition
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, partitionError("Failed to list partitions", "Error while scanning partition row", err)
+		}
+
+		m := commitPartitionName.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		lower := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		partitions = append(partitions, commitPartition{name: name, lower: lower, upper: lower.AddDate(0, 1, 0)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, partitionError("Failed to list partitions", "Error while processing partition rows", err)
+	}
+	return partitions, nil
+}
+
+// * DropExpiredPartitions detaches and drops every month whose commits all
+// * fall outside their repository's retention policy, which is far cheaper
+// * than deleting them row by row. A month is only dropped when every
+// * repository with commits in it has a max_age_days cutoff past the month's
+// * end. It returns the number of commits dropped.
+func (p *PostgresDB) DropExpiredPartitions(ctx context.Context, now time.Time) (int, error) {
+	partitions, err := commitPartitions(ctx, p.db)
+	if err != nil {
+		return 0, err
+	}
+
+	policies, err := queryRetentionPolicies(ctx, p.db, selectRetentionPolicies)
+	if err != nil {
+		return 0, err
+	}
+	byRepo := make(map[int]models.RetentionPolicy, len(policies))
+	for _, policy := range policies {
+		byRepo[policy.RepositoryID] = policy
+	}
+
+	dropped := 0
+	for _, part := range partitions {
+		if part.upper.After(now) {
+			break
+		}
+
+		err := p.WithTransaction(ctx, func(tx *sql.Tx) error {
+			n, err := dropPartitionIfExpired(ctx, tx, part, byRepo, now)
+			dropped += n
+			return err
+		})
+		if err != nil {
+			return dropped, err
+		}
+	}
+	return dropped, nil
+}
+
+// * dropPartitionIfExpired locks part so no commit can arrive between the
+// * check and the drop, and reports how many commits it dropped
+func dropPartitionIfExpired(ctx context.Context, tx *sql.Tx, part commitPartition, policies map[int]models.RetentionPolicy, now time.Time) (int, error) {
+	table := pq.QuoteIdentifier(part.name)
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE `+table+` IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return 0, partitionError("Failed to lock partition", fmt.Sprintf("Could not lock partition %s", part.name), err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT repository_id, COUNT(*) FROM `+table+` GROUP BY repository_id`)
+	if err != nil {
+		return 0, partitionError("Failed to inspect partition", fmt.Sprintf("Could not count commits in partition %s", part.name), err)
+	}
+	defer rows.Close()
+
+	commits := 0
+	expired := true
+	for rows.Next() {
+		var repoID, n int
+		if err := rows.Scan(&repoID, &n); err != nil {
+			return 0, partitionError("Failed to inspect partition", "Error while scanning partition count row", err)
+		}
+		commits += n
+
+		policy, ok := policies[repoID]
+		cutoff := policy.Cutoff(now)
+		if !ok || cutoff == nil || cutoff.Before(part.upper) {
+			expired = false
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, partitionError("Failed to inspect partition", "Error while processing partition count rows", err)
+	}
+
+	// * Empty past months are kept; a late sync may still fill them
+	if !expired || commits == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE commits DETACH PARTITION `+table); err != nil {
+		return 0, partitionError("Failed to detach partition", fmt.Sprintf("Could not detach partition %s", part.name), err)
+	}
+	if _, err := tx.ExecContext(ctx, `DROP TABLE `+table); err != nil {
+		return 0, partitionError("Failed to drop partition", fmt.Sprintf("Could not drop partition %s", part.name), err)
+	}
+
+	// * Dropping a table fires no row triggers, so the month's daily stats
+	// * are removed here
+	_, err = tx.ExecContext(ctx, `DELETE FROM commit_daily_stats WHERE day >= $1 AND day < $2`,
+		part.lower.Format(time.DateOnly), part.upper.Format(time.DateOnly))
+	if err != nil {
+		return 0, partitionError("Failed to drop partition", fmt.Sprintf("Could not remove daily stats of partition %s", part.name), err)
+	}
+
+	logger.Info("Dropped expired partition %s with %d commits", part.name, commits)
+	return commits, nil
+}
@@ -6,20 +6,97 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/mailmap"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/search"
+	"github.com/KOFI-GYIMAH/github-monitor/migrations"
 	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
 	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
-	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// * PostgresDB writes to the primary and, when replicas are configured,
+// * serves GetRepository, GetAllRepositories, GetCommits, GetTopAuthors,
+// * SearchCommits, GetCommitActivity and GetPunchCard from them. Transactions always stay on the primary.
 type PostgresDB struct {
-	db *sql.DB
+	db       *sql.DB
+	locks    *sql.DB
+	replicas *replicaSet
 }
 
-func NewPostgresDB(url string) (*PostgresDB, error) {
+// * NewPostgresDB connects to the primary at url and to any read replicas.
+// * An unreachable replica does not fail startup; it is health-checked and
+// * joins the rotation once it answers.
+func NewPostgresDB(url string, replicaURLs ...string) (*PostgresDB, error) {
+	db, err := openPostgres(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// * Advisory locks pin a connection each for as long as they are held,
+	// * while the work done under them needs connections of its own. Taking
+	// * them from a separate pool keeps lock holders from starving that work.
+	locks, err := sql.Open("postgres", url)
+	if err != nil {
+		db.Close()
+		return nil, errors.New(
+			"DB_CONNECTION_ERROR",
+			"Failed to open lock connection",
+			"Could not initialize the advisory lock connection pool",
+			err,
+			errors.LevelError,
+		)
+	}
+	locks.SetMaxOpenConns(LockPoolSize)
+	locks.SetMaxIdleConns(2)
+	locks.SetConnMaxLifetime(5 * time.Minute)
+
+	logger.Info("connected to database successfully 🎉")
+	p := &PostgresDB{db: db, locks: locks}
+
+	if len(replicaURLs) == 0 {
+		return p, nil
+	}
+
+	var replicas []*replica
+	for i, replicaURL := range replicaURLs {
+		r := &replica{name: fmt.Sprintf("replica %d", i+1)}
+		r.db, err = sql.Open("postgres", replicaURL)
+		if err != nil {
+			for _, opened := range replicas {
+				opened.db.Close()
+			}
+			locks.Close()
+			db.Close()
+			return nil, errors.New(
+				"DB_CONNECTION_ERROR",
+				"Failed to open replica connection",
+				fmt.Sprintf("Could not initialize connection to %s", r.name),
+				err,
+				errors.LevelError,
+			)
+		}
+		configurePool(r.db)
+		replicas = append(replicas, r)
+	}
+
+	p.replicas = newReplicaSet(replicas)
+	p.replicas.check(context.Background())
+	go p.replicas.run(replicaCheckInterval)
+
+	logger.Info("routing reads to %d replica(s)", len(replicas))
+	return p, nil
+}
+
+func configurePool(db *sql.DB) {
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(25)
+	db.SetConnMaxLifetime(5 * time.Minute)
+}
+
+func openPostgres(url string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", url)
 	if err != nil {
 		return nil, errors.New(
@@ -32,9 +109,7 @@ func NewPostgresDB(url string) (*PostgresDB, error) {
 	}
 
 	// * Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	configurePool(db)
 
 	// * Verify connection
 	if err := db.Ping(); err != nil {
@@ -47,49 +122,55 @@ func NewPostgresDB(url string) (*PostgresDB, error) {
 		)
 	}
 
-	logger.Info("connected to database successfully 🎉")
-	return &PostgresDB{db: db}, nil
+	return db, nil
 }
 
-func (p *PostgresDB) Migrate() error {
-	driver, err := postgres.WithInstance(p.db, &postgres.Config{})
+// * Migrator returns a Migrator over the embedded Postgres migrations. It
+// * holds one pooled connection until closed.
+func (p *PostgresDB) Migrator() (*Migrator, error) {
+	ctx := context.Background()
+	conn, err := p.db.Conn(ctx)
 	if err != nil {
-		return errors.New(
+		return nil, errors.New(
 			"DB_MIGRATION_ERROR",
 			"Failed to create migration driver",
-			"Could not initialize migration driver instance",
+			"Could not reserve a connection for migrations",
 			err,
 			errors.LevelError,
 		)
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		"postgres", driver)
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
 	if err != nil {
-		return errors.New(
+		conn.Close()
+		return nil, errors.New(
 			"DB_MIGRATION_ERROR",
-			"Failed to create migration instance",
-			"Could not create migration instance with database",
+			"Failed to create migration driver",
+			"Could not initialize migration driver instance",
 			err,
 			errors.LevelError,
 		)
 	}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return errors.New(
-			"DB_MIGRATION_ERROR",
-			"Failed to run migrations",
-			"Migration up operation failed",
-			err,
-			errors.LevelError,
-		)
-	}
+	return newMigrator(migrations.Postgres, ".", "postgres", driver)
+}
 
-	return nil
+// * Migrate refuses a dirty or too-new schema, then applies pending migrations
+func (p *PostgresDB) Migrate() error {
+	return checkAndMigrate(p.Migrator())
 }
 
 func (p *PostgresDB) Close() error {
+	if err := p.replicas.close(); err != nil {
+		logger.Warn("failed to close replica connections: %v", err)
+	}
+
+	if p.locks != nil {
+		if err := p.locks.Close(); err != nil {
+			logger.Warn("failed to close lock connections: %v", err)
+		}
+	}
+
 	if err := p.db.Close(); err != nil {
 		return errors.New(
 			"DB_CONNECTION_ERROR",
@@ -162,6 +243,7 @@ func (p *PostgresDB) UpsertRepository(ctx context.Context, repo *models.Reposito
 			open_issues_count = EXCLUDED.open_issues_count,
 			watchers_count = EXCLUDED.watchers_count,
 			updated_at = EXCLUDED.updated_at
+		WHERE repositories.status = 'active'
 		RETURNING id, last_commit_fetched_at
 	`
 
@@ -173,6 +255,10 @@ func (p *PostgresDB) UpsertRepository(ctx context.Context, repo *models.Reposito
 
 	var lastFetched sql.NullTime
 	err := row.Scan(&repo.ID, &lastFetched)
+	if err == sql.ErrNoRows {
+		// * The update is skipped for archived and deleted repositories
+		return repositoryInactive(repo.Name)
+	}
 	if err != nil {
 		return errors.New(
 			"DB_REPOSITORY_ERROR",
@@ -183,6 +269,7 @@ func (p *PostgresDB) UpsertRepository(ctx context.Context, repo *models.Reposito
 		)
 	}
 
+	repo.Status = models.RepositoryActive
 	if lastFetched.Valid {
 		repo.LastCommitFetchedAt = &lastFetched.Time
 	}
@@ -191,22 +278,33 @@ func (p *PostgresDB) UpsertRepository(ctx context.Context, repo *models.Reposito
 }
 
 func (p *PostgresDB) GetRepository(ctx context.Context, name string) (*models.Repository, error) {
+	var repo *models.Repository
+	err := p.read(ctx, func(q queryer) error {
+		var err error
+		repo, err = getPostgresRepository(ctx, q, name)
+		return err
+	})
+	return repo, err
+}
+
+func getPostgresRepository(ctx context.Context, q queryer, name string) (*models.Repository, error) {
 	query := `
 		SELECT id, name, description, url, language, forks_count, stars_count,
-		open_issues_count, watchers_count, created_at, updated_at, last_commit_fetched_at
+		open_issues_count, watchers_count, created_at, updated_at, last_commit_fetched_at,
+		status, status_changed_at
 		FROM repositories
 		WHERE name = $1
 	`
 
-	row := p.db.QueryRowContext(ctx, query, name)
+	row := q.QueryRowContext(ctx, query, name)
 
 	var repo models.Repository
-	var lastFetched sql.NullTime
+	var lastFetched, statusChanged sql.NullTime
 
 	err := row.Scan(
 		&repo.ID, &repo.Name, &repo.Description, &repo.URL, &repo.Language,
 		&repo.ForksCount, &repo.StarsCount, &repo.OpenIssuesCount, &repo.WatchersCount,
-		&repo.CreatedAt, &repo.UpdatedAt, &lastFetched,
+		&repo.CreatedAt, &repo.UpdatedAt, &lastFetched, &repo.Status, &statusChanged,
 	)
 
 	if err != nil {
@@ -231,12 +329,25 @@ func (p *PostgresDB) GetRepository(ctx context.Context, name string) (*models.Re
 	if lastFetched.Valid {
 		repo.LastCommitFetchedAt = &lastFetched.Time
 	}
+	if statusChanged.Valid {
+		repo.StatusChangedAt = &statusChanged.Time
+	}
 
 	return &repo, nil
 }
 
 func (s *PostgresDB) GetAllRepositories(ctx context.Context) ([]*models.Repository, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT name FROM repositories`)
+	var repos []*models.Repository
+	err := s.read(ctx, func(q queryer) error {
+		var err error
+		repos, err = getAllPostgresRepositories(ctx, q)
+		return err
+	})
+	return repos, err
+}
+
+func getAllPostgresRepositories(ctx context.Context, q queryer) ([]*models.Repository, error) {
+	rows, err := q.QueryContext(ctx, `SELECT name, status FROM repositories WHERE status <> 'deleted'`)
 	if err != nil {
 		return nil, err
 	}
@@ -245,7 +356,7 @@ func (s *PostgresDB) GetAllRepositories(ctx context.Context) ([]*models.Reposito
 	var repos []*models.Repository
 	for rows.Next() {
 		var r models.Repository
-		if err := rows.Scan(&r.Name); err != nil {
+		if err := rows.Scan(&r.Name, &r.Status); err != nil {
 			return nil, errors.New(
 				"DB_REPOSITORY_ERROR",
 				"Failed to fetch repository",
@@ -280,187 +391,377 @@ func (p *PostgresDB) UpdateRepository(ctx context.Context, repo *models.Reposito
 	return nil
 }
 
+// * InsertCommit resolves the commit author and stores both in one
+// * transaction
 func (p *PostgresDB) InsertCommit(ctx context.Context, commit *models.Commit) error {
-	query := `
-		INSERT INTO commits (
-			sha, repository_id, message, author_name, author_email, author_date, commit_url
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT(sha, repository_id) DO NOTHING
-	`
+	return p.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return insertSQLCommit(ctx, tx, commit, func(t time.Time) time.Time { return t })
+	})
+}
 
-	_, err := p.db.ExecContext(ctx, query,
-		commit.SHA, commit.RepositoryID, commit.Message, commit.AuthorName,
-		commit.AuthorEmail, commit.AuthorDate, commit.CommitURL,
-	)
+// * GetCommits returns one page of commits, newest first, using keyset
+// * pagination on (author_date, id) so deep pages cost the same as the first
+func (p *PostgresDB) GetCommits(ctx context.Context, repoName string, filter models.CommitFilter) (*models.CommitPage, error) {
+	var page *models.CommitPage
+	err := p.read(ctx, func(q queryer) error {
+		var err error
+		page, err = queryCommitPage(ctx, q, repoName, filter, func(t time.Time) time.Time { return t })
+		return err
+	})
+	return page, err
+}
 
-	if err != nil {
-		return errors.New(
-			"DB_COMMIT_ERROR",
-			"Failed to insert commit",
-			fmt.Sprintf("Could not insert commit '%s' for repository '%d'", commit.SHA, commit.RepositoryID),
-			err,
-			errors.LevelError,
-		)
-	}
+func (p *PostgresDB) GetTopAuthors(ctx context.Context, repoName string, limit int, includeBots bool) ([]models.AuthorCommitCount, error) {
+	var authors []models.AuthorCommitCount
+	err := p.read(ctx, func(q queryer) error {
+		var err error
+		authors, err = queryTopAuthors(ctx, q, repoName, limit, includeBots)
+		return err
+	})
+	return authors, err
+}
 
-	return nil
+// * FindCommit looks a commit up by SHA or by a prefix of it
+func (p *PostgresDB) FindCommit(ctx context.Context, repoName, shaPrefix string) (*models.Commit, error) {
+	var commit *models.Commit
+	err := p.read(ctx, func(q queryer) error {
+		var err error
+		commit, err = queryCommitBySHA(ctx, q, repoName, shaPrefix)
+		return err
+	})
+	return commit, err
 }
 
-func (p *PostgresDB) GetCommits(ctx context.Context, repoName string, since, until *time.Time) ([]models.Commit, error) {
-	query := `
-		SELECT c.sha, c.repository_id, c.message, c.author_name, c.author_email, 
-					c.author_date, c.commit_url
-		FROM commits c
-		JOIN repositories r ON c.repository_id = r.id
-		WHERE r.name = $1
-	`
+func (p *PostgresDB) GetChangelogCommits(ctx context.Context, repoName string, filter models.ChangelogFilter) ([]models.ChangelogCommit, error) {
+	var commits []models.ChangelogCommit
+	err := p.read(ctx, func(q queryer) error {
+		var err error
+		commits, err = queryChangelogCommits(ctx, q, repoName, filter, func(t time.Time) time.Time { return t })
+		return err
+	})
+	return commits, err
+}
 
-	args := []any{repoName}
-	paramCount := 1
+// * GetCommitActivity buckets commits in SQL: date_trunc on the local time
+// * of each commit yields the bucket's wall clock start in filter.Location
+func (p *PostgresDB) GetCommitActivity(ctx context.Context, filter models.ActivityFilter) ([]models.ActivityCount, error) {
+	query, args := buildActivityQuery(filter, "date_trunc($1, c.author_date AT TIME ZONE $2)",
+		[]any{string(filter.Interval), filter.Location.String()}, func(t time.Time) time.Time { return t })
+
+	var counts []models.ActivityCount
+	err := p.read(ctx, func(q queryer) error {
+		var err error
+		counts, err = queryActivity(ctx, q, query, args, func(t time.Time) time.Time {
+			return filter.Interval.Start(wallClock(t, filter.Location))
+		})
+		return err
+	})
+	return counts, err
+}
 
-	if since != nil {
-		paramCount++
-		query += fmt.Sprintf(" AND c.author_date >= $%d", paramCount)
-		args = append(args, *since)
+// * GetPunchCard counts commits per ISO weekday and hour in SQL. Commits
+// * stored before their offset was kept count as UTC in the author's zone.
+func (p *PostgresDB) GetPunchCard(ctx context.Context, filter models.PunchCardFilter) (models.PunchCardCounts, error) {
+	local, localArgs := "c.author_date AT TIME ZONE $1", []any{filter.Location.String()}
+	if filter.AuthorOffset {
+		local = "(c.author_date AT TIME ZONE 'UTC' + make_interval(secs => COALESCE(c.author_utc_offset, 0)))"
+		localArgs = nil
 	}
+	query, args := buildPunchCardQuery(filter,
+		fmt.Sprintf("EXTRACT(ISODOW FROM %s)::int - 1, EXTRACT(HOUR FROM %s)::int, COUNT(*)", local, local), localArgs,
+		func(t time.Time) time.Time { return t })
 
-	if until != nil {
-		paramCount++
-		query += fmt.Sprintf(" AND c.author_date <= $%d", paramCount)
-		args = append(args, *until)
-	}
+	var counts models.PunchCardCounts
+	err := p.read(ctx, func(q queryer) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return analyticsError("Failed to query punch card", "Could not count commits per weekday and hour", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var day, hour, n int
+			if err := rows.Scan(&day, &hour, &n); err != nil {
+				return analyticsError("Failed to scan punch card", "Error while scanning punch card row", err)
+			}
+			counts[day][hour] += n
+		}
+		if err := rows.Err(); err != nil {
+			return analyticsError("Failed to process punch card", "Error while processing punch card rows", err)
+		}
+		return nil
+	})
+	return counts, err
+}
 
-	query += " ORDER BY c.author_date DESC"
+// * headlineOptions controls the snippets returned by SearchCommits. Matches
+// * are delimited for search.Snippet, which escapes the rest of the message.
+const headlineOptions = "StartSel=" + search.SnippetStart + ", StopSel=" + search.SnippetStop + ", MaxFragments=2, MaxWords=24, MinWords=8"
 
-	rows, err := p.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, errors.New(
-			"DB_COMMIT_ERROR",
-			"Failed to query commits",
-			fmt.Sprintf("Could not fetch commits for repository '%s'", repoName),
-			err,
-			errors.LevelError,
-		)
+// * SearchCommits ranks commits whose message matches the query using the
+// * message_tsv GIN index. Snippets are only built for the returned page.
+func (p *PostgresDB) SearchCommits(ctx context.Context, search models.CommitSearch) ([]models.CommitSearchResult, error) {
+	args := []any{search.Query.TSQuery()}
+	where := "c.message_tsv @@ q AND r.status <> 'deleted'"
+
+	if len(search.Repositories) > 0 {
+		args = append(args, pq.Array(search.Repositories))
+		where += fmt.Sprintf(" AND r.name = ANY($%d)", len(args))
+	}
+	if search.ExcludeBots {
+		where += excludeBotCommits
 	}
-	defer rows.Close()
 
-	var commits []models.Commit
-	for rows.Next() {
-		var c models.Commit
-		err := rows.Scan(
-			&c.SHA, &c.RepositoryID, &c.Message, &c.AuthorName,
-			&c.AuthorEmail, &c.AuthorDate, &c.CommitURL,
-		)
+	args = append(args, search.Limit)
+	limitParam := len(args)
+	args = append(args, headlineOptions)
+	headlineParam := len(args)
+
+	query := fmt.Sprintf(`
+		SELECT m.id, m.sha, m.repository_id, m.message, m.author_name, m.author_email,
+					m.author_date, m.commit_url, COALESCE(m.author_login, ''), m.author_id, m.repository_name, m.rank,
+					ts_headline('english', m.message, m.q, $%d) AS snippet
+		FROM (
+			SELECT c.*, r.name AS repository_name, q, ts_rank_cd(c.message_tsv, q) AS rank
+			FROM commits c
+			JOIN repositories r ON c.repository_id = r.id
+			CROSS JOIN to_tsquery('english', $1) AS q
+			WHERE %s
+			ORDER BY rank DESC, c.author_date DESC
+			LIMIT $%d
+		) m
+		ORDER BY m.rank DESC, m.author_date DESC
+	`, headlineParam, where, limitParam)
+
+	var results []models.CommitSearchResult
+	err := p.read(ctx, func(q queryer) error {
+		rows, err := q.QueryContext(ctx, query, args...)
 		if err != nil {
-			return nil, errors.New(
-				"DB_COMMIT_ERROR",
-				"Failed to scan commit",
-				"Error while scanning commit row",
+			return errors.New(
+				"DB_SEARCH_ERROR",
+				"Failed to search commits",
+				"Could not run full-text search over commit messages",
 				err,
 				errors.LevelError,
 			)
 		}
-		commits = append(commits, c)
-	}
+		defer rows.Close()
 
-	if err := rows.Err(); err != nil {
-		return nil, errors.New(
-			"DB_COMMIT_ERROR",
-			"Failed to process commits",
-			"Error while processing commit rows",
-			err,
-			errors.LevelError,
-		)
-	}
+		results, err = scanSearchResults(rows)
+		return err
+	})
+	return results, err
+}
 
-	return commits, nil
+// * SetRepositoryStatus archives, soft-deletes or restores a repository.
+// * It waits for a sync that holds the repository row, and syncs started
+// * afterwards stop at the upsert.
+func (p *PostgresDB) SetRepositoryStatus(ctx context.Context, name string, status models.RepositoryStatus) (*models.Repository, error) {
+	var repo *models.Repository
+	err := p.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		repo, err = p.SetRepositoryStatusTx(ctx, tx, name, status)
+		return err
+	})
+	return repo, err
 }
 
-func (p *PostgresDB) GetTopAuthors(ctx context.Context, repoName string, limit int) ([]models.AuthorCommitCount, error) {
-	query := `
-		SELECT c.author_name, COUNT(*) as commit_count 
-		FROM commits c
-		JOIN repositories r ON c.repository_id = r.id 
-		WHERE r.name = $1 
-		GROUP BY c.author_name 
-		ORDER BY commit_count DESC 
-		LIMIT $2
-	`
+func (p *PostgresDB) DeleteRepository(ctx context.Context, name string) error {
+	return deleteSQLRepository(ctx, p.db, name)
+}
 
-	rows, err := p.db.QueryContext(ctx, query, repoName, limit)
-	if err != nil {
-		return nil, errors.New(
-			"DB_AUTHOR_ERROR",
-			"Failed to query top authors",
-			fmt.Sprintf("Could not fetch top authors for repository '%s'", repoName),
-			err,
-			errors.LevelError,
-		)
-	}
-	defer rows.Close()
+// * RecordAudit appends an audit entry outside any transaction, for actions
+// * that failed or ran their own
+func (p *PostgresDB) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	return insertSQLAudit(ctx, p.db, entry, func(t time.Time) time.Time { return t })
+}
 
-	var results []models.AuthorCommitCount
-	for rows.Next() {
-		var acc models.AuthorCommitCount
-		err := rows.Scan(&acc.AuthorName, &acc.CommitCount)
-		if err != nil {
-			return nil, errors.New(
-				"DB_AUTHOR_ERROR",
-				"Failed to scan author commit count",
-				"Error while scanning author commit count row",
-				err,
-				errors.LevelError,
-			)
+// * GetAuditLog returns audit entries matching filter, newest first
+func (p *PostgresDB) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	return querySQLAuditLog(ctx, p.db, filter, func(t time.Time) time.Time { return t })
+}
+
+// * StartSyncRun records that a sync has started. It runs outside the sync's
+// * transaction so the run is kept even when the sync rolls back.
+func (p *PostgresDB) StartSyncRun(ctx context.Context, run *models.SyncRun) error {
+	return insertSQLSyncRun(ctx, p.db, run, func(t time.Time) time.Time { return t })
+}
+
+// * FinishSyncRun records the outcome of a started sync
+func (p *PostgresDB) FinishSyncRun(ctx context.Context, run *models.SyncRun) error {
+	return finishSQLSyncRun(ctx, p.db, run, func(t time.Time) time.Time { return t })
+}
+
+func (p *PostgresDB) CreateResetJob(ctx context.Context, job *models.ResetJob) error {
+	return insertSQLResetJob(ctx, p.db, job, func(t time.Time) time.Time { return t })
+}
+
+func (p *PostgresDB) UpdateResetJob(ctx context.Context, job *models.ResetJob) error {
+	return updateSQLResetJob(ctx, p.db, job, func(t time.Time) time.Time { return t })
+}
+
+func (p *PostgresDB) GetResetJob(ctx context.Context, id int) (*models.ResetJob, error) {
+	return getSQLResetJob(ctx, p.db, id)
+}
+
+// * TryLock takes a session advisory lock on the primary, shared by every
+// * replica of the service, on a connection from the lock pool
+func (p *PostgresDB) TryLock(ctx context.Context, name string) (models.Lock, error) {
+	return tryAdvisoryLock(ctx, p.locks, name)
+}
+
+// * EnqueueJob queues job unless a job with its dedupe key is pending
+func (p *PostgresDB) EnqueueJob(ctx context.Context, job *models.Job) (bool, error) {
+	return enqueueSQLJob(ctx, p.db, job, func(t time.Time) time.Time { return t })
+}
+
+// * ClaimJob locks the next due job for worker. Concurrent claims skip each
+// * other's rows instead of waiting on them, so replicas never share a job.
+func (p *PostgresDB) ClaimJob(ctx context.Context, worker string, now, lockedUntil time.Time) (*models.Job, error) {
+	return claimSQLJob(ctx, p.db, worker, now, lockedUntil, "FOR UPDATE SKIP LOCKED", func(t time.Time) time.Time { return t })
+}
+
+func (p *PostgresDB) ExtendJob(ctx context.Context, id int, worker string, lockedUntil time.Time) error {
+	return extendSQLJob(ctx, p.db, id, worker, lockedUntil, func(t time.Time) time.Time { return t })
+}
+
+func (p *PostgresDB) CompleteJob(ctx context.Context, id int, worker string) error {
+	return completeSQLJob(ctx, p.db, id, worker, func(t time.Time) time.Time { return t })
+}
+
+func (p *PostgresDB) FailJob(ctx context.Context, id int, worker, message string, retryAt *time.Time) error {
+	return failSQLJob(ctx, p.db, id, worker, message, retryAt, func(t time.Time) time.Time { return t })
+}
+
+func (p *PostgresDB) DeferJob(ctx context.Context, id int, worker string, runAt time.Time) error {
+	return deferSQLJob(ctx, p.db, id, worker, runAt, func(t time.Time) time.Time { return t })
+}
+
+// * GetJobs returns jobs matching filter, newest first. It reads the primary
+// * so a job is visible as soon as it is enqueued.
+func (p *PostgresDB) GetJobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	return querySQLJobs(ctx, p.db, filter)
+}
+
+// * RebuildDailyStats recomputes commit_daily_stats from commits for
+// * repoName, or for every repository when it is empty. Commit writes wait
+// * until the rebuild commits so none is counted twice or missed.
+func (p *PostgresDB) RebuildDailyStats(ctx context.Context, repoName string) (int, error) {
+	var n int
+	err := p.WithTransaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `LOCK TABLE commits IN SHARE MODE`); err != nil {
+			return dailyStatsError("Failed to rebuild daily stats", "Could not lock commits", err)
 		}
-		results = append(results, acc)
-	}
+		var err error
+		n, err = rebuildSQLDailyStats(ctx, tx, repoName, "(c.author_date AT TIME ZONE 'UTC')::date")
+		return err
+	})
+	return n, err
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, errors.New(
-			"DB_AUTHOR_ERROR",
-			"Failed to process top authors",
-			"Error while processing author rows",
-			err,
-			errors.LevelError,
-		)
-	}
+// * GetSyncRuns returns sync runs matching filter, newest first
+func (p *PostgresDB) GetSyncRuns(ctx context.Context, filter models.SyncRunFilter) ([]models.SyncRun, error) {
+	return querySQLSyncRuns(ctx, p.db, filter)
+}
 
-	return results, nil
+func (p *PostgresDB) ResetRepository(ctx context.Context, repoName string, since time.Time) (int, error) {
+	return resetSQLRepository(ctx, p.db, repoName, since, func(t time.Time) time.Time { return t })
 }
 
-func (p *PostgresDB) ResetRepository(ctx context.Context, repoName string, since time.Time) error {
-	_, err := p.db.ExecContext(ctx, `
-		DELETE FROM commits 
-		WHERE repository_id = (
-			SELECT id FROM repositories WHERE name = $1
-		)
-	`, repoName)
-	if err != nil {
-		return errors.New(
-			"DB_RESET_ERROR",
-			"Failed to delete commits",
-			fmt.Sprintf("Could not delete commits for repository '%s'", repoName),
-			err,
-			errors.LevelError,
-		)
-	}
+func (p *PostgresDB) SetRetentionPolicy(ctx context.Context, repoName string, policy models.RetentionPolicy) (*models.RetentionPolicy, error) {
+	return setRetentionPolicy(ctx, p.db, repoName, policy, func(t time.Time) time.Time { return t })
+}
 
-	_, err = p.db.ExecContext(ctx, `
-		UPDATE repositories 
-		SET last_commit_fetched_at = $1 
-		WHERE name = $2
-	`, since, repoName)
-	if err != nil {
-		return errors.New(
-			"DB_RESET_ERROR",
-			"Failed to update repository",
-			fmt.Sprintf("Could not update last fetched time for repository '%s'", repoName),
-			err,
-			errors.LevelError,
-		)
-	}
+func (p *PostgresDB) GetRetentionPolicy(ctx context.Context, repoName string) (*models.RetentionPolicy, error) {
+	return getRetentionPolicy(ctx, p.db, repoName)
+}
 
-	return nil
+func (p *PostgresDB) GetRetentionPolicies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	return queryRetentionPolicies(ctx, p.db, selectRetentionPolicies)
+}
+
+func (p *PostgresDB) PreviewPrune(ctx context.Context, repoName string, policy models.RetentionPolicy, now time.Time) (*models.PrunePreview, error) {
+	return previewPrune(ctx, p.db, repoName, policy, now, func(t time.Time) time.Time { return t })
+}
+
+// * PruneCommits deletes one batch of expired commits; callers loop until
+// * fewer than batchSize rows come back so no statement holds locks for long
+func (p *PostgresDB) PruneCommits(ctx context.Context, repoName string, policy models.RetentionPolicy, now time.Time, batchSize int) (int, error) {
+	return pruneCommits(ctx, p.db, repoName, policy, now, batchSize, func(t time.Time) time.Time { return t })
+}
+
+func (p *PostgresDB) GetAuthors(ctx context.Context, limit int) ([]models.Author, error) {
+	var authors []models.Author
+	err := p.read(ctx, func(q queryer) error {
+		var err error
+		authors, err = getSQLAuthors(ctx, q, limit)
+		return err
+	})
+	return authors, err
+}
+
+func (p *PostgresDB) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	var author *models.Author
+	err := p.read(ctx, func(q queryer) error {
+		var err error
+		author, err = getSQLAuthor(ctx, q, id)
+		return err
+	})
+	return author, err
+}
+
+func (p *PostgresDB) MergeAuthors(ctx context.Context, targetID int, sourceIDs []int) (*models.Author, error) {
+	var author *models.Author
+	err := p.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		author, err = mergeAuthors(ctx, sqlAuthorStore{q: tx, normalize: func(t time.Time) time.Time { return t }}, targetID, sourceIDs)
+		return err
+	})
+	return author, err
+}
+
+func (p *PostgresDB) SplitAuthor(ctx context.Context, authorID int, identityIDs []int, name string) (*models.Author, error) {
+	var author *models.Author
+	err := p.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		author, err = splitAuthor(ctx, sqlAuthorStore{q: tx, normalize: func(t time.Time) time.Time { return t }}, authorID, identityIDs, name)
+		return err
+	})
+	return author, err
+}
+
+func (p *PostgresDB) GetMailmap(ctx context.Context) (mailmap.Map, error) {
+	return sqlAuthorStore{q: p.db, normalize: func(t time.Time) time.Time { return t }}.loadMailmap(ctx)
+}
+
+// * SetMailmap replaces the mailmap and applies it to resolved authors
+func (p *PostgresDB) SetMailmap(ctx context.Context, m mailmap.Map) error {
+	return p.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return applyMailmap(ctx, sqlAuthorStore{q: tx, normalize: func(t time.Time) time.Time { return t }}, m)
+	})
+}
+
+func (p *PostgresDB) GetBotRules(ctx context.Context) (bots.Rules, error) {
+	return sqlAuthorStore{q: p.db, normalize: func(t time.Time) time.Time { return t }}.loadBotRules(ctx)
+}
+
+// * SetBotRules replaces the bot rules and reclassifies every author under them
+func (p *PostgresDB) SetBotRules(ctx context.Context, rules bots.Rules) error {
+	return p.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return applyBotRules(ctx, sqlAuthorStore{q: tx, normalize: func(t time.Time) time.Time { return t }}, rules)
+	})
+}
+
+// * ResolveCommitAuthors resolves one batch of commits stored without an
+// * author and reports how many it resolved
+func (p *PostgresDB) ResolveCommitAuthors(ctx context.Context, batchSize int) (int, error) {
+	resolved := 0
+	err := p.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		resolved, err = resolveCommitAuthors(ctx, sqlAuthorStore{q: tx, normalize: func(t time.Time) time.Time { return t }}, batchSize)
+		return err
+	})
+	return resolved, err
 }
 
 // * Transaction versions of methods for use with WithTransaction
@@ -479,6 +780,7 @@ func (p *PostgresDB) UpsertRepositoryTx(ctx context.Context, tx *sql.Tx, repo *m
 			open_issues_count = EXCLUDED.open_issues_count,
 			watchers_count = EXCLUDED.watchers_count,
 			updated_at = EXCLUDED.updated_at
+		WHERE repositories.status = 'active'
 		RETURNING id, last_commit_fetched_at
 	`
 
@@ -490,6 +792,10 @@ func (p *PostgresDB) UpsertRepositoryTx(ctx context.Context, tx *sql.Tx, repo *m
 
 	var lastFetched sql.NullTime
 	err := row.Scan(&repo.ID, &lastFetched)
+	if err == sql.ErrNoRows {
+		// * The update is skipped for archived and deleted repositories
+		return repositoryInactive(repo.Name)
+	}
 	if err != nil {
 		return errors.New(
 			"DB_REPOSITORY_ERROR",
@@ -500,6 +806,7 @@ func (p *PostgresDB) UpsertRepositoryTx(ctx context.Context, tx *sql.Tx, repo *m
 		)
 	}
 
+	repo.Status = models.RepositoryActive
 	if lastFetched.Valid {
 		repo.LastCommitFetchedAt = &lastFetched.Time
 	}
@@ -507,30 +814,18 @@ func (p *PostgresDB) UpsertRepositoryTx(ctx context.Context, tx *sql.Tx, repo *m
 	return nil
 }
 
-func (p *PostgresDB) InsertCommitTx(ctx context.Context, tx *sql.Tx, commit *models.Commit) error {
-	query := `
-		INSERT INTO commits (
-			sha, repository_id, message, author_name, author_email, author_date, commit_url
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT(sha, repository_id) DO NOTHING
-	`
-
-	_, err := tx.ExecContext(ctx, query,
-		commit.SHA, commit.RepositoryID, commit.Message, commit.AuthorName,
-		commit.AuthorEmail, commit.AuthorDate, commit.CommitURL,
-	)
+func (p *PostgresDB) RefreshRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
+	return refreshSQLRepository(ctx, tx, repo, func(t time.Time) time.Time { return t })
+}
 
-	if err != nil {
-		return errors.New(
-			"DB_COMMIT_ERROR",
-			"Failed to insert commit in transaction",
-			fmt.Sprintf("Could not insert commit '%s' in transaction", commit.SHA),
-			err,
-			errors.LevelError,
-		)
-	}
+func (p *PostgresDB) InsertCommitTx(ctx context.Context, tx *sql.Tx, commit *models.Commit) error {
+	return insertSQLCommit(ctx, tx, commit, func(t time.Time) time.Time { return t })
+}
 
-	return nil
+// * InsertCommitsTx inserts commits with multi-row statements, one round trip
+// * per batch instead of one per commit
+func (p *PostgresDB) InsertCommitsTx(ctx context.Context, tx *sql.Tx, commits []models.Commit) (models.InsertResult, error) {
+	return insertSQLCommits(ctx, tx, commits, func(t time.Time) time.Time { return t })
 }
 
 func (p *PostgresDB) UpdateRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
@@ -553,3 +848,24 @@ func (p *PostgresDB) UpdateRepositoryTx(ctx context.Context, tx *sql.Tx, repo *m
 
 	return nil
 }
+
+func (p *PostgresDB) ResetRepositoryTx(ctx context.Context, tx *sql.Tx, repoName string, since time.Time) (int, error) {
+	return resetSQLRepository(ctx, tx, repoName, since, func(t time.Time) time.Time { return t })
+}
+
+func (p *PostgresDB) SetRepositoryStatusTx(ctx context.Context, tx *sql.Tx, name string, status models.RepositoryStatus) (*models.Repository, error) {
+	if err := setSQLRepositoryStatus(ctx, tx, name, status, func(t time.Time) time.Time { return t }); err != nil {
+		return nil, err
+	}
+	return getPostgresRepository(ctx, tx, name)
+}
+
+func (p *PostgresDB) DeleteRepositoryTx(ctx context.Context, tx *sql.Tx, name string) error {
+	return deleteSQLRepository(ctx, tx, name)
+}
+
+// * RecordAuditTx appends an audit entry in the same transaction as the
+// * action it describes, so one is never stored without the other
+func (p *PostgresDB) RecordAuditTx(ctx context.Context, tx *sql.Tx, entry *models.AuditEntry) error {
+	return insertSQLAudit(ctx, tx, entry, func(t time.Time) time.Time { return t })
+}
@@ -3,12 +3,19 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/conventional"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/search"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUpsertRepository(t *testing.T) {
@@ -55,7 +62,8 @@ func TestGetRepository(t *testing.T) {
 	rows := sqlmock.NewRows([]string{
 		"id", "name", "description", "url", "language", "forks_count", "stars_count",
 		"open_issues_count", "watchers_count", "created_at", "updated_at", "last_commit_fetched_at",
-	}).AddRow(1, "test/repo", "desc", "url", "Go", 1, 2, 3, 4, now, now, nil)
+		"status", "status_changed_at",
+	}).AddRow(1, "test/repo", "desc", "url", "Go", 1, 2, 3, 4, now, now, nil, "active", nil)
 
 	mock.ExpectQuery("SELECT id, name, description, url, language").
 		WithArgs("test/repo").
@@ -65,9 +73,82 @@ func TestGetRepository(t *testing.T) {
 	repo, err := pg.GetRepository(context.Background(), "test/repo")
 	assert.NoError(t, err)
 	assert.Equal(t, "test/repo", repo.Name)
+	assert.Equal(t, models.RepositoryActive, repo.Status)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestUpsertRepository_Inactive(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// * The conflict update is skipped for an archived repository, so no row
+	// * comes back
+	mock.ExpectQuery(`INSERT INTO repositories .* WHERE repositories.status = 'active'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "last_commit_fetched_at"}))
+
+	pg := &PostgresDB{db: mockDB}
+	err = pg.UpsertRepository(context.Background(), &models.Repository{Name: "test/repo"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DB_REPOSITORY_INACTIVE")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetRepositoryStatus(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE repositories\s+SET status = \$1, status_changed_at = \$2\s+WHERE name = \$3`).
+		WithArgs("archived", sqlmock.AnyArg(), "test/repo").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, name, description, url, language").
+		WithArgs("test/repo").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "name", "description", "url", "language", "forks_count", "stars_count",
+			"open_issues_count", "watchers_count", "created_at", "updated_at", "last_commit_fetched_at",
+			"status", "status_changed_at",
+		}).AddRow(1, "test/repo", "", "url", "Go", 0, 0, 0, 0, now, now, nil, "archived", now))
+	mock.ExpectCommit()
+
+	// * A missing repository rolls back
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE repositories`).
+		WithArgs("active", sqlmock.AnyArg(), "missing/repo").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	pg := &PostgresDB{db: mockDB}
+	repo, err := pg.SetRepositoryStatus(context.Background(), "test/repo", models.RepositoryArchived)
+	require.NoError(t, err)
+	assert.Equal(t, models.RepositoryArchived, repo.Status)
+	require.NotNil(t, repo.StatusChangedAt)
+
+	_, err = pg.SetRepositoryStatus(context.Background(), "missing/repo", models.RepositoryActive)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DB_REPOSITORY_NOT_FOUND")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// * expectNewAuthor expects the queries that resolve a commit whose single
+// * identity is not known yet, with an empty mailmap and no bot rules
+func expectNewAuthor(mock sqlmock.Sqlmock, kind, value string, authorID, identityID int) {
+	mock.ExpectQuery("FROM mailmap_entries").
+		WillReturnRows(sqlmock.NewRows([]string{"proper_name", "proper_email", "commit_name", "commit_email"}))
+	mock.ExpectQuery("FROM bot_rules").
+		WillReturnRows(sqlmock.NewRows([]string{"pattern", "bot"}))
+	mock.ExpectQuery("SELECT id, author_id FROM author_identities").
+		WithArgs(kind, value).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "author_id"}))
+	mock.ExpectQuery("INSERT INTO authors").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(authorID))
+	mock.ExpectQuery("INSERT INTO author_identities").
+		WithArgs(authorID, kind, value).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(identityID))
+}
+
 func TestInsertCommit(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -76,21 +157,27 @@ func TestInsertCommit(t *testing.T) {
 	commit := &models.Commit{
 		SHA:          "abc123",
 		RepositoryID: 1,
-		Message:      "initial commit",
+		Message:      "feat(core)!: initial commit",
 		AuthorName:   "test",
-		AuthorEmail:  "test@example.com",
-		AuthorDate:   time.Now(),
+		AuthorEmail:  "Test@Example.com",
+		AuthorLogin:  "tester",
+		AuthorDate:   time.Now().In(time.FixedZone("CEST", 2*60*60)),
 		CommitURL:    "https://github.com/commit/abc123",
 	}
 
+	mock.ExpectBegin()
+	expectNewAuthor(mock, models.IdentityEmail, "test@example.com", 3, 4)
 	mock.ExpectExec("INSERT INTO commits").
 		WithArgs(commit.SHA, commit.RepositoryID, commit.Message, commit.AuthorName,
-			commit.AuthorEmail, commit.AuthorDate, commit.CommitURL).
+			commit.AuthorEmail, commit.AuthorDate, commit.CommitURL, "tester", 0, 3, 4, 2*60*60,
+			"feat", "core", true, "initial commit").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	pg := &PostgresDB{db: mockDB}
 	err = pg.InsertCommit(context.Background(), commit)
 	assert.NoError(t, err)
+	assert.Equal(t, 3, *commit.AuthorID)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -125,3 +212,762 @@ func TestWithTransaction_Rollback(t *testing.T) {
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestInsertCommitsTx(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	commits := make([]models.Commit, commitBatchSize+2)
+	for i := range commits {
+		commits[i] = models.Commit{
+			SHA:          fmt.Sprintf("sha%d", i),
+			RepositoryID: 1,
+			Message:      "commit",
+			AuthorDate:   now,
+		}
+	}
+
+	mock.ExpectBegin()
+	// * Every commit shares one anonymous identity, resolved once
+	expectNewAuthor(mock, models.IdentityName, "unknown", 1, 1)
+	// * First batch: two rows already existed
+	mock.ExpectExec(`INSERT INTO commits .* VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$10, \$11, \$12, \$13, \$14, \$15, \$16\), .* ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, int64(commitBatchSize-2)))
+	mock.ExpectExec(`INSERT INTO commits .* VALUES \(\$1, .* \$16\), \(\$17, .* \$32\) ON CONFLICT`).
+		WithArgs(
+			"sha1000", 1, "commit", "", "", now, "", "", 0, 1, 1, 0, nil, nil, false, nil,
+			"sha1001", 1, "commit", "", "", now, "", "", 0, 1, 1, 0, nil, nil, false, nil,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	pg := &PostgresDB{db: mockDB}
+	var result models.InsertResult
+	err = pg.WithTransaction(context.Background(), func(tx *sql.Tx) error {
+		result, err = pg.InsertCommitsTx(context.Background(), tx, commits)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, models.InsertResult{Inserted: commitBatchSize, Skipped: 2}, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCommits_Keyset(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	cursor := &models.CommitCursor{AuthorDate: now, ID: 10}
+
+	rows := sqlmock.NewRows([]string{
+		"id", "sha", "repository_id", "message", "author_name", "author_email", "author_date", "commit_url",
+		"author_login", "author_id",
+	}).
+		AddRow(9, "b", 1, "m", "a", "e", now, "url", "login", 2).
+		AddRow(8, "c", 1, "m", "a", "e", now.Add(-time.Hour), "url", "", nil)
+
+	mock.ExpectQuery(`WHERE r.name = \$1 AND c.author_date <= \$2 AND \(c.author_date, c.id\) < \(\$2, \$3\)\s+ORDER BY c.author_date DESC, c.id DESC LIMIT \$4`).
+		WithArgs("test/repo", now, 10, 2).
+		WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT COUNT\(\*\)`).
+		WithArgs("test/repo").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+
+	pg := &PostgresDB{db: mockDB}
+	page, err := pg.GetCommits(context.Background(), "test/repo", models.CommitFilter{Limit: 1, After: cursor, WithTotal: true})
+	assert.NoError(t, err)
+	assert.Len(t, page.Commits, 1)
+	assert.Equal(t, &models.CommitCursor{AuthorDate: now, ID: 9}, page.NextCursor)
+	assert.Equal(t, 12, *page.Total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchCommits(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	q, err := search.Parse(`"use after free" fix*`)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{
+		"id", "sha", "repository_id", "message", "author_name", "author_email", "author_date", "commit_url",
+		"author_login", "author_id", "repository_name", "rank", "snippet",
+	}).AddRow(1, "abc", 1, "Fix use after free <b>", "a", "e", now, "url", "", nil, "test/repo", 0.5,
+		search.SnippetStart+"Fix"+search.SnippetStop+" use after free <b>")
+
+	mock.ExpectQuery(`to_tsquery\('english', \$1\).*r.name = ANY\(\$2\) AND NOT EXISTS \(SELECT 1 FROM authors a WHERE a.id = c.author_id AND a.is_bot\)`).
+		WithArgs(`('use' <-> 'after' <-> 'free') & 'fix':*`, sqlmock.AnyArg(), 5, headlineOptions).
+		WillReturnRows(rows)
+
+	pg := &PostgresDB{db: mockDB}
+	results, err := pg.SearchCommits(context.Background(), models.CommitSearch{
+		Query:        q,
+		Repositories: []string{"test/repo"},
+		Limit:        5,
+		ExcludeBots:  true,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "test/repo", results[0].RepositoryName)
+	assert.Equal(t, "<mark>Fix</mark> use after free &lt;b&gt;", results[0].Snippet)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTopAuthors_ExcludesBots(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(`FROM commit_daily_stats s.+WHERE r.name = \$1 AND NOT COALESCE\(a.is_bot, FALSE\)`).
+		WithArgs("test/repo", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "commit_count"}).AddRow(1, "alice", 3))
+
+	pg := &PostgresDB{db: mockDB}
+	authors, err := pg.GetTopAuthors(context.Background(), "test/repo", 10, false)
+	require.NoError(t, err)
+	assert.Equal(t, []models.AuthorCommitCount{{AuthorID: 1, AuthorName: "alice", CommitCount: 3}}, authors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCommitActivity(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, berlin)
+	until := time.Date(2024, 5, 1, 0, 0, 0, 0, berlin)
+
+	// * date_trunc yields the bucket's wall clock start without a time zone
+	mock.ExpectQuery(`SELECT date_trunc\(\$1, c.author_date AT TIME ZONE \$2\), COALESCE\(a.id, 0\), COALESCE\(a.name, c.author_name\), r.name, COUNT\(\*\).+LEFT JOIN authors a.+r.name IN \(\$5, \$6\)`).
+		WithArgs("month", "Europe/Berlin", since, until, "test/repo", "test/other").
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "id", "name", "repository", "count"}).
+			AddRow(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 1, "alice", "test/repo", 2).
+			AddRow(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 1, "alice", "test/other", 3))
+
+	pg := &PostgresDB{db: mockDB}
+	counts, err := pg.GetCommitActivity(context.Background(), models.ActivityFilter{
+		Repositories: []string{"test/repo", "test/other"},
+		Interval:     models.ActivityMonth,
+		Since:        since,
+		Until:        until,
+		Location:     berlin,
+		ByAuthor:     true,
+		ByRepository: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, counts, 2)
+	assert.True(t, since.Equal(counts[0].Start))
+	assert.Equal(t, 3, counts[0].Commits)
+	assert.Equal(t, "test/other", counts[0].Repository)
+	assert.True(t, time.Date(2024, 4, 1, 0, 0, 0, 0, berlin).Equal(counts[1].Start))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCommitActivity_UTCReadsDailyStats(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	since := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC)
+
+	// * Days of the same week are folded into one bucket
+	mock.ExpectQuery(`SELECT s.day, 0, '', '', SUM\(s.commit_count\)\s+FROM commit_daily_stats s.+s.day >= \$1 AND s.day < \$2.+NOT EXISTS \(SELECT 1 FROM authors b WHERE b.id = s.author_id AND b.is_bot\)`).
+		WithArgs("2024-03-04", "2024-03-18").
+		WillReturnRows(sqlmock.NewRows([]string{"day", "id", "name", "repository", "count"}).
+			AddRow(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), 0, "", "", 2).
+			AddRow(time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), 0, "", "", 1).
+			AddRow(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), 0, "", "", 4))
+
+	pg := &PostgresDB{db: mockDB}
+	counts, err := pg.GetCommitActivity(context.Background(), models.ActivityFilter{
+		Interval:    models.ActivityWeek,
+		Since:       since,
+		Until:       until,
+		Location:    time.UTC,
+		ExcludeBots: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, counts, 2)
+	assert.True(t, since.Equal(counts[0].Start))
+	assert.Equal(t, 3, counts[0].Commits)
+	assert.Equal(t, 4, counts[1].Commits)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPunchCard(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// * In the author's offset, commits without one count as UTC
+	mock.ExpectQuery(`EXTRACT\(ISODOW FROM \(c.author_date AT TIME ZONE 'UTC' \+ make_interval\(secs => COALESCE\(c.author_utc_offset, 0\)\)\)\)::int - 1.+AND c.author_id = \$2`).
+		WithArgs("test/repo", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "hour", "count"}).AddRow(0, 22, 3).AddRow(6, 9, 1))
+
+	pg := &PostgresDB{db: mockDB}
+	counts, err := pg.GetPunchCard(context.Background(), models.PunchCardFilter{
+		Repositories: []string{"test/repo"},
+		AuthorID:     7,
+		Location:     time.UTC,
+		AuthorOffset: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, counts[0][22])
+	assert.Equal(t, 1, counts[6][9])
+	assert.Equal(t, 4, counts.Total())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetChangelogCommits(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	from := models.CommitCursor{AuthorDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), ID: 4}
+	to := models.CommitCursor{AuthorDate: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), ID: 9}
+	columns := []string{"id", "sha", "message", "author_name", "author_date", "commit_url", "type", "scope", "breaking", "subject"}
+
+	// * The second row was stored before parsing, the third does not follow
+	// * the convention
+	mock.ExpectQuery(`\(c.author_date, c.id\) > \(\$2, \$3\) AND \(c.author_date, c.id\) <= \(\$4, \$5\).+ORDER BY c.author_date DESC, c.id DESC`).
+		WithArgs("test/repo", from.AuthorDate, from.ID, to.AuthorDate, to.ID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(9, "sha9", "feat(api)!: drop v1", "alice", to.AuthorDate, "url", "feat", "api", true, "drop v1").
+			AddRow(7, "sha7", "fix: typo", "bob", from.AuthorDate, "url", nil, nil, nil, nil).
+			AddRow(5, "sha5", "Update README\n\nbody", "bob", from.AuthorDate, "url", nil, nil, false, nil))
+
+	pg := &PostgresDB{db: mockDB}
+	commits, err := pg.GetChangelogCommits(context.Background(), "test/repo", models.ChangelogFilter{After: &from, Through: &to})
+	require.NoError(t, err)
+	require.Len(t, commits, 3)
+	assert.Equal(t, &conventional.Commit{Type: "feat", Scope: "api", Breaking: true, Subject: "drop v1"}, commits[0].Conventional)
+	assert.Equal(t, &conventional.Commit{Type: "fix", Subject: "typo"}, commits[1].Conventional)
+	assert.Nil(t, commits[2].Conventional)
+	assert.Equal(t, "Update README", commits[2].Subject)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPruneCommits(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	kept := now.Add(-time.Hour)
+	maxAge, maxCommits := 30, 100
+
+	mock.ExpectQuery(`SELECT id FROM repositories WHERE name = \$1`).
+		WithArgs("test/repo").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`ORDER BY author_date DESC, id DESC\s+LIMIT 1 OFFSET \$2`).
+		WithArgs(1, maxCommits-1).
+		WillReturnRows(sqlmock.NewRows([]string{"author_date", "id"}).AddRow(kept, 42))
+	mock.ExpectExec(`DELETE FROM commits WHERE id IN \(\s+SELECT c.id FROM commits c\s+WHERE c.repository_id = \$1 AND \(c.author_date < \$2 OR \(c.author_date, c.id\) < \(\$3, \$4\)\)\s+ORDER BY c.author_date, c.id\s+LIMIT \$5`).
+		WithArgs(1, now.AddDate(0, 0, -maxAge), kept, 42, 500).
+		WillReturnResult(sqlmock.NewResult(0, 500))
+
+	pg := &PostgresDB{db: mockDB}
+	policy := models.RetentionPolicy{MaxAgeDays: &maxAge, MaxCommits: &maxCommits}
+	deleted, err := pg.PruneCommits(context.Background(), "test/repo", policy, now, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMaintainPartitions(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Date(2024, 11, 15, 10, 0, 0, 0, time.UTC)
+	stray := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT DISTINCT date_trunc\('month', author_date AT TIME ZONE 'UTC'\) FROM commits_default`).
+		WillReturnRows(sqlmock.NewRows([]string{"month"}).AddRow(stray))
+	// * This month, three ahead across the year boundary, then the stray month
+	for _, month := range []time.Time{
+		time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		stray,
+	} {
+		mock.ExpectExec(`SELECT create_commit_partition\(\$1\)`).
+			WithArgs(month).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	pg := &PostgresDB{db: mockDB}
+	require.NoError(t, pg.MaintainPartitions(context.Background(), now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDropExpiredPartitions(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM pg_inherits`).
+		WillReturnRows(sqlmock.NewRows([]string{"relname"}).
+			AddRow("commits_2024_01").
+			AddRow("commits_2024_02").
+			AddRow("commits_default").
+			AddRow("commits_2024_06"))
+	// * Repository 1 keeps 90 days, repository 2 only caps its commit count
+	mock.ExpectQuery(`FROM retention_policies p`).
+		WillReturnRows(sqlmock.NewRows([]string{"repository_id", "name", "max_age_days", "max_commits", "updated_at"}).
+			AddRow(1, "a/one", 90, nil, now).
+			AddRow(2, "a/two", nil, 10, now))
+
+	// * January only holds repository 1, whose cutoff is past its end
+	mock.ExpectBegin()
+	mock.ExpectExec(`LOCK TABLE "commits_2024_01" IN ACCESS EXCLUSIVE MODE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT repository_id, COUNT\(\*\) FROM "commits_2024_01"`).
+		WillReturnRows(sqlmock.NewRows([]string{"repository_id", "count"}).AddRow(1, 40))
+	mock.ExpectExec(`ALTER TABLE commits DETACH PARTITION "commits_2024_01"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DROP TABLE "commits_2024_01"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM commit_daily_stats WHERE day >= \$1 AND day < \$2`).
+		WithArgs("2024-01-01", "2024-02-01").
+		WillReturnResult(sqlmock.NewResult(0, 12))
+	mock.ExpectCommit()
+
+	// * February also holds repository 2, so it stays
+	mock.ExpectBegin()
+	mock.ExpectExec(`LOCK TABLE "commits_2024_02"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT repository_id, COUNT\(\*\) FROM "commits_2024_02"`).
+		WillReturnRows(sqlmock.NewRows([]string{"repository_id", "count"}).AddRow(1, 5).AddRow(2, 3))
+	mock.ExpectCommit()
+
+	pg := &PostgresDB{db: mockDB}
+	dropped, err := pg.DropExpiredPartitions(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 40, dropped)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditLog(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// * Parameters are bound as text so lib/pq does not send them as bytea
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs("alice", "repository.reset", "test/repo", `{"since":"2024-01-01T00:00:00Z"}`, "success", "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM audit_log WHERE 1 = 1 AND actor = \$1 AND outcome = \$2 AND created_at >= \$3 AND id < \$4\s+ORDER BY id DESC LIMIT \$5`).
+		WithArgs("alice", "failure", since, 7, 10).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "actor", "action", "repository_name", "parameters", "outcome", "error_reference", "created_at",
+		}).AddRow(3, "alice", "repository.purge", "test/repo", nil, "failure", "DB_REPOSITORY_NOT_FOUND", since))
+
+	pg := &PostgresDB{db: mockDB}
+	entry := &models.AuditEntry{
+		Actor: "alice", Action: models.AuditRepositoryReset, RepositoryName: "test/repo",
+		Parameters: []byte(`{"since":"2024-01-01T00:00:00Z"}`), Outcome: models.AuditSuccess,
+	}
+	require.NoError(t, pg.RecordAudit(context.Background(), entry))
+	assert.Equal(t, 7, entry.ID)
+
+	entries, err := pg.GetAuditLog(context.Background(), models.AuditFilter{
+		Actor: "alice", Outcome: models.AuditFailure, Since: &since, BeforeID: 7, Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "DB_REPOSITORY_NOT_FOUND", entries[0].ErrorReference)
+	assert.Nil(t, entries[0].Parameters)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetRepository(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`UPDATE repositories\s+SET last_commit_fetched_at = \$1\s+WHERE name = \$2`).
+		WithArgs(since, "test/repo").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM commits\s+WHERE repository_id = \(SELECT id FROM repositories WHERE name = \$1\) AND author_date >= \$2`).
+		WithArgs("test/repo", since).
+		WillReturnResult(sqlmock.NewResult(0, 7))
+	mock.ExpectExec(`UPDATE repositories`).
+		WithArgs(since, "missing/repo").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	pg := &PostgresDB{db: mockDB}
+	deleted, err := pg.ResetRepository(context.Background(), "test/repo", since)
+	require.NoError(t, err)
+	assert.Equal(t, 7, deleted)
+
+	_, err = pg.ResetRepository(context.Background(), "missing/repo", since)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRebuildDailyStats(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`LOCK TABLE commits IN SHARE MODE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT id FROM repositories WHERE name = \$1`).
+		WithArgs("test/repo").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`DELETE FROM commit_daily_stats WHERE repository_id = \$1`).
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 40))
+	mock.ExpectExec(`INSERT INTO commit_daily_stats .+ SELECT c.repository_id, \(c.author_date AT TIME ZONE 'UTC'\)::date, .+ FROM commits c WHERE repository_id = \$1\s+GROUP BY 1, 2, 3, 4`).
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 42))
+	mock.ExpectCommit()
+
+	pg := &PostgresDB{db: mockDB}
+	n, err := pg.RebuildDailyStats(context.Background(), "test/repo")
+	require.NoError(t, err)
+	assert.Equal(t, 42, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncRuns(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	startedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`INSERT INTO sync_runs`).
+		WithArgs("test/repo", "worker", nil, startedAt, "running").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec(`UPDATE sync_runs`).
+		WithArgs(sqlmock.AnyArg(), 1, 10, 8, 2, "success", "", "", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM sync_runs WHERE 1 = 1 AND status = \$1 AND id < \$2\s+ORDER BY id DESC LIMIT \$3`).
+		WithArgs("failure", 4, 20).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "repository_name", "triggered_by", "since", "started_at", "finished_at", "pages_fetched",
+			"commits_seen", "commits_inserted", "api_calls", "status", "error_reference", "error_message",
+		}).AddRow(2, "other/repo", "api", nil, startedAt, startedAt, 0, 0, 0, 1, "failure", "GITHUB_API_ERROR", "not found"))
+
+	pg := &PostgresDB{db: mockDB}
+	run := &models.SyncRun{RepositoryName: "test/repo", Trigger: models.SyncTriggerWorker, StartedAt: startedAt}
+	require.NoError(t, pg.StartSyncRun(context.Background(), run))
+	assert.Equal(t, 4, run.ID)
+
+	finishedAt := startedAt.Add(time.Minute)
+	run.FinishedAt = &finishedAt
+	run.Status = models.SyncSuccess
+	run.PagesFetched, run.CommitsSeen, run.CommitsInserted, run.APICalls = 1, 10, 8, 2
+	require.NoError(t, pg.FinishSyncRun(context.Background(), run))
+
+	runs, err := pg.GetSyncRuns(context.Background(), models.SyncRunFilter{Status: models.SyncFailure, BeforeID: 4, Limit: 20})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "GITHUB_API_ERROR", runs[0].ErrorReference)
+	assert.Nil(t, runs[0].Since)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func newReplicatedPostgres(t *testing.T) (*PostgresDB, sqlmock.Sqlmock, sqlmock.Sqlmock, *replica) {
+	t.Helper()
+
+	primaryDB, primary, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { primaryDB.Close() })
+
+	replicaDB, replicaMock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	t.Cleanup(func() { replicaDB.Close() })
+
+	r := &replica{name: "replica 1", db: replicaDB}
+	r.healthy.Store(true)
+
+	// * The health-check loop is not started; tests call check directly
+	return &PostgresDB{db: primaryDB, replicas: newReplicaSet([]*replica{r})}, primary, replicaMock, r
+}
+
+func TestReplicas_ReadsUseReplicaWritesUsePrimary(t *testing.T) {
+	pg, primary, replicaMock, _ := newReplicatedPostgres(t)
+	ctx := context.Background()
+
+	replicaMock.ExpectQuery(`SELECT COALESCE\(a.id, 0\), COALESCE\(a.name, s.author_name\)`).
+		WithArgs("test/repo", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "commit_count"}).AddRow(1, "alice", 3))
+	primary.ExpectBegin()
+	expectNewAuthor(primary, models.IdentityName, "unknown", 1, 1)
+	primary.ExpectExec("INSERT INTO commits").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	primary.ExpectCommit()
+	primary.ExpectBegin()
+	primary.ExpectCommit()
+
+	authors, err := pg.GetTopAuthors(ctx, "test/repo", 5, true)
+	require.NoError(t, err)
+	assert.Equal(t, []models.AuthorCommitCount{{AuthorID: 1, AuthorName: "alice", CommitCount: 3}}, authors)
+
+	require.NoError(t, pg.InsertCommit(ctx, &models.Commit{SHA: "abc", RepositoryID: 1}))
+	require.NoError(t, pg.WithTransaction(ctx, func(tx *sql.Tx) error { return nil }))
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReplicas_FailedReadFallsBackToPrimary(t *testing.T) {
+	pg, primary, replicaMock, r := newReplicatedPostgres(t)
+	ctx := context.Background()
+
+	replicaMock.ExpectQuery("SELECT name, status FROM repositories").
+		WillReturnError(&net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")})
+	primary.ExpectQuery("SELECT name, status FROM repositories").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "status"}).AddRow("test/repo", "active"))
+	// * Once marked unhealthy the replica is skipped entirely
+	primary.ExpectQuery("SELECT name, status FROM repositories").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "status"}).AddRow("test/repo", "active"))
+
+	repos, err := pg.GetAllRepositories(ctx)
+	require.NoError(t, err)
+	assert.Len(t, repos, 1)
+	assert.False(t, r.healthy.Load())
+
+	_, err = pg.GetAllRepositories(ctx)
+	require.NoError(t, err)
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReplicas_QueryErrorKeepsReplicaHealthy(t *testing.T) {
+	pg, primary, replicaMock, r := newReplicatedPostgres(t)
+	ctx := context.Background()
+
+	// * A query failing on the replica says nothing about its connection
+	syntaxError := &pq.Error{Code: "42601", Message: `syntax error at or near "FORM"`}
+	replicaMock.ExpectQuery("SELECT name, status FROM repositories").
+		WillReturnError(syntaxError)
+	primary.ExpectQuery("SELECT name, status FROM repositories").
+		WillReturnError(syntaxError)
+	replicaMock.ExpectQuery("SELECT name, status FROM repositories").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "status"}).AddRow("test/repo", "active"))
+
+	_, err := pg.GetAllRepositories(ctx)
+	require.Error(t, err)
+	assert.True(t, r.healthy.Load())
+
+	repos, err := pg.GetAllRepositories(ctx)
+	require.NoError(t, err)
+	assert.Len(t, repos, 1)
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReplicas_ReadPrimarySkipsReplicas(t *testing.T) {
+	pg, primary, replicaMock, r := newReplicatedPostgres(t)
+
+	primary.ExpectQuery("SELECT name, status FROM repositories").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "status"}).AddRow("test/repo", "deleted"))
+
+	repos, err := pg.GetAllRepositories(models.ReadPrimary(context.Background()))
+	require.NoError(t, err)
+	require.Len(t, repos, 1)
+	assert.Equal(t, models.RepositoryDeleted, repos[0].Status)
+	assert.True(t, r.healthy.Load())
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReplicas_MissingRowIsRetriedOnPrimary(t *testing.T) {
+	pg, primary, replicaMock, r := newReplicatedPostgres(t)
+
+	columns := []string{
+		"id", "name", "description", "url", "language", "forks_count", "stars_count",
+		"open_issues_count", "watchers_count", "created_at", "updated_at", "last_commit_fetched_at",
+		"status", "status_changed_at",
+	}
+	replicaMock.ExpectQuery("SELECT id, name").
+		WithArgs("test/repo").
+		WillReturnRows(sqlmock.NewRows(columns))
+	primary.ExpectQuery("SELECT id, name").
+		WithArgs("test/repo").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "test/repo", "", "url", "Go", 0, 0, 0, 0, time.Now(), time.Now(), nil, "active", nil))
+
+	repo, err := pg.GetRepository(context.Background(), "test/repo")
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.ID)
+
+	// * A lagging replica is still healthy
+	assert.True(t, r.healthy.Load())
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReplicas_HealthCheck(t *testing.T) {
+	pg, _, replicaMock, r := newReplicatedPostgres(t)
+
+	replicaMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	pg.replicas.check(context.Background())
+	assert.False(t, r.healthy.Load())
+	assert.Nil(t, pg.replicas.pick())
+
+	replicaMock.ExpectPing()
+	pg.replicas.check(context.Background())
+	assert.True(t, r.healthy.Load())
+	assert.Same(t, r, pg.replicas.pick())
+
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestJobQueue(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	lease := now.Add(time.Minute)
+
+	mock.ExpectQuery(`INSERT INTO jobs .+ ON CONFLICT \(dedupe_key\) WHERE status IN \('queued', 'running'\) DO NOTHING\s+RETURNING id`).
+		WithArgs("sync", `{}`, "sync:test/repo", 0, "queued", now, 3, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	columns := []string{"id", "kind", "payload", "dedupe_key", "priority", "status", "run_at", "attempts",
+		"max_attempts", "locked_by", "locked_until", "last_error", "created_at", "finished_at"}
+	mock.ExpectQuery(`UPDATE jobs\s+SET status = 'running', attempts = attempts \+ 1, locked_by = \$1, locked_until = \$2\s+WHERE id = \(\s+SELECT id FROM jobs .+ LIMIT 1\s+FOR UPDATE SKIP LOCKED\s+\)`).
+		WithArgs("w1", lease, now).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(4, "sync", []byte(`{"owner":"test"}`), "sync:test/repo", 0, "running", now, 1, 3, "w1", lease, "", now, nil))
+	mock.ExpectQuery(`UPDATE jobs`).
+		WithArgs("w1", lease, now).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	mock.ExpectExec(`UPDATE jobs\s+SET status = 'queued', locked_by = NULL, locked_until = NULL, last_error = \$1, run_at = \$2\s+WHERE id = \$3 AND status = 'running' AND locked_by = \$4`).
+		WithArgs("rate limited", lease, 4, "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE jobs`).
+		WithArgs(sqlmock.AnyArg(), 4, "w2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	pg := &PostgresDB{db: mockDB}
+	ctx := context.Background()
+
+	ok, err := pg.EnqueueJob(ctx, &models.Job{Kind: models.JobSync, DedupeKey: "sync:test/repo", RunAt: now, MaxAttempts: 3})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	job, err := pg.ClaimJob(ctx, "w1", now, lease)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, 4, job.ID)
+	assert.Equal(t, models.JobRunning, job.Status)
+	assert.Equal(t, "w1", job.LockedBy)
+	require.NotNil(t, job.LockedUntil)
+
+	job, err = pg.ClaimJob(ctx, "w1", now, lease)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	require.NoError(t, pg.FailJob(ctx, 4, "w1", "rate limited", &lease))
+	assert.ErrorContains(t, pg.CompleteJob(ctx, 4, "w2"), "DB_JOB_LOCK_LOST")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTryLock(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(hashtextextended\(\$1, 0\)\)`).
+		WithArgs("sync:test/repo").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(hashtextextended\(\$1, 0\)\)`).
+		WithArgs("sync:test/repo").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock`).
+		WithArgs("sync:test/repo").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+	pg := &PostgresDB{locks: mockDB}
+	ctx := context.Background()
+
+	lock, err := pg.TryLock(ctx, "sync:test/repo")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	require.NoError(t, lock.Release())
+	// * Releasing twice only unlocks once
+	require.NoError(t, lock.Release())
+
+	lock, err = pg.TryLock(ctx, "sync:test/repo")
+	require.NoError(t, err)
+	assert.Nil(t, lock)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTryLock_FailedUnlockDiscardsConnection(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(hashtextextended\(\$1, 0\)\)`).
+		WithArgs("sync:test/repo").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(hashtextextended\(\$1, 0\)\)`).
+		WithArgs("sync:test/repo").
+		WillReturnError(fmt.Errorf("statement timeout"))
+	// * The session still holding the lock is closed, not pooled
+	mock.ExpectClose()
+
+	pg := &PostgresDB{locks: mockDB}
+	lock, err := pg.TryLock(context.Background(), "sync:test/repo")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	assert.ErrorContains(t, lock.Release(), "DB_LOCK_ERROR")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTryLock_UsesLockPool(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+	lockDB, lockMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer lockDB.Close()
+
+	// * With every pooled connection busy in a transaction, a lock can
+	// * still be taken
+	mockDB.SetMaxOpenConns(1)
+	mock.ExpectBegin()
+	tx, err := mockDB.Begin()
+	require.NoError(t, err)
+
+	lockMock.ExpectQuery(`SELECT pg_try_advisory_lock\(hashtextextended\(\$1, 0\)\)`).
+		WithArgs("sync:test/repo").
+		WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(true))
+	lockMock.ExpectExec(`SELECT pg_advisory_unlock\(hashtextextended\(\$1, 0\)\)`).
+		WithArgs("sync:test/repo").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	pg := &PostgresDB{db: mockDB, locks: lockDB}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	lock, err := pg.TryLock(ctx, "sync:test/repo")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	assert.NoError(t, lock.Release())
+
+	mock.ExpectRollback()
+	require.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, lockMock.ExpectationsWereMet())
+}
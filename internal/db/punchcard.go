@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+)
+
+// * buildPunchCardQuery returns the query counting the commits selected by
+// * filter per value of columns, which may refer to the args it is given,
+// * numbered from $1. The last column must be the count.
+func buildPunchCardQuery(filter models.PunchCardFilter, columns string, columnArgs []any, normalize func(time.Time) time.Time) (string, []any) {
+	where, args := commitScope(columnArgs, filter.Repositories, filter.Since, filter.Until, filter.ExcludeBots, normalize)
+	if filter.AuthorID != 0 {
+		args = append(args, filter.AuthorID)
+		where += fmt.Sprintf(" AND c.author_id = $%d", len(args))
+	}
+
+	return `
+		SELECT ` + columns + `
+		FROM commits c
+		JOIN repositories r ON c.repository_id = r.id
+		WHERE ` + where + `
+		GROUP BY 1, 2`, args
+}
+
+// * punchCardTime is t as the clock on the card reads it: in the author's
+// * own offset, in seconds east of UTC, or in the filter's location
+func punchCardTime(filter models.PunchCardFilter, t time.Time, offset int) time.Time {
+	if filter.AuthorOffset {
+		return t.In(time.FixedZone("", offset))
+	}
+	return t.In(filter.Location)
+}
+
+// * queryPunchCard runs a punch card query whose rows are shaped
+// * (author_date, offset, count), placing each row on the card in Go
+func queryPunchCard(ctx context.Context, q queryer, filter models.PunchCardFilter, query string, args []any) (models.PunchCardCounts, error) {
+	var counts models.PunchCardCounts
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return counts, analyticsError("Failed to query punch card", "Could not count commits per weekday and hour", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t time.Time
+		var offset, n int
+		if err := rows.Scan(&t, &offset, &n); err != nil {
+			return counts, analyticsError("Failed to scan punch card", "Error while scanning punch card row", err)
+		}
+		counts.Add(punchCardTime(filter, t, offset), n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return counts, analyticsError("Failed to process punch card", "Error while processing punch card rows", err)
+	}
+	return counts, nil
+}
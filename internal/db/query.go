@@ -0,0 +1,276 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/search"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * queryer is the read side shared by *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// * excludeBotCommits leaves out commits c whose resolved author is a bot
+const excludeBotCommits = " AND NOT EXISTS (SELECT 1 FROM authors a WHERE a.id = c.author_id AND a.is_bot)"
+
+// * commitScope returns the conditions on commits c of repositories r shared
+// * by the analytics queries, binding its values after args: the commits of
+// * repos, or of every repository not deleted when empty, authored from
+// * since up to but not including until. A zero bound is left open.
+func commitScope(args []any, repos []string, since, until time.Time, excludeBots bool, normalize func(time.Time) time.Time) (string, []any) {
+	where := "r.status <> 'deleted'"
+
+	if !since.IsZero() {
+		args = append(args, normalize(since))
+		where += fmt.Sprintf(" AND c.author_date >= $%d", len(args))
+	}
+	if !until.IsZero() {
+		args = append(args, normalize(until))
+		where += fmt.Sprintf(" AND c.author_date < $%d", len(args))
+	}
+
+	if len(repos) > 0 {
+		placeholders := make([]string, len(repos))
+		for i, name := range repos {
+			args = append(args, name)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		where += " AND r.name IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if excludeBots {
+		where += excludeBotCommits
+	}
+
+	return where, args
+}
+
+// * buildGetCommitsQuery returns the page query for filter and, separately,
+// * the count query that ignores the cursor. normalize is applied to every
+// * bound timestamp so SQLite can compare its stored UTC strings.
+func buildGetCommitsQuery(repoName string, filter models.CommitFilter, normalize func(time.Time) time.Time) (string, []any, string, []any) {
+	where := " WHERE r.name = $1"
+	args := []any{repoName}
+
+	if filter.Since != nil {
+		args = append(args, normalize(*filter.Since))
+		where += fmt.Sprintf(" AND c.author_date >= $%d", len(args))
+	}
+
+	if filter.Until != nil {
+		args = append(args, normalize(*filter.Until))
+		where += fmt.Sprintf(" AND c.author_date <= $%d", len(args))
+	}
+
+	if filter.ExcludeBots {
+		where += excludeBotCommits
+	}
+
+	from := `
+		FROM commits c
+		JOIN repositories r ON c.repository_id = r.id`
+
+	countQuery := "SELECT COUNT(*)" + from + where
+	countArgs := slices.Clone(args)
+
+	if filter.After != nil {
+		// * The plain bound on author_date is implied by the row comparison but
+		// * lets Postgres skip partitions newer than the cursor
+		args = append(args, normalize(filter.After.AuthorDate), filter.After.ID)
+		where += fmt.Sprintf(" AND c.author_date <= $%d AND (c.author_date, c.id) < ($%d, $%d)", len(args)-1, len(args)-1, len(args))
+	}
+
+	query := `
+		SELECT c.id, c.sha, c.repository_id, c.message, c.author_name, c.author_email,
+					c.author_date, c.commit_url, COALESCE(c.author_login, ''), c.author_id` + from + where + `
+		ORDER BY c.author_date DESC, c.id DESC`
+
+	if filter.Limit > 0 {
+		// * One extra row tells us whether another page exists
+		args = append(args, filter.Limit+1)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+		if filter.After == nil && filter.Offset > 0 {
+			args = append(args, filter.Offset)
+			query += fmt.Sprintf(" OFFSET $%d", len(args))
+		}
+	}
+
+	return query, args, countQuery, countArgs
+}
+
+// * queryCommitPage runs GetCommits for the SQL-backed implementations
+func queryCommitPage(ctx context.Context, q queryer, repoName string, filter models.CommitFilter, normalize func(time.Time) time.Time) (*models.CommitPage, error) {
+	query, args, countQuery, countArgs := buildGetCommitsQuery(repoName, filter, normalize)
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New(
+			"DB_COMMIT_ERROR",
+			"Failed to query commits",
+			fmt.Sprintf("Could not fetch commits for repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+	defer rows.Close()
+
+	var commits []models.Commit
+	for rows.Next() {
+		var c models.Commit
+		err := rows.Scan(
+			&c.ID, &c.SHA, &c.RepositoryID, &c.Message, &c.AuthorName,
+			&c.AuthorEmail, &c.AuthorDate, &c.CommitURL, &c.AuthorLogin, &c.AuthorID,
+		)
+		if err != nil {
+			return nil, errors.New(
+				"DB_COMMIT_ERROR",
+				"Failed to scan commit",
+				"Error while scanning commit row",
+				err,
+				errors.LevelError,
+			)
+		}
+		commits = append(commits, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(
+			"DB_COMMIT_ERROR",
+			"Failed to process commits",
+			"Error while processing commit rows",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	page := &models.CommitPage{Commits: commits}
+	if filter.Limit > 0 && len(commits) > filter.Limit {
+		page.Commits = commits[:filter.Limit]
+		last := page.Commits[filter.Limit-1]
+		page.NextCursor = &models.CommitCursor{AuthorDate: last.AuthorDate, ID: last.ID}
+	}
+
+	if filter.WithTotal {
+		var total int
+		if err := q.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+			return nil, errors.New(
+				"DB_COMMIT_ERROR",
+				"Failed to count commits",
+				fmt.Sprintf("Could not count commits for repository '%s'", repoName),
+				err,
+				errors.LevelError,
+			)
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// * queryTopAuthors sums the daily stats per resolved author, so its cost
+// * grows with days and authors rather than commits. Commits not yet
+// * resolved are grouped by their recorded name under author ID 0 and never
+// * count as bots.
+func queryTopAuthors(ctx context.Context, q queryer, repoName string, limit int, includeBots bool) ([]models.AuthorCommitCount, error) {
+	where := "r.name = $1"
+	if !includeBots {
+		where += " AND NOT COALESCE(a.is_bot, FALSE)"
+	}
+
+	query := `
+		SELECT COALESCE(a.id, 0), COALESCE(a.name, s.author_name) AS name, SUM(s.commit_count) AS commit_count
+		FROM commit_daily_stats s
+		JOIN repositories r ON s.repository_id = r.id
+		LEFT JOIN authors a ON a.id = s.author_id
+		WHERE ` + where + `
+		GROUP BY COALESCE(a.id, 0), COALESCE(a.name, s.author_name)
+		ORDER BY commit_count DESC, name
+		LIMIT $2
+	`
+
+	rows, err := q.QueryContext(ctx, query, repoName, limit)
+	if err != nil {
+		return nil, errors.New(
+			"DB_AUTHOR_ERROR",
+			"Failed to query top authors",
+			fmt.Sprintf("Could not fetch top authors for repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+	defer rows.Close()
+
+	var results []models.AuthorCommitCount
+	for rows.Next() {
+		var acc models.AuthorCommitCount
+		err := rows.Scan(&acc.AuthorID, &acc.AuthorName, &acc.CommitCount)
+		if err != nil {
+			return nil, errors.New(
+				"DB_AUTHOR_ERROR",
+				"Failed to scan author commit count",
+				"Error while scanning author commit count row",
+				err,
+				errors.LevelError,
+			)
+		}
+		results = append(results, acc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(
+			"DB_AUTHOR_ERROR",
+			"Failed to process top authors",
+			"Error while processing author rows",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return results, nil
+}
+
+// * scanSearchResults reads rows shaped as commit columns followed by
+// * repository name, rank and a snippet delimited for search.Snippet
+func scanSearchResults(rows *sql.Rows) ([]models.CommitSearchResult, error) {
+	var results []models.CommitSearchResult
+	for rows.Next() {
+		var res models.CommitSearchResult
+		err := rows.Scan(
+			&res.ID, &res.SHA, &res.RepositoryID, &res.Message, &res.AuthorName,
+			&res.AuthorEmail, &res.AuthorDate, &res.CommitURL, &res.AuthorLogin, &res.AuthorID,
+			&res.RepositoryName, &res.Rank, &res.Snippet,
+		)
+		if err != nil {
+			return nil, errors.New(
+				"DB_SEARCH_ERROR",
+				"Failed to scan search result",
+				"Error while scanning commit search row",
+				err,
+				errors.LevelError,
+			)
+		}
+		res.Snippet = search.Snippet(res.Snippet)
+		results = append(results, res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(
+			"DB_SEARCH_ERROR",
+			"Failed to process search results",
+			"Error while processing commit search rows",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	stderrors "errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+	"github.com/lib/pq"
+)
+
+const (
+	// * replicaCheckInterval is how often every replica is pinged
+	replicaCheckInterval = 10 * time.Second
+	replicaPingTimeout   = 2 * time.Second
+)
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// * setHealthy records a health change and logs transitions only
+func (r *replica) setHealthy(healthy bool, cause error) {
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		logger.Info("%s is healthy again, routing reads to it", r.name)
+	} else {
+		logger.Warn("%s is unhealthy, reads fall back to other replicas or the primary: %v", r.name, cause)
+	}
+}
+
+// * replicaSet spreads reads round-robin over the healthy replicas and
+// * pings them in the background so a failed one rejoins once it recovers
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newReplicaSet(replicas []*replica) *replicaSet {
+	return &replicaSet{
+		replicas: replicas,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// * pick returns the next healthy replica, or nil when reads must go to the
+// * primary. A nil set has no replicas.
+func (rs *replicaSet) pick() *replica {
+	if rs == nil {
+		return nil
+	}
+
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := range n {
+		r := rs.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// * check pings every replica once
+func (rs *replicaSet) check(ctx context.Context) {
+	for _, r := range rs.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		err := r.db.PingContext(pingCtx)
+		cancel()
+		r.setHealthy(err == nil, err)
+	}
+}
+
+func (rs *replicaSet) run(interval time.Duration) {
+	defer close(rs.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rs.check(context.Background())
+		case <-rs.stop:
+			return
+		}
+	}
+}
+
+func (rs *replicaSet) close() error {
+	if rs == nil {
+		return nil
+	}
+
+	rs.stopOnce.Do(func() { close(rs.stop) })
+	<-rs.done
+
+	var errs []error
+	for _, r := range rs.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return stderrors.Join(errs...)
+}
+
+// * read runs fn on a healthy replica when there is one and ctx was not
+// * marked by models.ReadPrimary, otherwise on the primary. If the replica
+// * fails, the read is retried on the primary; a missing row is retried too
+// * since the replica may simply be lagging. Only losing the connection
+// * takes the replica out of the rotation.
+func (p *PostgresDB) read(ctx context.Context, fn func(q queryer) error) error {
+	if models.ReadsPrimary(ctx) {
+		return fn(p.db)
+	}
+	r := p.replicas.pick()
+	if r == nil {
+		return fn(p.db)
+	}
+
+	err := fn(r.db)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	if connectionError(err) {
+		r.setHealthy(false, err)
+	}
+	return fn(p.db)
+}
+
+// * connectionError reports whether err means the server could not be
+// * reached or went away, as opposed to a query failing on a server that is
+// * otherwise fine
+func connectionError(err error) bool {
+	if stderrors.Is(err, driver.ErrBadConn) || stderrors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var netErr net.Error
+	if stderrors.As(err, &netErr) {
+		return true
+	}
+
+	// * Class 08 is connection exceptions; 57P01 to 57P03 are a server
+	// * shutting down or not yet accepting connections
+	var pqErr *pq.Error
+	if stderrors.As(err, &pqErr) {
+		return pqErr.Code.Class() == "08" || strings.HasPrefix(string(pqErr.Code), "57P")
+	}
+	return false
+}
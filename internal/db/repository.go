@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+func repositoryNotFound(name string) error {
+	return errors.New(
+		"DB_REPOSITORY_NOT_FOUND",
+		"Repository not found",
+		fmt.Sprintf("Repository '%s' does not exist", name),
+		sql.ErrNoRows,
+		errors.LevelInfo,
+	)
+}
+
+// * repositoryInactive is returned when a sync tries to store a repository
+// * that was archived, deleted or purged in the meantime
+func repositoryInactive(name string) error {
+	return errors.New(
+		"DB_REPOSITORY_INACTIVE",
+		"Repository is not active",
+		fmt.Sprintf("Repository '%s' is archived or deleted; restore it to sync it again", name),
+		sql.ErrNoRows,
+		errors.LevelWarning,
+	)
+}
+
+// * refreshSQLRepository backs RefreshRepositoryTx for both SQL
+// * implementations. Unlike the upsert it never inserts, so a sync queued
+// * before the repository was purged cannot bring it back.
+func refreshSQLRepository(ctx context.Context, q queryer, repo *models.Repository, normalize func(time.Time) time.Time) error {
+	row := q.QueryRowContext(ctx, `
+		UPDATE repositories SET
+			description = $1,
+			url = $2,
+			language = $3,
+			forks_count = $4,
+			stars_count = $5,
+			open_issues_count = $6,
+			watchers_count = $7,
+			updated_at = $8
+		WHERE name = $9 AND status = 'active'
+		RETURNING id, last_commit_fetched_at
+	`, repo.Description, repo.URL, repo.Language, repo.ForksCount, repo.StarsCount,
+		repo.OpenIssuesCount, repo.WatchersCount, normalize(repo.UpdatedAt), repo.Name)
+
+	var lastFetched sql.NullTime
+	err := row.Scan(&repo.ID, &lastFetched)
+	if err == sql.ErrNoRows {
+		// * Archived, deleted or purged since the sync was queued
+		return repositoryInactive(repo.Name)
+	}
+	if err != nil {
+		return errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to refresh repository in transaction",
+			fmt.Sprintf("Could not update repository '%s' in transaction", repo.Name),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	repo.Status = models.RepositoryActive
+	repo.LastCommitFetchedAt = nil
+	if lastFetched.Valid {
+		repo.LastCommitFetchedAt = &lastFetched.Time
+	}
+	return nil
+}
+
+// * setSQLRepositoryStatus backs SetRepositoryStatus for both SQL
+// * implementations
+func setSQLRepositoryStatus(ctx context.Context, q execQueryer, name string, status models.RepositoryStatus, normalize func(time.Time) time.Time) error {
+	res, err := q.ExecContext(ctx, `
+		UPDATE repositories
+		SET status = $1, status_changed_at = $2
+		WHERE name = $3
+	`, string(status), normalize(time.Now()), name)
+	if err != nil {
+		return errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to update repository status",
+			fmt.Sprintf("Could not mark repository '%s' as %s", name, status),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return requireRepositoryRow(res, name)
+}
+
+// * deleteSQLRepository removes a repository; its commits and retention
+// * policy go with it through ON DELETE CASCADE
+func deleteSQLRepository(ctx context.Context, q execQueryer, name string) error {
+	res, err := q.ExecContext(ctx, `DELETE FROM repositories WHERE name = $1`, name)
+	if err != nil {
+		return errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to delete repository",
+			fmt.Sprintf("Could not delete repository '%s'", name),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return requireRepositoryRow(res, name)
+}
+
+// * resetSQLRepository deletes the commits of repoName authored at or after
+// * since and moves its sync position back to since, so the next sync
+// * fetches them again. A zero since deletes every commit and makes the next
+// * sync a full one. It returns the number of commits deleted.
+func resetSQLRepository(ctx context.Context, q execQueryer, repoName string, since time.Time, normalize func(time.Time) time.Time) (int, error) {
+	var fetchedAt any
+	if !since.IsZero() {
+		fetchedAt = normalize(since)
+	}
+
+	res, err := q.ExecContext(ctx, `
+		UPDATE repositories
+		SET last_commit_fetched_at = $1
+		WHERE name = $2
+	`, fetchedAt, repoName)
+	if err != nil {
+		return 0, errors.New(
+			"DB_RESET_ERROR",
+			"Failed to update repository",
+			fmt.Sprintf("Could not update last fetched time for repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+	if err := requireRepositoryRow(res, repoName); err != nil {
+		return 0, err
+	}
+
+	res, err = q.ExecContext(ctx, `
+		DELETE FROM commits
+		WHERE repository_id = (SELECT id FROM repositories WHERE name = $1) AND author_date >= $2
+	`, repoName, normalize(since))
+	if err != nil {
+		return 0, errors.New(
+			"DB_RESET_ERROR",
+			"Failed to delete commits",
+			fmt.Sprintf("Could not delete commits for repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.New(
+			"DB_RESET_ERROR",
+			"Failed to count deleted commits",
+			fmt.Sprintf("Could not read affected rows for repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+	return int(deleted), nil
+}
+
+func requireRepositoryRow(res sql.Result, name string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to update repository",
+			fmt.Sprintf("Could not tell whether repository '%s' was updated", name),
+			err,
+			errors.LevelError,
+		)
+	}
+	if n == 0 {
+		return repositoryNotFound(name)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+func resetJobNotFound(id int) error {
+	return errors.New(
+		"DB_RESET_JOB_NOT_FOUND",
+		"Reset job not found",
+		fmt.Sprintf("Reset job %d does not exist", id),
+		sql.ErrNoRows,
+		errors.LevelInfo,
+	)
+}
+
+func resetJobError(title, detail string, err error) error {
+	return errors.New("DB_RESET_JOB_ERROR", title, detail, err, errors.LevelError)
+}
+
+// * optionalTime binds t as NULL when it is nil
+func optionalTime(t *time.Time, normalize func(time.Time) time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return normalize(*t)
+}
+
+// * insertSQLResetJob stores job and fills in its ID and creation time
+func insertSQLResetJob(ctx context.Context, q queryer, job *models.ResetJob, normalize func(time.Time) time.Time) error {
+	job.CreatedAt = normalize(time.Now())
+
+	err := q.QueryRowContext(ctx, `
+		INSERT INTO reset_jobs (repository_name, since, actor, status, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, job.RepositoryName, optionalTime(job.Since, normalize), job.Actor, string(job.Status), job.CreatedAt).Scan(&job.ID)
+	if err != nil {
+		return resetJobError("Failed to create reset job", fmt.Sprintf("Could not record a reset of '%s'", job.RepositoryName), err)
+	}
+	return nil
+}
+
+// * updateSQLResetJob stores the progress of job
+func updateSQLResetJob(ctx context.Context, q execQueryer, job *models.ResetJob, normalize func(time.Time) time.Time) error {
+	res, err := q.ExecContext(ctx, `
+		UPDATE reset_jobs
+		SET status = $1, commits_deleted = $2, commits_inserted = $3, sync_run_id = $4,
+			error_reference = NULLIF($5, ''), error_message = NULLIF($6, ''), started_at = $7, finished_at = $8
+		WHERE id = $9
+	`, string(job.Status), job.CommitsDeleted, job.CommitsInserted, job.SyncRunID,
+		job.ErrorReference, job.ErrorMessage, optionalTime(job.StartedAt, normalize), optionalTime(job.FinishedAt, normalize), job.ID)
+	if err != nil {
+		return resetJobError("Failed to update reset job", fmt.Sprintf("Could not record progress of reset job %d", job.ID), err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return resetJobError("Failed to update reset job", fmt.Sprintf("Could not tell whether reset job %d was updated", job.ID), err)
+	}
+	if n == 0 {
+		return resetJobNotFound(job.ID)
+	}
+	return nil
+}
+
+// * getSQLResetJob backs GetResetJob for both SQL implementations
+func getSQLResetJob(ctx context.Context, q queryer, id int) (*models.ResetJob, error) {
+	var job models.ResetJob
+	err := q.QueryRowContext(ctx, `
+		SELECT id, repository_name, since, actor, status, commits_deleted, commits_inserted, sync_run_id,
+					COALESCE(error_reference, ''), COALESCE(error_message, ''), created_at, started_at, finished_at
+		FROM reset_jobs
+		WHERE id = $1
+	`, id).Scan(&job.ID, &job.RepositoryName, &job.Since, &job.Actor, &job.Status, &job.CommitsDeleted,
+		&job.CommitsInserted, &job.SyncRunID, &job.ErrorReference, &job.ErrorMessage, &job.CreatedAt,
+		&job.StartedAt, &job.FinishedAt)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, resetJobNotFound(id)
+	}
+	if err != nil {
+		return nil, resetJobError("Failed to fetch reset job", fmt.Sprintf("Could not read reset job %d", id), err)
+	}
+	return &job, nil
+}
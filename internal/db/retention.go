@@ -0,0 +1,289 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * execQueryer is queryer plus writes, shared by *sql.DB and *sql.Tx
+type execQueryer interface {
+	queryer
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// * The retention helpers below back both SQL implementations; the only
+// * difference between engines is how bound timestamps are normalized.
+
+func repositoryID(ctx context.Context, q queryer, repoName string) (int, error) {
+	var id int
+	err := q.QueryRowContext(ctx, `SELECT id FROM repositories WHERE name = $1`, repoName).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errors.New(
+			"DB_REPOSITORY_NOT_FOUND",
+			"Repository not found",
+			fmt.Sprintf("Repository '%s' does not exist", repoName),
+			err,
+			errors.LevelInfo,
+		)
+	}
+	if err != nil {
+		return 0, errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to fetch repository",
+			fmt.Sprintf("Could not fetch repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+	return id, nil
+}
+
+func setRetentionPolicy(ctx context.Context, q execQueryer, repoName string, policy models.RetentionPolicy, normalize func(time.Time) time.Time) (*models.RetentionPolicy, error) {
+	repoID, err := repositoryID(ctx, q, repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO retention_policies (repository_id, max_age_days, max_commits, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (repository_id) DO UPDATE SET
+			max_age_days = EXCLUDED.max_age_days,
+			max_commits = EXCLUDED.max_commits,
+			updated_at = EXCLUDED.updated_at
+	`, repoID, policy.MaxAgeDays, policy.MaxCommits, normalize(time.Now()))
+	if err != nil {
+		return nil, errors.New(
+			"DB_RETENTION_ERROR",
+			"Failed to save retention policy",
+			fmt.Sprintf("Could not save retention policy for repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return getRetentionPolicy(ctx, q, repoName)
+}
+
+const selectRetentionPolicies = `
+	SELECT p.repository_id, r.name, p.max_age_days, p.max_commits, p.updated_at
+	FROM retention_policies p
+	JOIN repositories r ON r.id = p.repository_id`
+
+func getRetentionPolicy(ctx context.Context, q queryer, repoName string) (*models.RetentionPolicy, error) {
+	policies, err := queryRetentionPolicies(ctx, q, selectRetentionPolicies+` WHERE r.name = $1`, repoName)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, errors.New(
+			"DB_RETENTION_NOT_FOUND",
+			"Retention policy not found",
+			fmt.Sprintf("Repository '%s' has no retention policy", repoName),
+			sql.ErrNoRows,
+			errors.LevelInfo,
+		)
+	}
+	return &policies[0], nil
+}
+
+func queryRetentionPolicies(ctx context.Context, q queryer, query string, args ...any) ([]models.RetentionPolicy, error) {
+	rows, err := q.QueryContext(ctx, query+` ORDER BY r.name`, args...)
+	if err != nil {
+		return nil, errors.New(
+			"DB_RETENTION_ERROR",
+			"Failed to query retention policies",
+			"Could not fetch retention policies",
+			err,
+			errors.LevelError,
+		)
+	}
+	defer rows.Close()
+
+	var policies []models.RetentionPolicy
+	for rows.Next() {
+		var p models.RetentionPolicy
+		var maxAge, maxCommits sql.NullInt64
+		if err := rows.Scan(&p.RepositoryID, &p.RepositoryName, &maxAge, &maxCommits, &p.UpdatedAt); err != nil {
+			return nil, errors.New(
+				"DB_RETENTION_ERROR",
+				"Failed to scan retention policy",
+				"Error while scanning retention policy row",
+				err,
+				errors.LevelError,
+			)
+		}
+		if maxAge.Valid {
+			n := int(maxAge.Int64)
+			p.MaxAgeDays = &n
+		}
+		if maxCommits.Valid {
+			n := int(maxCommits.Int64)
+			p.MaxCommits = &n
+		}
+		policies = append(policies, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(
+			"DB_RETENTION_ERROR",
+			"Failed to process retention policies",
+			"Error while processing retention policy rows",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return policies, nil
+}
+
+// * pruneScope returns the condition selecting the commits of repoID that
+// * policy expires at now. ok is false when nothing can expire. The commit
+// * count limit becomes a keyset bound on the newest commit still kept, so
+// * it uses the same (repository_id, author_date, id) index as paging.
+func pruneScope(ctx context.Context, q queryer, repoID int, policy models.RetentionPolicy, now time.Time, normalize func(time.Time) time.Time) (string, []any, bool, error) {
+	args := []any{repoID}
+	var conds []string
+
+	if cutoff := policy.Cutoff(now); cutoff != nil {
+		args = append(args, normalize(*cutoff))
+		conds = append(conds, fmt.Sprintf("c.author_date < $%d", len(args)))
+	}
+
+	if policy.MaxCommits != nil {
+		var keptDate time.Time
+		var keptID int
+		err := q.QueryRowContext(ctx, `
+			SELECT author_date, id FROM commits
+			WHERE repository_id = $1
+			ORDER BY author_date DESC, id DESC
+			LIMIT 1 OFFSET $2
+		`, repoID, *policy.MaxCommits-1).Scan(&keptDate, &keptID)
+
+		switch {
+		case err == sql.ErrNoRows:
+			// * Fewer commits than the limit; none expire by count
+		case err != nil:
+			return "", nil, false, errors.New(
+				"DB_RETENTION_ERROR",
+				"Failed to evaluate retention policy",
+				fmt.Sprintf("Could not find the oldest kept commit for repository '%d'", repoID),
+				err,
+				errors.LevelError,
+			)
+		default:
+			args = append(args, normalize(keptDate), keptID)
+			conds = append(conds, fmt.Sprintf("(c.author_date, c.id) < ($%d, $%d)", len(args)-1, len(args)))
+		}
+	}
+
+	if len(conds) == 0 {
+		return "", nil, false, nil
+	}
+
+	return "c.repository_id = $1 AND (" + strings.Join(conds, " OR ") + ")", args, true, nil
+}
+
+func previewPrune(ctx context.Context, q queryer, repoName string, policy models.RetentionPolicy, now time.Time, normalize func(time.Time) time.Time) (*models.PrunePreview, error) {
+	repoID, err := repositoryID(ctx, q, repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.RepositoryID = repoID
+	policy.RepositoryName = repoName
+	preview := &models.PrunePreview{
+		RepositoryName: repoName,
+		Policy:         policy,
+		Cutoff:         policy.Cutoff(now),
+	}
+
+	where, args, ok, err := pruneScope(ctx, q, repoID, policy, now, normalize)
+	if err != nil || !ok {
+		return preview, err
+	}
+
+	wrap := func(err error) error {
+		return errors.New(
+			"DB_RETENTION_ERROR",
+			"Failed to preview pruning",
+			fmt.Sprintf("Could not count expired commits for repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM commits c WHERE `+where, args...).Scan(&preview.Commits); err != nil {
+		return nil, wrap(err)
+	}
+	if preview.Commits == 0 {
+		return preview, nil
+	}
+
+	// * Read the bounds as columns rather than MIN/MAX so SQLite returns
+	// * typed timestamps
+	var oldest, newest time.Time
+	if err := q.QueryRowContext(ctx, `SELECT c.author_date FROM commits c WHERE `+where+` ORDER BY c.author_date ASC LIMIT 1`, args...).Scan(&oldest); err != nil {
+		return nil, wrap(err)
+	}
+	if err := q.QueryRowContext(ctx, `SELECT c.author_date FROM commits c WHERE `+where+` ORDER BY c.author_date DESC LIMIT 1`, args...).Scan(&newest); err != nil {
+		return nil, wrap(err)
+	}
+	preview.OldestDate = &oldest
+	preview.NewestDate = &newest
+
+	return preview, nil
+}
+
+// * pruneCommits deletes at most batchSize expired commits, oldest first.
+// * Derived rows, such as the SQLite FTS index, go with them via triggers
+// * or generated columns.
+func pruneCommits(ctx context.Context, q execQueryer, repoName string, policy models.RetentionPolicy, now time.Time, batchSize int, normalize func(time.Time) time.Time) (int, error) {
+	repoID, err := repositoryID(ctx, q, repoName)
+	if err != nil {
+		return 0, err
+	}
+
+	where, args, ok, err := pruneScope(ctx, q, repoID, policy, now, normalize)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	args = append(args, batchSize)
+	res, err := q.ExecContext(ctx, fmt.Sprintf(`
+		DELETE FROM commits WHERE id IN (
+			SELECT c.id FROM commits c
+			WHERE %s
+			ORDER BY c.author_date, c.id
+			LIMIT $%d
+		)
+	`, where, len(args)), args...)
+	if err != nil {
+		return 0, errors.New(
+			"DB_RETENTION_ERROR",
+			"Failed to prune commits",
+			fmt.Sprintf("Could not delete expired commits for repository '%s'", repoName),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.New(
+			"DB_RETENTION_ERROR",
+			"Failed to prune commits",
+			"Could not read number of deleted commits",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return int(deleted), nil
+}
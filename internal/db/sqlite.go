@@ -0,0 +1,702 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/mailmap"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/search"
+	"github.com/KOFI-GYIMAH/github-monitor/migrations"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// * sqliteParams are appended to every SQLite DSN. Times are written in a
+// * sortable format so range filters on author_date compare correctly.
+const sqliteParams = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+
+type SQLiteDB struct {
+	db    *sql.DB
+	locks processLocks
+}
+
+// * NewSQLiteDB opens the SQLite database file at path, creating it if needed.
+// * Pass ":memory:" for a throwaway database.
+func NewSQLiteDB(path string) (*SQLiteDB, error) {
+	if path == "" {
+		return nil, errors.New(
+			"DB_CONNECTION_ERROR",
+			"Failed to open database connection",
+			"SQLite database path is empty",
+			nil,
+			errors.LevelError,
+		)
+	}
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+sep+sqliteParams)
+	if err != nil {
+		return nil, errors.New(
+			"DB_CONNECTION_ERROR",
+			"Failed to open database connection",
+			"Could not initialize database connection",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	// * SQLite allows a single writer; one connection avoids SQLITE_BUSY and
+	// * keeps in-memory databases shared across calls
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+
+	if err := db.Ping(); err != nil {
+		return nil, errors.New(
+			"DB_CONNECTION_ERROR",
+			"Failed to verify database connection",
+			"Database ping failed",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	logger.Info("connected to sqlite database successfully 🎉")
+	return &SQLiteDB{db: db}, nil
+}
+
+// * sqliteMigrationDriver keeps the shared *sql.DB open when a Migrator is
+// * closed; the stock driver closes the pool it was given
+type sqliteMigrationDriver struct {
+	database.Driver
+}
+
+func (sqliteMigrationDriver) Close() error { return nil }
+
+// * Migrator returns a Migrator over the embedded SQLite migrations
+func (s *SQLiteDB) Migrator() (*Migrator, error) {
+	driver, err := sqlite.WithInstance(s.db, &sqlite.Config{})
+	if err != nil {
+		return nil, errors.New(
+			"DB_MIGRATION_ERROR",
+			"Failed to create migration driver",
+			"Could not initialize migration driver instance",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return newMigrator(migrations.SQLite, "sqlite", "sqlite", sqliteMigrationDriver{driver})
+}
+
+// * Migrate refuses a dirty or too-new schema, then applies pending migrations
+func (s *SQLiteDB) Migrate() error {
+	return checkAndMigrate(s.Migrator())
+}
+
+func (s *SQLiteDB) Close() error {
+	if err := s.db.Close(); err != nil {
+		return errors.New(
+			"DB_CONNECTION_ERROR",
+			"Failed to close database connection",
+			"Error while closing database connection",
+			err,
+			errors.LevelWarning,
+		)
+	}
+	return nil
+}
+
+func (s *SQLiteDB) WithTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(
+			"DB_TRANSACTION_ERROR",
+			"Failed to begin transaction",
+			"Could not start database transaction",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.New(
+				"DB_TRANSACTION_ERROR",
+				"Transaction failed and rollback encountered error",
+				"Transaction error with additional rollback failure",
+				fmt.Errorf("transaction error: %v, rollback error: %w", err, rbErr),
+				errors.LevelError,
+			)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.New(
+			"DB_TRANSACTION_ERROR",
+			"Failed to commit transaction",
+			"Error while committing transaction",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return nil
+}
+
+// * utcPtr normalizes optional timestamps so stored values sort lexically
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+const sqliteUpsertRepository = `
+	INSERT INTO repositories (
+		name, description, url, language, forks_count, stars_count,
+		open_issues_count, watchers_count, created_at, updated_at
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT(name) DO UPDATE SET
+		description = excluded.description,
+		url = excluded.url,
+		language = excluded.language,
+		forks_count = excluded.forks_count,
+		stars_count = excluded.stars_count,
+		open_issues_count = excluded.open_issues_count,
+		watchers_count = excluded.watchers_count,
+		updated_at = excluded.updated_at
+	WHERE repositories.status = 'active'
+	RETURNING id, last_commit_fetched_at
+`
+
+func (s *SQLiteDB) UpsertRepository(ctx context.Context, repo *models.Repository) error {
+	row := s.db.QueryRowContext(ctx, sqliteUpsertRepository,
+		repo.Name, repo.Description, repo.URL, repo.Language, repo.ForksCount,
+		repo.StarsCount, repo.OpenIssuesCount, repo.WatchersCount,
+		repo.CreatedAt.UTC(), repo.UpdatedAt.UTC(),
+	)
+
+	var lastFetched sql.NullTime
+	err := row.Scan(&repo.ID, &lastFetched)
+	if err == sql.ErrNoRows {
+		// * The update is skipped for archived and deleted repositories
+		return repositoryInactive(repo.Name)
+	}
+	if err != nil {
+		return errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to upsert repository",
+			fmt.Sprintf("Could not upsert repository '%s'", repo.Name),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	repo.Status = models.RepositoryActive
+	if lastFetched.Valid {
+		repo.LastCommitFetchedAt = &lastFetched.Time
+	}
+
+	return nil
+}
+
+func (s *SQLiteDB) GetRepository(ctx context.Context, name string) (*models.Repository, error) {
+	return getSQLiteRepository(ctx, s.db, name)
+}
+
+func getSQLiteRepository(ctx context.Context, q queryer, name string) (*models.Repository, error) {
+	query := `
+		SELECT id, name, description, url, language, forks_count, stars_count,
+		open_issues_count, watchers_count, created_at, updated_at, last_commit_fetched_at,
+		status, status_changed_at
+		FROM repositories
+		WHERE name = $1
+	`
+
+	row := q.QueryRowContext(ctx, query, name)
+
+	var repo models.Repository
+	var lastFetched, statusChanged sql.NullTime
+
+	err := row.Scan(
+		&repo.ID, &repo.Name, &repo.Description, &repo.URL, &repo.Language,
+		&repo.ForksCount, &repo.StarsCount, &repo.OpenIssuesCount, &repo.WatchersCount,
+		&repo.CreatedAt, &repo.UpdatedAt, &lastFetched, &repo.Status, &statusChanged,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New(
+				"DB_REPOSITORY_NOT_FOUND",
+				"Repository not found",
+				fmt.Sprintf("Repository '%s' does not exist", name),
+				err,
+				errors.LevelInfo,
+			)
+		}
+		return nil, errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to fetch repository",
+			fmt.Sprintf("Could not fetch repository '%s'", name),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	if lastFetched.Valid {
+		repo.LastCommitFetchedAt = &lastFetched.Time
+	}
+	if statusChanged.Valid {
+		repo.StatusChangedAt = &statusChanged.Time
+	}
+
+	return &repo, nil
+}
+
+func (s *SQLiteDB) GetAllRepositories(ctx context.Context) ([]*models.Repository, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name, status FROM repositories WHERE status <> 'deleted'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repos []*models.Repository
+	for rows.Next() {
+		var r models.Repository
+		if err := rows.Scan(&r.Name, &r.Status); err != nil {
+			return nil, errors.New(
+				"DB_REPOSITORY_ERROR",
+				"Failed to fetch repository",
+				fmt.Sprintf("Could not fetch repository '%s'", r.Name),
+				err,
+				errors.LevelError)
+		}
+		repos = append(repos, &r)
+	}
+
+	return repos, nil
+}
+
+func (s *SQLiteDB) UpdateRepository(ctx context.Context, repo *models.Repository) error {
+	query := `
+		UPDATE repositories
+		SET last_commit_fetched_at = $1
+		WHERE id = $2
+	`
+
+	_, err := s.db.ExecContext(ctx, query, utcPtr(repo.LastCommitFetchedAt), repo.ID)
+	if err != nil {
+		return errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to update repository",
+			fmt.Sprintf("Could not update repository '%d'", repo.ID),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return nil
+}
+
+// * InsertCommit resolves the commit author and stores both in one
+// * transaction
+func (s *SQLiteDB) InsertCommit(ctx context.Context, commit *models.Commit) error {
+	return s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return insertSQLCommit(ctx, tx, commit, time.Time.UTC)
+	})
+}
+
+// * GetCommits returns one page of commits, newest first, using keyset
+// * pagination on (author_date, id) so deep pages cost the same as the first
+func (s *SQLiteDB) GetCommits(ctx context.Context, repoName string, filter models.CommitFilter) (*models.CommitPage, error) {
+	return queryCommitPage(ctx, s.db, repoName, filter, time.Time.UTC)
+}
+
+func (s *SQLiteDB) GetTopAuthors(ctx context.Context, repoName string, limit int, includeBots bool) ([]models.AuthorCommitCount, error) {
+	return queryTopAuthors(ctx, s.db, repoName, limit, includeBots)
+}
+
+// * FindCommit looks a commit up by SHA or by a prefix of it
+func (s *SQLiteDB) FindCommit(ctx context.Context, repoName, shaPrefix string) (*models.Commit, error) {
+	return queryCommitBySHA(ctx, s.db, repoName, shaPrefix)
+}
+
+func (s *SQLiteDB) GetChangelogCommits(ctx context.Context, repoName string, filter models.ChangelogFilter) ([]models.ChangelogCommit, error) {
+	return queryChangelogCommits(ctx, s.db, repoName, filter, time.Time.UTC)
+}
+
+// * GetCommitActivity counts commits per exact timestamp and buckets them
+// * here, since SQLite has no notion of named time zones
+func (s *SQLiteDB) GetCommitActivity(ctx context.Context, filter models.ActivityFilter) ([]models.ActivityCount, error) {
+	query, args := buildActivityQuery(filter, "c.author_date", nil, time.Time.UTC)
+	return queryActivity(ctx, s.db, query, args, func(t time.Time) time.Time {
+		return filter.Interval.Start(t.In(filter.Location))
+	})
+}
+
+// * GetPunchCard counts commits per exact timestamp and offset, leaving the
+// * time zone arithmetic to Go like GetCommitActivity
+func (s *SQLiteDB) GetPunchCard(ctx context.Context, filter models.PunchCardFilter) (models.PunchCardCounts, error) {
+	query, args := buildPunchCardQuery(filter, "c.author_date, COALESCE(c.author_utc_offset, 0), COUNT(*)", nil, time.Time.UTC)
+	return queryPunchCard(ctx, s.db, filter, query, args)
+}
+
+// * snippetMarks delimit the matches in the snippets returned by
+// * SearchCommits for search.Snippet, which escapes the rest of the message
+var snippetMarks = []any{search.SnippetStart, search.SnippetStop}
+
+// * SearchCommits ranks commits whose message matches the query using the
+// * commits_fts FTS5 index. bm25 scores are negated so higher is better.
+func (s *SQLiteDB) SearchCommits(ctx context.Context, search models.CommitSearch) ([]models.CommitSearchResult, error) {
+	args := []any{search.Query.FTS5()}
+	where := "commits_fts MATCH $1 AND r.status <> 'deleted'"
+
+	if len(search.Repositories) > 0 {
+		placeholders := make([]string, len(search.Repositories))
+		for i, name := range search.Repositories {
+			args = append(args, name)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		where += " AND r.name IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if search.ExcludeBots {
+		where += excludeBotCommits
+	}
+
+	args = append(args, search.Limit)
+	limitParam := len(args)
+	args = append(args, snippetMarks...)
+	query := fmt.Sprintf(`
+		SELECT c.id, c.sha, c.repository_id, c.message, c.author_name, c.author_email,
+					c.author_date, c.commit_url, COALESCE(c.author_login, ''), c.author_id, r.name,
+					-bm25(commits_fts) AS rank,
+					snippet(commits_fts, 0, $%d, $%d, '…', 24) AS snippet
+		FROM commits_fts
+		JOIN commits c ON c.id = commits_fts.rowid
+		JOIN repositories r ON c.repository_id = r.id
+		WHERE %s
+		ORDER BY rank DESC, c.author_date DESC
+		LIMIT $%d
+	`, limitParam+1, limitParam+2, where, limitParam)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New(
+			"DB_SEARCH_ERROR",
+			"Failed to search commits",
+			"Could not run full-text search over commit messages",
+			err,
+			errors.LevelError,
+		)
+	}
+	defer rows.Close()
+
+	return scanSearchResults(rows)
+}
+
+// * SetRepositoryStatus archives, soft-deletes or restores a repository
+func (s *SQLiteDB) SetRepositoryStatus(ctx context.Context, name string, status models.RepositoryStatus) (*models.Repository, error) {
+	var repo *models.Repository
+	err := s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		repo, err = s.SetRepositoryStatusTx(ctx, tx, name, status)
+		return err
+	})
+	return repo, err
+}
+
+func (s *SQLiteDB) DeleteRepository(ctx context.Context, name string) error {
+	return deleteSQLRepository(ctx, s.db, name)
+}
+
+// * RecordAudit appends an audit entry outside any transaction, for actions
+// * that failed or ran their own
+func (s *SQLiteDB) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	return insertSQLAudit(ctx, s.db, entry, time.Time.UTC)
+}
+
+// * GetAuditLog returns audit entries matching filter, newest first
+func (s *SQLiteDB) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	return querySQLAuditLog(ctx, s.db, filter, time.Time.UTC)
+}
+
+// * StartSyncRun records that a sync has started. It runs outside the sync's
+// * transaction so the run is kept even when the sync rolls back.
+func (s *SQLiteDB) StartSyncRun(ctx context.Context, run *models.SyncRun) error {
+	return insertSQLSyncRun(ctx, s.db, run, time.Time.UTC)
+}
+
+// * FinishSyncRun records the outcome of a started sync
+func (s *SQLiteDB) FinishSyncRun(ctx context.Context, run *models.SyncRun) error {
+	return finishSQLSyncRun(ctx, s.db, run, time.Time.UTC)
+}
+
+func (s *SQLiteDB) CreateResetJob(ctx context.Context, job *models.ResetJob) error {
+	return insertSQLResetJob(ctx, s.db, job, time.Time.UTC)
+}
+
+func (s *SQLiteDB) UpdateResetJob(ctx context.Context, job *models.ResetJob) error {
+	return updateSQLResetJob(ctx, s.db, job, time.Time.UTC)
+}
+
+func (s *SQLiteDB) GetResetJob(ctx context.Context, id int) (*models.ResetJob, error) {
+	return getSQLResetJob(ctx, s.db, id)
+}
+
+// * TryLock takes an in-process lock; a SQLite file is served by one
+// * process at a time
+func (s *SQLiteDB) TryLock(ctx context.Context, name string) (models.Lock, error) {
+	return s.locks.tryLock(name), nil
+}
+
+// * EnqueueJob queues job unless a job with its dedupe key is pending
+func (s *SQLiteDB) EnqueueJob(ctx context.Context, job *models.Job) (bool, error) {
+	return enqueueSQLJob(ctx, s.db, job, time.Time.UTC)
+}
+
+// * ClaimJob locks the next due job for worker. SQLite runs one write at a
+// * time, so the UPDATE alone keeps the claim exclusive.
+func (s *SQLiteDB) ClaimJob(ctx context.Context, worker string, now, lockedUntil time.Time) (*models.Job, error) {
+	return claimSQLJob(ctx, s.db, worker, now, lockedUntil, "", time.Time.UTC)
+}
+
+func (s *SQLiteDB) ExtendJob(ctx context.Context, id int, worker string, lockedUntil time.Time) error {
+	return extendSQLJob(ctx, s.db, id, worker, lockedUntil, time.Time.UTC)
+}
+
+func (s *SQLiteDB) CompleteJob(ctx context.Context, id int, worker string) error {
+	return completeSQLJob(ctx, s.db, id, worker, time.Time.UTC)
+}
+
+func (s *SQLiteDB) FailJob(ctx context.Context, id int, worker, message string, retryAt *time.Time) error {
+	return failSQLJob(ctx, s.db, id, worker, message, retryAt, time.Time.UTC)
+}
+
+func (s *SQLiteDB) DeferJob(ctx context.Context, id int, worker string, runAt time.Time) error {
+	return deferSQLJob(ctx, s.db, id, worker, runAt, time.Time.UTC)
+}
+
+// * GetJobs returns jobs matching filter, newest first
+func (s *SQLiteDB) GetJobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	return querySQLJobs(ctx, s.db, filter)
+}
+
+// * RebuildDailyStats recomputes commit_daily_stats from commits for
+// * repoName, or for every repository when it is empty
+func (s *SQLiteDB) RebuildDailyStats(ctx context.Context, repoName string) (int, error) {
+	var n int
+	err := s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		n, err = rebuildSQLDailyStats(ctx, tx, repoName, "date(c.author_date)")
+		return err
+	})
+	return n, err
+}
+
+// * GetSyncRuns returns sync runs matching filter, newest first
+func (s *SQLiteDB) GetSyncRuns(ctx context.Context, filter models.SyncRunFilter) ([]models.SyncRun, error) {
+	return querySQLSyncRuns(ctx, s.db, filter)
+}
+
+func (s *SQLiteDB) ResetRepository(ctx context.Context, repoName string, since time.Time) (int, error) {
+	return resetSQLRepository(ctx, s.db, repoName, since, time.Time.UTC)
+}
+
+func (s *SQLiteDB) SetRetentionPolicy(ctx context.Context, repoName string, policy models.RetentionPolicy) (*models.RetentionPolicy, error) {
+	return setRetentionPolicy(ctx, s.db, repoName, policy, time.Time.UTC)
+}
+
+func (s *SQLiteDB) GetRetentionPolicy(ctx context.Context, repoName string) (*models.RetentionPolicy, error) {
+	return getRetentionPolicy(ctx, s.db, repoName)
+}
+
+func (s *SQLiteDB) GetRetentionPolicies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	return queryRetentionPolicies(ctx, s.db, selectRetentionPolicies)
+}
+
+func (s *SQLiteDB) PreviewPrune(ctx context.Context, repoName string, policy models.RetentionPolicy, now time.Time) (*models.PrunePreview, error) {
+	return previewPrune(ctx, s.db, repoName, policy, now, time.Time.UTC)
+}
+
+// * PruneCommits deletes one batch of expired commits; callers loop until
+// * fewer than batchSize rows come back so no statement holds locks for long
+func (s *SQLiteDB) PruneCommits(ctx context.Context, repoName string, policy models.RetentionPolicy, now time.Time, batchSize int) (int, error) {
+	return pruneCommits(ctx, s.db, repoName, policy, now, batchSize, time.Time.UTC)
+}
+
+func (s *SQLiteDB) GetAuthors(ctx context.Context, limit int) ([]models.Author, error) {
+	return getSQLAuthors(ctx, s.db, limit)
+}
+
+func (s *SQLiteDB) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	return getSQLAuthor(ctx, s.db, id)
+}
+
+func (s *SQLiteDB) MergeAuthors(ctx context.Context, targetID int, sourceIDs []int) (*models.Author, error) {
+	var author *models.Author
+	err := s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		author, err = mergeAuthors(ctx, sqlAuthorStore{q: tx, normalize: time.Time.UTC}, targetID, sourceIDs)
+		return err
+	})
+	return author, err
+}
+
+func (s *SQLiteDB) SplitAuthor(ctx context.Context, authorID int, identityIDs []int, name string) (*models.Author, error) {
+	var author *models.Author
+	err := s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		author, err = splitAuthor(ctx, sqlAuthorStore{q: tx, normalize: time.Time.UTC}, authorID, identityIDs, name)
+		return err
+	})
+	return author, err
+}
+
+func (s *SQLiteDB) GetMailmap(ctx context.Context) (mailmap.Map, error) {
+	return sqlAuthorStore{q: s.db, normalize: time.Time.UTC}.loadMailmap(ctx)
+}
+
+// * SetMailmap replaces the mailmap and applies it to resolved authors
+func (s *SQLiteDB) SetMailmap(ctx context.Context, m mailmap.Map) error {
+	return s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return applyMailmap(ctx, sqlAuthorStore{q: tx, normalize: time.Time.UTC}, m)
+	})
+}
+
+func (s *SQLiteDB) GetBotRules(ctx context.Context) (bots.Rules, error) {
+	return sqlAuthorStore{q: s.db, normalize: time.Time.UTC}.loadBotRules(ctx)
+}
+
+// * SetBotRules replaces the bot rules and reclassifies every author under them
+func (s *SQLiteDB) SetBotRules(ctx context.Context, rules bots.Rules) error {
+	return s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return applyBotRules(ctx, sqlAuthorStore{q: tx, normalize: time.Time.UTC}, rules)
+	})
+}
+
+// * ResolveCommitAuthors resolves one batch of commits stored without an
+// * author and reports how many it resolved
+func (s *SQLiteDB) ResolveCommitAuthors(ctx context.Context, batchSize int) (int, error) {
+	resolved := 0
+	err := s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		resolved, err = resolveCommitAuthors(ctx, sqlAuthorStore{q: tx, normalize: time.Time.UTC}, batchSize)
+		return err
+	})
+	return resolved, err
+}
+
+// * SQLite has no table partitioning; retention deletes rows instead
+func (s *SQLiteDB) MaintainPartitions(ctx context.Context, now time.Time) error { return nil }
+func (s *SQLiteDB) DropExpiredPartitions(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+// * Transaction versions of methods for use with WithTransaction
+func (s *SQLiteDB) UpsertRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
+	row := tx.QueryRowContext(ctx, sqliteUpsertRepository,
+		repo.Name, repo.Description, repo.URL, repo.Language, repo.ForksCount,
+		repo.StarsCount, repo.OpenIssuesCount, repo.WatchersCount,
+		repo.CreatedAt.UTC(), repo.UpdatedAt.UTC(),
+	)
+
+	var lastFetched sql.NullTime
+	err := row.Scan(&repo.ID, &lastFetched)
+	if err == sql.ErrNoRows {
+		// * The update is skipped for archived and deleted repositories
+		return repositoryInactive(repo.Name)
+	}
+	if err != nil {
+		return errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to upsert repository in transaction",
+			fmt.Sprintf("Could not upsert repository '%s' in transaction", repo.Name),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	repo.Status = models.RepositoryActive
+	if lastFetched.Valid {
+		repo.LastCommitFetchedAt = &lastFetched.Time
+	}
+
+	return nil
+}
+
+func (s *SQLiteDB) RefreshRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
+	return refreshSQLRepository(ctx, tx, repo, time.Time.UTC)
+}
+
+func (s *SQLiteDB) InsertCommitTx(ctx context.Context, tx *sql.Tx, commit *models.Commit) error {
+	return insertSQLCommit(ctx, tx, commit, time.Time.UTC)
+}
+
+// * InsertCommitsTx inserts commits with multi-row statements, one round trip
+// * per batch instead of one per commit
+func (s *SQLiteDB) InsertCommitsTx(ctx context.Context, tx *sql.Tx, commits []models.Commit) (models.InsertResult, error) {
+	return insertSQLCommits(ctx, tx, commits, time.Time.UTC)
+}
+
+func (s *SQLiteDB) UpdateRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
+	query := `
+		UPDATE repositories
+		SET last_commit_fetched_at = $1
+		WHERE id = $2
+	`
+
+	_, err := tx.ExecContext(ctx, query, utcPtr(repo.LastCommitFetchedAt), repo.ID)
+	if err != nil {
+		return errors.New(
+			"DB_REPOSITORY_ERROR",
+			"Failed to update repository in transaction",
+			fmt.Sprintf("Could not update repository '%d' in transaction", repo.ID),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return nil
+}
+
+func (s *SQLiteDB) ResetRepositoryTx(ctx context.Context, tx *sql.Tx, repoName string, since time.Time) (int, error) {
+	return resetSQLRepository(ctx, tx, repoName, since, time.Time.UTC)
+}
+
+func (s *SQLiteDB) SetRepositoryStatusTx(ctx context.Context, tx *sql.Tx, name string, status models.RepositoryStatus) (*models.Repository, error) {
+	if err := setSQLRepositoryStatus(ctx, tx, name, status, time.Time.UTC); err != nil {
+		return nil, err
+	}
+	return getSQLiteRepository(ctx, tx, name)
+}
+
+func (s *SQLiteDB) DeleteRepositoryTx(ctx context.Context, tx *sql.Tx, name string) error {
+	return deleteSQLRepository(ctx, tx, name)
+}
+
+// * RecordAuditTx appends an audit entry in the same transaction as the
+// * action it describes, so one is never stored without the other
+func (s *SQLiteDB) RecordAuditTx(ctx context.Context, tx *sql.Tx, entry *models.AuditEntry) error {
+	return insertSQLAudit(ctx, tx, entry, time.Time.UTC)
+}
@@ -0,0 +1,576 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLite(t *testing.T) *SQLiteDB {
+	t.Helper()
+
+	s, err := NewSQLiteDB(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	require.NoError(t, s.Migrate())
+
+	return s
+}
+
+func seedSQLiteRepo(t *testing.T, s *SQLiteDB, name string) *models.Repository {
+	t.Helper()
+
+	repo := &models.Repository{
+		Name:      name,
+		URL:       "https://github.com/" + name,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	require.NoError(t, s.UpsertRepository(context.Background(), repo))
+	return repo
+}
+
+func TestSQLite_UpsertAndGetRepository(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+
+	repo := seedSQLiteRepo(t, s, "test/repo")
+	assert.NotZero(t, repo.ID)
+
+	repo.StarsCount = 42
+	require.NoError(t, s.UpsertRepository(ctx, repo))
+
+	got, err := s.GetRepository(ctx, "test/repo")
+	require.NoError(t, err)
+	assert.Equal(t, repo.ID, got.ID)
+	assert.Equal(t, 42, got.StarsCount)
+	assert.Nil(t, got.LastCommitFetchedAt)
+
+	_, err = s.GetRepository(ctx, "missing/repo")
+	assert.Error(t, err)
+}
+
+func TestSQLite_InsertCommitIgnoresDuplicates(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	commit := &models.Commit{
+		SHA:          "abc123",
+		RepositoryID: repo.ID,
+		Message:      "initial commit",
+		AuthorName:   "test",
+		AuthorEmail:  "test@example.com",
+		AuthorDate:   time.Now(),
+		CommitURL:    "https://github.com/commit/abc123",
+	}
+	require.NoError(t, s.InsertCommit(ctx, commit))
+	require.NoError(t, s.InsertCommit(ctx, commit))
+
+	commits := listCommits(t, s, "test/repo", models.CommitFilter{})
+	assert.Len(t, commits, 1)
+}
+
+func TestSQLite_GetCommitsFiltersByDate(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// * Mixed zones make sure stored values compare as instants, not strings
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	dates := []time.Time{base, base.Add(24 * time.Hour).In(zone), base.Add(48 * time.Hour)}
+	for i, d := range dates {
+		require.NoError(t, s.InsertCommit(ctx, &models.Commit{
+			SHA:          string(rune('a' + i)),
+			RepositoryID: repo.ID,
+			Message:      "commit",
+			AuthorName:   "test",
+			AuthorDate:   d,
+			CommitURL:    "url",
+		}))
+	}
+
+	since := base.Add(12 * time.Hour)
+	commits := listCommits(t, s, "test/repo", models.CommitFilter{Since: &since})
+	require.Len(t, commits, 2)
+	assert.Equal(t, "c", commits[0].SHA)
+	assert.Equal(t, "b", commits[1].SHA)
+	assert.True(t, dates[1].Equal(commits[1].AuthorDate))
+
+	until := base.Add(36 * time.Hour)
+	commits = listCommits(t, s, "test/repo", models.CommitFilter{Since: &since, Until: &until})
+	require.Len(t, commits, 1)
+	assert.Equal(t, "b", commits[0].SHA)
+}
+
+func TestSQLite_GetTopAuthors(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	for i, author := range []string{"alice", "bob", "alice", "alice", "bob", "carol"} {
+		require.NoError(t, s.InsertCommit(ctx, &models.Commit{
+			SHA:          string(rune('a' + i)),
+			RepositoryID: repo.ID,
+			Message:      "commit",
+			AuthorName:   author,
+			AuthorDate:   time.Now(),
+			CommitURL:    "url",
+		}))
+	}
+
+	authors, err := s.GetTopAuthors(ctx, "test/repo", 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, []models.AuthorCommitCount{
+		{AuthorID: 1, AuthorName: "alice", CommitCount: 3},
+		{AuthorID: 2, AuthorName: "bob", CommitCount: 2},
+	}, authors)
+}
+
+func TestSQLite_ResetRepository(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for sha, date := range map[string]time.Time{"old": since.Add(-time.Hour), "abc": since, "def": time.Now()} {
+		require.NoError(t, s.InsertCommit(ctx, &models.Commit{
+			SHA: sha, RepositoryID: repo.ID, Message: "m", AuthorDate: date, CommitURL: "url",
+		}))
+	}
+
+	// * Only commits from since onwards are deleted
+	deleted, err := s.ResetRepository(ctx, "test/repo", since)
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	commits := listCommits(t, s, "test/repo", models.CommitFilter{})
+	require.Len(t, commits, 1)
+	assert.Equal(t, "old", commits[0].SHA)
+
+	got, err := s.GetRepository(ctx, "test/repo")
+	require.NoError(t, err)
+	require.NotNil(t, got.LastCommitFetchedAt)
+	assert.True(t, since.Equal(*got.LastCommitFetchedAt))
+
+	// * A zero since clears everything and makes the next sync a full one
+	deleted, err = s.ResetRepository(ctx, "test/repo", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	got, err = s.GetRepository(ctx, "test/repo")
+	require.NoError(t, err)
+	assert.Nil(t, got.LastCommitFetchedAt)
+
+	_, err = s.ResetRepository(ctx, "missing/repo", since)
+	assert.ErrorContains(t, err, "DB_REPOSITORY_NOT_FOUND")
+}
+
+func TestSQLite_WithTransactionRollsBack(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+
+	err := s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		repo := &models.Repository{Name: "test/repo", URL: "url"}
+		if err := s.UpsertRepositoryTx(ctx, tx, repo); err != nil {
+			return err
+		}
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	repos, err := s.GetAllRepositories(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, repos)
+
+	err = s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		repo := &models.Repository{Name: "test/repo", URL: "url"}
+		if err := s.UpsertRepositoryTx(ctx, tx, repo); err != nil {
+			return err
+		}
+		now := time.Now()
+		repo.LastCommitFetchedAt = &now
+		return s.UpdateRepositoryTx(ctx, tx, repo)
+	})
+	require.NoError(t, err)
+
+	got, err := s.GetRepository(ctx, "test/repo")
+	require.NoError(t, err)
+	assert.NotNil(t, got.LastCommitFetchedAt)
+}
+
+func TestSQLite_InsertCommitsTxReportsSkipped(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	require.NoError(t, s.InsertCommit(ctx, &models.Commit{
+		SHA: "sha0", RepositoryID: repo.ID, Message: "m", AuthorDate: time.Now(), CommitURL: "url",
+	}))
+
+	commits := make([]models.Commit, commitBatchSize+5)
+	for i := range commits {
+		commits[i] = models.Commit{
+			SHA:          fmt.Sprintf("sha%d", i),
+			RepositoryID: repo.ID,
+			Message:      "m",
+			AuthorDate:   time.Now(),
+			CommitURL:    "url",
+		}
+	}
+	// * Duplicates inside the same batch are skipped as well
+	commits = append(commits, commits[1])
+
+	var result models.InsertResult
+	err := s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		result, err = s.InsertCommitsTx(ctx, tx, commits)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.InsertResult{Inserted: commitBatchSize + 4, Skipped: 2}, result)
+
+	stored := listCommits(t, s, "test/repo", models.CommitFilter{})
+	assert.Len(t, stored, commitBatchSize+5)
+}
+
+func TestSQLite_GetCommitsKeysetPagination(t *testing.T) {
+	s := newTestSQLite(t)
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	assertKeysetPagination(t, s, repo.ID)
+}
+
+func TestSQLite_SearchCommits(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	chromium := seedSQLiteRepo(t, s, "chromium/chromium")
+	v8 := seedSQLiteRepo(t, s, "v8/v8")
+
+	messages := []struct {
+		repoID  int
+		sha     string
+		message string
+	}{
+		{chromium.ID, "a", "Fix use-after-free in compositor"},
+		{chromium.ID, "b", "Revert \"Fix use after free in compositor\""},
+		{chromium.ID, "c", "Free memory after use"},
+		{v8.ID, "d", "Fix regression in use after free handling"},
+	}
+	for _, m := range messages {
+		require.NoError(t, s.InsertCommit(ctx, &models.Commit{
+			SHA: m.sha, RepositoryID: m.repoID, Message: m.message, AuthorDate: time.Now(), CommitURL: "url",
+		}))
+	}
+
+	q, err := search.Parse(`"use after free" -revert`)
+	require.NoError(t, err)
+
+	results, err := s.SearchCommits(ctx, models.CommitSearch{Query: q, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.ElementsMatch(t, []string{"a", "d"}, []string{results[0].SHA, results[1].SHA})
+	assert.Contains(t, results[0].Snippet, "<mark>")
+
+	results, err = s.SearchCommits(ctx, models.CommitSearch{Query: q, Repositories: []string{"v8/v8"}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "v8/v8", results[0].RepositoryName)
+
+	q, err = search.Parse("regress*")
+	require.NoError(t, err)
+	results, err = s.SearchCommits(ctx, models.CommitSearch{Query: q, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "d", results[0].SHA)
+}
+
+func TestSQLite_Retention(t *testing.T) {
+	s := newTestSQLite(t)
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	assertRetention(t, s, repo.ID)
+
+	// * Pruned rows leave the full-text index too
+	q, err := search.Parse("m")
+	require.NoError(t, err)
+	results, err := s.SearchCommits(context.Background(), models.CommitSearch{Query: q, Limit: 100})
+	require.NoError(t, err)
+	assert.Len(t, results, 3)
+}
+
+func TestSQLite_Bots(t *testing.T) {
+	s := newTestSQLite(t)
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	assertBots(t, s, repo.ID)
+}
+
+func TestSQLite_Activity(t *testing.T) {
+	s := newTestSQLite(t)
+	repo := seedSQLiteRepo(t, s, "test/repo")
+	other := seedSQLiteRepo(t, s, "test/other")
+
+	assertActivity(t, s, repo.ID, other.ID)
+}
+
+func TestSQLite_PunchCard(t *testing.T) {
+	s := newTestSQLite(t)
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	assertPunchCard(t, s, repo.ID)
+}
+
+func TestSQLite_Authors(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	assertAuthors(t, s, repo.ID)
+
+	// * Commits stored before identities existed are resolved by the backfill
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO commits (sha, repository_id, message, author_name, author_email, author_date, commit_url, author_login)
+		VALUES ('old', $1, 'm', 'bobby', 'bob@laptop.local', $2, 'url', 'bobby')
+	`, repo.ID, time.Now().UTC())
+	require.NoError(t, err)
+
+	resolved, err := s.ResolveCommitAuthors(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resolved)
+	assert.Equal(t, 4, topAuthors(t, s)["Robert"].CommitCount)
+
+	resolved, err = s.ResolveCommitAuthors(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, resolved)
+}
+
+// * dailyStats returns every commit_daily_stats row as one string
+func dailyStats(t *testing.T, s *SQLiteDB) []string {
+	t.Helper()
+
+	rows, err := s.db.Query(`
+		SELECT repository_id, day, author_id, author_name, commit_count
+		FROM commit_daily_stats ORDER BY 1, 2, 3, 4
+	`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var stats []string
+	for rows.Next() {
+		var repoID, authorID, count int
+		var day, name string
+		require.NoError(t, rows.Scan(&repoID, &day, &authorID, &name, &count))
+		stats = append(stats, fmt.Sprintf("%d %s %d %q %d", repoID, day, authorID, name, count))
+	}
+	require.NoError(t, rows.Err())
+	return stats
+}
+
+func TestSQLite_DailyStats(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	// * Resolution, mailmap, split and merge all move counts between authors
+	assertAuthors(t, s, repo.ID)
+
+	// * Days are UTC, whatever offset the commit was authored in
+	east := time.FixedZone("UTC+3", 3*60*60)
+	for i, date := range []time.Time{
+		time.Date(2024, 3, 1, 1, 0, 0, 0, east),
+		time.Date(2024, 3, 1, 4, 0, 0, 0, east),
+		time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC),
+	} {
+		require.NoError(t, s.InsertCommit(ctx, &models.Commit{
+			SHA: fmt.Sprintf("d%d", i), RepositoryID: repo.ID, Message: "m",
+			AuthorName: "dora", AuthorEmail: "dora@example.com", AuthorDate: date, CommitURL: "url",
+		}))
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM commits WHERE sha = 'd2'`)
+	require.NoError(t, err)
+
+	var days []string
+	rows, err := s.db.Query(`SELECT day || ':' || commit_count FROM commit_daily_stats WHERE day LIKE '2024-%' ORDER BY day`)
+	require.NoError(t, err)
+	for rows.Next() {
+		var day string
+		require.NoError(t, rows.Scan(&day))
+		days = append(days, day)
+	}
+	require.NoError(t, rows.Err())
+	rows.Close()
+	assert.Equal(t, []string{"2024-02-29:1", "2024-03-01:1"}, days)
+
+	// * What the triggers maintained matches a rebuild from scratch
+	maintained := dailyStats(t, s)
+	n, err := s.RebuildDailyStats(ctx, "test/repo")
+	require.NoError(t, err)
+	assert.Equal(t, len(maintained), n)
+	assert.Equal(t, maintained, dailyStats(t, s))
+
+	n, err = s.RebuildDailyStats(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, len(maintained), n)
+
+	_, err = s.RebuildDailyStats(ctx, "missing/repo")
+	assert.ErrorContains(t, err, "DB_REPOSITORY_NOT_FOUND")
+
+	_, err = s.ResetRepository(ctx, "test/repo", time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, dailyStats(t, s))
+}
+
+func TestSQLite_RepositoryLifecycle(t *testing.T) {
+	s := newTestSQLite(t)
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	assertRepositoryLifecycle(t, s, repo.ID)
+
+	// * The cascade also emptied the full-text index
+	var n int
+	require.NoError(t, s.db.QueryRow(`SELECT COUNT(*) FROM commits_fts`).Scan(&n))
+	assert.Zero(t, n)
+}
+
+func TestSQLite_AuditLog(t *testing.T) {
+	s := newTestSQLite(t)
+	assertAuditLog(t, s)
+
+	// * The table refuses to be rewritten
+	_, err := s.db.Exec(`UPDATE audit_log SET actor = 'mallory'`)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = s.db.Exec(`DELETE FROM audit_log`)
+	assert.ErrorContains(t, err, "append-only")
+}
+
+func TestSQLite_SyncRuns(t *testing.T) {
+	assertSyncRuns(t, newTestSQLite(t))
+}
+
+func TestSQLite_ResetJobs(t *testing.T) {
+	assertResetJobs(t, newTestSQLite(t))
+}
+
+func TestSQLite_JobQueue(t *testing.T) {
+	assertJobQueue(t, newTestSQLite(t))
+}
+
+func TestSQLite_Migrator(t *testing.T) {
+	s := newTestSQLite(t)
+
+	mg, err := s.Migrator()
+	require.NoError(t, err)
+	t.Cleanup(func() { mg.Close() })
+
+	v, err := mg.Version()
+	require.NoError(t, err)
+	assert.False(t, v.Dirty)
+	assert.Equal(t, v.Latest, v.Version)
+	assert.GreaterOrEqual(t, v.Latest, uint(4))
+
+	// * Every down migration runs cleanly and up restores the schema
+	require.NoError(t, mg.Goto(0))
+	v, err = mg.Version()
+	require.NoError(t, err)
+	assert.Zero(t, v.Version)
+	_, err = s.GetAllRepositories(context.Background())
+	assert.Error(t, err)
+
+	require.NoError(t, mg.Up())
+	require.NoError(t, mg.Down(2))
+	v, err = mg.Version()
+	require.NoError(t, err)
+	assert.Equal(t, v.Latest-2, v.Version)
+	require.NoError(t, mg.Goto(v.Latest))
+
+	assert.Error(t, mg.Goto(v.Latest+1))
+	assert.Error(t, mg.Down(0))
+
+	// * The database connection outlives the migrator
+	require.NoError(t, mg.Close())
+	seedSQLiteRepo(t, s, "test/repo")
+}
+
+func TestSQLite_MigrateRefusesUnsafeSchema(t *testing.T) {
+	s := newTestSQLite(t)
+
+	_, err := s.db.Exec(`UPDATE schema_migrations SET dirty = 1`)
+	require.NoError(t, err)
+	err = s.Migrate()
+	assert.ErrorContains(t, err, "DB_SCHEMA_DIRTY")
+
+	// * force clears the dirty flag
+	mg, err := s.Migrator()
+	require.NoError(t, err)
+	v, err := mg.Version()
+	require.NoError(t, err)
+	require.NoError(t, mg.Force(int(v.Version)))
+	require.NoError(t, mg.Close())
+	require.NoError(t, s.Migrate())
+
+	_, err = s.db.Exec(`UPDATE schema_migrations SET version = 999`)
+	require.NoError(t, err)
+	err = s.Migrate()
+	assert.ErrorContains(t, err, "DB_SCHEMA_TOO_NEW")
+}
+
+func TestSQLite_Locks(t *testing.T) {
+	assertLocks(t, newTestSQLite(t))
+}
+
+func TestSQLite_Changelog(t *testing.T) {
+	s := newTestSQLite(t)
+	repo := seedSQLiteRepo(t, s, "test/repo")
+
+	assertChangelog(t, s, repo.ID)
+
+	// * Commits stored before parsing are parsed when read
+	_, err := s.db.Exec(`UPDATE commits SET conventional_type = NULL, conventional_breaking = NULL WHERE sha = 'ccc444'`)
+	require.NoError(t, err)
+	commits, err := s.GetChangelogCommits(context.Background(), "test/repo", models.ChangelogFilter{})
+	require.NoError(t, err)
+	require.NotNil(t, commits[0].Conventional)
+	assert.Equal(t, "fix", commits[0].Conventional.Type)
+}
+
+// * staleAuthorStore looks identities up as a transaction that started
+// * before another one stored them
+type staleAuthorStore struct {
+	sqlAuthorStore
+}
+
+func (staleAuthorStore) findIdentity(ctx context.Context, kind, value string) (*models.AuthorIdentity, error) {
+	return nil, nil
+}
+
+func TestSQLite_ResolveSameIdentityConcurrently(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+
+	commit := models.Commit{AuthorName: "dependabot[bot]", AuthorEmail: "bot@example.com", AuthorLogin: "dependabot[bot]", AuthorGitHubID: 49699333}
+	first, second := []models.Commit{commit}, []models.Commit{commit}
+
+	require.NoError(t, s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return resolveCommits(ctx, sqlAuthorStore{q: tx, normalize: time.Time.UTC}, first)
+	}))
+	// * The second sync missed the identities and creates an author of its
+	// * own, which folds into the first one's instead of failing the sync
+	require.NoError(t, s.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return resolveCommits(ctx, staleAuthorStore{sqlAuthorStore{q: tx, normalize: time.Time.UTC}}, second)
+	}))
+
+	assert.Equal(t, *first[0].AuthorID, *second[0].AuthorID)
+	assert.Equal(t, *first[0].AuthorIdentityID, *second[0].AuthorIdentityID)
+	authors, err := s.GetAuthors(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, authors, 1)
+}
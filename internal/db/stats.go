@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * commit_daily_stats is kept up to date by triggers on commits; see
+// * migration 010. The helpers here rebuild it and clean up after partition
+// * drops, which bypass the triggers.
+
+func dailyStatsError(title, detail string, err error) error {
+	return errors.New("DB_DAILY_STATS_ERROR", title, detail, err, errors.LevelError)
+}
+
+// * rebuildSQLDailyStats recomputes the daily stats of repoName, or of every
+// * repository when repoName is empty, and returns the number of buckets
+// * written. dayExpr turns c.author_date into the engine's UTC day. q should
+// * be a transaction that keeps commits from changing meanwhile.
+func rebuildSQLDailyStats(ctx context.Context, q execQueryer, repoName, dayExpr string) (int, error) {
+	var where string
+	var args []any
+	if repoName != "" {
+		var repoID int
+		err := q.QueryRowContext(ctx, `SELECT id FROM repositories WHERE name = $1`, repoName).Scan(&repoID)
+		if stderrors.Is(err, sql.ErrNoRows) {
+			return 0, repositoryNotFound(repoName)
+		}
+		if err != nil {
+			return 0, dailyStatsError("Failed to rebuild daily stats", fmt.Sprintf("Could not look up repository '%s'", repoName), err)
+		}
+		where = " WHERE repository_id = $1"
+		args = append(args, repoID)
+	}
+
+	if _, err := q.ExecContext(ctx, `DELETE FROM commit_daily_stats`+where, args...); err != nil {
+		return 0, dailyStatsError("Failed to rebuild daily stats", "Could not clear the existing daily stats", err)
+	}
+
+	res, err := q.ExecContext(ctx, `
+		INSERT INTO commit_daily_stats (repository_id, day, author_id, author_name, commit_count)
+		SELECT c.repository_id, `+dayExpr+`, COALESCE(c.author_id, 0),
+					CASE WHEN c.author_id IS NULL THEN COALESCE(c.author_name, '') ELSE '' END, COUNT(*)
+		FROM commits c`+where+`
+		GROUP BY 1, 2, 3, 4
+	`, args...)
+	if err != nil {
+		return 0, dailyStatsError("Failed to rebuild daily stats", "Could not aggregate commits into daily stats", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, dailyStatsError("Failed to rebuild daily stats", "Could not count rebuilt daily stats", err)
+	}
+	return int(n), nil
+}
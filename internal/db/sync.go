@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * insertSQLSyncRun records run as started and fills in its ID
+func insertSQLSyncRun(ctx context.Context, q queryer, run *models.SyncRun, normalize func(time.Time) time.Time) error {
+	run.StartedAt = normalize(run.StartedAt)
+	run.Status = models.SyncRunning
+
+	var since any
+	if run.Since != nil {
+		since = normalize(*run.Since)
+	}
+
+	err := q.QueryRowContext(ctx, `
+		INSERT INTO sync_runs (repository_name, triggered_by, since, started_at, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, run.RepositoryName, string(run.Trigger), since, run.StartedAt, string(run.Status)).Scan(&run.ID)
+	if err != nil {
+		return errors.New(
+			"DB_SYNC_RUN_ERROR",
+			"Failed to record sync run",
+			fmt.Sprintf("Could not record the start of a sync of '%s'", run.RepositoryName),
+			err,
+			errors.LevelError,
+		)
+	}
+	return nil
+}
+
+// * finishSQLSyncRun stores the outcome and counters of a started run
+func finishSQLSyncRun(ctx context.Context, q execQueryer, run *models.SyncRun, normalize func(time.Time) time.Time) error {
+	var finishedAt any
+	if run.FinishedAt != nil {
+		finishedAt = normalize(*run.FinishedAt)
+	}
+
+	_, err := q.ExecContext(ctx, `
+		UPDATE sync_runs
+		SET finished_at = $1, pages_fetched = $2, commits_seen = $3, commits_inserted = $4,
+			api_calls = $5, status = $6, error_reference = NULLIF($7, ''), error_message = NULLIF($8, '')
+		WHERE id = $9
+	`, finishedAt, run.PagesFetched, run.CommitsSeen, run.CommitsInserted,
+		run.APICalls, string(run.Status), run.ErrorReference, run.ErrorMessage, run.ID)
+	if err != nil {
+		return errors.New(
+			"DB_SYNC_RUN_ERROR",
+			"Failed to record sync run",
+			fmt.Sprintf("Could not record the end of sync run %d of '%s'", run.ID, run.RepositoryName),
+			err,
+			errors.LevelError,
+		)
+	}
+	return nil
+}
+
+// * querySQLSyncRuns backs GetSyncRuns for both SQL implementations
+func querySQLSyncRuns(ctx context.Context, q queryer, filter models.SyncRunFilter) ([]models.SyncRun, error) {
+	where := " WHERE 1 = 1"
+	var args []any
+
+	add := func(clause string, arg any) {
+		args = append(args, arg)
+		where += fmt.Sprintf(clause, len(args))
+	}
+	if filter.RepositoryName != "" {
+		add(" AND repository_name = $%d", filter.RepositoryName)
+	}
+	if filter.Status != "" {
+		add(" AND status = $%d", string(filter.Status))
+	}
+	if filter.BeforeID > 0 {
+		add(" AND id < $%d", filter.BeforeID)
+	}
+
+	query := `
+		SELECT id, repository_name, triggered_by, since, started_at, finished_at, pages_fetched,
+					commits_seen, commits_inserted, api_calls, status,
+					COALESCE(error_reference, ''), COALESCE(error_message, '')
+		FROM sync_runs` + where + `
+		ORDER BY id DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New(
+			"DB_SYNC_RUN_ERROR",
+			"Failed to query sync runs",
+			"Could not fetch sync run history",
+			err,
+			errors.LevelError,
+		)
+	}
+	defer rows.Close()
+
+	var runs []models.SyncRun
+	for rows.Next() {
+		var r models.SyncRun
+		err := rows.Scan(&r.ID, &r.RepositoryName, &r.Trigger, &r.Since, &r.StartedAt, &r.FinishedAt,
+			&r.PagesFetched, &r.CommitsSeen, &r.CommitsInserted, &r.APICalls, &r.Status,
+			&r.ErrorReference, &r.ErrorMessage)
+		if err != nil {
+			return nil, errors.New(
+				"DB_SYNC_RUN_ERROR",
+				"Failed to scan sync run",
+				"Error while scanning sync run row",
+				err,
+				errors.LevelError,
+			)
+		}
+		runs = append(runs, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(
+			"DB_SYNC_RUN_ERROR",
+			"Failed to process sync runs",
+			"Error while processing sync run rows",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return runs, nil
+}
@@ -50,6 +50,7 @@ func (c *Client) makeRequest(ctx context.Context, method, path string) (*http.Re
 	}
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
+	countAPICall(ctx)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute HTTP request: %w", err)
@@ -115,6 +116,56 @@ func (c *Client) GetRepository(ctx context.Context, owner, repo string) (*Reposi
 	return &repository, nil
 }
 
+// * GetCommit fetches the commit ref points to, which may be a SHA, a
+// * branch or a tag
+func (c *Client) GetCommit(ctx context.Context, owner, repo, ref string) (*Commit, error) {
+	resp, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/repos/%s/%s/commits/%s", owner, repo, url.PathEscape(ref)))
+	if err != nil {
+		return nil, errors.New(
+			"GITHUB_API_ERROR",
+			"Failed to fetch commit from GitHub",
+			fmt.Sprintf("Could not retrieve '%s' of %s/%s from GitHub API", ref, owner, repo),
+			err,
+			errors.LevelError,
+		)
+	}
+	defer resp.Body.Close()
+
+	// * GitHub answers 422 for refs that name nothing
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity {
+		return nil, errors.New(
+			"REF_NOT_FOUND",
+			"Ref not found on GitHub",
+			fmt.Sprintf("No commit, branch or tag '%s' exists in %s/%s", ref, owner, repo),
+			nil,
+			errors.LevelInfo,
+		)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(
+			"GITHUB_API_ERROR",
+			"Unexpected response from GitHub API",
+			fmt.Sprintf("GitHub API returned status %d when fetching '%s' of %s/%s", resp.StatusCode, ref, owner, repo),
+			nil,
+			errors.LevelError,
+		)
+	}
+
+	var commit Commit
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		return nil, errors.New(
+			"GITHUB_API_ERROR",
+			"Failed to parse GitHub API response",
+			"Could not understand the response from GitHub API",
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return &commit, nil
+}
+
 func (c *Client) ListCommits(ctx context.Context, owner, repo string, opts CommitListOptions) ([]*Commit, error) {
 	path := fmt.Sprintf("/repos/%s/%s/commits", owner, repo)
 
@@ -126,10 +177,19 @@ func (c *Client) ListCommits(ctx context.Context, owner, repo string, opts Commi
 		queryParams.Add("since", sinceParam)
 	}
 
+	var commits []*Commit
+	var err error
 	if opts.Page > 0 {
-		return c.fetchSinglePage(ctx, path, queryParams)
+		commits, err = c.fetchSinglePage(ctx, path, queryParams)
+	} else {
+		commits, err = c.fetchAllPages(ctx, path, queryParams)
 	}
-	return c.fetchAllPages(ctx, path, queryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	c.withAuthoredDates(ctx, owner, repo, commits)
+	return commits, nil
 }
 
 func (c *Client) fetchSinglePage(ctx context.Context, path string, queryParams url.Values) ([]*Commit, error) {
@@ -180,6 +240,7 @@ func (c *Client) fetchSinglePage(ctx context.Context, path string, queryParams u
 			errors.LevelError,
 		)
 	}
+	countPage(ctx)
 
 	return commits, nil
 }
@@ -240,6 +301,8 @@ func (c *Client) fetchAllPages(ctx context.Context, path string, queryParams url
 			)
 		}
 
+		countPage(ctx)
+
 		if len(commits) == 0 {
 			break
 		}
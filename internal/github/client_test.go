@@ -187,6 +187,66 @@ func TestClient_GetRepository(t *testing.T) {
 	}
 }
 
+func TestClient_GetCommit(t *testing.T) {
+	tests := []struct {
+		name           string
+		ref            string
+		serverResponse func(w http.ResponseWriter, r *http.Request)
+		expectedSHA    string
+		errorCode      string
+	}{
+		{
+			name: "tag resolves to its commit",
+			ref:  "v1.2.0",
+			serverResponse: func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/testowner/testrepo/commits/v1.2.0", r.URL.Path)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"sha": "abc123", "commit": {"message": "chore: release 1.2.0"}}`))
+			},
+			expectedSHA: "abc123",
+		},
+		{
+			name: "unknown ref",
+			ref:  "nope",
+			serverResponse: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			},
+			errorCode: "REF_NOT_FOUND",
+		},
+		{
+			name: "server error",
+			ref:  "main",
+			serverResponse: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			errorCode: "GITHUB_API_ERROR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(tt.serverResponse))
+			defer server.Close()
+
+			client := NewClient("test-token")
+			originalBaseURL := baseURL
+			baseURL = server.URL
+			defer func() { baseURL = originalBaseURL }()
+
+			commit, err := client.GetCommit(context.Background(), "testowner", "testrepo", tt.ref)
+
+			if tt.errorCode != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorCode)
+				assert.Nil(t, commit)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedSHA, commit.SHA)
+			}
+		})
+	}
+}
+
 func TestClient_ListCommits(t *testing.T) {
 	mockCommits := []*Commit{
 		{
@@ -212,8 +272,11 @@ func TestClient_ListCommits(t *testing.T) {
 				},
 			},
 			Author: struct {
+				ID    int64  `json:"id"`
 				Login string `json:"login"`
+				Type  string `json:"type"`
 			}{
+				ID:    1,
 				Login: "johndoe",
 			},
 		},
@@ -240,8 +303,11 @@ func TestClient_ListCommits(t *testing.T) {
 				},
 			},
 			Author: struct {
+				ID    int64  `json:"id"`
 				Login string `json:"login"`
+				Type  string `json:"type"`
 			}{
+				ID:    2,
 				Login: "janesmith",
 			},
 		},
@@ -330,6 +396,10 @@ func TestClient_ListCommits(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/graphql" {
+					w.Write([]byte(`{"data": {"repository": {}}}`))
+					return
+				}
 				if tt.validateRequest != nil {
 					tt.validateRequest(t, r)
 				}
@@ -374,6 +444,10 @@ func TestClient_fetchAllPages(t *testing.T) {
 
 	pageCount := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			w.Write([]byte(`{"data": {"repository": {}}}`))
+			return
+		}
 		pageCount++
 
 		if pageCount == 1 {
@@ -406,6 +480,89 @@ func TestClient_fetchAllPages(t *testing.T) {
 	assert.Equal(t, 2, pageCount)
 }
 
+func TestClient_CallStats(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("Link", `<https://api.github.com/repos/owner/repo/commits?page=2>; rel="next"`)
+			json.NewEncoder(w).Encode([]*Commit{{SHA: "commit1"}})
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			json.NewEncoder(w).Encode([]*Commit{{SHA: "commit2"}})
+			return
+		}
+		json.NewEncoder(w).Encode([]*Commit{})
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	originalBaseURL := baseURL
+	baseURL = server.URL
+	defer func() { baseURL = originalBaseURL }()
+
+	ctx, stats := WithCallStats(context.Background())
+	commits, err := client.ListCommits(ctx, "owner", "repo", CommitListOptions{})
+
+	require.NoError(t, err)
+	assert.Len(t, commits, 2)
+	assert.Equal(t, int64(requests), stats.APICalls.Load())
+	assert.Equal(t, int64(2), stats.Pages.Load())
+
+	// * Requests made without stats in their context are not counted anywhere
+	_, err = client.ListCommits(context.Background(), "owner", "repo", CommitListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Pages.Load())
+}
+
+func TestClient_ListCommits_AuthoredDates(t *testing.T) {
+	utc := time.Date(2024, 3, 11, 20, 0, 0, 0, time.UTC)
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			assert.Equal(t, "POST", r.Method)
+			assert.Equal(t, "bearer test-token", r.Header.Get("Authorization"))
+			var body struct {
+				Query     string            `json:"query"`
+				Variables map[string]string `json:"variables"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, map[string]string{"owner": "owner", "name": "repo"}, body.Variables)
+			query = body.Query
+			w.Write([]byte(`{"data": {"repository": {
+				"c0": {"authoredDate": "2024-03-11T22:00:00+02:00"},
+				"c1": null
+			}}}`))
+			return
+		}
+		commits := []*Commit{{SHA: "abc"}, {SHA: "def"}}
+		for _, c := range commits {
+			c.Commit.Author.Date = utc
+		}
+		json.NewEncoder(w).Encode(commits)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	originalBaseURL := baseURL
+	baseURL = server.URL
+	defer func() { baseURL = originalBaseURL }()
+
+	commits, err := client.ListCommits(context.Background(), "owner", "repo", CommitListOptions{Page: 1})
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Contains(t, query, `c0: object(oid: "abc")`)
+	assert.Contains(t, query, `c1: object(oid: "def")`)
+
+	// * The first commit keeps its author's offset, the second stays in UTC
+	assert.True(t, utc.Equal(commits[0].Commit.Author.Date))
+	_, offset := commits[0].Commit.Author.Date.Zone()
+	assert.Equal(t, 2*60*60, offset)
+	_, offset = commits[1].Commit.Author.Date.Zone()
+	assert.Zero(t, offset)
+}
+
 func TestClient_fetchAllPages_EmptyResponse(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,138 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * authoredDateBatch is the number of commits whose authored date one
+// * GraphQL query asks for
+const authoredDateBatch = 100
+
+// * graphQLResponse is the envelope of a GitHub GraphQL answer
+type graphQLResponse struct {
+	Data struct {
+		Repository map[string]*struct {
+			AuthoredDate string `json:"authoredDate"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// * withAuthoredDates replaces the author dates of commits, which the REST
+// * API reports in UTC, with the GraphQL authoredDate, which keeps the offset
+// * the author committed with. GraphQL needs a token; without one, or when
+// * the query fails, the dates stay in UTC.
+func (c *Client) withAuthoredDates(ctx context.Context, owner, repo string, commits []*Commit) {
+	if c.token == "" {
+		return
+	}
+
+	for start := 0; start < len(commits); start += authoredDateBatch {
+		batch := commits[start:min(start+authoredDateBatch, len(commits))]
+		dates, err := c.authoredDates(ctx, owner, repo, batch)
+		if err != nil {
+			logger.Warn("author offsets of %s/%s are not known, keeping UTC dates: %v", owner, repo, err)
+			return
+		}
+		for _, commit := range batch {
+			if date, ok := dates[commit.SHA]; ok && date.Equal(commit.Commit.Author.Date) {
+				commit.Commit.Author.Date = date
+			}
+		}
+	}
+}
+
+// * authoredDates asks GraphQL for the authored date of each of commits,
+// * keyed by SHA
+func (c *Client) authoredDates(ctx context.Context, owner, repo string, commits []*Commit) (map[string]time.Time, error) {
+	var q strings.Builder
+	q.WriteString("query($owner: String!, $name: String!) { repository(owner: $owner, name: $name) {")
+	for i, commit := range commits {
+		fmt.Fprintf(&q, " c%d: object(oid: %q) { ... on Commit { authoredDate } }", i, commit.SHA)
+	}
+	q.WriteString(" } }")
+
+	body, err := json.Marshal(map[string]any{
+		"query":     q.String(),
+		"variables": map[string]string{"owner": owner, "name": repo},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/graphql", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Authorization", "bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	countAPICall(ctx)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.New(
+			"GITHUB_API_ERROR",
+			"Failed to fetch authored dates from GitHub",
+			fmt.Sprintf("Could not connect to GitHub GraphQL API for commits of %s/%s", owner, repo),
+			err,
+			errors.LevelError,
+		)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(
+			"GITHUB_API_ERROR",
+			"Failed to fetch authored dates from GitHub",
+			fmt.Sprintf("GitHub GraphQL API returned status %d for commits of %s/%s", resp.StatusCode, owner, repo),
+			nil,
+			errors.LevelError,
+		)
+	}
+
+	var result graphQLResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.New(
+			"GITHUB_API_ERROR",
+			"Failed to parse GitHub API response",
+			"Could not understand the authored dates returned by GitHub GraphQL API",
+			err,
+			errors.LevelError,
+		)
+	}
+	if len(result.Errors) > 0 {
+		return nil, errors.New(
+			"GITHUB_API_ERROR",
+			"Failed to fetch authored dates from GitHub",
+			fmt.Sprintf("GitHub GraphQL API answered: %s", result.Errors[0].Message),
+			nil,
+			errors.LevelError,
+		)
+	}
+
+	dates := make(map[string]time.Time, len(commits))
+	for i, commit := range commits {
+		object := result.Data.Repository[fmt.Sprintf("c%d", i)]
+		if object == nil {
+			continue
+		}
+		// * authoredDate is ISO 8601 with the author's own offset
+		date, err := time.Parse(time.RFC3339, object.AuthoredDate)
+		if err != nil {
+			continue
+		}
+		dates[commit.SHA] = date
+	}
+	return dates, nil
+}
@@ -0,0 +1,39 @@
+package github
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// * CallStats counts the GitHub API work done under a context, so callers
+// * can tell how much of the rate limit one sync used
+type CallStats struct {
+	APICalls atomic.Int64
+	Pages    atomic.Int64
+}
+
+type callStatsKey struct{}
+
+// * WithCallStats returns a context whose requests are counted in the
+// * returned CallStats
+func WithCallStats(ctx context.Context) (context.Context, *CallStats) {
+	stats := &CallStats{}
+	return context.WithValue(ctx, callStatsKey{}, stats), stats
+}
+
+func callStatsFrom(ctx context.Context) *CallStats {
+	stats, _ := ctx.Value(callStatsKey{}).(*CallStats)
+	return stats
+}
+
+func countAPICall(ctx context.Context) {
+	if stats := callStatsFrom(ctx); stats != nil {
+		stats.APICalls.Add(1)
+	}
+}
+
+func countPage(ctx context.Context) {
+	if stats := callStatsFrom(ctx); stats != nil {
+		stats.Pages.Add(1)
+	}
+}
@@ -26,8 +26,12 @@ type Commit struct {
 			Date  time.Time `json:"date"`
 		} `json:"author"`
 	} `json:"commit"`
+	// * Author is the linked GitHub account; zero when the commit email is
+	// * not tied to one. Type is "Bot" for GitHub App accounts.
 	Author struct {
+		ID    int64  `json:"id"`
 		Login string `json:"login"`
+		Type  string `json:"type"`
 	} `json:"author"`
 }
 
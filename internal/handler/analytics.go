@@ -0,0 +1,473 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+type AnalyticsHandler struct {
+	service *service.AnalyticsService
+}
+
+func NewAnalyticsHandler(service *service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{service: service}
+}
+
+func (h *AnalyticsHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/repositories/{owner}/{name}/activity", h.getRepositoryActivity).Methods("GET")
+	r.HandleFunc("/activity", h.getActivity).Methods("GET")
+	r.HandleFunc("/repositories/{owner}/{name}/punch-card", h.getRepositoryPunchCard).Methods("GET")
+	r.HandleFunc("/punch-card", h.getPunchCard).Methods("GET")
+	r.HandleFunc("/repositories/{owner}/{name}/bus-factor", h.getBusFactor).Methods("GET")
+	r.HandleFunc("/repositories/{owner}/{name}/cohorts", h.getRepositoryCohorts).Methods("GET")
+	r.HandleFunc("/cohorts", h.getCohorts).Methods("GET")
+	r.HandleFunc("/leaderboard", h.getLeaderboard).Methods("GET")
+}
+
+// getRepositoryActivity godoc
+// @Summary Get Repository Commit Activity
+// @Description Commit counts per day, week or month in the given time zone, including empty buckets. since and until are widened to whole buckets. Bot accounts are left out unless include_bots is set.
+// @Tags Analytics
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Param interval query string false "Bucket width: day, week (from Monday) or month" default(day)
+// @Param since query string false "Start, as RFC3339 or a YYYY-MM-DD date in tz"
+// @Param until query string false "End, as RFC3339 or a YYYY-MM-DD date in tz; defaults to now"
+// @Param tz query string false "IANA time zone the buckets are counted in" default(UTC)
+// @Param by query string false "author, repository or both comma-separated, to break each bucket down that way"
+// @Param include_bots query bool false "Count commits by bot accounts" default(false)
+// @Success 200 {object} models.Activity
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid interval, time zone or range"
+// @Failure 404 {object} errors.HTTPErrorResponse "Repository not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/activity [get]
+func (h *AnalyticsHandler) getRepositoryActivity(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	h.writeActivity(w, r, []string{vars["owner"] + "/" + vars["name"]})
+}
+
+// getActivity godoc
+// @Summary Get Commit Activity Across Repositories
+// @Description Commit counts per day, week or month over every monitored repository, or only those listed in repos
+// @Tags Analytics
+// @Produce json
+// @Param repos query string false "Comma-separated owner/name list"
+// @Param interval query string false "Bucket width: day, week (from Monday) or month" default(day)
+// @Param since query string false "Start, as RFC3339 or a YYYY-MM-DD date in tz"
+// @Param until query string false "End, as RFC3339 or a YYYY-MM-DD date in tz; defaults to now"
+// @Param tz query string false "IANA time zone the buckets are counted in" default(UTC)
+// @Param by query string false "author, repository or both comma-separated, to break each bucket down that way"
+// @Param include_bots query bool false "Count commits by bot accounts" default(false)
+// @Success 200 {object} models.Activity
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid interval, time zone or range"
+// @Failure 404 {object} errors.HTTPErrorResponse "Repository not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /activity [get]
+func (h *AnalyticsHandler) getActivity(w http.ResponseWriter, r *http.Request) {
+	h.writeActivity(w, r, repoList(r))
+}
+
+func (h *AnalyticsHandler) writeActivity(w http.ResponseWriter, r *http.Request, repos []string) {
+	query := r.URL.Query()
+
+	loc, err := parseTimezone(query.Get("tz"))
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	bots, err := includeBots(r, false)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	filter := models.ActivityFilter{
+		Repositories: repos,
+		Interval:     models.ActivityInterval(query.Get("interval")),
+		Location:     loc,
+		ExcludeBots:  !bots,
+	}
+	for _, by := range strings.Split(query.Get("by"), ",") {
+		switch strings.TrimSpace(by) {
+		case "author":
+			filter.ByAuthor = true
+		case "repository":
+			filter.ByRepository = true
+		}
+	}
+	if filter.Since, err = parseTimeParam(query, "since", loc); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+	if filter.Until, err = parseTimeParam(query, "until", loc); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	activity, err := h.service.GetActivity(r.Context(), filter)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Fetched %d %s activity buckets", len(activity.Buckets), activity.Interval)
+	writeSuccess(w, activity, "Successfully fetched commit activity")
+}
+
+// getRepositoryPunchCard godoc
+// @Summary Get Repository Punch Card
+// @Description Commit counts per weekday (Monday first) and hour of day, as JSON or CSV. Hours are read in tz, or in each author's own UTC offset with tz=author. Bot accounts are left out unless include_bots is set.
+// @Tags Analytics
+// @Produce json,text/csv
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Param author_id query int false "Only count commits by this author"
+// @Param since query string false "Start, as RFC3339 or a YYYY-MM-DD date in tz"
+// @Param until query string false "Exclusive end, as RFC3339 or a YYYY-MM-DD date in tz"
+// @Param tz query string false "IANA time zone, or author for the author's own offset" default(UTC)
+// @Param format query string false "json or csv" default(json)
+// @Param include_bots query bool false "Count commits by bot accounts" default(false)
+// @Success 200 {object} models.PunchCard
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid time zone, author or range"
+// @Failure 404 {object} errors.HTTPErrorResponse "Repository or author not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/punch-card [get]
+func (h *AnalyticsHandler) getRepositoryPunchCard(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	h.writePunchCard(w, r, []string{vars["owner"] + "/" + vars["name"]})
+}
+
+// getPunchCard godoc
+// @Summary Get Punch Card Across Repositories
+// @Description Commit counts per weekday and hour of day over every monitored repository, or only those listed in repos, as JSON or CSV
+// @Tags Analytics
+// @Produce json,text/csv
+// @Param repos query string false "Comma-separated owner/name list"
+// @Param author_id query int false "Only count commits by this author"
+// @Param since query string false "Start, as RFC3339 or a YYYY-MM-DD date in tz"
+// @Param until query string false "Exclusive end, as RFC3339 or a YYYY-MM-DD date in tz"
+// @Param tz query string false "IANA time zone, or author for the author's own offset" default(UTC)
+// @Param format query string false "json or csv" default(json)
+// @Param include_bots query bool false "Count commits by bot accounts" default(false)
+// @Success 200 {object} models.PunchCard
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid time zone, author or range"
+// @Failure 404 {object} errors.HTTPErrorResponse "Repository or author not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /punch-card [get]
+func (h *AnalyticsHandler) getPunchCard(w http.ResponseWriter, r *http.Request) {
+	h.writePunchCard(w, r, repoList(r))
+}
+
+func (h *AnalyticsHandler) writePunchCard(w http.ResponseWriter, r *http.Request, repos []string) {
+	query := r.URL.Query()
+
+	bots, err := includeBots(r, false)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	filter := models.PunchCardFilter{
+		Repositories: repos,
+		ExcludeBots:  !bots,
+	}
+
+	if tz := query.Get("tz"); tz == service.PunchCardAuthorZone {
+		filter.AuthorOffset = true
+		filter.Location = time.UTC
+	} else if filter.Location, err = parseTimezone(tz); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	if id := query.Get("author_id"); id != "" {
+		if filter.AuthorID, err = strconv.Atoi(id); err != nil || filter.AuthorID < 1 {
+			errors.WriteHTTPError(w, errors.New("INVALID_AUTHOR_ID", "Invalid author ID", "author_id must be a positive integer", err, errors.LevelError))
+			return
+		}
+	}
+	if filter.Since, err = parseTimeParam(query, "since", filter.Location); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+	if filter.Until, err = parseTimeParam(query, "until", filter.Location); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	card, err := h.service.GetPunchCard(r.Context(), filter)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Fetched punch card of %d commits", card.Total)
+	if query.Get("format") == "csv" {
+		writePunchCardCSV(w, card)
+		return
+	}
+	writeSuccess(w, card, "Successfully fetched punch card")
+}
+
+// * writePunchCardCSV writes one row per weekday with a column per hour
+func writePunchCardCSV(w http.ResponseWriter, card *models.PunchCard) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="punch-card.csv"`)
+
+	out := csv.NewWriter(w)
+	header := []string{"day"}
+	for hour := range 24 {
+		header = append(header, fmt.Sprintf("%02d", hour))
+	}
+	_ = out.Write(header)
+
+	for day, counts := range card.Counts {
+		row := []string{card.Days[day]}
+		for _, n := range counts {
+			row = append(row, strconv.Itoa(n))
+		}
+		_ = out.Write(row)
+	}
+	out.Flush()
+}
+
+// getBusFactor godoc
+// @Summary Get Repository Bus Factor
+// @Description Knowledge concentration of a repository's commits: the fewest authors covering threshold percent of them, the Gini coefficient of commits per author and the share of the top_n busiest authors. Measured over a trailing window of whole UTC months, now and at the end of each earlier month. Commits carry no file data, so there is no breakdown by directory. Bot accounts are left out unless include_bots is set.
+// @Tags Analytics
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Param threshold query number false "Percentage of commits the key authors must cover" default(50)
+// @Param top_n query int false "Number of busiest authors top_share is taken over" default(3)
+// @Param window_months query int false "Months each measure covers" default(12)
+// @Param history query int false "Number of monthly measures, latest last" default(12)
+// @Param include_bots query bool false "Count commits by bot accounts" default(false)
+// @Success 200 {object} models.BusFactor
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid options"
+// @Failure 404 {object} errors.HTTPErrorResponse "Repository not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/bus-factor [get]
+func (h *AnalyticsHandler) getBusFactor(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fullName := vars["owner"] + "/" + vars["name"]
+	query := r.URL.Query()
+
+	bots, err := includeBots(r, false)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	opts := models.BusFactorOptions{ExcludeBots: !bots}
+	if t := query.Get("threshold"); t != "" {
+		opts.Threshold, err = strconv.ParseFloat(t, 64)
+	}
+	for _, p := range []struct {
+		key   string
+		value *int
+	}{{"top_n", &opts.TopN}, {"window_months", &opts.WindowMonths}, {"history", &opts.History}} {
+		if v := query.Get(p.key); v != "" && err == nil {
+			*p.value, err = strconv.Atoi(v)
+		}
+	}
+	if err != nil {
+		errors.WriteHTTPError(w, errors.New(
+			"INVALID_BUS_FACTOR_OPTIONS",
+			"Invalid bus factor options",
+			"threshold must be a number and top_n, window_months and history integers",
+			err,
+			errors.LevelError,
+		))
+		return
+	}
+
+	report, err := h.service.GetBusFactor(r.Context(), fullName, opts)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Fetched bus factor of %s: %d", fullName, report.Current.BusFactor)
+	writeSuccess(w, report, "Successfully fetched bus factor")
+}
+
+// getRepositoryCohorts godoc
+// @Summary Get Repository Contributor Cohorts
+// @Description New, returning and churned contributors per period, and a retention table of the fraction of each period's new contributors still committing k periods later. Periods are in UTC; commits before since still decide when a contributor first appeared. Bot accounts are left out unless include_bots is set.
+// @Tags Analytics
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Param interval query string false "Period: day, week (from Monday) or month" default(month)
+// @Param since query string false "Start, as RFC3339 or a YYYY-MM-DD date"
+// @Param until query string false "End, as RFC3339 or a YYYY-MM-DD date; defaults to now"
+// @Param include_bots query bool false "Count bot accounts as contributors" default(false)
+// @Success 200 {object} models.Cohorts
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid interval or range"
+// @Failure 404 {object} errors.HTTPErrorResponse "Repository not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/cohorts [get]
+func (h *AnalyticsHandler) getRepositoryCohorts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	h.writeCohorts(w, r, []string{vars["owner"] + "/" + vars["name"]})
+}
+
+// getCohorts godoc
+// @Summary Get Contributor Cohorts Across Repositories
+// @Description Contributor flow and retention over a group of repositories, every monitored one unless repos lists some. A contributor is new to the group with their first commit to any of them.
+// @Tags Analytics
+// @Produce json
+// @Param repos query string false "Comma-separated owner/name list"
+// @Param interval query string false "Period: day, week (from Monday) or month" default(month)
+// @Param since query string false "Start, as RFC3339 or a YYYY-MM-DD date"
+// @Param until query string false "End, as RFC3339 or a YYYY-MM-DD date; defaults to now"
+// @Param include_bots query bool false "Count bot accounts as contributors" default(false)
+// @Success 200 {object} models.Cohorts
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid interval or range"
+// @Failure 404 {object} errors.HTTPErrorResponse "Repository not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /cohorts [get]
+func (h *AnalyticsHandler) getCohorts(w http.ResponseWriter, r *http.Request) {
+	h.writeCohorts(w, r, repoList(r))
+}
+
+func (h *AnalyticsHandler) writeCohorts(w http.ResponseWriter, r *http.Request, repos []string) {
+	query := r.URL.Query()
+
+	bots, err := includeBots(r, false)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	filter := models.CohortFilter{
+		Repositories: repos,
+		Interval:     models.ActivityInterval(query.Get("interval")),
+		ExcludeBots:  !bots,
+	}
+	if filter.Since, err = parseTimeParam(query, "since", time.UTC); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+	if filter.Until, err = parseTimeParam(query, "until", time.UTC); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	cohorts, err := h.service.GetCohorts(r.Context(), filter)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Fetched %d %s contributor cohorts", len(cohorts.Cohorts), cohorts.Interval)
+	writeSuccess(w, cohorts, "Successfully fetched contributor cohorts")
+}
+
+// getLeaderboard godoc
+// @Summary Get Cross-Repository Leaderboard
+// @Description The busiest authors and the most active repositories over a group of repositories, every monitored one unless repos lists some. Authors are counted by resolved identity, once across all repositories, with their commits per repository. Bot accounts are left out unless include_bots is set.
+// @Tags Analytics
+// @Produce json
+// @Param repos query string false "Comma-separated owner/name list"
+// @Param since query string false "Start, as RFC3339 or a YYYY-MM-DD date"
+// @Param until query string false "End, exclusive, as RFC3339 or a YYYY-MM-DD date"
+// @Param limit query int false "Authors and repositories to rank" default(10)
+// @Param include_bots query bool false "Rank bot accounts" default(false)
+// @Success 200 {object} models.Leaderboard
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid range"
+// @Failure 404 {object} errors.HTTPErrorResponse "Repository not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /leaderboard [get]
+func (h *AnalyticsHandler) getLeaderboard(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	bots, err := includeBots(r, false)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	filter := models.LeaderboardFilter{
+		Repositories: repoList(r),
+		Limit:        limit,
+		ExcludeBots:  !bots,
+	}
+	if filter.Since, err = parseTimeParam(query, "since", time.UTC); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+	if filter.Until, err = parseTimeParam(query, "until", time.UTC); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	board, err := h.service.GetLeaderboard(r.Context(), filter)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Ranked %d authors and %d repositories", len(board.Authors), len(board.TopRepositories))
+	writeSuccess(w, board, "Successfully fetched leaderboard")
+}
+
+// * parseTimezone loads an IANA time zone, UTC when name is empty. The
+// * server's own zone is refused since it differs between replicas.
+func parseTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || loc == time.Local {
+		return nil, errors.New(
+			"INVALID_TIMEZONE",
+			"Invalid time zone",
+			"tz must be an IANA time zone name such as Europe/Berlin",
+			err,
+			errors.LevelError,
+		)
+	}
+	return loc, nil
+}
+
+// * parseTimeParam reads the query parameter key as RFC3339, or as a
+// * YYYY-MM-DD date starting at midnight in loc. It is zero when absent.
+func parseTimeParam(query url.Values, key string, loc *time.Location) (time.Time, error) {
+	value := query.Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, loc)
+	if err != nil {
+		return time.Time{}, errors.New(
+			"INVALID_DATE",
+			"Invalid date",
+			key+" must be an RFC3339 time or a YYYY-MM-DD date",
+			err,
+			errors.LevelError,
+		)
+	}
+	return t, nil
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/gorilla/mux"
+)
+
+type AuditHandler struct {
+	service *service.AuditService
+}
+
+func NewAuditHandler(service *service.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+func (h *AuditHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/audit", h.listAudit).Methods("GET")
+}
+
+func invalidAuditFilter(detail string, err error) error {
+	return errors.New("INVALID_AUDIT_FILTER", "Invalid audit filter", detail, err, errors.LevelError)
+}
+
+// listAudit godoc
+// @Summary List Audit Log
+// @Description List administrative actions newest first. Follow pagination.next_cursor to fetch older entries.
+// @Tags Audit
+// @Produce json
+// @Param actor query string false "Only actions by this actor"
+// @Param action query string false "Only this action, e.g. repository.reset"
+// @Param repository query string false "Only actions on this owner/name"
+// @Param outcome query string false "success or failure"
+// @Param since query string false "Start time (RFC3339)"
+// @Param until query string false "End time (RFC3339)"
+// @Param limit query int false "Max entries to return" default(50)
+// @Param cursor query string false "pagination.next_cursor from a previous page"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid audit filter"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /audit [get]
+func (h *AuditHandler) listAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	filter := models.AuditFilter{
+		Actor:          query.Get("actor"),
+		Action:         query.Get("action"),
+		RepositoryName: query.Get("repository"),
+		Outcome:        models.AuditOutcome(query.Get("outcome")),
+		Limit:          limit,
+	}
+
+	switch filter.Outcome {
+	case "", models.AuditSuccess, models.AuditFailure:
+	default:
+		errors.WriteHTTPError(w, invalidAuditFilter("outcome must be success or failure", nil))
+		return
+	}
+
+	for param, dst := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := query.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				errors.WriteHTTPError(w, invalidAuditFilter(param+" must be an RFC3339 time", err))
+				return
+			}
+			*dst = &t
+		}
+	}
+
+	if c := query.Get("cursor"); c != "" {
+		id, err := strconv.Atoi(c)
+		if err != nil || id < 1 {
+			errors.WriteHTTPError(w, invalidAuditFilter("cursor must be a next_cursor value returned by this endpoint", err))
+			return
+		}
+		filter.BeforeID = id
+	}
+
+	entries, err := h.service.List(r.Context(), filter)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+
+	pagination := &Pagination{Limit: limit}
+	if len(entries) == limit {
+		pagination.NextCursor = strconv.Itoa(entries[len(entries)-1].ID)
+	}
+
+	writePage(w, entries, pagination, "Successfully fetched audit log")
+}
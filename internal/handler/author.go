@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+// * maxMailmapBytes caps the size of an uploaded mailmap
+const maxMailmapBytes = 1 << 20
+
+type AuthorHandler struct {
+	service *service.AuthorService
+}
+
+func NewAuthorHandler(service *service.AuthorService) *AuthorHandler {
+	return &AuthorHandler{service: service}
+}
+
+func (h *AuthorHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/admin/authors", h.listAuthors).Methods("GET")
+	r.HandleFunc("/admin/authors/merge", h.mergeAuthors).Methods("POST")
+	r.HandleFunc("/admin/authors/resolve", h.resolveAuthors).Methods("POST")
+	r.HandleFunc("/admin/authors/{id:[0-9]+}", h.getAuthor).Methods("GET")
+	r.HandleFunc("/admin/authors/{id:[0-9]+}/split", h.splitAuthor).Methods("POST")
+	r.HandleFunc("/admin/mailmap", h.getMailmap).Methods("GET")
+	r.HandleFunc("/admin/mailmap", h.setMailmap).Methods("PUT")
+	r.HandleFunc("/admin/bot-rules", h.getBotRules).Methods("GET")
+	r.HandleFunc("/admin/bot-rules", h.setBotRules).Methods("PUT")
+}
+
+func authorID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, errors.New("INVALID_AUTHOR_ID", "Invalid author ID", "Author ID must be a whole number", err, errors.LevelError)
+	}
+	return id, nil
+}
+
+// listAuthors godoc
+// @Summary List Authors
+// @Description List resolved authors with the GitHub IDs, emails and names that map onto them
+// @Tags Authors
+// @Produce json
+// @Param limit query int false "Max authors to return" default(100)
+// @Success 200 {array} models.Author
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/authors [get]
+func (h *AuthorHandler) listAuthors(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	authors, err := h.service.ListAuthors(r.Context(), limit)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	if authors == nil {
+		authors = []models.Author{}
+	}
+	writeSuccess(w, authors, "Successfully fetched authors")
+}
+
+// getAuthor godoc
+// @Summary Get Author
+// @Description Fetch one author and its identities
+// @Tags Authors
+// @Produce json
+// @Param id path int true "Author ID"
+// @Success 200 {object} models.Author
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid author ID"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/authors/{id} [get]
+func (h *AuthorHandler) getAuthor(w http.ResponseWriter, r *http.Request) {
+	id, err := authorID(r)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	author, err := h.service.GetAuthor(r.Context(), id)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	writeSuccess(w, author, "Successfully fetched author")
+}
+
+// mergeAuthors godoc
+// @Summary Merge Authors
+// @Description Fold the source authors, with their identities and commits, into the target author
+// @Tags Authors
+// @Accept json
+// @Produce json
+// @Param request body MergeAuthorsRequest true "Authors to merge"
+// @Success 200 {object} models.Author
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid merge"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/authors/merge [post]
+func (h *AuthorHandler) mergeAuthors(w http.ResponseWriter, r *http.Request) {
+	var req MergeAuthorsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	author, err := h.service.Merge(r.Context(), req.TargetID, req.SourceIDs)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Merged authors %v into %d", req.SourceIDs, req.TargetID)
+	writeSuccess(w, author, "Successfully merged authors")
+}
+
+// splitAuthor godoc
+// @Summary Split Author
+// @Description Move identities, and the commits resolved through them, off an author onto a new one
+// @Tags Authors
+// @Accept json
+// @Produce json
+// @Param id path int true "Author ID"
+// @Param request body SplitAuthorRequest true "Identities to split off"
+// @Success 200 {object} models.Author
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid split"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/authors/{id}/split [post]
+func (h *AuthorHandler) splitAuthor(w http.ResponseWriter, r *http.Request) {
+	id, err := authorID(r)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	var req SplitAuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	author, err := h.service.Split(r.Context(), id, req.IdentityIDs, req.Name)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Split identities %v off author %d into author %d", req.IdentityIDs, id, author.ID)
+	writeSuccess(w, author, "Successfully split author")
+}
+
+// resolveAuthors godoc
+// @Summary Resolve Authors
+// @Description Assign an author to every stored commit that does not have one yet
+// @Tags Authors
+// @Produce json
+// @Success 200 {object} ResolveAuthorsResponse
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/authors/resolve [post]
+func (h *AuthorHandler) resolveAuthors(w http.ResponseWriter, r *http.Request) {
+	resolved, err := h.service.ResolveAll(r.Context())
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	writeSuccess(w, ResolveAuthorsResponse{Resolved: resolved}, "Successfully resolved commit authors")
+}
+
+// getMailmap godoc
+// @Summary Get Mailmap
+// @Description Fetch the stored mailmap in .mailmap format
+// @Tags Authors
+// @Produce plain
+// @Success 200 {string} string "Mailmap"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/mailmap [get]
+func (h *AuthorHandler) getMailmap(w http.ResponseWriter, r *http.Request) {
+	m, err := h.service.GetMailmap(r.Context())
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, m.String())
+}
+
+// setMailmap godoc
+// @Summary Set Mailmap
+// @Description Replace the mailmap with a .mailmap file and apply it to existing authors. Entries that also name the commit author only affect commits synced afterwards.
+// @Tags Authors
+// @Accept plain
+// @Produce json
+// @Param mailmap body string true "Mailmap in .mailmap format"
+// @Success 200 {array} mailmap.Entry
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid mailmap"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/mailmap [put]
+func (h *AuthorHandler) setMailmap(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMailmapBytes))
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.service.SetMailmap(r.Context(), string(body))
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Updated mailmap with %d entries", len(m))
+	writeSuccess(w, m, "Successfully saved mailmap")
+}
+
+// getBotRules godoc
+// @Summary Get Bot Rules
+// @Description Fetch the allow and deny lists used on top of automatic bot detection
+// @Tags Authors
+// @Produce json
+// @Success 200 {object} bots.Rules
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/bot-rules [get]
+func (h *AuthorHandler) getBotRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.service.GetBotRules(r.Context())
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	writeSuccess(w, rules, "Successfully fetched bot rules")
+}
+
+// setBotRules godoc
+// @Summary Set Bot Rules
+// @Description Replace the bot allow and deny lists and reclassify every author. Patterns match a login, name or email, ignoring case, and * matches anything. Allowed accounts are never bots; denied ones always are; the rest are bots when GitHub reports a Bot account or the name ends in [bot].
+// @Tags Authors
+// @Accept json
+// @Produce json
+// @Param rules body bots.Rules true "Allow and deny lists"
+// @Success 200 {object} bots.Rules
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid bot rules"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/bot-rules [put]
+func (h *AuthorHandler) setBotRules(w http.ResponseWriter, r *http.Request) {
+	var req bots.Rules
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	rules, err := h.service.SetBotRules(r.Context(), req)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Updated bot rules: %d allowed, %d denied", len(rules.Allow), len(rules.Deny))
+	writeSuccess(w, rules, "Successfully saved bot rules")
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+type ChangelogHandler struct {
+	service *service.ChangelogService
+}
+
+func NewChangelogHandler(service *service.ChangelogService) *ChangelogHandler {
+	return &ChangelogHandler{service: service}
+}
+
+func (h *ChangelogHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/repositories/{owner}/{name}/changelog", h.getChangelog).Methods("GET")
+}
+
+// getChangelog godoc
+// @Summary Get Repository Changelog
+// @Description Stored commits grouped by their Conventional Commits type, with breaking changes listed on their own and the share of commits following the convention. from and to are SHAs, SHA prefixes or tags; the range holds the commits authored after from up to and including to.
+// @Tags Changelog
+// @Produce json,text/markdown
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Param from query string false "Exclusive start: a SHA, a prefix of one, or a tag"
+// @Param to query string false "Inclusive end: a SHA, a prefix of one, or a tag"
+// @Param since query string false "Start, as RFC3339 or a YYYY-MM-DD date"
+// @Param until query string false "End, exclusive, as RFC3339 or a YYYY-MM-DD date"
+// @Param format query string false "json or markdown" default(json)
+// @Success 200 {object} models.Changelog
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid or ambiguous range"
+// @Failure 404 {object} errors.HTTPErrorResponse "Repository, commit or tag not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/changelog [get]
+func (h *ChangelogHandler) getChangelog(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	span := models.ChangelogRange{
+		From: query.Get("from"),
+		To:   query.Get("to"),
+	}
+	var err error
+	if span.Since, err = parseTimeParam(query, "since", time.UTC); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+	if span.Until, err = parseTimeParam(query, "until", time.UTC); err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	changelog, err := h.service.GetChangelog(r.Context(), vars["owner"], vars["name"], span)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Built changelog of %d commits for %s", changelog.Commits, changelog.Repository)
+	if query.Get("format") == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		_, _ = io.WriteString(w, service.ChangelogMarkdown(changelog))
+		return
+	}
+	writeSuccess(w, changelog, "Successfully built changelog")
+}
@@ -1,12 +1,11 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
@@ -19,29 +18,44 @@ import (
 
 type RepositoryHandler struct {
 	service *service.RepositoryService
-	ctx     context.Context
+	workers *worker.SyncManager
 }
 
-func NewRepositoryHandler(ctx context.Context, service *service.RepositoryService) *RepositoryHandler {
+func NewRepositoryHandler(service *service.RepositoryService, workers *worker.SyncManager) *RepositoryHandler {
 	return &RepositoryHandler{
 		service: service,
-		ctx:     ctx,
+		workers: workers,
 	}
 }
 
 func (h *RepositoryHandler) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/repositories/{owner}/{repo}", h.getRepository).Methods("GET")
+	r.HandleFunc("/repositories/{owner}/{name}", h.deleteRepository).Methods("DELETE")
+	r.HandleFunc("/repositories/{owner}/{name}/archive", h.archiveRepository).Methods("POST")
+	r.HandleFunc("/repositories/{owner}/{name}/restore", h.restoreRepository).Methods("POST")
 	r.HandleFunc("/repositories", h.AddRepository).Methods("POST")
 	r.HandleFunc("/repositories/{owner}/{name}/commits", h.getCommits).Methods("GET")
+	r.HandleFunc("/repositories/{owner}/{name}/commits/search", h.searchRepositoryCommits).Methods("GET")
+	r.HandleFunc("/commits/search", h.searchCommits).Methods("GET")
 	r.HandleFunc("/repositories/{owner}/{name}/top-authors", h.getTopCommitAuthors).Methods("GET")
 	r.HandleFunc("/repositories/{owner}/{name}/reset-collection", h.resetCollection).Methods("POST")
+	r.HandleFunc("/reset-jobs/{id}", h.getResetJob).Methods("GET")
 	r.HandleFunc("/repositories/{owner}/{name}/monitor", h.monitorRepository).Methods("POST")
+	r.HandleFunc("/repositories/{owner}/{name}/syncs", h.listSyncRuns).Methods("GET")
+	r.HandleFunc("/syncs/failures", h.listSyncFailures).Methods("GET")
+	r.HandleFunc("/repositories/{owner}/{name}/stats/rebuild", h.rebuildRepositoryStats).Methods("POST")
+	r.HandleFunc("/stats/rebuild", h.rebuildAllStats).Methods("POST")
 }
 
 func writeSuccess(w http.ResponseWriter, data interface{}, message ...string) {
+	writePage(w, data, nil, message...)
+}
+
+func writePage(w http.ResponseWriter, data interface{}, pagination *Pagination, message ...string) {
 	resp := APIResponse{
-		Status: "success",
-		Data:   data,
+		Status:     "success",
+		Data:       data,
+		Pagination: pagination,
 	}
 	if len(message) > 0 {
 		resp.Message = message[0]
@@ -50,6 +64,38 @@ func writeSuccess(w http.ResponseWriter, data interface{}, message ...string) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// * includeBots reads the include_bots query parameter, def when absent.
+// * Leaderboards leave bots out by default; commit listings keep them.
+func includeBots(r *http.Request, def bool) (bool, error) {
+	value := r.URL.Query().Get("include_bots")
+	if value == "" {
+		return def, nil
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New(
+			"INVALID_PARAMETER",
+			"Invalid parameter",
+			fmt.Sprintf("include_bots must be true or false, not '%s'", value),
+			err,
+			errors.LevelError,
+		)
+	}
+	return include, nil
+}
+
+// * repoList reads the comma-separated owner/name list in the repos query
+// * parameter; it is empty when every repository is meant
+func repoList(r *http.Request) []string {
+	var repos []string
+	for _, name := range strings.Split(r.URL.Query().Get("repos"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			repos = append(repos, name)
+		}
+	}
+	return repos
+}
+
 // getRepository godoc
 // @Summary Get Repository
 // @Description Fetch repository metadata from DB
@@ -85,7 +131,7 @@ func (h *RepositoryHandler) getRepository(w http.ResponseWriter, r *http.Request
 // @Param repository body AddRepositoryRequest true "Repository to Add"
 // @Success 201 {object} map[string]string
 // @Failure 400 {string} string "Invalid request"
-// @Failure 409 {string} string "Repository already monitored"
+// @Failure 409 {string} string "Repository already monitored, or being synced by another replica"
 // @Failure 500 {string} string "Failed to sync repository"
 // @Router /repositories [post]
 func (h *RepositoryHandler) AddRepository(w http.ResponseWriter, r *http.Request) {
@@ -107,23 +153,23 @@ func (h *RepositoryHandler) AddRepository(w http.ResponseWriter, r *http.Request
 	for _, r := range existingRepos {
 		if r.Name == repoName {
 			logger.Info("Repository %s already exists", repoName)
+			if r.Status == models.RepositoryArchived {
+				http.Error(w, "Repository is archived; restore it to resume monitoring", http.StatusConflict)
+				return
+			}
 			http.Error(w, "Repository already monitored", http.StatusConflict)
 			return
 		}
 	}
 
 	// * Sync the new repo
-	if err := h.service.SyncRepository(ctx, req.Owner, req.Name, time.Time{}); err != nil {
+	if err := h.service.AddRepository(ctx, req.Owner, req.Name); err != nil {
 		errors.WriteHTTPError(w, err)
 		return
 	}
 
 	// * Start monitoring
-	go func() {
-		syncInterval, _ := time.ParseDuration(os.Getenv("SYNC_INTERVAL"))
-		w := worker.NewSyncWorker(h.service, syncInterval, req.Owner, req.Name)
-		w.Run(h.ctx)
-	}()
+	h.workers.Start(req.Owner, req.Name)
 
 	w.WriteHeader(http.StatusCreated)
 	writeSuccess(w, map[string]string{
@@ -133,70 +179,173 @@ func (h *RepositoryHandler) AddRepository(w http.ResponseWriter, r *http.Request
 
 // getCommits godoc
 // @Summary Get Commits
-// @Description List commits for a repository (supports filtering & pagination)
+// @Description List commits for a repository, newest first. Follow pagination.next_cursor to fetch the next page.
 // @Tags Commits
 // @Produce json
 // @Param owner path string true "Repository Owner"
 // @Param name path string true "Repository Name"
-// @Param page query int false "Page number" default(1)
 // @Param limit query int false "Number of items per page" default(30)
+// @Param cursor query string false "Opaque cursor from a previous page's pagination.next_cursor"
+// @Param page query int false "Page number, ignored when cursor is set (deprecated)" default(1)
+// @Param include_total query bool false "Also count all commits matching since/until"
+// @Param include_bots query bool false "Include commits by bot accounts" default(true)
 // @Param since query string false "Start date (RFC3339)"
 // @Param until query string false "End date (RFC3339)"
 // @Success 200 {array} models.Commit
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid cursor"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /repositories/{owner}/{name}/commits [get]
 func (h *RepositoryHandler) getCommits(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	owner := vars["owner"]
 	repoName := vars["name"]
+	query := r.URL.Query()
 
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page, _ := strconv.Atoi(query.Get("page"))
 	if page < 1 {
 		page = 1
 	}
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
 	if limit < 1 || limit > 100 {
 		limit = 30
 	}
 
-	var since, until *time.Time
-	if s := r.URL.Query().Get("since"); s != "" {
+	bots, err := includeBots(r, true)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	filter := models.CommitFilter{
+		Limit:       limit,
+		WithTotal:   query.Get("include_total") == "true",
+		ExcludeBots: !bots,
+	}
+
+	if s := query.Get("since"); s != "" {
 		if t, err := time.Parse(time.RFC3339, s); err == nil {
-			since = &t
+			filter.Since = &t
 		}
 	}
-	if u := r.URL.Query().Get("until"); u != "" {
+	if u := query.Get("until"); u != "" {
 		if t, err := time.Parse(time.RFC3339, u); err == nil {
-			until = &t
+			filter.Until = &t
 		}
 	}
 
+	if c := query.Get("cursor"); c != "" {
+		cursor, err := models.DecodeCommitCursor(c)
+		if err != nil {
+			errors.WriteHTTPError(w, errors.New(
+				"INVALID_CURSOR",
+				"Invalid cursor",
+				"The cursor parameter must be a next_cursor value returned by this endpoint",
+				err,
+				errors.LevelError,
+			))
+			return
+		}
+		filter.After = cursor
+	} else {
+		filter.Offset = (page - 1) * limit
+	}
+
 	fullName := owner + "/" + repoName
-	commits, err := h.service.GetCommits(r.Context(), fullName, since, until)
+	result, err := h.service.GetCommits(r.Context(), fullName, filter)
 	if err != nil {
 		errors.WriteHTTPError(w, err)
 		return
 	}
 
-	start := (page - 1) * limit
-	if start > len(commits) {
-		json.NewEncoder(w).Encode([]any{})
+	commits := result.Commits
+	if commits == nil {
+		commits = []models.Commit{}
+	}
+
+	pagination := &Pagination{Limit: limit, Total: result.Total}
+	if result.NextCursor != nil {
+		pagination.NextCursor = result.NextCursor.Encode()
+	}
+
+	logger.Info("Fetched %d commits for %s", len(commits), fullName)
+	writePage(w, commits, pagination, "Successfully fetched commits")
+}
+
+// searchRepositoryCommits godoc
+// @Summary Search Repository Commits
+// @Description Full-text search over a repository's commit messages, best matches first. Supports "exact phrases", prefix* and -excluded words.
+// @Tags Commits
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Param q query string true "Search query"
+// @Param limit query int false "Max results" default(20)
+// @Param include_bots query bool false "Include commits by bot accounts" default(true)
+// @Success 200 {array} models.CommitSearchResult
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid search query"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/commits/search [get]
+func (h *RepositoryHandler) searchRepositoryCommits(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fullName := vars["owner"] + "/" + vars["name"]
+
+	h.writeSearchResults(w, r, []string{fullName})
+}
+
+// searchCommits godoc
+// @Summary Search Commits Across Repositories
+// @Description Full-text search over commit messages of every monitored repository, or only those listed in repos
+// @Tags Commits
+// @Produce json
+// @Param q query string true "Search query"
+// @Param repos query string false "Comma-separated owner/name list"
+// @Param limit query int false "Max results" default(20)
+// @Param include_bots query bool false "Include commits by bot accounts" default(true)
+// @Success 200 {array} models.CommitSearchResult
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid search query"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /commits/search [get]
+func (h *RepositoryHandler) searchCommits(w http.ResponseWriter, r *http.Request) {
+	h.writeSearchResults(w, r, repoList(r))
+}
+
+func (h *RepositoryHandler) writeSearchResults(w http.ResponseWriter, r *http.Request, repos []string) {
+	q := r.URL.Query().Get("q")
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	bots, err := includeBots(r, true)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
 		return
 	}
-	end := min(start+limit, len(commits))
 
-	logger.Info("Fetched %d commits for %s", end-start, fullName)
-	writeSuccess(w, commits[start:end], "Successfully fetched commits")
+	results, err := h.service.SearchCommits(r.Context(), q, repos, limit, bots)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	if results == nil {
+		results = []models.CommitSearchResult{}
+	}
+
+	logger.Info("Found %d commits matching %q", len(results), q)
+	writeSuccess(w, results, "Successfully searched commits")
 }
 
 // getTopCommitAuthors godoc
 // @Summary Get Top Authors
-// @Description Fetch top commit authors by number of commits
+// @Description Fetch top commit authors by number of commits. Bot accounts such as dependabot are left out unless include_bots is set.
 // @Tags Analytics
 // @Produce json
 // @Param owner path string true "Repository Owner"
 // @Param name path string true "Repository Name"
 // @Param limit query int false "Max authors to return" default(10)
+// @Param include_bots query bool false "Rank bot accounts too" default(false)
 // @Success 200 {array} models.AuthorCommitCount
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /repositories/{owner}/{name}/top-authors [get]
@@ -210,8 +359,14 @@ func (h *RepositoryHandler) getTopCommitAuthors(w http.ResponseWriter, r *http.R
 		limit = 10
 	}
 
+	bots, err := includeBots(r, false)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
 	fullName := owner + "/" + repoName
-	authors, err := h.service.GetTopAuthors(r.Context(), fullName, limit)
+	authors, err := h.service.GetTopAuthors(r.Context(), fullName, limit, bots)
 	if err != nil {
 		errors.WriteHTTPError(w, err)
 		return
@@ -228,15 +383,16 @@ func (h *RepositoryHandler) getTopCommitAuthors(w http.ResponseWriter, r *http.R
 
 // resetCollection godoc
 // @Summary Reset Repository Data
-// @Description Deletes and reloads repo data from GitHub starting from a given date
+// @Description Starts a background job that deletes the commits authored since a given date and syncs them again from GitHub. Poll the returned job for progress.
 // @Tags Repository
 // @Accept json
 // @Produce json
 // @Param owner path string true "Repository Owner"
 // @Param name path string true "Repository Name"
 // @Param request body models.DateRequest true "Start date"
-// @Success 200 {object} map[string]string
+// @Success 202 {object} models.ResetJob
 // @Failure 400 {string} string "Bad Request"
+// @Failure 409 {object} errors.HTTPErrorResponse "Repository is archived or deleted"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /repositories/{owner}/{name}/reset-collection [post]
 func (h *RepositoryHandler) resetCollection(w http.ResponseWriter, r *http.Request) {
@@ -254,17 +410,43 @@ func (h *RepositoryHandler) resetCollection(w http.ResponseWriter, r *http.Reque
 	}
 
 	fullName := owner + "/" + repoName
-	if err := h.service.ResetRepository(r.Context(), fullName, request.Since); err != nil {
+	job, err := h.service.StartReset(r.Context(), fullName, request.Since)
+	if err != nil {
 		errors.WriteHTTPError(w, err)
 		return
 	}
 
-	logger.Info("Reset repository %s data since %s", fullName, request.Since.Format(time.RFC3339))
-	w.WriteHeader(http.StatusOK)
-	writeSuccess(w, map[string]string{
-		"message": "Repository data reset successfully",
-		"since":   request.Since.Format(time.RFC3339),
-	}, "Repository data reset successfully")
+	logger.Info("Started reset job %d of %s since %s", job.ID, fullName, request.Since.Format(time.RFC3339))
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/reset-jobs/%d", job.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	writeSuccess(w, job, "Repository reset started")
+}
+
+// getResetJob godoc
+// @Summary Get Reset Job
+// @Description Returns the progress of a reset started with reset-collection: queued, deleting, syncing, then succeeded or failed
+// @Tags Repository
+// @Produce json
+// @Param id path int true "Reset job ID"
+// @Success 200 {object} models.ResetJob
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /reset-jobs/{id} [get]
+func (h *RepositoryHandler) getResetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id < 1 {
+		errors.WriteHTTPError(w, errors.New("INVALID_RESET_JOB_ID", "Invalid reset job ID", "The job ID must be a positive integer", err, errors.LevelError))
+		return
+	}
+
+	job, err := h.service.GetResetJob(r.Context(), id)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	writeSuccess(w, job, "Successfully fetched reset job")
 }
 
 // monitorRepository godoc
@@ -278,6 +460,7 @@ func (h *RepositoryHandler) resetCollection(w http.ResponseWriter, r *http.Reque
 // @Param request body models.DateRequest true "Start date"
 // @Success 200 {object} map[string]string
 // @Failure 400 {string} string "Bad Request"
+// @Failure 409 {object} errors.HTTPErrorResponse "Repository is being synced by another replica"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /repositories/{owner}/{name}/monitor [post]
 func (h *RepositoryHandler) monitorRepository(w http.ResponseWriter, r *http.Request) {
@@ -294,7 +477,7 @@ func (h *RepositoryHandler) monitorRepository(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if err := h.service.SyncRepository(r.Context(), owner, repoName, request.Since); err != nil {
+	if err := h.service.MonitorRepository(r.Context(), owner, repoName, request.Since); err != nil {
 		errors.WriteHTTPError(w, err)
 		return
 	}
@@ -306,3 +489,97 @@ func (h *RepositoryHandler) monitorRepository(w http.ResponseWriter, r *http.Req
 		"since":   request.Since.Format(time.RFC3339),
 	}, "Repository monitoring started successfully")
 }
+
+// deleteRepository godoc
+// @Summary Delete Repository
+// @Description Stops monitoring a repository. By default it is soft-deleted and can be restored; purge=true removes it with all its commits.
+// @Tags Repository
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Param purge query bool false "Remove the repository and its commits for good"
+// @Success 200 {object} models.Repository
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name} [delete]
+func (h *RepositoryHandler) deleteRepository(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fullName := vars["owner"] + "/" + vars["name"]
+	ctx := r.Context()
+
+	// * Marking it deleted first makes any sync already running fail when it
+	// * stores the repository. Queued sync jobs only ever update it, so one
+	// * that runs after a purge finds nothing to update and is dropped.
+	repository, err := h.service.DeleteRepository(ctx, fullName)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+	h.workers.Stop(fullName)
+
+	if r.URL.Query().Get("purge") == "true" {
+		if err := h.service.PurgeRepository(ctx, fullName); err != nil {
+			errors.WriteHTTPError(w, err)
+			return
+		}
+
+		logger.Info("Purged repository %s", fullName)
+		writeSuccess(w, map[string]string{
+			"message": "Repository and its commits removed",
+		}, "Repository purged")
+		return
+	}
+
+	logger.Info("Deleted repository %s", fullName)
+	writeSuccess(w, repository, "Repository deleted")
+}
+
+// archiveRepository godoc
+// @Summary Archive Repository
+// @Description Stops monitoring a repository while keeping it listed and its commits readable
+// @Tags Repository
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Success 200 {object} models.Repository
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/archive [post]
+func (h *RepositoryHandler) archiveRepository(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fullName := vars["owner"] + "/" + vars["name"]
+
+	repository, err := h.service.ArchiveRepository(r.Context(), fullName)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+	h.workers.Stop(fullName)
+
+	logger.Info("Archived repository %s", fullName)
+	writeSuccess(w, repository, "Repository archived")
+}
+
+// restoreRepository godoc
+// @Summary Restore Repository
+// @Description Resumes monitoring an archived or soft-deleted repository
+// @Tags Repository
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Success 200 {object} models.Repository
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/restore [post]
+func (h *RepositoryHandler) restoreRepository(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	owner, name := vars["owner"], vars["name"]
+	fullName := owner + "/" + name
+
+	repository, err := h.service.RestoreRepository(r.Context(), fullName)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+	h.workers.Start(owner, name)
+
+	logger.Info("Restored repository %s", fullName)
+	writeSuccess(w, repository, "Repository restored")
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/gorilla/mux"
+)
+
+type JobHandler struct {
+	service *service.JobService
+}
+
+func NewJobHandler(service *service.JobService) *JobHandler {
+	return &JobHandler{service: service}
+}
+
+func (h *JobHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/jobs", h.listJobs).Methods("GET")
+}
+
+func invalidJobFilter(detail string, err error) error {
+	return errors.New("INVALID_JOB_FILTER", "Invalid job filter", detail, err, errors.LevelError)
+}
+
+// listJobs godoc
+// @Summary List Jobs
+// @Description List jobs on the database job queue newest first, e.g. status=failed for jobs that ran out of attempts. Follow pagination.next_cursor to fetch older jobs.
+// @Tags Jobs
+// @Produce json
+// @Param status query string false "queued, running, succeeded or failed"
+// @Param kind query string false "sync, reset or resolve_authors"
+// @Param limit query int false "Max jobs to return" default(50)
+// @Param cursor query string false "pagination.next_cursor from a previous page"
+// @Success 200 {array} models.Job
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid job filter"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /jobs [get]
+func (h *JobHandler) listJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	filter := models.JobFilter{
+		Status: models.JobStatus(query.Get("status")),
+		Kind:   models.JobKind(query.Get("kind")),
+		Limit:  limit,
+	}
+
+	switch filter.Status {
+	case "", models.JobQueued, models.JobRunning, models.JobSucceeded, models.JobFailed:
+	default:
+		errors.WriteHTTPError(w, invalidJobFilter("status must be queued, running, succeeded or failed", nil))
+		return
+	}
+
+	if c := query.Get("cursor"); c != "" {
+		id, err := strconv.Atoi(c)
+		if err != nil || id < 1 {
+			errors.WriteHTTPError(w, invalidJobFilter("cursor must be a next_cursor value returned by this endpoint", err))
+			return
+		}
+		filter.BeforeID = id
+	}
+
+	jobs, err := h.service.ListJobs(r.Context(), filter)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	if jobs == nil {
+		jobs = []models.Job{}
+	}
+
+	pagination := &Pagination{Limit: limit}
+	if len(jobs) == limit {
+		pagination.NextCursor = strconv.Itoa(jobs[len(jobs)-1].ID)
+	}
+
+	writePage(w, jobs, pagination, "Successfully fetched jobs")
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+type RetentionHandler struct {
+	service *service.RetentionService
+}
+
+func NewRetentionHandler(service *service.RetentionService) *RetentionHandler {
+	return &RetentionHandler{service: service}
+}
+
+func (h *RetentionHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/repositories/{owner}/{name}/retention", h.getRetentionPolicy).Methods("GET")
+	r.HandleFunc("/repositories/{owner}/{name}/retention", h.setRetentionPolicy).Methods("PUT")
+	r.HandleFunc("/admin/retention/preview", h.previewAll).Methods("GET")
+	r.HandleFunc("/admin/retention/preview/{owner}/{name}", h.previewRepository).Methods("GET")
+}
+
+// getRetentionPolicy godoc
+// @Summary Get Retention Policy
+// @Description Fetch the retention policy of a repository
+// @Tags Retention
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Success 200 {object} models.RetentionPolicy
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/retention [get]
+func (h *RetentionHandler) getRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fullName := vars["owner"] + "/" + vars["name"]
+
+	policy, err := h.service.GetPolicy(r.Context(), fullName)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	writeSuccess(w, policy, "Successfully fetched retention policy")
+}
+
+// setRetentionPolicy godoc
+// @Summary Set Retention Policy
+// @Description Keep only commits newer than max_age_days and/or the newest max_commits. Omitted limits are not enforced.
+// @Tags Retention
+// @Accept json
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Param request body RetentionPolicyRequest true "Retention limits"
+// @Success 200 {object} models.RetentionPolicy
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid retention policy"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/retention [put]
+func (h *RetentionHandler) setRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fullName := vars["owner"] + "/" + vars["name"]
+
+	var req RetentionPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.service.SetPolicy(r.Context(), fullName, req.MaxAgeDays, req.MaxCommits)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Updated retention policy for %s", fullName)
+	writeSuccess(w, policy, "Successfully saved retention policy")
+}
+
+// previewAll godoc
+// @Summary Preview Pruning
+// @Description Report what the next prune run would delete for every repository with a retention policy. Nothing is deleted.
+// @Tags Retention
+// @Produce json
+// @Success 200 {array} models.PrunePreview
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/retention/preview [get]
+func (h *RetentionHandler) previewAll(w http.ResponseWriter, r *http.Request) {
+	previews, err := h.service.PreviewAll(r.Context())
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	writeSuccess(w, previews, "Successfully previewed pruning")
+}
+
+// previewRepository godoc
+// @Summary Preview Repository Pruning
+// @Description Report what pruning would delete for one repository. Passing max_age_days or max_commits previews that policy instead of the stored one. Nothing is deleted.
+// @Tags Retention
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Param max_age_days query int false "Keep commits newer than this many days"
+// @Param max_commits query int false "Keep only the newest commits"
+// @Success 200 {object} models.PrunePreview
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid retention policy"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/retention/preview/{owner}/{name} [get]
+func (h *RetentionHandler) previewRepository(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fullName := vars["owner"] + "/" + vars["name"]
+	query := r.URL.Query()
+
+	var override *models.RetentionPolicy
+	if query.Has("max_age_days") || query.Has("max_commits") {
+		override = &models.RetentionPolicy{}
+		for param, target := range map[string]**int{
+			"max_age_days": &override.MaxAgeDays,
+			"max_commits":  &override.MaxCommits,
+		} {
+			if !query.Has(param) {
+				continue
+			}
+			n, err := strconv.Atoi(query.Get(param))
+			if err != nil {
+				errors.WriteHTTPError(w, errors.New(
+					"INVALID_RETENTION_POLICY",
+					"Invalid retention policy",
+					param+" must be a whole number",
+					err,
+					errors.LevelError,
+				))
+				return
+			}
+			*target = &n
+		}
+	}
+
+	preview, err := h.service.Preview(r.Context(), fullName, override)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Previewed pruning for %s: %d commits", fullName, preview.Commits)
+	writeSuccess(w, preview, "Successfully previewed pruning")
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"cmp"
+	"net/http"
+
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+type RebuildStatsResponse struct {
+	Buckets int `json:"buckets"`
+}
+
+// rebuildRepositoryStats godoc
+// @Summary Rebuild Repository Daily Stats
+// @Description Recomputes the pre-aggregated daily commit counts of a repository from its commits. They are kept up to date during sync, so this is only needed after repairs made outside the service.
+// @Tags Repository
+// @Produce json
+// @Param owner path string true "Repository Owner"
+// @Param name path string true "Repository Name"
+// @Success 200 {object} RebuildStatsResponse
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/stats/rebuild [post]
+func (h *RepositoryHandler) rebuildRepositoryStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	h.rebuildStats(w, r, vars["owner"]+"/"+vars["name"])
+}
+
+// rebuildAllStats godoc
+// @Summary Rebuild All Daily Stats
+// @Description Recomputes the pre-aggregated daily commit counts of every repository from their commits
+// @Tags Repository
+// @Produce json
+// @Success 200 {object} RebuildStatsResponse
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /stats/rebuild [post]
+func (h *RepositoryHandler) rebuildAllStats(w http.ResponseWriter, r *http.Request) {
+	h.rebuildStats(w, r, "")
+}
+
+func (h *RepositoryHandler) rebuildStats(w http.ResponseWriter, r *http.Request, repoName string) {
+	n, err := h.service.RebuildDailyStats(r.Context(), repoName)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	logger.Info("Rebuilt %d daily stats buckets for %s", n, cmp.Or(repoName, "all repositories"))
+	writeSuccess(w, RebuildStatsResponse{Buckets: n}, "Daily stats rebuilt")
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/gorilla/mux"
+)
+
+func invalidSyncFilter(detail string, err error) error {
+	return errors.New("INVALID_SYNC_FILTER", "Invalid sync run filter", detail, err, errors.LevelError)
+}
+
+// listSyncRuns godoc
+// @Summary List Sync Runs
+// @Description List sync attempts of a repository newest first, with their counters and outcome. Follow pagination.next_cursor to fetch older runs.
+// @Tags Repositories
+// @Produce json
+// @Param owner path string true "Repository owner"
+// @Param name path string true "Repository name"
+// @Param status query string false "running, success or failure"
+// @Param limit query int false "Max runs to return" default(50)
+// @Param cursor query string false "pagination.next_cursor from a previous page"
+// @Success 200 {array} models.SyncRun
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid sync run filter"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /repositories/{owner}/{name}/syncs [get]
+func (h *RepositoryHandler) listSyncRuns(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filter := models.SyncRunFilter{
+		RepositoryName: vars["owner"] + "/" + vars["name"],
+		Status:         models.SyncStatus(r.URL.Query().Get("status")),
+	}
+
+	switch filter.Status {
+	case "", models.SyncRunning, models.SyncSuccess, models.SyncFailure:
+	default:
+		errors.WriteHTTPError(w, invalidSyncFilter("status must be running, success or failure", nil))
+		return
+	}
+
+	h.writeSyncRuns(w, r, filter, "Successfully fetched sync runs")
+}
+
+// listSyncFailures godoc
+// @Summary List Failed Syncs
+// @Description List failed sync attempts across all repositories newest first. Follow pagination.next_cursor to fetch older runs.
+// @Tags Repositories
+// @Produce json
+// @Param limit query int false "Max runs to return" default(50)
+// @Param cursor query string false "pagination.next_cursor from a previous page"
+// @Success 200 {array} models.SyncRun
+// @Failure 400 {object} errors.HTTPErrorResponse "Invalid sync run filter"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /syncs/failures [get]
+func (h *RepositoryHandler) listSyncFailures(w http.ResponseWriter, r *http.Request) {
+	h.writeSyncRuns(w, r, models.SyncRunFilter{Status: models.SyncFailure}, "Successfully fetched failed syncs")
+}
+
+// * writeSyncRuns applies the limit and cursor of r to filter and writes the
+// * matching page of runs
+func (h *RepositoryHandler) writeSyncRuns(w http.ResponseWriter, r *http.Request, filter models.SyncRunFilter, message string) {
+	query := r.URL.Query()
+
+	filter.Limit, _ = strconv.Atoi(query.Get("limit"))
+	if filter.Limit < 1 || filter.Limit > 500 {
+		filter.Limit = 50
+	}
+
+	if c := query.Get("cursor"); c != "" {
+		id, err := strconv.Atoi(c)
+		if err != nil || id < 1 {
+			errors.WriteHTTPError(w, invalidSyncFilter("cursor must be a next_cursor value returned by this endpoint", err))
+			return
+		}
+		filter.BeforeID = id
+	}
+
+	runs, err := h.service.ListSyncRuns(r.Context(), filter)
+	if err != nil {
+		errors.WriteHTTPError(w, err)
+		return
+	}
+
+	if runs == nil {
+		runs = []models.SyncRun{}
+	}
+
+	pagination := &Pagination{Limit: filter.Limit}
+	if len(runs) == filter.Limit {
+		pagination.NextCursor = strconv.Itoa(runs[len(runs)-1].ID)
+	}
+
+	writePage(w, runs, pagination, message)
+}
@@ -6,8 +6,34 @@ type AddRepositoryRequest struct {
 }
 
 type APIResponse struct {
-	Status  string `json:"status"`
-	Data    any    `json:"data,omitempty"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Status     string      `json:"status"`
+	Data       any         `json:"data,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+}
+
+type RetentionPolicyRequest struct {
+	MaxAgeDays *int `json:"max_age_days"`
+	MaxCommits *int `json:"max_commits"`
+}
+
+type MergeAuthorsRequest struct {
+	TargetID  int   `json:"target_id"`
+	SourceIDs []int `json:"source_ids"`
+}
+
+type SplitAuthorRequest struct {
+	IdentityIDs []int  `json:"identity_ids"`
+	Name        string `json:"name"`
+}
+
+type ResolveAuthorsResponse struct {
+	Resolved int `json:"resolved"`
 }
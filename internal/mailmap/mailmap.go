@@ -0,0 +1,129 @@
+package mailmap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// * Entry is one line of a .mailmap file. CommitName is optional; when set,
+// * the entry only applies to commits with that name and email. An empty
+// * ProperName or ProperEmail leaves that part of the identity unchanged.
+type Entry struct {
+	ProperName  string `json:"proper_name,omitempty"`
+	ProperEmail string `json:"proper_email,omitempty"`
+	CommitName  string `json:"commit_name,omitempty"`
+	CommitEmail string `json:"commit_email"`
+}
+
+// * Map is a parsed .mailmap
+type Map []Entry
+
+// * Parse reads the git .mailmap format:
+// *   Proper Name <commit@email>
+// *   <proper@email> <commit@email>
+// *   Proper Name <proper@email> <commit@email>
+// *   Proper Name <proper@email> Commit Name <commit@email>
+// * Blank lines and # comments are ignored.
+func Parse(text string) (Map, error) {
+	var m Map
+	for i, line := range strings.Split(text, "\n") {
+		if hash := strings.Index(line, "#"); hash >= 0 {
+			line = line[:hash]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		entry, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("mailmap line %d: %w", i+1, err)
+		}
+		m = append(m, entry)
+	}
+	return m, nil
+}
+
+// * parseLine splits a line into alternating names and <emails>
+func parseLine(line string) (Entry, error) {
+	var names, emails []string
+	rest := line
+	for rest != "" {
+		open := strings.Index(rest, "<")
+		if open < 0 {
+			return Entry{}, fmt.Errorf("text after the last email: %q", rest)
+		}
+		end := strings.Index(rest[open:], ">")
+		if end < 0 {
+			return Entry{}, fmt.Errorf("unterminated email in %q", line)
+		}
+
+		names = append(names, strings.TrimSpace(rest[:open]))
+		emails = append(emails, strings.TrimSpace(rest[open+1:open+end]))
+		rest = strings.TrimSpace(rest[open+end+1:])
+	}
+
+	switch len(emails) {
+	case 1:
+		if names[0] == "" {
+			return Entry{}, fmt.Errorf("a single email needs a proper name: %q", line)
+		}
+		return Entry{ProperName: names[0], CommitEmail: emails[0]}, nil
+	case 2:
+		return Entry{ProperName: names[0], ProperEmail: emails[0], CommitName: names[1], CommitEmail: emails[1]}, nil
+	default:
+		return Entry{}, fmt.Errorf("expected one or two emails, found %d: %q", len(emails), line)
+	}
+}
+
+// * String renders m back into .mailmap format
+func (m Map) String() string {
+	var b strings.Builder
+	for _, e := range m {
+		if e.ProperName != "" {
+			b.WriteString(e.ProperName + " ")
+		}
+		if e.ProperEmail != "" {
+			b.WriteString("<" + e.ProperEmail + "> ")
+		}
+		if e.CommitName != "" {
+			b.WriteString(e.CommitName + " ")
+		}
+		b.WriteString("<" + e.CommitEmail + ">\n")
+	}
+	return b.String()
+}
+
+// * Lookup returns the canonical name and email for a commit identity.
+// * Emails and names compare case-insensitively, and an entry naming the
+// * commit author wins over one that only matches the email, as in git.
+func (m Map) Lookup(name, email string) (string, string, bool) {
+	var match *Entry
+	for i := range m {
+		e := &m[i]
+		if !strings.EqualFold(e.CommitEmail, email) {
+			continue
+		}
+		if e.CommitName != "" {
+			if strings.EqualFold(e.CommitName, name) {
+				match = e
+				break
+			}
+			continue
+		}
+		if match == nil {
+			match = e
+		}
+	}
+
+	if match == nil {
+		return name, email, false
+	}
+	if match.ProperName != "" {
+		name = match.ProperName
+	}
+	if match.ProperEmail != "" {
+		email = match.ProperEmail
+	}
+	return name, email, true
+}
@@ -0,0 +1,71 @@
+package mailmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = `
+# comments and blank lines are ignored
+Jane Doe <jane@example.com>
+<jane@example.com> <jane@old-job.example>
+Jane Doe <jane@example.com> jd <JD@laptop.local>   # trailing comment
+Joe Bloggs <joe@example.com> <joe@example.com>
+`
+
+func TestParse(t *testing.T) {
+	m, err := Parse(sample)
+	require.NoError(t, err)
+
+	assert.Equal(t, Map{
+		{ProperName: "Jane Doe", CommitEmail: "jane@example.com"},
+		{ProperEmail: "jane@example.com", CommitEmail: "jane@old-job.example"},
+		{ProperName: "Jane Doe", ProperEmail: "jane@example.com", CommitName: "jd", CommitEmail: "JD@laptop.local"},
+		{ProperName: "Joe Bloggs", ProperEmail: "joe@example.com", CommitEmail: "joe@example.com"},
+	}, m)
+
+	// * Round-trips through String
+	again, err := Parse(m.String())
+	require.NoError(t, err)
+	assert.Equal(t, m, again)
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, line := range []string{
+		"Jane Doe",
+		"Jane <jane@example.com",
+		"<jane@example.com>",
+		"<a@x> <b@x> <c@x>",
+		"<a@x> trailing",
+	} {
+		_, err := Parse(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	m, err := Parse(sample)
+	require.NoError(t, err)
+
+	name, email, ok := m.Lookup("jane", "Jane@Old-Job.example")
+	assert.True(t, ok)
+	assert.Equal(t, "jane", name)
+	assert.Equal(t, "jane@example.com", email)
+
+	// * The name-specific entry wins and replaces both parts
+	name, email, ok = m.Lookup("JD", "jd@laptop.local")
+	assert.True(t, ok)
+	assert.Equal(t, "Jane Doe", name)
+	assert.Equal(t, "jane@example.com", email)
+
+	// * A different name at the same email does not match that entry
+	_, _, ok = m.Lookup("someone", "jd@laptop.local")
+	assert.False(t, ok)
+
+	name, email, ok = m.Lookup("x", "nobody@example.com")
+	assert.False(t, ok)
+	assert.Equal(t, "x", name)
+	assert.Equal(t, "nobody@example.com", email)
+}
@@ -1,12 +1,17 @@
 package middleware
 
 import (
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
 	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
 )
 
+// * ActorHeader names whoever makes a request, for the audit log
+const ActorHeader = "X-Actor"
+
 type responseRecorder struct {
 	http.ResponseWriter
 	statusCode int
@@ -30,3 +35,19 @@ func (rr *responseRecorder) WriteHeader(code int) {
 	rr.statusCode = code
 	rr.ResponseWriter.WriteHeader(code)
 }
+
+// * ActorMiddleware tags the request context with the caller named in the
+// * X-Actor header, falling back to the client address
+func ActorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get(ActorHeader)
+		if actor == "" {
+			actor = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				actor = host
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(models.WithActor(r.Context(), actor)))
+	})
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// * ActivityInterval is the width of the buckets commit activity is counted in
+type ActivityInterval string
+
+const (
+	ActivityDay   ActivityInterval = "day"
+	ActivityWeek  ActivityInterval = "week"
+	ActivityMonth ActivityInterval = "month"
+)
+
+func (i ActivityInterval) Valid() bool {
+	switch i {
+	case ActivityDay, ActivityWeek, ActivityMonth:
+		return true
+	}
+	return false
+}
+
+// * Start returns the start of the bucket holding t, at midnight in t's
+// * location. Weeks start on Monday.
+func (i ActivityInterval) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch i {
+	case ActivityWeek:
+		day -= (int(t.Weekday()) + 6) % 7
+	case ActivityMonth:
+		day = 1
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// * Next returns the start of the bucket after the one starting at start
+func (i ActivityInterval) Next(start time.Time) time.Time {
+	switch i {
+	case ActivityWeek:
+		return start.AddDate(0, 0, 7)
+	case ActivityMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// * ActivityFilter selects the commits counted for an activity series.
+// * Commits are bucketed in Location from Since up to, but not including,
+// * Until.
+type ActivityFilter struct {
+	// * Repositories limits the counts to these full names; empty counts all
+	Repositories []string
+	Interval     ActivityInterval
+	Since        time.Time
+	Until        time.Time
+	Location     *time.Location
+	// * ByAuthor counts each bucket per resolved author
+	ByAuthor bool
+	// * ByRepository counts each bucket per repository
+	ByRepository bool
+	// * ExcludeBots leaves out commits by authors classified as bots
+	ExcludeBots bool
+}
+
+// * ActivityCount is the number of commits in the bucket starting at Start,
+// * by one author and in one repository when broken down. Commits not yet
+// * resolved to an author are counted under their recorded name with author
+// * ID 0.
+type ActivityCount struct {
+	Start      time.Time
+	AuthorID   int
+	AuthorName string
+	Repository string
+	Commits    int
+}
+
+// * RepositoryCommitCount is the number of commits made to one repository
+type RepositoryCommitCount struct {
+	Repository string `json:"repository"`
+	Commits    int    `json:"commits"`
+}
+
+// * ActivityBucket is one point of an activity series. Authors and
+// * Repositories are only set when the series is broken down that way,
+// * busiest first.
+type ActivityBucket struct {
+	Start        time.Time               `json:"start"`
+	Commits      int                     `json:"commits"`
+	Authors      []AuthorCommitCount     `json:"authors,omitempty"`
+	Repositories []RepositoryCommitCount `json:"repositories,omitempty"`
+}
+
+// * Activity is a commit count series over consecutive buckets, including
+// * the empty ones, from the bucket holding Since to the one before Until
+type Activity struct {
+	Interval     ActivityInterval `json:"interval"`
+	Timezone     string           `json:"timezone"`
+	Since        time.Time        `json:"since"`
+	Until        time.Time        `json:"until"`
+	Repositories []string         `json:"repositories,omitempty"`
+	Buckets      []ActivityBucket `json:"buckets"`
+}
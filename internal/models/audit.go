@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// * Audited administrative actions
+const (
+	AuditRepositoryAdd     = "repository.add"
+	AuditRepositoryMonitor = "repository.monitor"
+	AuditRepositoryReset   = "repository.reset"
+	AuditRepositoryArchive = "repository.archive"
+	AuditRepositoryDelete  = "repository.delete"
+	AuditRepositoryPurge   = "repository.purge"
+	AuditRepositoryRestore = "repository.restore"
+	AuditRetentionSet      = "retention.set"
+	AuditAuthorsMerge      = "authors.merge"
+	AuditAuthorsSplit      = "authors.split"
+	AuditMailmapSet        = "mailmap.set"
+	AuditBotRulesSet       = "bot_rules.set"
+	AuditStatsRebuild      = "stats.rebuild"
+)
+
+type AuditOutcome string
+
+const (
+	AuditSuccess AuditOutcome = "success"
+	AuditFailure AuditOutcome = "failure"
+)
+
+// * AuditEntry records one administrative action. Entries are never updated
+// * or deleted; the repository is kept by name so entries outlive a purge.
+type AuditEntry struct {
+	ID             int             `json:"id"`
+	Actor          string          `json:"actor"`
+	Action         string          `json:"action"`
+	RepositoryName string          `json:"repository_name,omitempty"`
+	Parameters     json.RawMessage `json:"parameters,omitempty" swaggertype:"object"`
+	Outcome        AuditOutcome    `json:"outcome"`
+	ErrorReference string          `json:"error_reference,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// * AuditFilter narrows GetAuditLog; zero fields do not filter. Entries come
+// * newest first, and BeforeID pages past the last entry of a previous page.
+type AuditFilter struct {
+	Actor          string
+	Action         string
+	RepositoryName string
+	Outcome        AuditOutcome
+	Since          *time.Time
+	Until          *time.Time
+	BeforeID       int
+	Limit          int
+}
+
+// * SystemActor is recorded for actions taken without a request
+const SystemActor = "system"
+
+type actorKey struct{}
+
+// * WithActor tags ctx with whoever triggered the work done under it
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// * ActorFromContext returns the actor set by WithActor, or SystemActor
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
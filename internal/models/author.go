@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// * Identity kinds, in the order they are trusted when resolving a commit
+const (
+	IdentityGitHubID = "github_id"
+	IdentityEmail    = "email"
+	IdentityName     = "name"
+)
+
+// * Author is one person, however many names and emails they commit under.
+// * Type is the GitHub account type seen when the author was created; Bot
+// * marks automation accounts, left out of leaderboards by default.
+type Author struct {
+	ID         int              `json:"id"`
+	Name       string           `json:"name"`
+	Email      string           `json:"email,omitempty"`
+	Login      string           `json:"login,omitempty"`
+	Type       string           `json:"type,omitempty"`
+	Bot        bool             `json:"bot"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Identities []AuthorIdentity `json:"identities,omitempty"`
+}
+
+// * AuthorIdentity is a GitHub user ID, email or, for commits with neither,
+// * a name that resolves to an author. Each identity belongs to one author.
+type AuthorIdentity struct {
+	ID       int    `json:"id"`
+	AuthorID int    `json:"author_id"`
+	Kind     string `json:"kind"`
+	Value    string `json:"value"`
+}
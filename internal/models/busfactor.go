@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// * BusFactorOptions shape a bus factor report. Windows are whole UTC months
+// * and the last one includes the current month so far.
+type BusFactorOptions struct {
+	// * Threshold is the percentage of commits the key authors must cover
+	Threshold float64
+	// * TopN is the number of busiest authors TopShare is taken over
+	TopN int
+	// * WindowMonths is the length of the window each measure covers
+	WindowMonths int
+	// * History is the number of monthly measures to report, latest last
+	History int
+	// * ExcludeBots leaves out commits by authors classified as bots
+	ExcludeBots bool
+}
+
+// * Concentration measures how commits in one window are spread over
+// * their authors. BusFactor is the fewest authors covering the threshold
+// * share of commits, Gini runs from 0 (evenly spread) towards 1 (one
+// * author) and TopShare is the fraction made by the busiest TopN.
+type Concentration struct {
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+	Commits   int       `json:"commits"`
+	Authors   int       `json:"authors"`
+	BusFactor int       `json:"bus_factor"`
+	Gini      float64   `json:"gini"`
+	TopShare  float64   `json:"top_share"`
+	// * KeyAuthors are the authors making up the bus factor, busiest first;
+	// * only set on the current window
+	KeyAuthors []AuthorCommitCount `json:"key_authors,omitempty"`
+}
+
+// * BusFactor reports knowledge concentration in a repository's commits,
+// * now and at the end of each earlier month
+type BusFactor struct {
+	Repository   string          `json:"repository"`
+	Threshold    float64         `json:"threshold"`
+	TopN         int             `json:"top_n"`
+	WindowMonths int             `json:"window_months"`
+	Current      Concentration   `json:"current"`
+	History      []Concentration `json:"history"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/conventional"
+)
+
+// * ChangelogRange is the span a changelog is asked for: after the commit
+// * From names up to and including the one To names, each a SHA, a prefix of
+// * one or a tag, and authored from Since up to, but not including, Until.
+// * Empty bounds are left open.
+type ChangelogRange struct {
+	From  string
+	To    string
+	Since time.Time
+	Until time.Time
+}
+
+// * ChangelogFilter selects the commits of one repository that go into a
+// * changelog. Dates bound them from Since up to, but not including, Until.
+// * After and Through bound them by stored commit, in (author_date, id)
+// * order: after the commit After points at, up to and including Through.
+// * Zero and nil bounds are left open.
+type ChangelogFilter struct {
+	Since   time.Time
+	Until   time.Time
+	After   *CommitCursor
+	Through *CommitCursor
+}
+
+// * ChangelogCommit is a commit with its Conventional Commits fields;
+// * Conventional is nil when its message does not follow the convention
+type ChangelogCommit struct {
+	ID           int                  `json:"-"`
+	SHA          string               `json:"sha"`
+	AuthorName   string               `json:"author_name"`
+	AuthorDate   time.Time            `json:"author_date"`
+	CommitURL    string               `json:"commit_url"`
+	Subject      string               `json:"subject"`
+	Conventional *conventional.Commit `json:"conventional,omitempty"`
+}
+
+// * ChangelogSection lists the commits of one type, newest first
+type ChangelogSection struct {
+	Type    string            `json:"type"`
+	Title   string            `json:"title"`
+	Commits []ChangelogCommit `json:"commits"`
+}
+
+// * Changelog groups the commits of a range by their Conventional Commits
+// * type. Breaking changes are also listed on their own, and commits not
+// * following the convention end up in Other.
+type Changelog struct {
+	Repository string             `json:"repository"`
+	From       string             `json:"from,omitempty"`
+	To         string             `json:"to,omitempty"`
+	Since      *time.Time         `json:"since,omitempty"`
+	Until      *time.Time         `json:"until,omitempty"`
+	Commits    int                `json:"commits"`
+	Compliant  int                `json:"conventional_commits"`
+	Compliance float64            `json:"conventional_share"`
+	Breaking   []ChangelogCommit  `json:"breaking_changes"`
+	Sections   []ChangelogSection `json:"sections"`
+	Other      []ChangelogCommit  `json:"other"`
+}
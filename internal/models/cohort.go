@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// * CohortFilter selects the commits contributor cohorts are built from.
+// * Periods are counted in UTC from Since up to, but not including, Until;
+// * commits before Since still decide when a contributor first appeared.
+type CohortFilter struct {
+	// * Repositories limits the cohorts to these full names; empty uses all
+	Repositories []string
+	Interval     ActivityInterval
+	Since        time.Time
+	Until        time.Time
+	// * ExcludeBots leaves out commits by authors classified as bots
+	ExcludeBots bool
+}
+
+// * ContributorPeriod counts the contributors active in one period. New
+// * ones made their first commit in it and returning ones before it;
+// * churned ones were active in the previous period but not in this one.
+type ContributorPeriod struct {
+	Start     time.Time `json:"start"`
+	Active    int       `json:"active"`
+	New       int       `json:"new"`
+	Returning int       `json:"returning"`
+	Churned   int       `json:"churned"`
+}
+
+// * Cohort follows the contributors whose first commit fell in the period
+// * starting at Start. Active[k] of them committed k periods later, which
+// * is Retention[k] as a fraction of Size.
+type Cohort struct {
+	Start     time.Time `json:"start"`
+	Size      int       `json:"size"`
+	Active    []int     `json:"active"`
+	Retention []float64 `json:"retention"`
+}
+
+// * Cohorts describes whether a community is growing or shrinking: the
+// * flow of contributors per period and a retention table with one cohort
+// * per period, oldest first
+type Cohorts struct {
+	Interval     ActivityInterval    `json:"interval"`
+	Since        time.Time           `json:"since"`
+	Until        time.Time           `json:"until"`
+	Repositories []string            `json:"repositories,omitempty"`
+	Periods      []ContributorPeriod `json:"periods"`
+	Cohorts      []Cohort            `json:"cohorts"`
+}
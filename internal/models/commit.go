@@ -10,11 +10,56 @@ type Commit struct {
 	Message      string    `json:"message"`
 	AuthorName   string    `json:"author_name"`
 	AuthorEmail  string    `json:"author_email"`
+	AuthorLogin  string    `json:"author_login,omitempty"`
 	AuthorDate   time.Time `json:"author_date"`
 	CommitURL    string    `json:"commit_url"`
+	// * AuthorGitHubID is the linked GitHub user, 0 when unlinked
+	AuthorGitHubID int64 `json:"-"`
+	// * AuthorType is the GitHub account type of the linked user; it is only
+	// * known during sync and classifies authors created from the commit
+	AuthorType string `json:"-"`
+	// * AuthorID is the resolved identity; nil until resolved
+	AuthorID *int `json:"author_id,omitempty"`
+	// * AuthorIdentityID is the identity the commit was resolved through
+	AuthorIdentityID *int `json:"-"`
 }
 
+// * AuthorCommitCount aggregates on the resolved author. Commits stored
+// * before identities were resolved count under AuthorID 0.
 type AuthorCommitCount struct {
+	AuthorID    int    `json:"author_id"`
 	AuthorName  string `json:"author_name"`
 	CommitCount int    `json:"commit_count"`
 }
+
+// * InsertResult reports how a batch insert went; rows that already existed
+// * are skipped rather than treated as errors
+type InsertResult struct {
+	Inserted int `json:"inserted"`
+	Skipped  int `json:"skipped"`
+}
+
+// * CommitFilter narrows and pages a GetCommits query
+type CommitFilter struct {
+	Since *time.Time
+	Until *time.Time
+	// * Limit caps the page size; 0 returns every matching commit
+	Limit int
+	// * After continues from a previous page. Offset is only honoured
+	// * without a cursor and exists for clients still sending page numbers.
+	After  *CommitCursor
+	Offset int
+	// * WithTotal also counts every commit matching Since/Until
+	WithTotal bool
+	// * ExcludeBots leaves out commits by authors classified as bots
+	ExcludeBots bool
+}
+
+// * CommitPage is one page of commits, newest first
+type CommitPage struct {
+	Commits []Commit
+	// * NextCursor is nil on the last page
+	NextCursor *CommitCursor
+	// * Total is only set when CommitFilter.WithTotal was requested
+	Total *int
+}
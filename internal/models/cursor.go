@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// * CommitCursor is the keyset position of the last commit on a page.
+// * Commits are ordered by (author_date, id) descending, so the next page
+// * starts strictly below this pair.
+type CommitCursor struct {
+	AuthorDate time.Time
+	ID         int
+}
+
+// * Encode turns the cursor into the opaque token handed to API clients
+func (c CommitCursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.AuthorDate.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// * DecodeCommitCursor parses a token produced by CommitCursor.Encode
+func DecodeCommitCursor(token string) (*CommitCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", err)
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor timestamp: %w", err)
+	}
+	i, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor id: %w", err)
+	}
+
+	return &CommitCursor{AuthorDate: time.Unix(0, n).UTC(), ID: i}, nil
+}
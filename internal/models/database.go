@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/mailmap"
 )
 
 // * This interface defines all db operations needed by the application
@@ -13,16 +16,95 @@ type Database interface {
 	GetRepository(ctx context.Context, name string) (*Repository, error)
 	GetAllRepositories(ctx context.Context) ([]*Repository, error)
 	UpdateRepository(ctx context.Context, repo *Repository) error
-	ResetRepository(ctx context.Context, repoName string, since time.Time) error
+	ResetRepository(ctx context.Context, repoName string, since time.Time) (int, error)
+	SetRepositoryStatus(ctx context.Context, name string, status RepositoryStatus) (*Repository, error)
+	DeleteRepository(ctx context.Context, name string) error
 
 	// * Commit operations
 	InsertCommit(ctx context.Context, commit *Commit) error
-	GetCommits(ctx context.Context, repoName string, since, until *time.Time) ([]Commit, error)
-	GetTopAuthors(ctx context.Context, repoName string, limit int) ([]AuthorCommitCount, error)
+	GetCommits(ctx context.Context, repoName string, filter CommitFilter) (*CommitPage, error)
+	GetTopAuthors(ctx context.Context, repoName string, limit int, includeBots bool) ([]AuthorCommitCount, error)
+	SearchCommits(ctx context.Context, search CommitSearch) ([]CommitSearchResult, error)
+	GetCommitActivity(ctx context.Context, filter ActivityFilter) ([]ActivityCount, error)
+	GetPunchCard(ctx context.Context, filter PunchCardFilter) (PunchCardCounts, error)
+	FindCommit(ctx context.Context, repoName, shaPrefix string) (*Commit, error)
+	GetChangelogCommits(ctx context.Context, repoName string, filter ChangelogFilter) ([]ChangelogCommit, error)
+
+	// * Retention operations
+	SetRetentionPolicy(ctx context.Context, repoName string, policy RetentionPolicy) (*RetentionPolicy, error)
+	GetRetentionPolicy(ctx context.Context, repoName string) (*RetentionPolicy, error)
+	GetRetentionPolicies(ctx context.Context) ([]RetentionPolicy, error)
+	PreviewPrune(ctx context.Context, repoName string, policy RetentionPolicy, now time.Time) (*PrunePreview, error)
+	PruneCommits(ctx context.Context, repoName string, policy RetentionPolicy, now time.Time, batchSize int) (int, error)
+
+	// * Daily statistics, maintained alongside commits
+	RebuildDailyStats(ctx context.Context, repoName string) (int, error)
+
+	// * Partition maintenance; no-ops on engines without partitioning
+	MaintainPartitions(ctx context.Context, now time.Time) error
+	DropExpiredPartitions(ctx context.Context, now time.Time) (int, error)
+
+	// * Author identity operations
+	GetAuthors(ctx context.Context, limit int) ([]Author, error)
+	GetAuthor(ctx context.Context, id int) (*Author, error)
+	MergeAuthors(ctx context.Context, targetID int, sourceIDs []int) (*Author, error)
+	SplitAuthor(ctx context.Context, authorID int, identityIDs []int, name string) (*Author, error)
+	GetMailmap(ctx context.Context) (mailmap.Map, error)
+	SetMailmap(ctx context.Context, m mailmap.Map) error
+	GetBotRules(ctx context.Context) (bots.Rules, error)
+	SetBotRules(ctx context.Context, rules bots.Rules) error
+	ResolveCommitAuthors(ctx context.Context, batchSize int) (int, error)
+
+	// * Audit operations; entries are append-only
+	RecordAudit(ctx context.Context, entry *AuditEntry) error
+	GetAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+
+	// * Sync run history
+	StartSyncRun(ctx context.Context, run *SyncRun) error
+	FinishSyncRun(ctx context.Context, run *SyncRun) error
+	GetSyncRuns(ctx context.Context, filter SyncRunFilter) ([]SyncRun, error)
+
+	// * Reset jobs
+	CreateResetJob(ctx context.Context, job *ResetJob) error
+	UpdateResetJob(ctx context.Context, job *ResetJob) error
+	GetResetJob(ctx context.Context, id int) (*ResetJob, error)
+
+	// * Job queue; a job is only ever claimed by one worker at a time
+	EnqueueJob(ctx context.Context, job *Job) (bool, error)
+	ClaimJob(ctx context.Context, worker string, now, lockedUntil time.Time) (*Job, error)
+	ExtendJob(ctx context.Context, id int, worker string, lockedUntil time.Time) error
+	CompleteJob(ctx context.Context, id int, worker string) error
+	FailJob(ctx context.Context, id int, worker, message string, retryAt *time.Time) error
+	DeferJob(ctx context.Context, id int, worker string, runAt time.Time) error
+	GetJobs(ctx context.Context, filter JobFilter) ([]Job, error)
+
+	// * Coordination between replicas; TryLock returns a nil Lock when
+	// * another holder has name
+	TryLock(ctx context.Context, name string) (Lock, error)
 
 	// * Transaction support
 	WithTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error
 	UpsertRepositoryTx(ctx context.Context, tx *sql.Tx, repo *Repository) error
+	RefreshRepositoryTx(ctx context.Context, tx *sql.Tx, repo *Repository) error
 	InsertCommitTx(ctx context.Context, tx *sql.Tx, commit *Commit) error
+	InsertCommitsTx(ctx context.Context, tx *sql.Tx, commits []Commit) (InsertResult, error)
 	UpdateRepositoryTx(ctx context.Context, tx *sql.Tx, repo *Repository) error
+	ResetRepositoryTx(ctx context.Context, tx *sql.Tx, repoName string, since time.Time) (int, error)
+	SetRepositoryStatusTx(ctx context.Context, tx *sql.Tx, name string, status RepositoryStatus) (*Repository, error)
+	DeleteRepositoryTx(ctx context.Context, tx *sql.Tx, name string) error
+	RecordAuditTx(ctx context.Context, tx *sql.Tx, entry *AuditEntry) error
+}
+
+type readPrimaryKey struct{}
+
+// * ReadPrimary marks ctx so reads done under it skip the read replicas, for
+// * callers that act on what they read and cannot work from a lagging copy
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey{}, true)
+}
+
+// * ReadsPrimary reports whether ctx was marked by ReadPrimary
+func ReadsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(readPrimaryKey{}).(bool)
+	return primary
 }
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// * JobKind names the handler that runs a queued job
+type JobKind string
+
+const (
+	JobSync           JobKind = "sync"
+	JobReset          JobKind = "reset"
+	JobResolveAuthors JobKind = "resolve_authors"
+)
+
+// * JobStatus tracks a job in the database job queue
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// * Job is one unit of background work in the database job queue. Jobs run
+// * highest Priority first, not before RunAt. A running job whose
+// * LockedUntil has passed was abandoned by its worker and is claimed again.
+// * While a job with a DedupeKey is queued or running, enqueueing another
+// * with the same key is a no-op.
+type Job struct {
+	ID          int             `json:"id"`
+	Kind        JobKind         `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	DedupeKey   string          `json:"dedupe_key,omitempty"`
+	Priority    int             `json:"priority"`
+	Status      JobStatus       `json:"status"`
+	RunAt       time.Time       `json:"run_at"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LockedBy    string          `json:"locked_by,omitempty"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// * JobFilter narrows a job listing. Zero fields do not filter; BeforeID
+// * pages backwards through the newest-first results.
+type JobFilter struct {
+	Status   JobStatus
+	Kind     JobKind
+	BeforeID int
+	Limit    int
+}
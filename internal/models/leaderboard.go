@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// * LeaderboardFilter selects the commits ranked by the cross-repository
+// * leaderboard, from Since up to, but not including, Until. A zero bound is
+// * left open.
+type LeaderboardFilter struct {
+	// * Repositories limits the ranking to these full names; empty ranks
+	// * every repository not deleted
+	Repositories []string
+	Since        time.Time
+	Until        time.Time
+	// * Limit is the number of authors and of repositories ranked
+	Limit int
+	// * ExcludeBots leaves out commits by authors classified as bots
+	ExcludeBots bool
+}
+
+// * AuthorRanking is an author's place on the cross-repository leaderboard.
+// * The author is resolved across repositories, so the same person
+// * committing to several of them is ranked once.
+type AuthorRanking struct {
+	AuthorID     int                     `json:"author_id"`
+	AuthorName   string                  `json:"author_name"`
+	Commits      int                     `json:"commits"`
+	Repositories []RepositoryCommitCount `json:"repositories"`
+}
+
+// * RepositoryRanking is a repository's place among the most active ones,
+// * with its number of distinct authors and its busiest few
+type RepositoryRanking struct {
+	Repository string              `json:"repository"`
+	Commits    int                 `json:"commits"`
+	Authors    int                 `json:"authors"`
+	TopAuthors []AuthorCommitCount `json:"top_authors"`
+}
+
+// * Leaderboard ranks the busiest authors and the most active repositories
+// * over the same commits
+type Leaderboard struct {
+	Since           *time.Time          `json:"since,omitempty"`
+	Until           *time.Time          `json:"until,omitempty"`
+	Repositories    []string            `json:"repositories,omitempty"`
+	Commits         int                 `json:"commits"`
+	Authors         []AuthorRanking     `json:"authors"`
+	TopRepositories []RepositoryRanking `json:"top_repositories"`
+}
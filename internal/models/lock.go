@@ -0,0 +1,12 @@
+package models
+
+import "context"
+
+// * Lock is a named lock shared by every process using the same database,
+// * held until Release
+type Lock interface {
+	// * Held reports whether the lock is still held. A Postgres lock belongs
+	// * to its connection and is lost with it.
+	Held(ctx context.Context) bool
+	Release() error
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// * PunchCardDays names the rows of a punch card
+var PunchCardDays = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+// * PunchCardFilter selects the commits counted on a punch card
+type PunchCardFilter struct {
+	// * Repositories limits the card to these full names; empty counts all
+	Repositories []string
+	// * AuthorID limits the card to one resolved author; 0 counts everyone
+	AuthorID int
+	// * Since and Until bound the author dates when set; Until is exclusive
+	Since time.Time
+	Until time.Time
+	// * Location is the zone commits are placed in, unless AuthorOffset is set
+	Location *time.Location
+	// * AuthorOffset places each commit at its author's local time, using
+	// * the UTC offset its date was reported with
+	AuthorOffset bool
+	// * ExcludeBots leaves out commits by authors classified as bots
+	ExcludeBots bool
+}
+
+// * PunchCardCounts holds commits per weekday, Monday first, and hour
+type PunchCardCounts [7][24]int
+
+// * Add counts n commits at the weekday and hour of t in its location
+func (c *PunchCardCounts) Add(t time.Time, n int) {
+	c[(int(t.Weekday())+6)%7][t.Hour()] += n
+}
+
+func (c *PunchCardCounts) Total() int {
+	total := 0
+	for _, day := range c {
+		for _, n := range day {
+			total += n
+		}
+	}
+	return total
+}
+
+// * PunchCard is a weekday by hour heatmap of commits. Timezone is the zone
+// * the hours are in, or "author" when each commit keeps its author's offset.
+type PunchCard struct {
+	Timezone     string          `json:"timezone"`
+	Repositories []string        `json:"repositories,omitempty"`
+	AuthorID     int             `json:"author_id,omitempty"`
+	Total        int             `json:"total"`
+	Days         []string        `json:"days"`
+	Counts       PunchCardCounts `json:"counts"`
+}
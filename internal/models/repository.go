@@ -3,20 +3,33 @@ package models
 import "time"
 
 type Repository struct {
-	ID                  int        `json:"id"`
-	Name                string     `json:"name"`
-	Description         string     `json:"description"`
-	URL                 string     `json:"url"`
-	Language            string     `json:"language"`
-	ForksCount          int        `json:"forks_count"`
-	StarsCount          int        `json:"stars_count"`
-	OpenIssuesCount     int        `json:"open_issues_count"`
-	WatchersCount       int        `json:"watchers_count"`
-	CreatedAt           time.Time  `json:"created_at"`
-	UpdatedAt           time.Time  `json:"updated_at"`
-	LastCommitFetchedAt *time.Time `json:"last_commit_fetched_at,omitempty"`
+	ID                  int              `json:"id"`
+	Name                string           `json:"name"`
+	Description         string           `json:"description"`
+	URL                 string           `json:"url"`
+	Language            string           `json:"language"`
+	ForksCount          int              `json:"forks_count"`
+	StarsCount          int              `json:"stars_count"`
+	OpenIssuesCount     int              `json:"open_issues_count"`
+	WatchersCount       int              `json:"watchers_count"`
+	CreatedAt           time.Time        `json:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at"`
+	LastCommitFetchedAt *time.Time       `json:"last_commit_fetched_at,omitempty"`
+	Status              RepositoryStatus `json:"status"`
+	StatusChangedAt     *time.Time       `json:"status_changed_at,omitempty"`
 }
 
+// * RepositoryStatus controls whether a repository is synced. Archived and
+// * deleted repositories keep their commits but are never synced; deleted
+// * ones are also left out of listings and cross-repository search.
+type RepositoryStatus string
+
+const (
+	RepositoryActive   RepositoryStatus = "active"
+	RepositoryArchived RepositoryStatus = "archived"
+	RepositoryDeleted  RepositoryStatus = "deleted"
+)
+
 type DateRequest struct {
 	Since time.Time `json:"since"`
-}
\ No newline at end of file
+}
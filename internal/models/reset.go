@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// * ResetJobStatus tracks a reset through its phases
+type ResetJobStatus string
+
+const (
+	ResetQueued    ResetJobStatus = "queued"
+	ResetDeleting  ResetJobStatus = "deleting"
+	ResetSyncing   ResetJobStatus = "syncing"
+	ResetSucceeded ResetJobStatus = "succeeded"
+	ResetFailed    ResetJobStatus = "failed"
+)
+
+// * Done reports whether the job has stopped, successfully or not
+func (s ResetJobStatus) Done() bool {
+	return s == ResetSucceeded || s == ResetFailed
+}
+
+// * ResetJob records a background reset of a repository: deleting its
+// * commits from Since onwards, then syncing them again. SyncRunID points at
+// * the sync_runs record of the re-sync once it has started.
+type ResetJob struct {
+	ID              int            `json:"id"`
+	RepositoryName  string         `json:"repository_name"`
+	Since           *time.Time     `json:"since,omitempty"`
+	Actor           string         `json:"actor"`
+	Status          ResetJobStatus `json:"status"`
+	CommitsDeleted  int            `json:"commits_deleted"`
+	CommitsInserted int            `json:"commits_inserted"`
+	SyncRunID       *int           `json:"sync_run_id,omitempty"`
+	ErrorReference  string         `json:"error_reference,omitempty"`
+	ErrorMessage    string         `json:"error_message,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	StartedAt       *time.Time     `json:"started_at,omitempty"`
+	FinishedAt      *time.Time     `json:"finished_at,omitempty"`
+}
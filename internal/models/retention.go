@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// * RetentionPolicy bounds how many commits are kept for a repository. A
+// * commit expires when it is older than MaxAgeDays or falls outside the
+// * newest MaxCommits; a nil limit is not enforced.
+type RetentionPolicy struct {
+	RepositoryID   int       `json:"repository_id"`
+	RepositoryName string    `json:"repository_name"`
+	MaxAgeDays     *int      `json:"max_age_days,omitempty"`
+	MaxCommits     *int      `json:"max_commits,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// * IsEmpty reports whether the policy keeps everything
+func (p RetentionPolicy) IsEmpty() bool {
+	return p.MaxAgeDays == nil && p.MaxCommits == nil
+}
+
+// * Cutoff is the author date before which commits expire by age
+func (p RetentionPolicy) Cutoff(now time.Time) *time.Time {
+	if p.MaxAgeDays == nil {
+		return nil
+	}
+	t := now.AddDate(0, 0, -*p.MaxAgeDays)
+	return &t
+}
+
+// * PrunePreview describes what pruning a repository would delete
+type PrunePreview struct {
+	RepositoryName string          `json:"repository_name"`
+	Policy         RetentionPolicy `json:"policy"`
+	Cutoff         *time.Time      `json:"cutoff,omitempty"`
+	Commits        int             `json:"commits"`
+	OldestDate     *time.Time      `json:"oldest_date,omitempty"`
+	NewestDate     *time.Time      `json:"newest_date,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "github.com/KOFI-GYIMAH/github-monitor/internal/search"
+
+// * CommitSearch is a full-text search over commit messages
+type CommitSearch struct {
+	Query search.Query
+	// * Repositories limits the search to these full names; empty searches all
+	Repositories []string
+	Limit        int
+	// * ExcludeBots leaves out commits by authors classified as bots
+	ExcludeBots bool
+}
+
+// * CommitSearchResult is a matching commit, best matches first. Snippet is
+// * an excerpt of the message with matched words wrapped in <mark> tags.
+type CommitSearchResult struct {
+	Commit
+	RepositoryName string  `json:"repository_name"`
+	Rank           float64 `json:"rank"`
+	Snippet        string  `json:"snippet"`
+}
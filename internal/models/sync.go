@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// * SyncTrigger says what started a sync run
+type SyncTrigger string
+
+const (
+	SyncTriggerWorker  SyncTrigger = "worker"
+	SyncTriggerAPI     SyncTrigger = "api"
+	SyncTriggerWebhook SyncTrigger = "webhook"
+)
+
+type SyncStatus string
+
+const (
+	SyncRunning SyncStatus = "running"
+	SyncSuccess SyncStatus = "success"
+	SyncFailure SyncStatus = "failure"
+)
+
+// * SyncRun records one sync of a repository. It is written when the sync
+// * starts and updated when it ends, so a run that never finished stays
+// * visible as running. The repository is kept by name like audit entries.
+type SyncRun struct {
+	ID              int         `json:"id"`
+	RepositoryName  string      `json:"repository_name"`
+	Trigger         SyncTrigger `json:"trigger"`
+	Since           *time.Time  `json:"since,omitempty"`
+	StartedAt       time.Time   `json:"started_at"`
+	FinishedAt      *time.Time  `json:"finished_at,omitempty"`
+	PagesFetched    int         `json:"pages_fetched"`
+	CommitsSeen     int         `json:"commits_seen"`
+	CommitsInserted int         `json:"commits_inserted"`
+	APICalls        int         `json:"api_calls"`
+	Status          SyncStatus  `json:"status"`
+	ErrorReference  string      `json:"error_reference,omitempty"`
+	ErrorMessage    string      `json:"error_message,omitempty"`
+}
+
+// * SyncRunFilter narrows GetSyncRuns; zero fields do not filter. Runs come
+// * newest first, and BeforeID pages past the last run of a previous page.
+type SyncRunFilter struct {
+	RepositoryName string
+	Status         SyncStatus
+	BeforeID       int
+	Limit          int
+}
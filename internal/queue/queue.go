@@ -0,0 +1,323 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// * SyncQueue holds the sync jobs waiting for a worker
+	SyncQueue = "sync_jobs"
+	// * RetryQueue parks failed jobs until their retry delay expires, then
+	// * dead-letters them back onto SyncQueue
+	RetryQueue = "sync_jobs.retry"
+	// * DeadLetterQueue keeps the jobs that failed MaxAttempts times, for an
+	// * operator to inspect and re-publish
+	DeadLetterQueue = "sync_jobs.dead"
+
+	// * attemptsHeader counts the failed deliveries of a job
+	attemptsHeader = "x-attempts"
+)
+
+// * SyncJob asks a worker to sync one repository from Since; a nil Since
+// * syncs the full history
+type SyncJob struct {
+	Owner   string             `json:"owner"`
+	Name    string             `json:"name"`
+	Since   *time.Time         `json:"since,omitempty"`
+	Trigger models.SyncTrigger `json:"trigger"`
+}
+
+// * Config connects a Queue to RabbitMQ
+type Config struct {
+	URL string
+	// * MaxAttempts is how many times a job may fail before it is dead-lettered
+	MaxAttempts int
+	// * RetryDelay is how long a failed job waits before it is delivered again
+	RetryDelay time.Duration
+	// * Prefetch is how many unacknowledged jobs a consumer holds at once
+	Prefetch int
+}
+
+// * Handler runs one job. Returning an error retries the job, or
+// * dead-letters it once it has failed MaxAttempts times.
+type Handler func(ctx context.Context, job SyncJob) error
+
+// * publisher is the part of an AMQP channel used to publish jobs
+type publisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// * Queue publishes and consumes sync jobs through RabbitMQ. It connects on
+// * first use and reconnects after the broker closes the connection.
+type Queue struct {
+	cfg Config
+
+	mu   sync.Mutex
+	conn *amqp.Connection
+	ch   *amqp.Channel
+}
+
+// * New returns a queue for cfg; nothing is dialled until it is used
+func New(cfg Config) *Queue {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Prefetch < 1 {
+		cfg.Prefetch = 1
+	}
+	return &Queue{cfg: cfg}
+}
+
+// * Close closes the connection to the broker. Unacknowledged jobs are
+// * redelivered to another consumer.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.conn == nil {
+		return nil
+	}
+	err := q.conn.Close()
+	q.conn, q.ch = nil, nil
+	return err
+}
+
+// * channel returns the open channel, dialling the broker and declaring the
+// * queues when there is none
+func (q *Queue) channel() (*amqp.Channel, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.conn != nil && !q.conn.IsClosed() {
+		return q.ch, nil
+	}
+
+	conn, err := amqp.Dial(q.cfg.URL)
+	if err != nil {
+		return nil, queueError("connect to the broker", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, queueError("open a channel", err)
+	}
+	if err := declare(ch, q.cfg.Prefetch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	q.conn, q.ch = conn, ch
+	return ch, nil
+}
+
+// * discard drops ch after it failed so the next use reconnects; the
+// * connection may still be open when only the channel was closed
+func (q *Queue) discard(ch *amqp.Channel) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.ch != ch {
+		return
+	}
+	q.conn.Close()
+	q.conn, q.ch = nil, nil
+}
+
+// * declare creates the queues if they do not exist. Failed jobs go through
+// * RetryQueue back to SyncQueue; rejected ones are dead-lettered.
+func declare(ch *amqp.Channel, prefetch int) error {
+	queues := []struct {
+		name string
+		args amqp.Table
+	}{
+		{SyncQueue, amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": DeadLetterQueue,
+		}},
+		{RetryQueue, amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": SyncQueue,
+		}},
+		{DeadLetterQueue, nil},
+	}
+	for _, queue := range queues {
+		if _, err := ch.QueueDeclare(queue.name, true, false, false, false, queue.args); err != nil {
+			return queueError("declare queue "+queue.name, err)
+		}
+	}
+
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		return queueError("set the prefetch count", err)
+	}
+	return nil
+}
+
+// * Publish enqueues job as a persistent message
+func (q *Queue) Publish(ctx context.Context, job SyncJob) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return queueError("encode job", err)
+	}
+
+	ch, err := q.channel()
+	if err != nil {
+		return err
+	}
+
+	err = ch.Publish("", SyncQueue, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Body:         body,
+	})
+	if err != nil {
+		q.discard(ch)
+		return queueError("publish job", err)
+	}
+	return nil
+}
+
+// * Consume runs handle for every job on SyncQueue, up to Prefetch at a time,
+// * until ctx is done or the connection is lost. A lost connection returns
+// * an error; calling Consume again reconnects.
+func (q *Queue) Consume(ctx context.Context, handle Handler) error {
+	ch, err := q.channel()
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := ch.Consume(SyncQueue, "", false, false, false, false, nil)
+	if err != nil {
+		q.discard(ch)
+		return queueError("consume "+SyncQueue, err)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	slots := make(chan struct{}, q.cfg.Prefetch)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				q.discard(ch)
+				return queueError("consume "+SyncQueue, amqp.ErrClosed)
+			}
+
+			slots <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				q.handle(ctx, ch, d, handle)
+			}()
+		}
+	}
+}
+
+// * handle runs one delivery and settles it: acknowledged on success,
+// * requeued untouched when the consumer is shutting down, republished to
+// * RetryQueue after a failure and dead-lettered after the last attempt
+func (q *Queue) handle(ctx context.Context, pub publisher, d amqp.Delivery, handle Handler) {
+	var job SyncJob
+	if err := json.Unmarshal(d.Body, &job); err != nil {
+		logger.Error("dead-lettering undecodable sync job: %v", err)
+		reject(d)
+		return
+	}
+
+	err := handle(ctx, job)
+	if err == nil {
+		if aerr := d.Ack(false); aerr != nil {
+			logger.Error("failed to acknowledge sync job for %s/%s: %v", job.Owner, job.Name, aerr)
+		}
+		return
+	}
+
+	if ctx.Err() != nil {
+		// * Interrupted by shutdown rather than failed; another worker takes it
+		if nerr := d.Nack(false, true); nerr != nil {
+			logger.Error("failed to requeue sync job for %s/%s: %v", job.Owner, job.Name, nerr)
+		}
+		return
+	}
+
+	attempts := attemptsOf(d) + 1
+	if attempts >= q.cfg.MaxAttempts {
+		logger.Error("dead-lettering sync job for %s/%s after %d attempts: %v", job.Owner, job.Name, attempts, err)
+		reject(d)
+		return
+	}
+
+	logger.Warn("sync job for %s/%s failed (attempt %d of %d), retrying in %s: %v",
+		job.Owner, job.Name, attempts, q.cfg.MaxAttempts, q.cfg.RetryDelay, err)
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[attemptsHeader] = int32(attempts)
+
+	perr := pub.Publish("", RetryQueue, false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    d.Timestamp,
+		Expiration:   strconv.FormatInt(q.cfg.RetryDelay.Milliseconds(), 10),
+		Body:         d.Body,
+	})
+	if perr != nil {
+		// * The retry was not stored, so hand the job back rather than lose it
+		logger.Error("failed to schedule retry of sync job for %s/%s: %v", job.Owner, job.Name, perr)
+		if nerr := d.Nack(false, true); nerr != nil {
+			logger.Error("failed to requeue sync job for %s/%s: %v", job.Owner, job.Name, nerr)
+		}
+		return
+	}
+
+	if aerr := d.Ack(false); aerr != nil {
+		logger.Error("failed to acknowledge retried sync job for %s/%s: %v", job.Owner, job.Name, aerr)
+	}
+}
+
+// * reject sends d to DeadLetterQueue
+func reject(d amqp.Delivery) {
+	if err := d.Nack(false, false); err != nil {
+		logger.Error("failed to dead-letter sync job: %v", err)
+	}
+}
+
+// * attemptsOf reads how many times d has failed before
+func attemptsOf(d amqp.Delivery) int {
+	switch n := d.Headers[attemptsHeader].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+func queueError(action string, err error) error {
+	return errors.New(
+		"QUEUE_ERROR",
+		"Job queue error",
+		fmt.Sprintf("Failed to %s", action),
+		err,
+		errors.LevelFatal,
+	)
+}
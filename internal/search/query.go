@@ -0,0 +1,243 @@
+package search
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// * SnippetStart and SnippetStop delimit the matches in snippets built by
+// * the database. They are private-use characters rather than <mark> tags so
+// * the snippet can still be escaped before it is returned as HTML.
+const (
+	SnippetStart = "\ue000"
+	SnippetStop  = "\ue001"
+)
+
+// * Term is one element of a search query. A term with several words is a
+// * phrase and must match them consecutively.
+type Term struct {
+	Words  []string
+	Prefix bool
+	Negate bool
+}
+
+// * Query is a parsed commit search. It is engine-neutral; each database
+// * renders it into its own full-text syntax.
+type Query struct {
+	Terms []Term
+}
+
+// * Parse understands a small, predictable syntax:
+// *   fix crash        both words, in any order
+// *   "use after free" an exact phrase
+// *   regress*         any word starting with "regress"
+// *   -revert          excludes commits containing "revert"
+// * Punctuation inside a word splits it into a phrase, so "use-after-free"
+// * behaves like the quoted form.
+func Parse(raw string) (Query, error) {
+	var q Query
+
+	rest := strings.TrimSpace(raw)
+	for rest != "" {
+		var chunk string
+		negate := false
+
+		if strings.HasPrefix(rest, "-") {
+			negate = true
+			rest = rest[1:]
+		}
+
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return Query{}, fmt.Errorf("unterminated phrase in search query")
+			}
+			chunk, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			chunk, rest = rest[:end], rest[end:]
+		}
+		rest = strings.TrimSpace(rest)
+
+		prefix := strings.HasSuffix(chunk, "*")
+		words := tokenize(chunk)
+		if len(words) == 0 {
+			continue
+		}
+
+		q.Terms = append(q.Terms, Term{Words: words, Prefix: prefix, Negate: negate})
+	}
+
+	if !q.hasPositiveTerm() {
+		return Query{}, fmt.Errorf("search query needs at least one word to look for")
+	}
+
+	return q, nil
+}
+
+func (q Query) hasPositiveTerm() bool {
+	for _, t := range q.Terms {
+		if !t.Negate {
+			return true
+		}
+	}
+	return false
+}
+
+// * tokenize lower-cases s and splits it into letter/digit runs
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// * TSQuery renders q for Postgres to_tsquery
+func (q Query) TSQuery() string {
+	parts := make([]string, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		words := make([]string, len(t.Words))
+		for i, w := range t.Words {
+			words[i] = "'" + w + "'"
+		}
+		if t.Prefix {
+			words[len(words)-1] += ":*"
+		}
+
+		part := strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			part = "(" + part + ")"
+		}
+		if t.Negate {
+			part = "!" + part
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " & ")
+}
+
+// * FTS5 renders q for a SQLite FTS5 MATCH expression
+func (q Query) FTS5() string {
+	var include, exclude []string
+	for _, t := range q.Terms {
+		part := `"` + strings.Join(t.Words, " ") + `"`
+		if t.Prefix {
+			part += "*"
+		}
+		if t.Negate {
+			exclude = append(exclude, part)
+		} else {
+			include = append(include, part)
+		}
+	}
+
+	expr := strings.Join(include, " AND ")
+	for _, e := range exclude {
+		expr += " NOT " + e
+	}
+	return expr
+}
+
+// * Match scores text against q without an index. It returns 0 when text
+// * does not match, otherwise the number of matched term occurrences.
+func (q Query) Match(text string) int {
+	words := tokenize(text)
+
+	score := 0
+	for _, t := range q.Terms {
+		n := t.occurrences(words)
+		if t.Negate && n > 0 {
+			return 0
+		}
+		if !t.Negate {
+			if n == 0 {
+				return 0
+			}
+			score += n
+		}
+	}
+	return score
+}
+
+// * Snippet turns a snippet delimited by SnippetStart and SnippetStop into
+// * HTML: the text is escaped and the matches wrapped in <mark> tags
+func Snippet(s string) string {
+	s = html.EscapeString(s)
+	return strings.NewReplacer(SnippetStart, "<mark>", SnippetStop, "</mark>").Replace(s)
+}
+
+// * Highlight HTML-escapes text and wraps every word of it that q matches
+// * in <mark> tags
+func (q Query) Highlight(text string) string {
+	var b strings.Builder
+	word := []rune{}
+
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		w := string(word)
+		if q.highlights(strings.ToLower(w)) {
+			b.WriteString("<mark>" + html.EscapeString(w) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(w))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	flush()
+
+	return b.String()
+}
+
+func (q Query) highlights(word string) bool {
+	for _, t := range q.Terms {
+		if t.Negate {
+			continue
+		}
+		for i, w := range t.Words {
+			if w == word || (t.Prefix && i == len(t.Words)-1 && strings.HasPrefix(word, w)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t Term) occurrences(words []string) int {
+	n := 0
+	for i := 0; i+len(t.Words) <= len(words); i++ {
+		if t.matchesAt(words, i) {
+			n++
+		}
+	}
+	return n
+}
+
+func (t Term) matchesAt(words []string, i int) bool {
+	for j, w := range t.Words {
+		candidate := words[i+j]
+		if t.Prefix && j == len(t.Words)-1 {
+			if !strings.HasPrefix(candidate, w) {
+				return false
+			}
+			continue
+		}
+		if candidate != w {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		expected    []Term
+		expectError bool
+	}{
+		{
+			name:     "plain words",
+			raw:      "Fix  crash",
+			expected: []Term{{Words: []string{"fix"}}, {Words: []string{"crash"}}},
+		},
+		{
+			name:     "phrase, prefix and exclusion",
+			raw:      `"use after free" regress* -revert`,
+			expected: []Term{{Words: []string{"use", "after", "free"}}, {Words: []string{"regress"}, Prefix: true}, {Words: []string{"revert"}, Negate: true}},
+		},
+		{
+			name:     "punctuation splits into a phrase",
+			raw:      "use-after-free",
+			expected: []Term{{Words: []string{"use", "after", "free"}}},
+		},
+		{
+			name:     "tsquery operators are not passed through",
+			raw:      "crash & !(leak) | 'x'",
+			expected: []Term{{Words: []string{"crash"}}, {Words: []string{"leak"}}, {Words: []string{"x"}}},
+		},
+		{
+			name:        "unterminated phrase",
+			raw:         `"use after`,
+			expectError: true,
+		},
+		{
+			name:        "only exclusions",
+			raw:         "-revert",
+			expectError: true,
+		},
+		{
+			name:        "empty",
+			raw:         "  ",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.raw)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, q.Terms)
+		})
+	}
+}
+
+func TestQuery_Render(t *testing.T) {
+	q, err := Parse(`"use after free" regress* -revert`)
+	require.NoError(t, err)
+
+	assert.Equal(t, `('use' <-> 'after' <-> 'free') & 'regress':* & !'revert'`, q.TSQuery())
+	assert.Equal(t, `"use after free" AND "regress"* NOT "revert"`, q.FTS5())
+}
+
+func TestQuery_MatchAndHighlight(t *testing.T) {
+	q, err := Parse(`"use after free" regress* -revert`)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, q.Match("Fix use-after-free regression in parser"))
+	assert.Zero(t, q.Match("Fix use after the free regression"))
+	assert.Zero(t, q.Match("Revert: fix use after free regression"))
+
+	assert.Equal(t,
+		"Fix <mark>use</mark>-<mark>after</mark>-<mark>free</mark> <mark>regression</mark>",
+		q.Highlight("Fix use-after-free regression"),
+	)
+	// * The message is escaped, so it cannot smuggle markup of its own in
+	assert.Equal(t,
+		"&lt;img src=x onerror=alert(1)&gt; <mark>regression</mark>",
+		q.Highlight("<img src=x onerror=alert(1)> regression"),
+	)
+}
+
+func TestSnippet(t *testing.T) {
+	assert.Equal(t,
+		"&lt;script&gt; <mark>fix</mark> &amp; &#34;<mark>crash</mark>&#34;",
+		Snippet("<script> "+SnippetStart+"fix"+SnippetStop+" & \""+SnippetStart+"crash"+SnippetStop+"\""),
+	)
+}
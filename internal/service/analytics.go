@@ -0,0 +1,547 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/concentration"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * MaxActivityBuckets bounds the length of an activity series, which is
+// * zero-filled and so costs the same whether or not anything happened
+const MaxActivityBuckets = 1000
+
+// * leaderboardTopAuthors is the number of busiest authors listed per
+// * ranked repository
+const leaderboardTopAuthors = 3
+
+// * MaxCohortPeriods bounds the periods of a cohort report, whose retention
+// * table grows with their square
+const MaxCohortPeriods = 120
+
+type AnalyticsService struct {
+	db  models.Database
+	now func() time.Time
+}
+
+func NewAnalyticsService(db models.Database) *AnalyticsService {
+	return &AnalyticsService{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// * GetActivity counts the commits selected by filter per interval. The
+// * interval defaults to a day, the location to UTC, until to now and since
+// * to a span that suits the interval. since and until are widened to whole
+// * buckets, and buckets without commits are included with a zero count.
+func (s *AnalyticsService) GetActivity(ctx context.Context, filter models.ActivityFilter) (*models.Activity, error) {
+	if filter.Interval == "" {
+		filter.Interval = models.ActivityDay
+	}
+	if !filter.Interval.Valid() {
+		return nil, invalidInterval(filter.Interval)
+	}
+	if filter.Location == nil {
+		filter.Location = time.UTC
+	}
+	if filter.Until.IsZero() {
+		filter.Until = s.now()
+	}
+	if filter.Since.IsZero() {
+		filter.Since = defaultActivitySince(filter.Interval, filter.Until)
+	}
+	if filter.Since.After(filter.Until) {
+		return nil, invalidDateRange("since must not be after until")
+	}
+
+	start := filter.Interval.Start(filter.Since.In(filter.Location))
+	end := filter.Interval.Next(filter.Interval.Start(filter.Until.In(filter.Location)))
+
+	var starts []time.Time
+	for t := start; t.Before(end); t = filter.Interval.Next(t) {
+		if len(starts) == MaxActivityBuckets {
+			return nil, invalidDateRange(fmt.Sprintf("The range spans more than %d buckets; narrow it or use a wider interval", MaxActivityBuckets))
+		}
+		starts = append(starts, t)
+	}
+
+	if err := s.requireRepositories(ctx, filter.Repositories); err != nil {
+		return nil, err
+	}
+
+	filter.Since, filter.Until = start, end
+	counts, err := s.db.GetCommitActivity(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[int64]int, len(starts))
+	for i, t := range starts {
+		index[t.Unix()] = i
+	}
+	byBucket := make([][]models.ActivityCount, len(starts))
+	for _, c := range counts {
+		if i, ok := index[c.Start.Unix()]; ok {
+			byBucket[i] = append(byBucket[i], c)
+		}
+	}
+
+	buckets := make([]models.ActivityBucket, len(starts))
+	for i, t := range starts {
+		buckets[i].Start = t
+		for _, c := range byBucket[i] {
+			buckets[i].Commits += c.Commits
+		}
+		if filter.ByAuthor {
+			buckets[i].Authors = authorTotals(byBucket[i : i+1])
+		}
+		if filter.ByRepository {
+			buckets[i].Repositories = repositoryTotals(byBucket[i])
+		}
+	}
+
+	return &models.Activity{
+		Interval:     filter.Interval,
+		Timezone:     filter.Location.String(),
+		Since:        start,
+		Until:        end,
+		Repositories: filter.Repositories,
+		Buckets:      buckets,
+	}, nil
+}
+
+// * PunchCardAuthorZone is the Timezone of a punch card that places each
+// * commit in its author's own UTC offset
+const PunchCardAuthorZone = "author"
+
+// * GetPunchCard counts the commits selected by filter per weekday and hour,
+// * in filter.Location (UTC by default) or in each author's own offset
+func (s *AnalyticsService) GetPunchCard(ctx context.Context, filter models.PunchCardFilter) (*models.PunchCard, error) {
+	if filter.Location == nil {
+		filter.Location = time.UTC
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Since.After(filter.Until) {
+		return nil, invalidDateRange("since must not be after until")
+	}
+	if err := s.requireRepositories(ctx, filter.Repositories); err != nil {
+		return nil, err
+	}
+	if filter.AuthorID != 0 {
+		if _, err := s.db.GetAuthor(ctx, filter.AuthorID); err != nil {
+			return nil, err
+		}
+	}
+
+	counts, err := s.db.GetPunchCard(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	card := &models.PunchCard{
+		Timezone:     filter.Location.String(),
+		Repositories: filter.Repositories,
+		AuthorID:     filter.AuthorID,
+		Total:        counts.Total(),
+		Days:         models.PunchCardDays,
+		Counts:       counts,
+	}
+	if filter.AuthorOffset {
+		card.Timezone = PunchCardAuthorZone
+	}
+	return card, nil
+}
+
+// * GetBusFactor measures how concentrated the commits of repoName are
+// * among its authors over trailing windows of opts.WindowMonths months,
+// * one ending with each of the last opts.History months
+func (s *AnalyticsService) GetBusFactor(ctx context.Context, repoName string, opts models.BusFactorOptions) (*models.BusFactor, error) {
+	opts.Threshold = cmp.Or(opts.Threshold, 50)
+	opts.TopN = cmp.Or(opts.TopN, 3)
+	opts.WindowMonths = cmp.Or(opts.WindowMonths, 12)
+	opts.History = cmp.Or(opts.History, 12)
+	if opts.Threshold <= 0 || opts.Threshold > 100 || opts.TopN < 1 || opts.TopN > 100 ||
+		opts.WindowMonths < 1 || opts.WindowMonths > 120 || opts.History < 1 || opts.History > 60 {
+		return nil, errors.New(
+			"INVALID_BUS_FACTOR_OPTIONS",
+			"Invalid bus factor options",
+			"threshold must be in (0, 100], top_n in 1-100, window_months in 1-120 and history in 1-60",
+			nil,
+			errors.LevelError,
+		)
+	}
+	if err := s.requireRepositories(ctx, []string{repoName}); err != nil {
+		return nil, err
+	}
+
+	months := opts.History + opts.WindowMonths - 1
+	first := models.ActivityMonth.Start(s.now().UTC()).AddDate(0, 1-months, 0)
+	counts, err := s.db.GetCommitActivity(ctx, models.ActivityFilter{
+		Repositories: []string{repoName},
+		Interval:     models.ActivityMonth,
+		Since:        first,
+		Until:        first.AddDate(0, months, 0),
+		Location:     time.UTC,
+		ByAuthor:     true,
+		ExcludeBots:  opts.ExcludeBots,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byMonth := make([][]models.ActivityCount, months)
+	for _, c := range counts {
+		month := (c.Start.Year()-first.Year())*12 + int(c.Start.Month()-first.Month())
+		if month >= 0 && month < months {
+			byMonth[month] = append(byMonth[month], c)
+		}
+	}
+
+	report := &models.BusFactor{
+		Repository:   repoName,
+		Threshold:    opts.Threshold,
+		TopN:         opts.TopN,
+		WindowMonths: opts.WindowMonths,
+	}
+	for end := opts.WindowMonths; end <= months; end++ {
+		window := concentrationOf(byMonth[end-opts.WindowMonths:end], opts)
+		window.Since = first.AddDate(0, end-opts.WindowMonths, 0)
+		window.Until = first.AddDate(0, end, 0)
+		report.History = append(report.History, window)
+	}
+
+	report.Current = report.History[len(report.History)-1]
+	report.Current.KeyAuthors = keyAuthors(byMonth[months-opts.WindowMonths:], report.Current.BusFactor)
+	return report, nil
+}
+
+// * concentrationOf measures the commits of months, summed per author
+func concentrationOf(months [][]models.ActivityCount, opts models.BusFactorOptions) models.Concentration {
+	authors := authorTotals(months)
+	commits := make([]int, len(authors))
+	total := 0
+	for i, a := range authors {
+		commits[i] = a.CommitCount
+		total += a.CommitCount
+	}
+
+	return models.Concentration{
+		Commits:   total,
+		Authors:   len(authors),
+		BusFactor: concentration.BusFactor(commits, opts.Threshold/100),
+		Gini:      concentration.Gini(commits),
+		TopShare:  concentration.TopShare(commits, opts.TopN),
+	}
+}
+
+// * keyAuthors returns the n busiest authors of months
+func keyAuthors(months [][]models.ActivityCount, n int) []models.AuthorCommitCount {
+	authors := authorTotals(months)
+	return authors[:min(n, len(authors))]
+}
+
+// * authorTotals sums the commits of each author over months, busiest first
+func authorTotals(months [][]models.ActivityCount) []models.AuthorCommitCount {
+	type key struct {
+		id   int
+		name string
+	}
+	totals := make(map[key]int)
+	for _, month := range months {
+		for _, c := range month {
+			totals[key{c.AuthorID, c.AuthorName}] += c.Commits
+		}
+	}
+
+	authors := make([]models.AuthorCommitCount, 0, len(totals))
+	for k, n := range totals {
+		authors = append(authors, models.AuthorCommitCount{AuthorID: k.id, AuthorName: k.name, CommitCount: n})
+	}
+	slices.SortFunc(authors, func(a, b models.AuthorCommitCount) int {
+		if c := cmp.Compare(b.CommitCount, a.CommitCount); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.AuthorName, b.AuthorName)
+	})
+	return authors
+}
+
+// * GetCohorts follows contributors over the periods of filter: how many
+// * were new, returning or churned in each, and which fraction of those who
+// * started in a period were still committing in the ones after it. The
+// * defaults match GetActivity, in UTC.
+func (s *AnalyticsService) GetCohorts(ctx context.Context, filter models.CohortFilter) (*models.Cohorts, error) {
+	if filter.Interval == "" {
+		filter.Interval = models.ActivityMonth
+	}
+	if !filter.Interval.Valid() {
+		return nil, invalidInterval(filter.Interval)
+	}
+	if filter.Until.IsZero() {
+		filter.Until = s.now()
+	}
+	if filter.Since.IsZero() {
+		filter.Since = defaultActivitySince(filter.Interval, filter.Until)
+	}
+	if filter.Since.After(filter.Until) {
+		return nil, invalidDateRange("since must not be after until")
+	}
+
+	start := filter.Interval.Start(filter.Since.UTC())
+	end := filter.Interval.Next(filter.Interval.Start(filter.Until.UTC()))
+
+	var starts []time.Time
+	for t := start; t.Before(end); t = filter.Interval.Next(t) {
+		if len(starts) == MaxCohortPeriods {
+			return nil, invalidDateRange(fmt.Sprintf("The range spans more than %d periods; narrow it or use a wider interval", MaxCohortPeriods))
+		}
+		starts = append(starts, t)
+	}
+
+	if err := s.requireRepositories(ctx, filter.Repositories); err != nil {
+		return nil, err
+	}
+
+	// * The whole history up to end, so first commits before start count
+	counts, err := s.db.GetCommitActivity(ctx, models.ActivityFilter{
+		Repositories: filter.Repositories,
+		Interval:     filter.Interval,
+		Until:        end,
+		Location:     time.UTC,
+		ByAuthor:     true,
+		ExcludeBots:  filter.ExcludeBots,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		id   int
+		name string
+	}
+	first := make(map[key]time.Time)
+	active := make(map[key]map[int64]bool)
+	for _, c := range counts {
+		k := key{c.AuthorID, c.AuthorName}
+		if f, ok := first[k]; !ok || c.Start.Before(f) {
+			first[k] = c.Start
+		}
+		if active[k] == nil {
+			active[k] = make(map[int64]bool)
+		}
+		active[k][c.Start.Unix()] = true
+	}
+
+	cohorts := make(map[int64][]key)
+	for k, f := range first {
+		cohorts[f.Unix()] = append(cohorts[f.Unix()], k)
+	}
+
+	report := &models.Cohorts{
+		Interval:     filter.Interval,
+		Since:        start,
+		Until:        end,
+		Repositories: filter.Repositories,
+		Periods:      make([]models.ContributorPeriod, len(starts)),
+		Cohorts:      make([]models.Cohort, len(starts)),
+	}
+
+	previous := filter.Interval.Start(start.AddDate(0, 0, -1)).Unix()
+	for i, t := range starts {
+		period := models.ContributorPeriod{Start: t}
+		for k, periods := range active {
+			switch {
+			case periods[t.Unix()] && first[k].Equal(t):
+				period.New++
+			case periods[t.Unix()]:
+				period.Returning++
+			case periods[previous]:
+				period.Churned++
+			}
+		}
+		period.Active = period.New + period.Returning
+		report.Periods[i] = period
+		previous = t.Unix()
+
+		members := cohorts[t.Unix()]
+		cohort := models.Cohort{Start: t, Size: len(members)}
+		for _, later := range starts[i:] {
+			n := 0
+			for _, k := range members {
+				if active[k][later.Unix()] {
+					n++
+				}
+			}
+			retention := 0.0
+			if len(members) > 0 {
+				retention = float64(n) / float64(len(members))
+			}
+			cohort.Active = append(cohort.Active, n)
+			cohort.Retention = append(cohort.Retention, retention)
+		}
+		report.Cohorts[i] = cohort
+	}
+
+	return report, nil
+}
+
+// * GetLeaderboard ranks authors and repositories by their commits across
+// * the repositories selected by filter, all of them by default. Authors
+// * are counted by resolved identity, so someone committing to several
+// * repositories is ranked once with a breakdown per repository. The limit
+// * defaults to 10.
+func (s *AnalyticsService) GetLeaderboard(ctx context.Context, filter models.LeaderboardFilter) (*models.Leaderboard, error) {
+	if filter.Limit < 1 {
+		filter.Limit = 10
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return nil, invalidDateRange("since must be before until")
+	}
+	if err := s.requireRepositories(ctx, filter.Repositories); err != nil {
+		return nil, err
+	}
+
+	// * Monthly buckets only keep the rows few; they are summed away below
+	counts, err := s.db.GetCommitActivity(ctx, models.ActivityFilter{
+		Repositories: filter.Repositories,
+		Interval:     models.ActivityMonth,
+		Since:        filter.Since,
+		Until:        filter.Until,
+		Location:     time.UTC,
+		ByAuthor:     true,
+		ByRepository: true,
+		ExcludeBots:  filter.ExcludeBots,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		id   int
+		name string
+	}
+	byAuthor := make(map[key][]models.ActivityCount)
+	byRepository := make(map[string][]models.ActivityCount)
+	board := &models.Leaderboard{Repositories: filter.Repositories}
+	for _, c := range counts {
+		k := key{c.AuthorID, c.AuthorName}
+		byAuthor[k] = append(byAuthor[k], c)
+		byRepository[c.Repository] = append(byRepository[c.Repository], c)
+		board.Commits += c.Commits
+	}
+	if !filter.Since.IsZero() {
+		board.Since = &filter.Since
+	}
+	if !filter.Until.IsZero() {
+		board.Until = &filter.Until
+	}
+
+	board.Authors = make([]models.AuthorRanking, 0, len(byAuthor))
+	for k, counts := range byAuthor {
+		ranking := models.AuthorRanking{
+			AuthorID:     k.id,
+			AuthorName:   k.name,
+			Repositories: repositoryTotals(counts),
+		}
+		for _, r := range ranking.Repositories {
+			ranking.Commits += r.Commits
+		}
+		board.Authors = append(board.Authors, ranking)
+	}
+	slices.SortFunc(board.Authors, func(a, b models.AuthorRanking) int {
+		if c := cmp.Compare(b.Commits, a.Commits); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.AuthorName, b.AuthorName)
+	})
+	board.Authors = board.Authors[:min(len(board.Authors), filter.Limit)]
+
+	board.TopRepositories = make([]models.RepositoryRanking, 0, len(byRepository))
+	for name, counts := range byRepository {
+		authors := authorTotals([][]models.ActivityCount{counts})
+		ranking := models.RepositoryRanking{
+			Repository: name,
+			Authors:    len(authors),
+			TopAuthors: authors[:min(len(authors), leaderboardTopAuthors)],
+		}
+		for _, a := range authors {
+			ranking.Commits += a.CommitCount
+		}
+		board.TopRepositories = append(board.TopRepositories, ranking)
+	}
+	slices.SortFunc(board.TopRepositories, func(a, b models.RepositoryRanking) int {
+		if c := cmp.Compare(b.Commits, a.Commits); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Repository, b.Repository)
+	})
+	board.TopRepositories = board.TopRepositories[:min(len(board.TopRepositories), filter.Limit)]
+
+	return board, nil
+}
+
+// * repositoryTotals sums counts per repository, busiest first
+func repositoryTotals(counts []models.ActivityCount) []models.RepositoryCommitCount {
+	totals := make(map[string]int)
+	for _, c := range counts {
+		totals[c.Repository] += c.Commits
+	}
+
+	repos := make([]models.RepositoryCommitCount, 0, len(totals))
+	for name, n := range totals {
+		repos = append(repos, models.RepositoryCommitCount{Repository: name, Commits: n})
+	}
+	sortRepositoryCounts(repos)
+	return repos
+}
+
+func sortRepositoryCounts(repos []models.RepositoryCommitCount) {
+	slices.SortFunc(repos, func(a, b models.RepositoryCommitCount) int {
+		if c := cmp.Compare(b.Commits, a.Commits); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Repository, b.Repository)
+	})
+}
+
+// * requireRepositories fails on the first of names that is not stored;
+// * analytics would otherwise report it as having no commits at all
+func (s *AnalyticsService) requireRepositories(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if _, err := s.db.GetRepository(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * defaultActivitySince is a month of days, a quarter of weeks or a year
+// * of months before until
+func defaultActivitySince(interval models.ActivityInterval, until time.Time) time.Time {
+	switch interval {
+	case models.ActivityWeek:
+		return until.AddDate(0, 0, -7*12)
+	case models.ActivityMonth:
+		return until.AddDate(-1, 0, 0)
+	}
+	return until.AddDate(0, 0, -30)
+}
+
+func invalidInterval(interval models.ActivityInterval) error {
+	return errors.New(
+		"INVALID_ACTIVITY_INTERVAL",
+		"Invalid activity interval",
+		fmt.Sprintf("Interval '%s' is not one of day, week or month", interval),
+		nil,
+		errors.LevelError,
+	)
+}
+
+func invalidDateRange(detail string) error {
+	return errors.New("INVALID_DATE_RANGE", "Invalid date range", detail, nil, errors.LevelError)
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetActivity_ZeroFillsWeeks(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryDB()
+	repo := &models.Repository{Name: "owner/repo"}
+	require.NoError(t, store.UpsertRepository(ctx, repo))
+
+	for i, date := range []time.Time{
+		time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 7, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 24, 9, 0, 0, 0, time.UTC),
+	} {
+		require.NoError(t, store.InsertCommit(ctx, &models.Commit{
+			SHA:          string(rune('a' + i)),
+			RepositoryID: repo.ID,
+			AuthorName:   "alice",
+			AuthorDate:   date,
+		}))
+	}
+
+	service := NewAnalyticsService(store)
+	service.now = func() time.Time { return time.Date(2024, 1, 25, 12, 0, 0, 0, time.UTC) }
+
+	activity, err := service.GetActivity(ctx, models.ActivityFilter{
+		Repositories: []string{"owner/repo"},
+		Interval:     models.ActivityWeek,
+		Since:        time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	// * Since is widened to the Monday before; until defaults to now
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), activity.Since)
+	assert.Equal(t, time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC), activity.Until)
+	assert.Equal(t, "UTC", activity.Timezone)
+
+	var commits []int
+	for _, b := range activity.Buckets {
+		commits = append(commits, b.Commits)
+	}
+	assert.Equal(t, []int{2, 0, 0, 1}, commits)
+}
+
+func TestGetActivity_RejectsInvalidFilters(t *testing.T) {
+	ctx := context.Background()
+	service := NewAnalyticsService(db.NewMemoryDB())
+	now := time.Now()
+
+	for name, filter := range map[string]models.ActivityFilter{
+		"unknown interval":  {Interval: "hour"},
+		"since after until": {Since: now, Until: now.Add(-time.Hour)},
+		"too many buckets":  {Since: now.AddDate(-10, 0, 0), Until: now},
+		"unknown repo":      {Repositories: []string{"owner/missing"}},
+	} {
+		_, err := service.GetActivity(ctx, filter)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestGetPunchCard(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryDB()
+	repo := &models.Repository{Name: "owner/repo"}
+	require.NoError(t, store.UpsertRepository(ctx, repo))
+
+	commit := &models.Commit{
+		SHA:          "abc",
+		RepositoryID: repo.ID,
+		AuthorName:   "alice",
+		AuthorDate:   time.Date(2024, 3, 16, 23, 0, 0, 0, time.FixedZone("", 2*60*60)),
+	}
+	require.NoError(t, store.InsertCommit(ctx, commit))
+	service := NewAnalyticsService(store)
+
+	card, err := service.GetPunchCard(ctx, models.PunchCardFilter{Repositories: []string{"owner/repo"}})
+	require.NoError(t, err)
+	assert.Equal(t, "UTC", card.Timezone)
+	assert.Equal(t, 1, card.Total)
+	assert.Equal(t, 1, card.Counts[5][21])
+
+	card, err = service.GetPunchCard(ctx, models.PunchCardFilter{AuthorID: *commit.AuthorID, AuthorOffset: true})
+	require.NoError(t, err)
+	assert.Equal(t, PunchCardAuthorZone, card.Timezone)
+	assert.Equal(t, 1, card.Counts[5][23])
+
+	_, err = service.GetPunchCard(ctx, models.PunchCardFilter{AuthorID: *commit.AuthorID + 1})
+	assert.Error(t, err)
+	_, err = service.GetPunchCard(ctx, models.PunchCardFilter{Repositories: []string{"owner/missing"}})
+	assert.Error(t, err)
+}
+
+func TestGetBusFactor_TrailingWindows(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryDB()
+	repo := &models.Repository{Name: "owner/repo"}
+	require.NoError(t, store.UpsertRepository(ctx, repo))
+
+	commits := map[string][]time.Month{
+		"alice": {time.March, time.March, time.March, time.March, time.April},
+		"bob":   {time.April, time.May, time.May, time.June},
+		"carol": {time.June},
+	}
+	for name, months := range commits {
+		for i, month := range months {
+			require.NoError(t, store.InsertCommit(ctx, &models.Commit{
+				SHA:          fmt.Sprintf("%s%d", name, i),
+				RepositoryID: repo.ID,
+				AuthorName:   name,
+				AuthorEmail:  name + "@example.com",
+				AuthorDate:   time.Date(2024, month, 10, 12, 0, 0, 0, time.UTC),
+			}))
+		}
+	}
+
+	service := NewAnalyticsService(store)
+	service.now = func() time.Time { return time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC) }
+
+	report, err := service.GetBusFactor(ctx, "owner/repo", models.BusFactorOptions{TopN: 1, WindowMonths: 3, History: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, report.Threshold)
+	require.Len(t, report.History, 2)
+
+	march := report.History[0]
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), march.Since)
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), march.Until)
+	assert.Equal(t, 8, march.Commits)
+	assert.Equal(t, 1, march.BusFactor)
+	assert.InDelta(t, 0.125, march.Gini, 1e-9)
+	assert.InDelta(t, 5.0/8, march.TopShare, 1e-9)
+	assert.Empty(t, march.KeyAuthors)
+
+	// * Alice stopped committing; the current window rests on Bob
+	assert.Equal(t, 6, report.Current.Commits)
+	assert.Equal(t, 3, report.Current.Authors)
+	assert.Equal(t, 1, report.Current.BusFactor)
+	require.Len(t, report.Current.KeyAuthors, 1)
+	assert.Equal(t, "bob", report.Current.KeyAuthors[0].AuthorName)
+
+	_, err = service.GetBusFactor(ctx, "owner/repo", models.BusFactorOptions{Threshold: 150})
+	assert.Error(t, err)
+	_, err = service.GetBusFactor(ctx, "owner/missing", models.BusFactorOptions{})
+	assert.Error(t, err)
+}
+
+func TestGetCohorts_FlowAndRetention(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryDB()
+	repo := &models.Repository{Name: "owner/repo"}
+	other := &models.Repository{Name: "owner/other"}
+	require.NoError(t, store.UpsertRepository(ctx, repo))
+	require.NoError(t, store.UpsertRepository(ctx, other))
+
+	insert := func(repoID int, name string, month time.Month) {
+		t.Helper()
+		require.NoError(t, store.InsertCommit(ctx, &models.Commit{
+			SHA:          fmt.Sprintf("%s-%d-%d", name, repoID, month),
+			RepositoryID: repoID,
+			AuthorName:   name,
+			AuthorEmail:  name + "@example.com",
+			AuthorDate:   time.Date(2024, month, 10, 12, 0, 0, 0, time.UTC),
+		}))
+	}
+	// * Alice first committed before the range and takes March off
+	insert(repo.ID, "alice", time.January)
+	insert(repo.ID, "alice", time.February)
+	insert(repo.ID, "alice", time.April)
+	insert(repo.ID, "bob", time.February)
+	insert(repo.ID, "bob", time.March)
+	insert(other.ID, "carol", time.March)
+	insert(repo.ID, "dave", time.April)
+
+	service := NewAnalyticsService(store)
+	service.now = func() time.Time { return time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC) }
+
+	report, err := service.GetCohorts(ctx, models.CohortFilter{Since: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	assert.Equal(t, models.ActivityMonth, report.Interval)
+	assert.Equal(t, []models.ContributorPeriod{
+		{Start: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Active: 2, New: 1, Returning: 1},
+		{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Active: 2, New: 1, Returning: 1, Churned: 1},
+		{Start: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), Active: 2, New: 1, Returning: 1, Churned: 2},
+	}, report.Periods)
+
+	require.Len(t, report.Cohorts, 3)
+	assert.Equal(t, 1, report.Cohorts[0].Size)
+	assert.Equal(t, []int{1, 1, 0}, report.Cohorts[0].Active)
+	assert.Equal(t, []float64{1, 1, 0}, report.Cohorts[0].Retention)
+	assert.Equal(t, []int{1, 0}, report.Cohorts[1].Active)
+	assert.Equal(t, []int{1}, report.Cohorts[2].Active)
+
+	// * Carol only committed to the other repository
+	report, err = service.GetCohorts(ctx, models.CohortFilter{
+		Repositories: []string{"owner/repo"},
+		Since:        time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Periods[0].New)
+	assert.Equal(t, 0, report.Cohorts[0].Size)
+	assert.Equal(t, []float64{0, 0}, report.Cohorts[0].Retention)
+
+	_, err = service.GetCohorts(ctx, models.CohortFilter{Interval: models.ActivityDay, Since: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)})
+	assert.Error(t, err)
+}
+
+func TestGetLeaderboard_DeduplicatesAuthorsAcrossRepositories(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryDB()
+	repo := &models.Repository{Name: "owner/repo"}
+	other := &models.Repository{Name: "owner/other"}
+	require.NoError(t, store.UpsertRepository(ctx, repo))
+	require.NoError(t, store.UpsertRepository(ctx, other))
+
+	n := 0
+	insert := func(repoID int, name, email string, day int) {
+		t.Helper()
+		n++
+		require.NoError(t, store.InsertCommit(ctx, &models.Commit{
+			SHA:          fmt.Sprintf("sha-%d", n),
+			RepositoryID: repoID,
+			AuthorName:   name,
+			AuthorEmail:  email,
+			AuthorDate:   time.Date(2024, 3, day, 12, 0, 0, 0, time.UTC),
+		}))
+	}
+	// * Alice commits under two names with one email, once before the range
+	insert(repo.ID, "alice", "alice@example.com", 1)
+	insert(repo.ID, "alice", "alice@example.com", 5)
+	insert(repo.ID, "alice", "alice@example.com", 6)
+	insert(other.ID, "Alice Smith", "alice@example.com", 7)
+	insert(repo.ID, "bob", "bob@example.com", 8)
+	insert(other.ID, "carol", "carol@example.com", 9)
+	insert(other.ID, "carol", "carol@example.com", 10)
+	insert(other.ID, "carol", "carol@example.com", 11)
+	_, err := store.ResolveCommitAuthors(ctx, 100)
+	require.NoError(t, err)
+
+	service := NewAnalyticsService(store)
+	board, err := service.GetLeaderboard(ctx, models.LeaderboardFilter{
+		Since: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+		Limit: 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 7, board.Commits)
+
+	require.Len(t, board.Authors, 2)
+	assert.Equal(t, 3, board.Authors[0].Commits)
+	assert.Equal(t, 3, board.Authors[1].Commits)
+	alice := board.Authors[0]
+	if alice.AuthorName == "carol" {
+		alice = board.Authors[1]
+	}
+	assert.NotZero(t, alice.AuthorID)
+	assert.Equal(t, []models.RepositoryCommitCount{
+		{Repository: "owner/repo", Commits: 2},
+		{Repository: "owner/other", Commits: 1},
+	}, alice.Repositories)
+
+	require.Len(t, board.TopRepositories, 2)
+	assert.Equal(t, "owner/other", board.TopRepositories[0].Repository)
+	assert.Equal(t, 4, board.TopRepositories[0].Commits)
+	assert.Equal(t, 2, board.TopRepositories[0].Authors)
+	assert.Equal(t, "carol", board.TopRepositories[0].TopAuthors[0].AuthorName)
+	assert.Equal(t, 3, board.TopRepositories[1].Commits)
+
+	board, err = service.GetLeaderboard(ctx, models.LeaderboardFilter{Repositories: []string{"owner/repo"}})
+	require.NoError(t, err)
+	assert.Equal(t, 4, board.Commits)
+	assert.Len(t, board.TopRepositories, 1)
+
+	_, err = service.GetLeaderboard(ctx, models.LeaderboardFilter{Repositories: []string{"owner/missing"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+type AuditService struct {
+	db models.Database
+}
+
+func NewAuditService(db models.Database) *AuditService {
+	return &AuditService{db: db}
+}
+
+func (s *AuditService) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	return s.db.GetAuditLog(ctx, filter)
+}
+
+// * newAuditEntry describes action by the actor in ctx. params is stored as
+// * JSON and may be nil.
+func newAuditEntry(ctx context.Context, action, repoName string, params any) models.AuditEntry {
+	entry := models.AuditEntry{
+		Actor:          models.ActorFromContext(ctx),
+		Action:         action,
+		RepositoryName: repoName,
+	}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			logger.Error("failed to encode parameters of %s: %v", action, err)
+		} else {
+			entry.Parameters = raw
+		}
+	}
+	return entry
+}
+
+// * audited runs fn in a transaction that also records entry, so a
+// * successful action is never left unaudited. A failed action rolls back,
+// * so its entry is recorded on its own afterwards.
+func audited(ctx context.Context, db models.Database, entry models.AuditEntry, fn func(tx *sql.Tx) error) error {
+	err := db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		entry.Outcome = models.AuditSuccess
+		return db.RecordAuditTx(ctx, tx, &entry)
+	})
+	if err != nil {
+		recordAudit(ctx, db, entry, err)
+	}
+	return err
+}
+
+// * recordAudit records the outcome of an action that ran its own
+// * transaction. Failing to record is logged rather than failing the action,
+// * which has already happened.
+func recordAudit(ctx context.Context, db models.Database, entry models.AuditEntry, actionErr error) {
+	entry.Outcome = models.AuditSuccess
+	if actionErr != nil {
+		entry.Outcome = models.AuditFailure
+		entry.ErrorReference = errors.Reference(actionErr)
+		if entry.ErrorReference == "" {
+			entry.ErrorReference = "UNEXPECTED_ERROR"
+		}
+	}
+
+	// * A cancelled request must not lose the record of what it did
+	ctx = context.WithoutCancel(ctx)
+	if err := db.RecordAudit(ctx, &entry); err != nil {
+		logger.Error("failed to record %s by %s: %v", entry.Action, entry.Actor, err)
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit_MemoryDB(t *testing.T) {
+	store := db.NewMemoryDB()
+	repoService := NewRepositoryService(new(MockGitHubClient), store)
+	auditService := NewAuditService(store)
+	ctx := models.WithActor(context.Background(), "alice")
+
+	require.NoError(t, store.UpsertRepository(ctx, &models.Repository{Name: "owner/repo"}))
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := repoService.ResetRepository(ctx, "owner/repo", since)
+	require.NoError(t, err)
+
+	// * A failed action is recorded too, without the rolled back change
+	err = repoService.PurgeRepository(models.WithActor(context.Background(), "bob"), "missing/repo")
+	require.Error(t, err)
+
+	entries, err := auditService.List(ctx, models.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "bob", entries[0].Actor)
+	assert.Equal(t, models.AuditRepositoryPurge, entries[0].Action)
+	assert.Equal(t, models.AuditFailure, entries[0].Outcome)
+	assert.Equal(t, "DB_REPOSITORY_NOT_FOUND", entries[0].ErrorReference)
+
+	assert.Equal(t, "alice", entries[1].Actor)
+	assert.Equal(t, models.AuditRepositoryReset, entries[1].Action)
+	assert.Equal(t, "owner/repo", entries[1].RepositoryName)
+	assert.Equal(t, models.AuditSuccess, entries[1].Outcome)
+	assert.JSONEq(t, `{"since": "2024-01-01T00:00:00Z"}`, string(entries[1].Parameters))
+
+	entries, err = auditService.List(ctx, models.AuditFilter{Actor: "alice"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, models.AuditRepositoryReset, entries[0].Action)
+
+	entries, err = auditService.List(ctx, models.AuditFilter{BeforeID: entries[0].ID + 1, Outcome: models.AuditFailure})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
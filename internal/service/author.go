@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/mailmap"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * ResolveBatchSize bounds how many commits one backfill transaction touches
+const ResolveBatchSize = 1000
+
+type AuthorService struct {
+	db        models.Database
+	batchSize int
+}
+
+func NewAuthorService(db models.Database) *AuthorService {
+	return &AuthorService{db: db, batchSize: ResolveBatchSize}
+}
+
+func (s *AuthorService) ListAuthors(ctx context.Context, limit int) ([]models.Author, error) {
+	return s.db.GetAuthors(ctx, limit)
+}
+
+func (s *AuthorService) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	return s.db.GetAuthor(ctx, id)
+}
+
+// * Merge folds the source authors, with their identities and commits, into
+// * target
+func (s *AuthorService) Merge(ctx context.Context, targetID int, sourceIDs []int) (*models.Author, error) {
+	author, err := s.db.MergeAuthors(ctx, targetID, sourceIDs)
+	recordAudit(ctx, s.db, newAuditEntry(ctx, models.AuditAuthorsMerge, "", map[string]any{
+		"target_id":  targetID,
+		"source_ids": sourceIDs,
+	}), err)
+	return author, err
+}
+
+// * Split moves identities off an author onto a new one named name
+func (s *AuthorService) Split(ctx context.Context, authorID int, identityIDs []int, name string) (*models.Author, error) {
+	author, err := s.db.SplitAuthor(ctx, authorID, identityIDs, name)
+	recordAudit(ctx, s.db, newAuditEntry(ctx, models.AuditAuthorsSplit, "", map[string]any{
+		"author_id":    authorID,
+		"identity_ids": identityIDs,
+		"name":         name,
+	}), err)
+	return author, err
+}
+
+func (s *AuthorService) GetMailmap(ctx context.Context) (mailmap.Map, error) {
+	return s.db.GetMailmap(ctx)
+}
+
+// * SetMailmap parses text in .mailmap format, replaces the stored mailmap
+// * with it and applies it to authors already resolved
+func (s *AuthorService) SetMailmap(ctx context.Context, text string) (mailmap.Map, error) {
+	m, err := mailmap.Parse(text)
+	if err != nil {
+		return nil, errors.New(
+			"INVALID_MAILMAP",
+			"Invalid mailmap",
+			err.Error(),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	err = s.db.SetMailmap(ctx, m)
+	recordAudit(ctx, s.db, newAuditEntry(ctx, models.AuditMailmapSet, "", map[string]int{"entries": len(m)}), err)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *AuthorService) GetBotRules(ctx context.Context) (bots.Rules, error) {
+	return s.db.GetBotRules(ctx)
+}
+
+// * SetBotRules replaces the bot allow and deny lists and reclassifies every
+// * author under them
+func (s *AuthorService) SetBotRules(ctx context.Context, rules bots.Rules) (bots.Rules, error) {
+	if err := rules.Validate(); err != nil {
+		return bots.Rules{}, errors.New(
+			"INVALID_BOT_RULES",
+			"Invalid bot rules",
+			err.Error(),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	err := s.db.SetBotRules(ctx, rules)
+	recordAudit(ctx, s.db, newAuditEntry(ctx, models.AuditBotRulesSet, "", map[string]int{
+		"allow": len(rules.Allow),
+		"deny":  len(rules.Deny),
+	}), err)
+	if err != nil {
+		return bots.Rules{}, err
+	}
+	return rules, nil
+}
+
+// * ResolveAll assigns an author to every commit stored without one, a
+// * batch per transaction, and reports how many it resolved
+func (s *AuthorService) ResolveAll(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		resolved, err := s.db.ResolveCommitAuthors(ctx, s.batchSize)
+		total += resolved
+		if err != nil {
+			return total, err
+		}
+		if resolved < s.batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("Resolved authors of %d commits", total)
+	}
+	return total, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/mailmap"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMailmap_RejectsInvalidText(t *testing.T) {
+	mockDB := new(MockDatabase)
+	service := NewAuthorService(mockDB)
+
+	_, err := service.SetMailmap(context.Background(), "Jane Doe")
+	assert.Error(t, err)
+	mockDB.AssertNotCalled(t, "SetMailmap", mock.Anything, mock.Anything)
+
+	want := mailmap.Map{{ProperName: "Jane Doe", CommitEmail: "jane@example.com"}}
+	mockDB.On("SetMailmap", mock.Anything, want).Return(nil).Once()
+	mockDB.On("RecordAudit", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+		return e.Action == models.AuditMailmapSet && e.Outcome == models.AuditSuccess
+	})).Return(nil).Once()
+
+	m, err := service.SetMailmap(context.Background(), "Jane Doe <jane@example.com>\n")
+	require.NoError(t, err)
+	assert.Equal(t, want, m)
+	mockDB.AssertExpectations(t)
+}
+
+func TestSetBotRules_RejectsBlankPatterns(t *testing.T) {
+	mockDB := new(MockDatabase)
+	service := NewAuthorService(mockDB)
+
+	_, err := service.SetBotRules(context.Background(), bots.Rules{Deny: []string{"release-bot", " "}})
+	assert.Error(t, err)
+	mockDB.AssertNotCalled(t, "SetBotRules", mock.Anything, mock.Anything)
+
+	want := bots.Rules{Allow: []string{"alice"}, Deny: []string{"release-bot"}}
+	mockDB.On("SetBotRules", mock.Anything, want).Return(nil).Once()
+	mockDB.On("RecordAudit", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+		return e.Action == models.AuditBotRulesSet && e.Outcome == models.AuditSuccess
+	})).Return(nil).Once()
+
+	rules, err := service.SetBotRules(context.Background(), want)
+	require.NoError(t, err)
+	assert.Equal(t, want, rules)
+	mockDB.AssertExpectations(t)
+}
+
+func TestResolveAll_ResolvesInBatchesUntilDone(t *testing.T) {
+	mockDB := new(MockDatabase)
+	service := NewAuthorService(mockDB)
+	service.batchSize = 2
+
+	mockDB.On("ResolveCommitAuthors", mock.Anything, 2).Return(2, nil).Twice()
+	mockDB.On("ResolveCommitAuthors", mock.Anything, 2).Return(0, nil).Once()
+
+	resolved, err := service.ResolveAll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, resolved)
+	mockDB.AssertExpectations(t)
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+)
+
+// * changelogTypes orders the sections of a changelog and titles them;
+// * other types follow in alphabetical order under their own name
+var changelogTypes = []struct{ kind, title string }{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance Improvements"},
+	{"refactor", "Code Refactoring"},
+	{"revert", "Reverts"},
+	{"docs", "Documentation"},
+	{"style", "Styles"},
+	{"test", "Tests"},
+	{"build", "Build System"},
+	{"ci", "Continuous Integration"},
+	{"chore", "Chores"},
+}
+
+// * shaPattern matches what is looked up as a stored SHA rather than asked
+// * of GitHub as a tag or branch
+var shaPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+type ChangelogService struct {
+	githubClient GitHubClientInterface
+	db           models.Database
+}
+
+func NewChangelogService(githubClient GitHubClientInterface, db models.Database) *ChangelogService {
+	return &ChangelogService{
+		githubClient: githubClient,
+		db:           db,
+	}
+}
+
+// * GetChangelog groups the stored commits of owner/name in span by their
+// * Conventional Commits type. Commits are ordered by author date, so the
+// * range is the commits authored after From up to To rather than those
+// * reachable from To but not From.
+func (s *ChangelogService) GetChangelog(ctx context.Context, owner, name string, span models.ChangelogRange) (*models.Changelog, error) {
+	repoName := owner + "/" + name
+	if !span.Since.IsZero() && !span.Until.IsZero() && !span.Since.Before(span.Until) {
+		return nil, invalidDateRange("since must be before until")
+	}
+	if _, err := s.db.GetRepository(ctx, repoName); err != nil {
+		return nil, err
+	}
+
+	filter := models.ChangelogFilter{Since: span.Since, Until: span.Until}
+	var err error
+	if span.From != "" {
+		if filter.After, err = s.resolveRef(ctx, owner, name, span.From); err != nil {
+			return nil, err
+		}
+	}
+	if span.To != "" {
+		if filter.Through, err = s.resolveRef(ctx, owner, name, span.To); err != nil {
+			return nil, err
+		}
+	}
+	if filter.After != nil && filter.Through != nil && filter.Through.AuthorDate.Before(filter.After.AuthorDate) {
+		return nil, errors.New(
+			"INVALID_CHANGELOG_RANGE",
+			"Invalid changelog range",
+			fmt.Sprintf("'%s' was authored after '%s'; swap from and to", span.From, span.To),
+			nil,
+			errors.LevelError,
+		)
+	}
+
+	commits, err := s.db.GetChangelogCommits(ctx, repoName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	changelog := &models.Changelog{
+		Repository: repoName,
+		From:       span.From,
+		To:         span.To,
+		Commits:    len(commits),
+		Breaking:   []models.ChangelogCommit{},
+		Sections:   []models.ChangelogSection{},
+		Other:      []models.ChangelogCommit{},
+	}
+	if !span.Since.IsZero() {
+		changelog.Since = &span.Since
+	}
+	if !span.Until.IsZero() {
+		changelog.Until = &span.Until
+	}
+
+	sections := make(map[string][]models.ChangelogCommit)
+	for _, c := range commits {
+		if c.Conventional == nil {
+			changelog.Other = append(changelog.Other, c)
+			continue
+		}
+		changelog.Compliant++
+		sections[c.Conventional.Type] = append(sections[c.Conventional.Type], c)
+		if c.Conventional.Breaking {
+			changelog.Breaking = append(changelog.Breaking, c)
+		}
+	}
+	if len(commits) > 0 {
+		changelog.Compliance = float64(changelog.Compliant) / float64(len(commits))
+	}
+
+	for _, t := range changelogTypes {
+		if entries, ok := sections[t.kind]; ok {
+			changelog.Sections = append(changelog.Sections, models.ChangelogSection{Type: t.kind, Title: t.title, Commits: entries})
+			delete(sections, t.kind)
+		}
+	}
+	for _, kind := range slices.Sorted(maps.Keys(sections)) {
+		changelog.Sections = append(changelog.Sections, models.ChangelogSection{Type: kind, Title: kind, Commits: sections[kind]})
+	}
+
+	return changelog, nil
+}
+
+// * resolveRef finds the stored commit ref names. SHAs and their prefixes
+// * are looked up directly, anything else is resolved through GitHub first.
+func (s *ChangelogService) resolveRef(ctx context.Context, owner, name, ref string) (*models.CommitCursor, error) {
+	sha := ref
+	if !shaPattern.MatchString(ref) {
+		commit, err := s.githubClient.GetCommit(ctx, owner, name, ref)
+		if err != nil {
+			return nil, err
+		}
+		sha = commit.SHA
+	}
+
+	commit, err := s.db.FindCommit(ctx, owner+"/"+name, sha)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CommitCursor{AuthorDate: commit.AuthorDate, ID: commit.ID}, nil
+}
+
+// * ChangelogMarkdown renders changelog as Markdown, breaking changes first
+// * and commits outside the convention last
+func ChangelogMarkdown(changelog *models.Changelog) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "## %s", changelog.Repository)
+	switch {
+	case changelog.From != "" || changelog.To != "":
+		fmt.Fprintf(&b, " (%s...%s)", cmp.Or(changelog.From, "start"), cmp.Or(changelog.To, "latest"))
+	case changelog.Since != nil || changelog.Until != nil:
+		since, until := "start", "now"
+		if changelog.Since != nil {
+			since = changelog.Since.Format("2006-01-02")
+		}
+		if changelog.Until != nil {
+			until = changelog.Until.Format("2006-01-02")
+		}
+		fmt.Fprintf(&b, " (%s to %s)", since, until)
+	}
+	b.WriteString("\n")
+
+	section := func(title string, commits []models.ChangelogCommit) {
+		if len(commits) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", title)
+		for _, c := range commits {
+			b.WriteString("- ")
+			subject := c.Subject
+			if c.Conventional != nil {
+				subject = c.Conventional.Subject
+				if c.Conventional.Scope != "" {
+					fmt.Fprintf(&b, "**%s:** ", c.Conventional.Scope)
+				}
+			}
+			fmt.Fprintf(&b, "%s ([%s](%s))\n", subject, c.SHA[:min(len(c.SHA), 7)], c.CommitURL)
+		}
+	}
+
+	section("⚠ BREAKING CHANGES", changelog.Breaking)
+	for _, typed := range changelog.Sections {
+		section(typed.Title, typed.Commits)
+	}
+	section("Other Changes", changelog.Other)
+
+	fmt.Fprintf(&b, "\n_%d of %d commits (%.0f%%) follow Conventional Commits._\n",
+		changelog.Compliant, changelog.Commits, changelog.Compliance*100)
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/github"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func seedChangelog(t *testing.T) models.Database {
+	t.Helper()
+	ctx := context.Background()
+	store := db.NewMemoryDB()
+	repo := &models.Repository{Name: "owner/repo"}
+	require.NoError(t, store.UpsertRepository(ctx, repo))
+
+	for day, c := range []struct{ sha, message string }{
+		{"1111111aaa", "chore: release 1.0.0"},
+		{"2222222bbb", "feat(api): add changelogs"},
+		{"3333333ccc", "fix: handle empty ranges"},
+		{"4444444ddd", "Update README"},
+		{"5555555eee", "feat!: drop the v1 routes"},
+		{"6666666fff", "wip(ui): new theme"},
+		{"7777777aaa", "chore: release 1.1.0"},
+		{"8888888bbb", "fix: after the release"},
+	} {
+		require.NoError(t, store.InsertCommit(ctx, &models.Commit{
+			SHA:          c.sha,
+			RepositoryID: repo.ID,
+			Message:      c.message,
+			AuthorName:   "alice",
+			AuthorEmail:  "alice@example.com",
+			AuthorDate:   time.Date(2024, 3, day+1, 12, 0, 0, 0, time.UTC),
+			CommitURL:    "https://github.com/owner/repo/commit/" + c.sha,
+		}))
+	}
+	return store
+}
+
+func TestGetChangelog_BetweenTags(t *testing.T) {
+	ctx := context.Background()
+	client := new(MockGitHubClient)
+	client.On("GetCommit", mock.Anything, "owner", "repo", "v1.0.0").Return(&github.Commit{SHA: "1111111aaa"}, nil)
+	service := NewChangelogService(client, seedChangelog(t))
+
+	// * A SHA prefix is looked up without asking GitHub
+	changelog, err := service.GetChangelog(ctx, "owner", "repo", models.ChangelogRange{From: "v1.0.0", To: "7777777"})
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+
+	assert.Equal(t, 6, changelog.Commits)
+	assert.Equal(t, 5, changelog.Compliant)
+	assert.InDelta(t, 5.0/6, changelog.Compliance, 1e-9)
+	require.Len(t, changelog.Breaking, 1)
+	assert.Equal(t, "5555555eee", changelog.Breaking[0].SHA)
+
+	var types []string
+	for _, s := range changelog.Sections {
+		types = append(types, s.Type)
+	}
+	assert.Equal(t, []string{"feat", "fix", "chore", "wip"}, types)
+	assert.Len(t, changelog.Sections[0].Commits, 2)
+	require.Len(t, changelog.Other, 1)
+	assert.Equal(t, "Update README", changelog.Other[0].Subject)
+
+	markdown := ChangelogMarkdown(changelog)
+	assert.Contains(t, markdown, "## owner/repo (v1.0.0...7777777)\n")
+	assert.Contains(t, markdown, "### ⚠ BREAKING CHANGES\n\n- drop the v1 routes ([5555555](https://github.com/owner/repo/commit/5555555eee))\n")
+	assert.Contains(t, markdown, "### Features\n\n- drop the v1 routes ([5555555](https://github.com/owner/repo/commit/5555555eee))\n- **api:** add changelogs")
+	assert.Contains(t, markdown, "### Other Changes\n\n- Update README")
+	assert.Contains(t, markdown, "_5 of 6 commits (83%) follow Conventional Commits._")
+	assert.NotContains(t, markdown, "after the release")
+}
+
+func TestGetChangelog_ByDate(t *testing.T) {
+	service := NewChangelogService(new(MockGitHubClient), seedChangelog(t))
+
+	changelog, err := service.GetChangelog(context.Background(), "owner", "repo", models.ChangelogRange{
+		Since: time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, changelog.Commits)
+	assert.Equal(t, "fix", changelog.Sections[0].Type)
+	assert.Equal(t, 1.0, changelog.Compliance)
+}
+
+func TestGetChangelog_RejectsInvalidRanges(t *testing.T) {
+	ctx := context.Background()
+	service := NewChangelogService(new(MockGitHubClient), seedChangelog(t))
+
+	_, err := service.GetChangelog(ctx, "owner", "repo", models.ChangelogRange{From: "7777777", To: "2222222"})
+	assert.ErrorContains(t, err, "INVALID_CHANGELOG_RANGE")
+
+	_, err = service.GetChangelog(ctx, "owner", "repo", models.ChangelogRange{From: "0000000"})
+	assert.ErrorContains(t, err, "COMMIT_NOT_FOUND")
+
+	_, err = service.GetChangelog(ctx, "owner", "missing", models.ChangelogRange{})
+	assert.Error(t, err)
+}
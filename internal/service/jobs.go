@@ -0,0 +1,178 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+const (
+	// * defaultJobAttempts applies to jobs enqueued without MaxAttempts
+	defaultJobAttempts = 5
+	// * maxJobBackoff caps the delay between retries of a failing job
+	maxJobBackoff = time.Hour
+	// * minBusyDelay is the least a job waits for another sync to finish
+	minBusyDelay = 5 * time.Second
+)
+
+// * Job priorities; higher runs first
+const (
+	JobPriorityLow    = -10
+	JobPriorityNormal = 0
+	JobPriorityHigh   = 10
+)
+
+// * JobFailureHook is told when a job of its kind fails for good, so state
+// * the job was tracking elsewhere can be settled too
+type JobFailureHook func(ctx context.Context, job *models.Job, err error)
+
+// * JobService runs the database job queue: enqueueing, claiming and
+// * settling jobs. Failed jobs are retried with exponential backoff from
+// * retryDelay until they run out of attempts.
+type JobService struct {
+	db         models.Database
+	retryDelay time.Duration
+	onFailure  map[models.JobKind]JobFailureHook
+}
+
+func NewJobService(db models.Database, retryDelay time.Duration) *JobService {
+	return &JobService{db: db, retryDelay: retryDelay, onFailure: make(map[models.JobKind]JobFailureHook)}
+}
+
+// * OnFailure registers hook for jobs of kind that fail for good; it is set
+// * up before the runner starts
+func (s *JobService) OnFailure(kind models.JobKind, hook JobFailureHook) {
+	s.onFailure[kind] = hook
+}
+
+// * Enqueue queues job with payload encoded as JSON. It reports false when
+// * a job with the same dedupe key is still queued or running.
+func (s *JobService) Enqueue(ctx context.Context, job *models.Job, payload any) (bool, error) {
+	return enqueueJob(ctx, s.db, job, payload)
+}
+
+func enqueueJob(ctx context.Context, db models.Database, job *models.Job, payload any) (bool, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return false, errors.New(
+			"INVALID_JOB_PAYLOAD",
+			"Invalid job payload",
+			fmt.Sprintf("The payload of a %s job cannot be encoded", job.Kind),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	job.Payload = raw
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if job.MaxAttempts < 1 {
+		job.MaxAttempts = defaultJobAttempts
+	}
+	return db.EnqueueJob(ctx, job)
+}
+
+// * Claim locks the next due job for worker for lease. A job that comes
+// * back with no attempts left was abandoned by a worker that died on its
+// * last attempt, so it is failed instead of run. It returns nil when
+// * nothing is due.
+func (s *JobService) Claim(ctx context.Context, worker string, lease time.Duration) (*models.Job, error) {
+	for {
+		now := time.Now()
+		job, err := s.db.ClaimJob(ctx, worker, now, now.Add(lease))
+		if err != nil || job == nil {
+			return nil, err
+		}
+		if job.Attempts <= job.MaxAttempts {
+			return job, nil
+		}
+
+		logger.Error("job %d (%s) was abandoned on its last attempt", job.ID, job.Kind)
+		msg := cmp.Or(job.LastError, "abandoned: the worker's lock expired on the last attempt")
+		if err := s.db.FailJob(ctx, job.ID, worker, msg, nil); err != nil {
+			return nil, err
+		}
+		s.failed(ctx, job, errors.New(
+			"JOB_ABANDONED",
+			"Job abandoned",
+			msg,
+			nil,
+			errors.LevelFatal,
+		))
+	}
+}
+
+// * Extend keeps job locked by worker for another lease
+func (s *JobService) Extend(ctx context.Context, job *models.Job, worker string, lease time.Duration) error {
+	return s.db.ExtendJob(ctx, job.ID, worker, time.Now().Add(lease))
+}
+
+// * Finish settles job after a run: completed when err is nil, otherwise
+// * queued again after a backoff, or failed once it has used its attempts.
+// * A job that found its repository locked by another sync is queued again
+// * without counting the attempt, since it never got to run.
+func (s *JobService) Finish(ctx context.Context, job *models.Job, worker string, err error) error {
+	if err == nil {
+		return s.db.CompleteJob(ctx, job.ID, worker)
+	}
+
+	if errors.Reference(err) == "SYNC_IN_PROGRESS" {
+		runAt := time.Now().Add(max(s.retryDelay, minBusyDelay))
+		logger.Info("job %d (%s) waits for another sync, retrying at %s", job.ID, job.Kind, runAt.Format(time.RFC3339))
+		return s.db.DeferJob(ctx, job.ID, worker, runAt)
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		return s.Fail(ctx, job, worker, err)
+	}
+
+	retryAt := time.Now().Add(s.backoff(job.Attempts))
+	logger.Warn("job %d (%s) failed (attempt %d of %d), retrying at %s: %v",
+		job.ID, job.Kind, job.Attempts, job.MaxAttempts, retryAt.Format(time.RFC3339), err)
+	return s.db.FailJob(ctx, job.ID, worker, err.Error(), &retryAt)
+}
+
+// * Fail fails job for good, whatever attempts it has left
+func (s *JobService) Fail(ctx context.Context, job *models.Job, worker string, err error) error {
+	logger.Error("job %d (%s) failed for good after %d attempts: %v", job.ID, job.Kind, job.Attempts, err)
+	if ferr := s.db.FailJob(ctx, job.ID, worker, err.Error(), nil); ferr != nil {
+		return ferr
+	}
+	s.failed(ctx, job, err)
+	return nil
+}
+
+// * failed runs the failure hook of job's kind, if any
+func (s *JobService) failed(ctx context.Context, job *models.Job, err error) {
+	if hook, ok := s.onFailure[job.Kind]; ok {
+		hook(ctx, job, err)
+	}
+}
+
+// * Release hands job back to the queue at once, for a worker that is
+// * shutting down mid-run
+func (s *JobService) Release(ctx context.Context, job *models.Job, worker string) error {
+	now := time.Now()
+	return s.db.FailJob(ctx, job.ID, worker, "interrupted by shutdown", &now)
+}
+
+// * backoff doubles retryDelay with every failed attempt, up to maxJobBackoff
+func (s *JobService) backoff(attempts int) time.Duration {
+	delay := s.retryDelay
+	for i := 1; i < attempts && delay < maxJobBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxJobBackoff)
+}
+
+// * ListJobs returns jobs matching filter, newest first
+func (s *JobService) ListJobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	return s.db.GetJobs(ctx, filter)
+}
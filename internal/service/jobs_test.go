@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobService_Backoff(t *testing.T) {
+	s := NewJobService(db.NewMemoryDB(), 30*time.Second)
+
+	assert.Equal(t, 30*time.Second, s.backoff(1))
+	assert.Equal(t, time.Minute, s.backoff(2))
+	assert.Equal(t, 4*time.Minute, s.backoff(4))
+	assert.Equal(t, time.Hour, s.backoff(10))
+	assert.Equal(t, time.Hour, s.backoff(1000))
+}
+
+func TestJobService_RetriesThenFails(t *testing.T) {
+	store := db.NewMemoryDB()
+	s := NewJobService(store, 0)
+	ctx := context.Background()
+
+	job := &models.Job{Kind: models.JobSync, DedupeKey: "sync:owner/repo", MaxAttempts: 2}
+	ok, err := s.Enqueue(ctx, job, map[string]string{"owner": "owner"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.JSONEq(t, `{"owner":"owner"}`, string(job.Payload))
+
+	claimed, err := s.Claim(ctx, "w1", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	require.NoError(t, s.Finish(ctx, claimed, "w1", assert.AnError))
+
+	jobs, err := s.ListJobs(ctx, models.JobFilter{})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, models.JobQueued, jobs[0].Status)
+	assert.Equal(t, assert.AnError.Error(), jobs[0].LastError)
+
+	// * The second failure uses up the attempts
+	claimed, err = s.Claim(ctx, "w1", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, 2, claimed.Attempts)
+	require.NoError(t, s.Finish(ctx, claimed, "w1", assert.AnError))
+
+	jobs, err = s.ListJobs(ctx, models.JobFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, models.JobFailed, jobs[0].Status)
+
+	claimed, err = s.Claim(ctx, "w1", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, claimed)
+}
+
+func TestJobService_CompletesAndDefaults(t *testing.T) {
+	store := db.NewMemoryDB()
+	s := NewJobService(store, time.Minute)
+	ctx := context.Background()
+
+	job := &models.Job{Kind: models.JobResolveAuthors}
+	_, err := s.Enqueue(ctx, job, struct{}{})
+	require.NoError(t, err)
+	assert.Equal(t, defaultJobAttempts, job.MaxAttempts)
+	assert.False(t, job.RunAt.IsZero())
+
+	claimed, err := s.Claim(ctx, "w1", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	require.NoError(t, s.Finish(ctx, claimed, "w1", nil))
+
+	jobs, err := s.ListJobs(ctx, models.JobFilter{Status: models.JobSucceeded})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.NotNil(t, jobs[0].FinishedAt)
+}
+
+func TestJobService_ClaimFailsAbandonedLastAttempt(t *testing.T) {
+	store := db.NewMemoryDB()
+	s := NewJobService(store, time.Minute)
+	ctx := context.Background()
+
+	_, err := s.Enqueue(ctx, &models.Job{Kind: models.JobReset, MaxAttempts: 1}, struct{}{})
+	require.NoError(t, err)
+
+	// * w1 claims with a lease that has already run out, as if it died
+	claimed, err := s.Claim(ctx, "w1", -time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+
+	claimed, err = s.Claim(ctx, "w2", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, claimed)
+
+	jobs, err := s.ListJobs(ctx, models.JobFilter{Status: models.JobFailed})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Contains(t, jobs[0].LastError, "abandoned")
+
+	// * A worker shutting down hands its job straight back
+	_, err = s.Enqueue(ctx, &models.Job{Kind: models.JobSync}, struct{}{})
+	require.NoError(t, err)
+	claimed, err = s.Claim(ctx, "w3", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	require.NoError(t, s.Release(ctx, claimed, "w3"))
+
+	claimed, err = s.Claim(ctx, "w4", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, "interrupted by shutdown", claimed.LastError)
+}
@@ -1,19 +1,24 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/KOFI-GYIMAH/github-monitor/internal/github"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/search"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
 	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
 )
 
 type GitHubClientInterface interface {
 	GetRepository(ctx context.Context, owner, name string) (*github.Repository, error)
 	ListCommits(ctx context.Context, owner, name string, opts github.CommitListOptions) ([]*github.Commit, error)
+	GetCommit(ctx context.Context, owner, name, ref string) (*github.Commit, error)
 }
 
 type RepositoryService struct {
@@ -32,90 +37,338 @@ func (s *RepositoryService) GetRepository(ctx context.Context, name string) (*mo
 	return s.db.GetRepository(ctx, name)
 }
 
-func (s *RepositoryService) SyncRepository(ctx context.Context, owner, name string, since time.Time) error {
-	logger.Info("Syncing repository... %s", name)
+// * SyncRepository fetches owner/name from GitHub and stores what changed
+// * since, recording the attempt in the sync history under trigger
+func (s *RepositoryService) SyncRepository(ctx context.Context, trigger models.SyncTrigger, owner, name string, since time.Time) error {
+	return s.withRepositoryLock(ctx, owner+"/"+name, func() error {
+		_, err := s.sync(ctx, trigger, owner, name, since, true)
+		return err
+	})
+}
+
+// * RefreshRepository is SyncRepository for a repository already monitored:
+// * it only updates the stored repository and fails with
+// * DB_REPOSITORY_INACTIVE once it is archived, deleted or purged, so a sync
+// * queued before a purge cannot bring the repository back
+func (s *RepositoryService) RefreshRepository(ctx context.Context, trigger models.SyncTrigger, owner, name string, since time.Time) error {
+	return s.withRepositoryLock(ctx, owner+"/"+name, func() error {
+		_, err := s.sync(ctx, trigger, owner, name, since, false)
+		return err
+	})
+}
 
-	return s.db.WithTransaction(ctx, func(tx *sql.Tx) error {
-		repo, err := s.githubClient.GetRepository(ctx, owner, name)
-		if err != nil {
-			return err
+// * withRepositoryLock runs fn holding the sync lock of repoName, so no two
+// * replicas sync or reset one repository at once. It fails with
+// * SYNC_IN_PROGRESS, without running fn, while another holds the lock.
+func (s *RepositoryService) withRepositoryLock(ctx context.Context, repoName string, fn func() error) error {
+	lock, err := s.db.TryLock(ctx, "sync:"+repoName)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return errors.New(
+			"SYNC_IN_PROGRESS",
+			"Sync in progress",
+			fmt.Sprintf("Repository '%s' is being synced or reset elsewhere; try again later", repoName),
+			nil,
+			errors.LevelWarning,
+		)
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			logger.Error("failed to release the sync lock of %s: %v", repoName, err)
 		}
+	}()
+	return fn()
+}
 
-		logger.Info("Successfully fetched repository %s", repo.FullName)
-
-		// * Save repository metadata
-		dbRepo := models.Repository{
-			Name:            repo.FullName,
-			Description:     repo.Description,
-			URL:             repo.HTMLURL,
-			Language:        repo.Language,
-			ForksCount:      repo.ForksCount,
-			StarsCount:      repo.StargazersCount,
-			OpenIssuesCount: repo.OpenIssuesCount,
-			WatchersCount:   repo.WatchersCount,
-			CreatedAt:       repo.CreatedAt,
-			UpdatedAt:       repo.UpdatedAt,
+// * sync is SyncRepository, or RefreshRepository unless create is set,
+// * returning the recorded run, for callers that already hold the
+// * repository's sync lock
+func (s *RepositoryService) sync(ctx context.Context, trigger models.SyncTrigger, owner, name string, since time.Time, create bool) (*models.SyncRun, error) {
+	return s.tracked(ctx, trigger, owner, name, since, func(ctx context.Context, run *models.SyncRun) error {
+		return s.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+			return s.syncTx(ctx, tx, run, owner, name, since, create)
+		})
+	})
+}
+
+// * AddRepository starts monitoring owner/name with a full sync, recorded in
+// * the audit log
+func (s *RepositoryService) AddRepository(ctx context.Context, owner, name string) error {
+	entry := newAuditEntry(ctx, models.AuditRepositoryAdd, owner+"/"+name, nil)
+	return s.withRepositoryLock(ctx, owner+"/"+name, func() error {
+		_, err := s.tracked(ctx, models.SyncTriggerAPI, owner, name, time.Time{}, func(ctx context.Context, run *models.SyncRun) error {
+			return audited(ctx, s.db, entry, func(tx *sql.Tx) error {
+				return s.syncTx(ctx, tx, run, owner, name, time.Time{}, true)
+			})
+		})
+		return err
+	})
+}
+
+// * MonitorRepository syncs owner/name from since on request, recorded in
+// * the audit log
+func (s *RepositoryService) MonitorRepository(ctx context.Context, owner, name string, since time.Time) error {
+	entry := newAuditEntry(ctx, models.AuditRepositoryMonitor, owner+"/"+name, map[string]time.Time{"since": since})
+	return s.withRepositoryLock(ctx, owner+"/"+name, func() error {
+		_, err := s.tracked(ctx, models.SyncTriggerAPI, owner, name, since, func(ctx context.Context, run *models.SyncRun) error {
+			return audited(ctx, s.db, entry, func(tx *sql.Tx) error {
+				return s.syncTx(ctx, tx, run, owner, name, since, true)
+			})
+		})
+		return err
+	})
+}
+
+// * tracked runs sync and records it as a sync run, with the GitHub calls it
+// * made counted through its context. The history is best effort: failing to
+// * record a run is logged and never fails the sync itself.
+func (s *RepositoryService) tracked(ctx context.Context, trigger models.SyncTrigger, owner, name string, since time.Time,
+	sync func(ctx context.Context, run *models.SyncRun) error) (*models.SyncRun, error) {
+	run := &models.SyncRun{
+		RepositoryName: owner + "/" + name,
+		Trigger:        trigger,
+		StartedAt:      time.Now(),
+	}
+	if !since.IsZero() {
+		run.Since = &since
+	}
+
+	started := true
+	if err := s.db.StartSyncRun(ctx, run); err != nil {
+		logger.Error("failed to record start of sync of %s: %v", run.RepositoryName, err)
+		started = false
+	}
+
+	syncCtx, stats := github.WithCallStats(ctx)
+	err := sync(syncCtx, run)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.APICalls = int(stats.APICalls.Load())
+	run.PagesFetched = int(stats.Pages.Load())
+	run.Status = models.SyncSuccess
+	if err != nil {
+		// * The transaction rolled back, so nothing it inserted was kept
+		run.CommitsInserted = 0
+		run.Status = models.SyncFailure
+		run.ErrorReference = cmp.Or(errors.Reference(err), "UNEXPECTED_ERROR")
+		run.ErrorMessage = err.Error()
+	}
+
+	if started {
+		// * A cancelled sync must still be recorded as finished
+		if ferr := s.db.FinishSyncRun(context.WithoutCancel(ctx), run); ferr != nil {
+			logger.Error("failed to record end of sync of %s: %v", run.RepositoryName, ferr)
 		}
+	}
+	return run, err
+}
 
+func (s *RepositoryService) syncTx(ctx context.Context, tx *sql.Tx, run *models.SyncRun, owner, name string, since time.Time, create bool) error {
+	logger.Info("Syncing repository... %s", name)
+
+	repo, err := s.githubClient.GetRepository(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Successfully fetched repository %s", repo.FullName)
+
+	// * Save repository metadata
+	dbRepo := models.Repository{
+		Name:            repo.FullName,
+		Description:     repo.Description,
+		URL:             repo.HTMLURL,
+		Language:        repo.Language,
+		ForksCount:      repo.ForksCount,
+		StarsCount:      repo.StargazersCount,
+		OpenIssuesCount: repo.OpenIssuesCount,
+		WatchersCount:   repo.WatchersCount,
+		CreatedAt:       repo.CreatedAt,
+		UpdatedAt:       repo.UpdatedAt,
+	}
+
+	if create {
 		err = s.db.UpsertRepositoryTx(ctx, tx, &dbRepo)
-		if err != nil {
-			return err
-		}
+	} else {
+		err = s.db.RefreshRepositoryTx(ctx, tx, &dbRepo)
+	}
+	if err != nil {
+		return err
+	}
 
-		logger.Info("Successfully saved repository %s", repo.FullName)
+	logger.Info("Successfully saved repository %s", repo.FullName)
 
-		// * Get commits since last sync
-		commitOpts := github.CommitListOptions{Since: since}
-		commits, err := s.githubClient.ListCommits(ctx, owner, name, commitOpts)
-		if err != nil {
-			return fmt.Errorf("failed to list commits for %s: %w", repo.FullName, err)
-		}
+	// * Get commits since last sync
+	commitOpts := github.CommitListOptions{Since: since}
+	commits, err := s.githubClient.ListCommits(ctx, owner, name, commitOpts)
+	if err != nil {
+		return fmt.Errorf("failed to list commits for %s: %w", repo.FullName, err)
+	}
 
-		logger.Info("Successfully fetched %d commits for %s", len(commits), repo.FullName)
+	logger.Info("Successfully fetched %d commits for %s", len(commits), repo.FullName)
+	run.CommitsSeen = len(commits)
 
-		// * Save commits
-		for _, commit := range commits {
-			author := commit.Author
+	// * Save commits
+	dbCommits := make([]models.Commit, 0, len(commits))
+	for _, commit := range commits {
+		author := commit.Author
 
-			dbCommit := models.Commit{
-				SHA:          commit.SHA,
-				RepositoryID: dbRepo.ID,
-				Message:      commit.Commit.Message,
-				AuthorName:   author.Login,
-				AuthorEmail:  commit.Commit.Author.Email,
-				AuthorDate:   commit.Commit.Author.Date,
-				CommitURL:    commit.HTMLURL,
-			}
+		dbCommits = append(dbCommits, models.Commit{
+			SHA:            commit.SHA,
+			RepositoryID:   dbRepo.ID,
+			Message:        commit.Commit.Message,
+			AuthorName:     cmp.Or(commit.Commit.Author.Name, author.Login),
+			AuthorEmail:    commit.Commit.Author.Email,
+			AuthorLogin:    author.Login,
+			AuthorGitHubID: author.ID,
+			AuthorType:     author.Type,
+			AuthorDate:     commit.Commit.Author.Date,
+			CommitURL:      commit.HTMLURL,
+		})
+	}
 
-			err = s.db.InsertCommitTx(ctx, tx, &dbCommit)
-			if err != nil {
-				return fmt.Errorf("failed to insert commit for %s: %w", repo.FullName, err)
-			}
+	dbCommits, err = s.withinRetention(ctx, repo.FullName, dbCommits)
+	if err != nil {
+		return err
+	}
 
-			logger.Info("Successfully saved commit %s for %s", commit.SHA, repo.FullName)
-		}
+	result, err := s.db.InsertCommitsTx(ctx, tx, dbCommits)
+	if err != nil {
+		return fmt.Errorf("failed to insert commits for %s: %w", repo.FullName, err)
+	}
+	run.CommitsInserted = result.Inserted
 
-		logger.Info("Successfully synced repository %s with %d commits", repo.FullName, len(commits))
+	logger.Info("Successfully synced repository %s with %d commits (%d new, %d already stored)",
+		repo.FullName, len(commits), result.Inserted, result.Skipped)
 
-		// * Update last sync time
-		now := time.Now()
-		dbRepo.LastCommitFetchedAt = &now
-		return s.db.UpdateRepositoryTx(ctx, tx, &dbRepo)
-	})
+	// * Update last sync time
+	now := time.Now()
+	dbRepo.LastCommitFetchedAt = &now
+	return s.db.UpdateRepositoryTx(ctx, tx, &dbRepo)
+}
+
+// * withinRetention leaves out the commits the retention policy of repoName
+// * has expired by age, so a full sync after a prune does not bring them
+// * back until the next one
+func (s *RepositoryService) withinRetention(ctx context.Context, repoName string, commits []models.Commit) ([]models.Commit, error) {
+	policy, err := s.db.GetRetentionPolicy(ctx, repoName)
+	if errors.Reference(err) == "DB_RETENTION_NOT_FOUND" {
+		return commits, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := policy.Cutoff(time.Now())
+	if cutoff == nil {
+		return commits, nil
+	}
+	return slices.DeleteFunc(commits, func(c models.Commit) bool {
+		return c.AuthorDate.Before(*cutoff)
+	}), nil
+}
+
+// * ListSyncRuns returns the sync history matching filter, newest first
+func (s *RepositoryService) ListSyncRuns(ctx context.Context, filter models.SyncRunFilter) ([]models.SyncRun, error) {
+	return s.db.GetSyncRuns(ctx, filter)
 }
 
 func (s *RepositoryService) ListAllRepositories(ctx context.Context) ([]*models.Repository, error) {
 	return s.db.GetAllRepositories(ctx)
 }
 
-func (s *RepositoryService) GetTopAuthors(ctx context.Context, repoName string, limit int) ([]models.AuthorCommitCount, error) {
-	return s.db.GetTopAuthors(ctx, repoName, limit)
+// * GetTopAuthors ranks the authors of repoName by commits, leaving out
+// * bots unless includeBots is set
+func (s *RepositoryService) GetTopAuthors(ctx context.Context, repoName string, limit int, includeBots bool) ([]models.AuthorCommitCount, error) {
+	return s.db.GetTopAuthors(ctx, repoName, limit, includeBots)
+}
+
+func (s *RepositoryService) GetCommits(ctx context.Context, repoName string, filter models.CommitFilter) (*models.CommitPage, error) {
+	return s.db.GetCommits(ctx, repoName, filter)
 }
 
-func (s *RepositoryService) GetCommits(ctx context.Context, repoName string, since, until *time.Time) ([]models.Commit, error) {
-	return s.db.GetCommits(ctx, repoName, since, until)
+// * SearchCommits runs a full-text search over commit messages. repos limits
+// * the search to those full names; an empty list searches every repository.
+// * Commits by bots are only searched with includeBots.
+func (s *RepositoryService) SearchCommits(ctx context.Context, rawQuery string, repos []string, limit int, includeBots bool) ([]models.CommitSearchResult, error) {
+	q, err := search.Parse(rawQuery)
+	if err != nil {
+		return nil, errors.New(
+			"INVALID_SEARCH_QUERY",
+			"Invalid search query",
+			err.Error(),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	return s.db.SearchCommits(ctx, models.CommitSearch{
+		Query:        q,
+		Repositories: repos,
+		Limit:        limit,
+		ExcludeBots:  !includeBots,
+	})
+}
+
+// * ResetRepository deletes the commits of repoName authored at or after
+// * since and moves its sync position back to since, recorded in the audit
+// * log. It returns the number of commits deleted; StartReset also syncs
+// * them again.
+func (s *RepositoryService) ResetRepository(ctx context.Context, repoName string, since time.Time) (int, error) {
+	var deleted int
+	entry := newAuditEntry(ctx, models.AuditRepositoryReset, repoName, map[string]time.Time{"since": since})
+	err := audited(ctx, s.db, entry, func(tx *sql.Tx) error {
+		var err error
+		deleted, err = s.db.ResetRepositoryTx(ctx, tx, repoName, since)
+		return err
+	})
+	return deleted, err
+}
+
+// * RebuildDailyStats recomputes the daily commit statistics of repoName, or
+// * of every repository when it is empty, and returns the number of buckets
+// * written
+func (s *RepositoryService) RebuildDailyStats(ctx context.Context, repoName string) (int, error) {
+	n, err := s.db.RebuildDailyStats(ctx, repoName)
+	recordAudit(ctx, s.db, newAuditEntry(ctx, models.AuditStatsRebuild, repoName, nil), err)
+	return n, err
+}
+
+// * setStatus changes the status of repoName and audits it as action
+func (s *RepositoryService) setStatus(ctx context.Context, action, repoName string, status models.RepositoryStatus) (*models.Repository, error) {
+	var repo *models.Repository
+	err := audited(ctx, s.db, newAuditEntry(ctx, action, repoName, nil), func(tx *sql.Tx) error {
+		var err error
+		repo, err = s.db.SetRepositoryStatusTx(ctx, tx, repoName, status)
+		return err
+	})
+	return repo, err
+}
+
+// * ArchiveRepository stops syncing a repository but keeps it listed and
+// * its commits readable
+func (s *RepositoryService) ArchiveRepository(ctx context.Context, repoName string) (*models.Repository, error) {
+	return s.setStatus(ctx, models.AuditRepositoryArchive, repoName, models.RepositoryArchived)
+}
+
+// * DeleteRepository soft-deletes a repository: it stops syncing and is
+// * left out of listings and search until restored
+func (s *RepositoryService) DeleteRepository(ctx context.Context, repoName string) (*models.Repository, error) {
+	return s.setStatus(ctx, models.AuditRepositoryDelete, repoName, models.RepositoryDeleted)
+}
+
+// * PurgeRepository removes a repository with its commits and retention
+// * policy for good
+func (s *RepositoryService) PurgeRepository(ctx context.Context, repoName string) error {
+	return audited(ctx, s.db, newAuditEntry(ctx, models.AuditRepositoryPurge, repoName, nil), func(tx *sql.Tx) error {
+		return s.db.DeleteRepositoryTx(ctx, tx, repoName)
+	})
 }
 
-func (s *RepositoryService) ResetRepository(ctx context.Context, repoName string, since time.Time) error {
-	return s.db.ResetRepository(ctx, repoName, since)
+// * RestoreRepository makes an archived or deleted repository active again
+func (s *RepositoryService) RestoreRepository(ctx context.Context, repoName string) (*models.Repository, error) {
+	return s.setStatus(ctx, models.AuditRepositoryRestore, repoName, models.RepositoryActive)
 }
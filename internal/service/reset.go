@@ -0,0 +1,198 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * resetJobAttempts bounds how often a reset is restarted after its worker
+// * died part way
+const resetJobAttempts = 3
+
+// * resetPayload is the job queue payload of a reset
+type resetPayload struct {
+	ResetJobID int `json:"reset_job_id"`
+}
+
+// * StartReset records a reset job for repoName and queues it on the job
+// * queue: commits authored at or after since are deleted, then synced again
+// * from GitHub. A zero since resets the whole history. The returned job is
+// * the queued state; poll GetResetJob for progress.
+func (s *RepositoryService) StartReset(ctx context.Context, repoName string, since time.Time) (*models.ResetJob, error) {
+	owner, name, ok := strings.Cut(repoName, "/")
+	if !ok || owner == "" || name == "" {
+		return nil, errors.New(
+			"INVALID_REPOSITORY",
+			"Invalid repository name",
+			fmt.Sprintf("'%s' is not an owner/name repository name", repoName),
+			nil,
+			errors.LevelError,
+		)
+	}
+
+	repo, err := s.db.GetRepository(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	if repo.Status != models.RepositoryActive {
+		return nil, errors.New(
+			"REPOSITORY_NOT_ACTIVE",
+			"Repository is not active",
+			fmt.Sprintf("Repository '%s' is %s; restore it before resetting it", repoName, repo.Status),
+			nil,
+			errors.LevelWarning,
+		)
+	}
+
+	job := &models.ResetJob{
+		RepositoryName: repoName,
+		Actor:          models.ActorFromContext(ctx),
+		Status:         models.ResetQueued,
+	}
+	if !since.IsZero() {
+		job.Since = &since
+	}
+	if err := s.db.CreateResetJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	// * The reset jumps the queue ahead of scheduled syncs; runReset records
+	// * failures on the reset job itself, so attempts only cover crashes
+	_, err = enqueueJob(ctx, s.db, &models.Job{
+		Kind:        models.JobReset,
+		Priority:    JobPriorityHigh,
+		MaxAttempts: resetJobAttempts,
+	}, resetPayload{ResetJobID: job.ID})
+	if err != nil {
+		finishedAt := time.Now()
+		job.FinishedAt = &finishedAt
+		job.Status = models.ResetFailed
+		job.ErrorReference = cmp.Or(errors.Reference(err), "UNEXPECTED_ERROR")
+		job.ErrorMessage = err.Error()
+		s.saveResetJob(ctx, job)
+		return nil, err
+	}
+	return job, nil
+}
+
+// * FailResetJob marks the reset job named by queued failed once the queue
+// * job running it failed for good, so pollers see it end. It is registered
+// * as the JobService failure hook of resets.
+func (s *RepositoryService) FailResetJob(ctx context.Context, queued *models.Job, cause error) {
+	var payload resetPayload
+	if err := json.Unmarshal(queued.Payload, &payload); err != nil {
+		logger.Error("job %d does not name a reset job: %v", queued.ID, err)
+		return
+	}
+
+	job, err := s.db.GetResetJob(ctx, payload.ResetJobID)
+	if err != nil {
+		logger.Error("failed to load reset job %d: %v", payload.ResetJobID, err)
+		return
+	}
+	if job.Status.Done() {
+		return
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.Status = models.ResetFailed
+	job.ErrorReference = cmp.Or(errors.Reference(cause), "UNEXPECTED_ERROR")
+	job.ErrorMessage = cause.Error()
+	s.saveResetJob(ctx, job)
+}
+
+// * RunResetJob runs the reset job named by a queued reset job, holding the
+// * repository's sync lock throughout. It is registered with the job runner
+// * and carries on from scratch when a worker died mid-reset; a reset that
+// * already finished is left alone.
+func (s *RepositoryService) RunResetJob(ctx context.Context, queued *models.Job) error {
+	var payload resetPayload
+	if err := json.Unmarshal(queued.Payload, &payload); err != nil {
+		return errors.New(
+			"INVALID_JOB_PAYLOAD",
+			"Invalid job payload",
+			fmt.Sprintf("Job %d does not name a reset job", queued.ID),
+			err,
+			errors.LevelError,
+		)
+	}
+
+	job, err := s.db.GetResetJob(ctx, payload.ResetJobID)
+	if err != nil {
+		return err
+	}
+	if job.Status.Done() {
+		return nil
+	}
+
+	var since time.Time
+	if job.Since != nil {
+		since = *job.Since
+	}
+	owner, name, _ := strings.Cut(job.RepositoryName, "/")
+
+	// * The reset is audited under whoever started it. While another replica
+	// * syncs the repository the job is queued again without using an attempt.
+	return s.withRepositoryLock(ctx, job.RepositoryName, func() error {
+		s.runReset(models.WithActor(ctx, job.Actor), owner, name, since, job)
+		return nil
+	})
+}
+
+func (s *RepositoryService) GetResetJob(ctx context.Context, id int) (*models.ResetJob, error) {
+	return s.db.GetResetJob(ctx, id)
+}
+
+// * runReset moves job through its phases, saving each so pollers see
+// * progress
+func (s *RepositoryService) runReset(ctx context.Context, owner, name string, since time.Time, job *models.ResetJob) {
+	startedAt := time.Now()
+	job.StartedAt = &startedAt
+	job.Status = models.ResetDeleting
+	s.saveResetJob(ctx, job)
+
+	deleted, err := s.ResetRepository(ctx, job.RepositoryName, since)
+	if err == nil {
+		job.CommitsDeleted = deleted
+		job.Status = models.ResetSyncing
+		s.saveResetJob(ctx, job)
+
+		var run *models.SyncRun
+		run, err = s.sync(ctx, models.SyncTriggerAPI, owner, name, since, false)
+		job.CommitsInserted = run.CommitsInserted
+		if run.ID != 0 {
+			job.SyncRunID = &run.ID
+		}
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.Status = models.ResetSucceeded
+	if err != nil {
+		job.Status = models.ResetFailed
+		job.ErrorReference = cmp.Or(errors.Reference(err), "UNEXPECTED_ERROR")
+		job.ErrorMessage = err.Error()
+		logger.Error("reset job %d of %s failed: %v", job.ID, job.RepositoryName, err)
+	} else {
+		logger.Info("reset job %d of %s deleted %d commits and synced %d back",
+			job.ID, job.RepositoryName, job.CommitsDeleted, job.CommitsInserted)
+	}
+	s.saveResetJob(ctx, job)
+}
+
+// * saveResetJob stores job progress; a failed write is logged so the reset
+// * itself carries on
+func (s *RepositoryService) saveResetJob(ctx context.Context, job *models.ResetJob) {
+	if err := s.db.UpdateResetJob(ctx, job); err != nil {
+		logger.Error("failed to record progress of reset job %d: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/github"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// * runReset runs the queued reset job the way the job runner would and
+// * returns the finished reset
+func runReset(t *testing.T, store models.Database, service *RepositoryService, id int) *models.ResetJob {
+	t.Helper()
+	ctx := context.Background()
+	jobs := NewJobService(store, time.Second)
+
+	queued, err := jobs.Claim(ctx, "test", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, queued)
+	assert.Equal(t, models.JobReset, queued.Kind)
+	assert.Equal(t, JobPriorityHigh, queued.Priority)
+	require.NoError(t, jobs.Finish(ctx, queued, "test", service.RunResetJob(ctx, queued)))
+
+	job, err := service.GetResetJob(ctx, id)
+	require.NoError(t, err)
+	require.True(t, job.Status.Done())
+	return job
+}
+
+func TestStartReset_MemoryDB(t *testing.T) {
+	now := time.Now().UTC()
+	since := now.Add(-90 * time.Minute)
+
+	mockGitHubClient := new(MockGitHubClient)
+	store := db.NewMemoryDB()
+	service := NewRepositoryService(mockGitHubClient, store)
+	ctx := models.WithActor(context.Background(), "alice")
+
+	mockGitHubClient.On("GetRepository", mock.Anything, "owner", "repo").Return(&github.Repository{FullName: "owner/repo"}, nil)
+	mockGitHubClient.On("ListCommits", mock.Anything, "owner", "repo", github.CommitListOptions{}).Return([]*github.Commit{
+		newGitHubCommit("abc", "alice", now.Add(-2*time.Hour)),
+		newGitHubCommit("def", "bob", now.Add(-time.Hour)),
+		newGitHubCommit("ghi", "bob", now.Add(-time.Minute)),
+	}, nil).Once()
+	require.NoError(t, service.SyncRepository(ctx, models.SyncTriggerWorker, "owner", "repo", time.Time{}))
+
+	// * The re-sync finds one of the two deleted commits rewritten away
+	mockGitHubClient.On("ListCommits", mock.Anything, "owner", "repo", github.CommitListOptions{Since: since}).Return([]*github.Commit{
+		newGitHubCommit("def", "bob", now.Add(-time.Hour)),
+	}, nil).Once()
+
+	job, err := service.StartReset(ctx, "owner/repo", since)
+	require.NoError(t, err)
+	assert.Equal(t, models.ResetQueued, job.Status)
+	assert.Equal(t, "alice", job.Actor)
+
+	job = runReset(t, store, service, job.ID)
+	assert.Equal(t, models.ResetSucceeded, job.Status)
+	assert.Equal(t, 2, job.CommitsDeleted)
+	assert.Equal(t, 1, job.CommitsInserted)
+	require.NotNil(t, job.SyncRunID)
+	require.NotNil(t, job.FinishedAt)
+
+	// * The queued reset is audited under whoever started it
+	entries, err := store.GetAuditLog(ctx, models.AuditFilter{Action: models.AuditRepositoryReset})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "alice", entries[0].Actor)
+
+	page, err := service.GetCommits(ctx, "owner/repo", models.CommitFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Commits, 2)
+	assert.Equal(t, "def", page.Commits[0].SHA)
+	assert.Equal(t, "abc", page.Commits[1].SHA)
+
+	runs, err := service.ListSyncRuns(ctx, models.SyncRunFilter{RepositoryName: "owner/repo", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, *job.SyncRunID, runs[0].ID)
+	assert.Equal(t, models.SyncTriggerAPI, runs[0].Trigger)
+
+	// * A failing re-sync fails the job but the deletion stands
+	mockGitHubClient.On("ListCommits", mock.Anything, "owner", "repo", github.CommitListOptions{Since: since}).
+		Return([]*github.Commit(nil), assert.AnError).Once()
+	job, err = service.StartReset(ctx, "owner/repo", since)
+	require.NoError(t, err)
+	job = runReset(t, store, service, job.ID)
+	assert.Equal(t, models.ResetFailed, job.Status)
+	assert.Equal(t, 1, job.CommitsDeleted)
+	assert.Equal(t, "UNEXPECTED_ERROR", job.ErrorReference)
+
+	_, err = service.StartReset(ctx, "missing/repo", since)
+	assert.Error(t, err)
+
+	_, err = service.ArchiveRepository(ctx, "owner/repo")
+	require.NoError(t, err)
+	_, err = service.StartReset(ctx, "owner/repo", since)
+	assert.ErrorContains(t, err, "REPOSITORY_NOT_ACTIVE")
+
+	mockGitHubClient.AssertExpectations(t)
+}
+
+func TestResetJob_LockedElsewhereThenAbandoned(t *testing.T) {
+	mockGitHubClient := new(MockGitHubClient)
+	store := db.NewMemoryDB()
+	service := NewRepositoryService(mockGitHubClient, store)
+	jobs := NewJobService(store, 0)
+	jobs.OnFailure(models.JobReset, service.FailResetJob)
+	ctx := context.Background()
+
+	require.NoError(t, store.UpsertRepository(ctx, &models.Repository{Name: "owner/repo"}))
+	reset, err := service.StartReset(ctx, "owner/repo", time.Time{})
+	require.NoError(t, err)
+
+	// * Another replica syncs the repository: the reset waits without
+	// * using up its attempts
+	lock, err := store.TryLock(ctx, "sync:owner/repo")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	// * Each deferral makes the job due a little later; claim it as if then
+	later := time.Now()
+	for range resetJobAttempts + 1 {
+		later = later.Add(time.Minute)
+		queued, err := store.ClaimJob(ctx, "w1", later, later.Add(time.Minute))
+		require.NoError(t, err)
+		require.NotNil(t, queued)
+		assert.Equal(t, 1, queued.Attempts)
+		require.NoError(t, jobs.Finish(ctx, queued, "w1", service.RunResetJob(ctx, queued)))
+
+		queuedJobs, err := jobs.ListJobs(ctx, models.JobFilter{Kind: models.JobReset})
+		require.NoError(t, err)
+		require.Len(t, queuedJobs, 1)
+		assert.Equal(t, models.JobQueued, queuedJobs[0].Status)
+		assert.Equal(t, 0, queuedJobs[0].Attempts)
+	}
+	require.NoError(t, lock.Release())
+	mockGitHubClient.AssertNotCalled(t, "GetRepository", mock.Anything, "owner", "repo")
+
+	// * Workers die holding the job until it runs out of attempts
+	for range resetJobAttempts {
+		queued, err := store.ClaimJob(ctx, "dead", later.Add(time.Minute), time.Now())
+		require.NoError(t, err)
+		require.NotNil(t, queued)
+	}
+	claimed, err := jobs.Claim(ctx, "w1", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, claimed)
+
+	job, err := service.GetResetJob(ctx, reset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ResetFailed, job.Status)
+	assert.Equal(t, "JOB_ABANDONED", job.ErrorReference)
+	assert.NotNil(t, job.FinishedAt)
+}
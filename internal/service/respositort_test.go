@@ -7,10 +7,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/KOFI-GYIMAH/github-monitor/internal/bots"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/github"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/mailmap"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	pkgerrors "github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockGitHubClient struct {
@@ -27,6 +32,11 @@ func (m *MockGitHubClient) ListCommits(ctx context.Context, owner, name string,
 	return args.Get(0).([]*github.Commit), args.Error(1)
 }
 
+func (m *MockGitHubClient) GetCommit(ctx context.Context, owner, name, ref string) (*github.Commit, error) {
+	args := m.Called(ctx, owner, name, ref)
+	return args.Get(0).(*github.Commit), args.Error(1)
+}
+
 type MockDatabase struct {
 	mock.Mock
 }
@@ -54,9 +64,9 @@ func (m *MockDatabase) UpdateRepository(ctx context.Context, repo *models.Reposi
 	return args.Error(0)
 }
 
-func (m *MockDatabase) ResetRepository(ctx context.Context, repoName string, since time.Time) error {
+func (m *MockDatabase) ResetRepository(ctx context.Context, repoName string, since time.Time) (int, error) {
 	args := m.Called(ctx, repoName, since)
-	return args.Error(0)
+	return args.Int(0), args.Error(1)
 }
 
 func (m *MockDatabase) InsertCommit(ctx context.Context, commit *models.Commit) error {
@@ -64,16 +74,283 @@ func (m *MockDatabase) InsertCommit(ctx context.Context, commit *models.Commit)
 	return args.Error(0)
 }
 
-func (m *MockDatabase) GetCommits(ctx context.Context, repoName string, since, until *time.Time) ([]models.Commit, error) {
-	args := m.Called(ctx, repoName, since, until)
-	return args.Get(0).([]models.Commit), args.Error(1)
+func (m *MockDatabase) GetCommits(ctx context.Context, repoName string, filter models.CommitFilter) (*models.CommitPage, error) {
+	args := m.Called(ctx, repoName, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CommitPage), args.Error(1)
+}
+
+func (m *MockDatabase) GetCommitActivity(ctx context.Context, filter models.ActivityFilter) ([]models.ActivityCount, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]models.ActivityCount), args.Error(1)
 }
 
-func (m *MockDatabase) GetTopAuthors(ctx context.Context, repoName string, limit int) ([]models.AuthorCommitCount, error) {
-	args := m.Called(ctx, repoName, limit)
+func (m *MockDatabase) GetPunchCard(ctx context.Context, filter models.PunchCardFilter) (models.PunchCardCounts, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(models.PunchCardCounts), args.Error(1)
+}
+
+func (m *MockDatabase) FindCommit(ctx context.Context, repoName, shaPrefix string) (*models.Commit, error) {
+	args := m.Called(ctx, repoName, shaPrefix)
+	return args.Get(0).(*models.Commit), args.Error(1)
+}
+
+func (m *MockDatabase) GetChangelogCommits(ctx context.Context, repoName string, filter models.ChangelogFilter) ([]models.ChangelogCommit, error) {
+	args := m.Called(ctx, repoName, filter)
+	return args.Get(0).([]models.ChangelogCommit), args.Error(1)
+}
+
+func (m *MockDatabase) GetTopAuthors(ctx context.Context, repoName string, limit int, includeBots bool) ([]models.AuthorCommitCount, error) {
+	args := m.Called(ctx, repoName, limit, includeBots)
 	return args.Get(0).([]models.AuthorCommitCount), args.Error(1)
 }
 
+func (m *MockDatabase) SearchCommits(ctx context.Context, search models.CommitSearch) ([]models.CommitSearchResult, error) {
+	args := m.Called(ctx, search)
+	return args.Get(0).([]models.CommitSearchResult), args.Error(1)
+}
+
+func (m *MockDatabase) SetRetentionPolicy(ctx context.Context, repoName string, policy models.RetentionPolicy) (*models.RetentionPolicy, error) {
+	args := m.Called(ctx, repoName, policy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RetentionPolicy), args.Error(1)
+}
+
+func (m *MockDatabase) GetRetentionPolicy(ctx context.Context, repoName string) (*models.RetentionPolicy, error) {
+	args := m.Called(ctx, repoName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RetentionPolicy), args.Error(1)
+}
+
+func (m *MockDatabase) GetRetentionPolicies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.RetentionPolicy), args.Error(1)
+}
+
+func (m *MockDatabase) PreviewPrune(ctx context.Context, repoName string, policy models.RetentionPolicy, now time.Time) (*models.PrunePreview, error) {
+	args := m.Called(ctx, repoName, policy, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PrunePreview), args.Error(1)
+}
+
+func (m *MockDatabase) PruneCommits(ctx context.Context, repoName string, policy models.RetentionPolicy, now time.Time, batchSize int) (int, error) {
+	args := m.Called(ctx, repoName, policy, now, batchSize)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockDatabase) SetRepositoryStatus(ctx context.Context, name string, status models.RepositoryStatus) (*models.Repository, error) {
+	args := m.Called(ctx, name, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Repository), args.Error(1)
+}
+
+func (m *MockDatabase) DeleteRepository(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]models.AuditEntry), args.Error(1)
+}
+
+func (m *MockDatabase) CreateResetJob(ctx context.Context, job *models.ResetJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) UpdateResetJob(ctx context.Context, job *models.ResetJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) GetResetJob(ctx context.Context, id int) (*models.ResetJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ResetJob), args.Error(1)
+}
+
+func (m *MockDatabase) EnqueueJob(ctx context.Context, job *models.Job) (bool, error) {
+	args := m.Called(ctx, job)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDatabase) GetBotRules(ctx context.Context) (bots.Rules, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(bots.Rules), args.Error(1)
+}
+
+func (m *MockDatabase) SetBotRules(ctx context.Context, rules bots.Rules) error {
+	return m.Called(ctx, rules).Error(0)
+}
+
+func (m *MockDatabase) TryLock(ctx context.Context, name string) (models.Lock, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(models.Lock), args.Error(1)
+}
+
+type MockLock struct {
+	mock.Mock
+}
+
+func (m *MockLock) Held(ctx context.Context) bool {
+	return m.Called(ctx).Bool(0)
+}
+
+func (m *MockLock) Release() error {
+	return m.Called().Error(0)
+}
+
+func (m *MockDatabase) ClaimJob(ctx context.Context, worker string, now, lockedUntil time.Time) (*models.Job, error) {
+	args := m.Called(ctx, worker, now, lockedUntil)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Job), args.Error(1)
+}
+
+func (m *MockDatabase) ExtendJob(ctx context.Context, id int, worker string, lockedUntil time.Time) error {
+	args := m.Called(ctx, id, worker, lockedUntil)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) CompleteJob(ctx context.Context, id int, worker string) error {
+	args := m.Called(ctx, id, worker)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) FailJob(ctx context.Context, id int, worker, message string, retryAt *time.Time) error {
+	args := m.Called(ctx, id, worker, message, retryAt)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) DeferJob(ctx context.Context, id int, worker string, runAt time.Time) error {
+	args := m.Called(ctx, id, worker, runAt)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) GetJobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]models.Job), args.Error(1)
+}
+
+func (m *MockDatabase) RebuildDailyStats(ctx context.Context, repoName string) (int, error) {
+	args := m.Called(ctx, repoName)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockDatabase) StartSyncRun(ctx context.Context, run *models.SyncRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) FinishSyncRun(ctx context.Context, run *models.SyncRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) GetSyncRuns(ctx context.Context, filter models.SyncRunFilter) ([]models.SyncRun, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]models.SyncRun), args.Error(1)
+}
+
+func (m *MockDatabase) ResetRepositoryTx(ctx context.Context, tx *sql.Tx, repoName string, since time.Time) (int, error) {
+	args := m.Called(ctx, tx, repoName, since)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockDatabase) SetRepositoryStatusTx(ctx context.Context, tx *sql.Tx, name string, status models.RepositoryStatus) (*models.Repository, error) {
+	args := m.Called(ctx, tx, name, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Repository), args.Error(1)
+}
+
+func (m *MockDatabase) DeleteRepositoryTx(ctx context.Context, tx *sql.Tx, name string) error {
+	args := m.Called(ctx, tx, name)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) RecordAuditTx(ctx context.Context, tx *sql.Tx, entry *models.AuditEntry) error {
+	args := m.Called(ctx, tx, entry)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) MaintainPartitions(ctx context.Context, now time.Time) error {
+	args := m.Called(ctx, now)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) DropExpiredPartitions(ctx context.Context, now time.Time) (int, error) {
+	args := m.Called(ctx, now)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockDatabase) GetAuthors(ctx context.Context, limit int) ([]models.Author, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]models.Author), args.Error(1)
+}
+
+func (m *MockDatabase) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Author), args.Error(1)
+}
+
+func (m *MockDatabase) MergeAuthors(ctx context.Context, targetID int, sourceIDs []int) (*models.Author, error) {
+	args := m.Called(ctx, targetID, sourceIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Author), args.Error(1)
+}
+
+func (m *MockDatabase) SplitAuthor(ctx context.Context, authorID int, identityIDs []int, name string) (*models.Author, error) {
+	args := m.Called(ctx, authorID, identityIDs, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Author), args.Error(1)
+}
+
+func (m *MockDatabase) GetMailmap(ctx context.Context) (mailmap.Map, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(mailmap.Map), args.Error(1)
+}
+
+func (m *MockDatabase) SetMailmap(ctx context.Context, mm mailmap.Map) error {
+	args := m.Called(ctx, mm)
+	return args.Error(0)
+}
+
+func (m *MockDatabase) ResolveCommitAuthors(ctx context.Context, batchSize int) (int, error) {
+	args := m.Called(ctx, batchSize)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *MockDatabase) WithTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
 	args := m.Called(ctx, fn)
 	if err := fn(&sql.Tx{}); err != nil {
@@ -87,11 +364,21 @@ func (m *MockDatabase) UpsertRepositoryTx(ctx context.Context, tx *sql.Tx, repo
 	return args.Error(0)
 }
 
+func (m *MockDatabase) RefreshRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
+	args := m.Called(ctx, tx, repo)
+	return args.Error(0)
+}
+
 func (m *MockDatabase) InsertCommitTx(ctx context.Context, tx *sql.Tx, commit *models.Commit) error {
 	args := m.Called(ctx, tx, commit)
 	return args.Error(0)
 }
 
+func (m *MockDatabase) InsertCommitsTx(ctx context.Context, tx *sql.Tx, commits []models.Commit) (models.InsertResult, error) {
+	args := m.Called(ctx, tx, commits)
+	return args.Get(0).(models.InsertResult), args.Error(1)
+}
+
 func (m *MockDatabase) UpdateRepositoryTx(ctx context.Context, tx *sql.Tx, repo *models.Repository) error {
 	args := m.Called(ctx, tx, repo)
 	return args.Error(0)
@@ -194,8 +481,11 @@ func TestSyncRepository(t *testing.T) {
 				},
 			},
 			Author: struct {
+				ID    int64  `json:"id"`
 				Login string `json:"login"`
+				Type  string `json:"type"`
 			}{
+				ID:    7,
 				Login: "testuser",
 			},
 			HTMLURL: "http://github.com/owner/repo/commit/abc123",
@@ -211,6 +501,7 @@ func TestSyncRepository(t *testing.T) {
 		repoError    error
 		mockCommits  []*github.Commit
 		commitsError error
+		locked       bool
 		expectError  bool
 	}{
 		{
@@ -246,6 +537,14 @@ func TestSyncRepository(t *testing.T) {
 			commitsError: errors.New("github error"),
 			expectError:  true,
 		},
+		{
+			name:        "already syncing elsewhere",
+			owner:       "owner",
+			repoName:    "repo",
+			since:       now.Add(-1 * time.Hour),
+			locked:      true,
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -254,8 +553,34 @@ func TestSyncRepository(t *testing.T) {
 			mockDB := new(MockDatabase)
 			service := NewRepositoryService(mockGitHubClient, mockDB)
 
+			if tt.locked {
+				mockDB.On("TryLock", mock.Anything, "sync:owner/repo").Return(nil, nil)
+
+				err := service.SyncRepository(context.Background(), models.SyncTriggerWorker, tt.owner, tt.repoName, tt.since)
+				assert.Equal(t, "SYNC_IN_PROGRESS", pkgerrors.Reference(err))
+
+				mockGitHubClient.AssertExpectations(t)
+				mockDB.AssertExpectations(t)
+				return
+			}
+
+			lock := new(MockLock)
+			lock.On("Release").Return(nil).Once()
+			mockDB.On("TryLock", mock.Anything, "sync:owner/repo").Return(lock, nil)
+
 			mockGitHubClient.On("GetRepository", mock.Anything, tt.owner, tt.repoName).Return(tt.mockRepo, tt.repoError)
 
+			wantStatus := models.SyncSuccess
+			if tt.expectError {
+				wantStatus = models.SyncFailure
+			}
+			mockDB.On("StartSyncRun", mock.Anything, mock.MatchedBy(func(run *models.SyncRun) bool {
+				return run.RepositoryName == "owner/repo" && run.Trigger == models.SyncTriggerWorker
+			})).Return(nil)
+			mockDB.On("FinishSyncRun", mock.Anything, mock.MatchedBy(func(run *models.SyncRun) bool {
+				return run.Status == wantStatus && run.FinishedAt != nil
+			})).Return(nil)
+
 			mockDB.On("WithTransaction", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
 				fn := args.Get(1).(func(*sql.Tx) error)
 				fn(&sql.Tx{})
@@ -267,12 +592,13 @@ func TestSyncRepository(t *testing.T) {
 				mockDB.On("UpsertRepositoryTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Repository")).Return(nil)
 
 				if tt.commitsError == nil && tt.mockCommits != nil {
-					mockDB.On("InsertCommitTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Commit")).Return(nil)
+					mockDB.On("GetRetentionPolicy", mock.Anything, mock.Anything).Return(nil, pkgerrors.New("DB_RETENTION_NOT_FOUND", "Retention policy not found", "", nil, pkgerrors.LevelInfo))
+					mockDB.On("InsertCommitsTx", mock.Anything, mock.Anything, mock.AnythingOfType("[]models.Commit")).Return(models.InsertResult{Inserted: len(tt.mockCommits)}, nil)
 					mockDB.On("UpdateRepositoryTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.Repository")).Return(nil)
 				}
 			}
 
-			err := service.SyncRepository(context.Background(), tt.owner, tt.repoName, tt.since)
+			err := service.SyncRepository(context.Background(), models.SyncTriggerWorker, tt.owner, tt.repoName, tt.since)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -282,6 +608,7 @@ func TestSyncRepository(t *testing.T) {
 
 			mockGitHubClient.AssertExpectations(t)
 			mockDB.AssertExpectations(t)
+			lock.AssertExpectations(t)
 		})
 	}
 }
@@ -373,9 +700,9 @@ func TestGetTopAuthors(t *testing.T) {
 			mockDB := new(MockDatabase)
 			service := NewRepositoryService(mockGitHubClient, mockDB)
 
-			mockDB.On("GetTopAuthors", mock.Anything, tt.repoName, tt.limit).Return(tt.mockAuthors, tt.mockError)
+			mockDB.On("GetTopAuthors", mock.Anything, tt.repoName, tt.limit, false).Return(tt.mockAuthors, tt.mockError)
 
-			authors, err := service.GetTopAuthors(context.Background(), tt.repoName, tt.limit)
+			authors, err := service.GetTopAuthors(context.Background(), tt.repoName, tt.limit, false)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -392,45 +719,107 @@ func TestGetTopAuthors(t *testing.T) {
 
 func TestGetCommits(t *testing.T) {
 	now := time.Now()
-	mockCommits := []models.Commit{
-		{SHA: "abc123", Message: "commit 1"},
-		{SHA: "def456", Message: "commit 2"},
+	mockPage := &models.CommitPage{
+		Commits: []models.Commit{
+			{SHA: "abc123", Message: "commit 1"},
+			{SHA: "def456", Message: "commit 2"},
+		},
+		NextCursor: &models.CommitCursor{AuthorDate: now, ID: 2},
 	}
 
 	tests := []struct {
 		name        string
 		repoName    string
-		since       *time.Time
-		until       *time.Time
-		mockCommits []models.Commit
+		filter      models.CommitFilter
+		mockPage    *models.CommitPage
 		mockError   error
 		expectError bool
 	}{
 		{
 			name:        "success with time range",
 			repoName:    "owner/repo",
-			since:       &now,
-			until:       &now,
-			mockCommits: mockCommits,
+			filter:      models.CommitFilter{Since: &now, Until: &now},
+			mockPage:    mockPage,
 			mockError:   nil,
 			expectError: false,
 		},
 		{
-			name:        "success without time range",
+			name:        "success with cursor",
 			repoName:    "owner/repo",
-			since:       nil,
-			until:       nil,
-			mockCommits: mockCommits,
+			filter:      models.CommitFilter{Limit: 2, After: &models.CommitCursor{AuthorDate: now, ID: 5}},
+			mockPage:    mockPage,
 			mockError:   nil,
 			expectError: false,
 		},
 		{
 			name:        "database error",
 			repoName:    "owner/repo",
-			since:       nil,
-			until:       nil,
-			mockCommits: nil,
+			filter:      models.CommitFilter{},
+			mockPage:    nil,
+			mockError:   errors.New("db error"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGitHubClient := new(MockGitHubClient)
+			mockDB := new(MockDatabase)
+			service := NewRepositoryService(mockGitHubClient, mockDB)
+
+			mockDB.On("GetCommits", mock.Anything, tt.repoName, tt.filter).Return(tt.mockPage, tt.mockError)
+
+			page, err := service.GetCommits(context.Background(), tt.repoName, tt.filter)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Nil(t, page)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.mockPage, page)
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSearchCommits(t *testing.T) {
+	mockResults := []models.CommitSearchResult{
+		{Commit: models.Commit{SHA: "abc123"}, RepositoryName: "owner/repo", Snippet: "<mark>fix</mark>"},
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		repos       []string
+		includeBots bool
+		mockError   error
+		expectDB    bool
+		expectError bool
+	}{
+		{
+			name:        "single repository",
+			query:       "fix*",
+			repos:       []string{"owner/repo"},
+			includeBots: true,
+			expectDB:    true,
+		},
+		{
+			name:     "all repositories",
+			query:    `"use after free"`,
+			expectDB: true,
+		},
+		{
+			name:        "invalid query never reaches the database",
+			query:       `"unterminated`,
+			expectError: true,
+		},
+		{
+			name:        "database error",
+			query:       "fix",
 			mockError:   errors.New("db error"),
+			expectDB:    true,
 			expectError: true,
 		},
 	}
@@ -441,16 +830,20 @@ func TestGetCommits(t *testing.T) {
 			mockDB := new(MockDatabase)
 			service := NewRepositoryService(mockGitHubClient, mockDB)
 
-			mockDB.On("GetCommits", mock.Anything, tt.repoName, tt.since, tt.until).Return(tt.mockCommits, tt.mockError)
+			if tt.expectDB {
+				mockDB.On("SearchCommits", mock.Anything, mock.MatchedBy(func(s models.CommitSearch) bool {
+					return assert.ObjectsAreEqual(tt.repos, s.Repositories) && s.Limit == 10 && len(s.Query.Terms) > 0 &&
+						s.ExcludeBots == !tt.includeBots
+				})).Return(mockResults, tt.mockError)
+			}
 
-			commits, err := service.GetCommits(context.Background(), tt.repoName, tt.since, tt.until)
+			results, err := service.SearchCommits(context.Background(), tt.query, tt.repos, 10, tt.includeBots)
 
 			if tt.expectError {
 				assert.Error(t, err)
-				assert.Nil(t, commits)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.mockCommits, commits)
+				assert.Equal(t, mockResults, results)
 			}
 
 			mockDB.AssertExpectations(t)
@@ -490,17 +883,223 @@ func TestResetRepository(t *testing.T) {
 			mockDB := new(MockDatabase)
 			service := NewRepositoryService(mockGitHubClient, mockDB)
 
-			mockDB.On("ResetRepository", mock.Anything, tt.repoName, tt.since).Return(tt.mockError)
+			mockDB.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+			mockDB.On("ResetRepositoryTx", mock.Anything, mock.Anything, tt.repoName, tt.since).Return(3, tt.mockError)
+			if tt.expectError {
+				mockDB.On("RecordAudit", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+					return e.Action == models.AuditRepositoryReset && e.Outcome == models.AuditFailure
+				})).Return(nil)
+			} else {
+				mockDB.On("RecordAuditTx", mock.Anything, mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+					return e.Action == models.AuditRepositoryReset && e.Outcome == models.AuditSuccess && e.RepositoryName == tt.repoName
+				})).Return(nil)
+			}
 
-			err := service.ResetRepository(context.Background(), tt.repoName, tt.since)
+			deleted, err := service.ResetRepository(context.Background(), tt.repoName, tt.since)
 
 			if tt.expectError {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, 3, deleted)
 			}
 
 			mockDB.AssertExpectations(t)
 		})
 	}
 }
+
+func TestRepositoryStatusChanges(t *testing.T) {
+	mockDB := new(MockDatabase)
+	service := NewRepositoryService(new(MockGitHubClient), mockDB)
+	ctx := models.WithActor(context.Background(), "alice")
+
+	mockDB.On("WithTransaction", mock.Anything, mock.Anything).Return(nil)
+	for _, status := range []models.RepositoryStatus{models.RepositoryArchived, models.RepositoryDeleted, models.RepositoryActive} {
+		mockDB.On("SetRepositoryStatusTx", mock.Anything, mock.Anything, "owner/repo", status).
+			Return(&models.Repository{Name: "owner/repo", Status: status}, nil).Once()
+	}
+	mockDB.On("DeleteRepositoryTx", mock.Anything, mock.Anything, "owner/repo").Return(nil).Once()
+
+	var actions []string
+	mockDB.On("RecordAuditTx", mock.Anything, mock.Anything, mock.AnythingOfType("*models.AuditEntry")).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			entry := args.Get(2).(*models.AuditEntry)
+			assert.Equal(t, "alice", entry.Actor)
+			assert.Equal(t, models.AuditSuccess, entry.Outcome)
+			actions = append(actions, entry.Action)
+		})
+
+	repo, err := service.ArchiveRepository(ctx, "owner/repo")
+	require.NoError(t, err)
+	assert.Equal(t, models.RepositoryArchived, repo.Status)
+
+	repo, err = service.DeleteRepository(ctx, "owner/repo")
+	require.NoError(t, err)
+	assert.Equal(t, models.RepositoryDeleted, repo.Status)
+
+	repo, err = service.RestoreRepository(ctx, "owner/repo")
+	require.NoError(t, err)
+	assert.Equal(t, models.RepositoryActive, repo.Status)
+
+	require.NoError(t, service.PurgeRepository(ctx, "owner/repo"))
+	assert.Equal(t, []string{
+		models.AuditRepositoryArchive, models.AuditRepositoryDelete,
+		models.AuditRepositoryRestore, models.AuditRepositoryPurge,
+	}, actions)
+	mockDB.AssertExpectations(t)
+}
+
+func newGitHubCommit(sha, login string, date time.Time) *github.Commit {
+	c := &github.Commit{SHA: sha, HTMLURL: "http://github.com/owner/repo/commit/" + sha}
+	c.Commit.Message = "commit " + sha
+	c.Commit.Author.Name = login
+	c.Commit.Author.Email = login + "@example.com"
+	c.Commit.Author.Date = date
+	c.Author.Login = login
+	return c
+}
+
+func TestSyncRepository_MemoryDB(t *testing.T) {
+	now := time.Now().UTC()
+	testRepo := &github.Repository{
+		FullName: "owner/repo",
+		HTMLURL:  "http://github.com/owner/repo",
+	}
+
+	mockGitHubClient := new(MockGitHubClient)
+	memDB := db.NewMemoryDB()
+	service := NewRepositoryService(mockGitHubClient, memDB)
+	ctx := context.Background()
+
+	mockGitHubClient.On("GetRepository", mock.Anything, "owner", "repo").Return(testRepo, nil)
+	mockGitHubClient.On("ListCommits", mock.Anything, "owner", "repo", github.CommitListOptions{}).Return([]*github.Commit{
+		newGitHubCommit("abc", "alice", now.Add(-2*time.Hour)),
+		newGitHubCommit("def", "bob", now.Add(-time.Hour)),
+	}, nil).Once()
+
+	require.NoError(t, service.SyncRepository(ctx, models.SyncTriggerWorker, "owner", "repo", time.Time{}))
+
+	repo, err := service.GetRepository(ctx, "owner/repo")
+	require.NoError(t, err)
+	require.NotNil(t, repo.LastCommitFetchedAt)
+
+	// * A second sync returning an overlapping page must not duplicate commits
+	since := now.Add(-90 * time.Minute)
+	mockGitHubClient.On("ListCommits", mock.Anything, "owner", "repo", github.CommitListOptions{Since: since}).Return([]*github.Commit{
+		newGitHubCommit("def", "bob", now.Add(-time.Hour)),
+		newGitHubCommit("ghi", "alice", now),
+	}, nil).Once()
+
+	require.NoError(t, service.SyncRepository(ctx, models.SyncTriggerWorker, "owner", "repo", since))
+
+	repo, err = service.GetRepository(ctx, "owner/repo")
+	require.NoError(t, err)
+	lastSync := *repo.LastCommitFetchedAt
+
+	page, err := service.GetCommits(ctx, "owner/repo", models.CommitFilter{})
+	require.NoError(t, err)
+	commits := page.Commits
+	require.Len(t, commits, 3)
+	assert.Equal(t, "ghi", commits[0].SHA)
+	assert.Equal(t, "alice", commits[0].AuthorName)
+
+	authors, err := service.GetTopAuthors(ctx, "owner/repo", 10, false)
+	require.NoError(t, err)
+	assert.Equal(t, []models.AuthorCommitCount{
+		{AuthorID: 1, AuthorName: "alice", CommitCount: 2},
+		{AuthorID: 2, AuthorName: "bob", CommitCount: 1},
+	}, authors)
+
+	// * A failed sync rolls back entirely, including the repository update
+	mockGitHubClient.On("ListCommits", mock.Anything, "owner", "repo", github.CommitListOptions{Since: now}).Return([]*github.Commit(nil), errors.New("github error")).Once()
+
+	require.Error(t, service.SyncRepository(ctx, models.SyncTriggerWorker, "owner", "repo", now))
+
+	repo, err = service.GetRepository(ctx, "owner/repo")
+	require.NoError(t, err)
+	assert.Equal(t, lastSync, *repo.LastCommitFetchedAt)
+	page, err = service.GetCommits(ctx, "owner/repo", models.CommitFilter{})
+	require.NoError(t, err)
+	assert.Len(t, page.Commits, 3)
+
+	// * Every attempt is in the sync history, the failure included
+	runs, err := service.ListSyncRuns(ctx, models.SyncRunFilter{RepositoryName: "owner/repo"})
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+
+	assert.Equal(t, models.SyncFailure, runs[0].Status)
+	assert.Equal(t, "UNEXPECTED_ERROR", runs[0].ErrorReference)
+	assert.Contains(t, runs[0].ErrorMessage, "github error")
+	assert.Equal(t, now, *runs[0].Since)
+
+	assert.Equal(t, models.SyncSuccess, runs[1].Status)
+	assert.Equal(t, 2, runs[1].CommitsSeen)
+	assert.Equal(t, 1, runs[1].CommitsInserted)
+
+	assert.Equal(t, models.SyncSuccess, runs[2].Status)
+	assert.Equal(t, models.SyncTriggerWorker, runs[2].Trigger)
+	assert.Nil(t, runs[2].Since)
+	assert.Equal(t, 2, runs[2].CommitsInserted)
+	for _, run := range runs {
+		require.NotNil(t, run.FinishedAt)
+		assert.False(t, run.FinishedAt.Before(run.StartedAt))
+	}
+
+	failures, err := service.ListSyncRuns(ctx, models.SyncRunFilter{Status: models.SyncFailure})
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, runs[0].ID, failures[0].ID)
+
+	mockGitHubClient.AssertExpectations(t)
+}
+
+func TestRefreshRepository_DoesNotRecreatePurged(t *testing.T) {
+	mockGitHubClient := new(MockGitHubClient)
+	memDB := db.NewMemoryDB()
+	service := NewRepositoryService(mockGitHubClient, memDB)
+	ctx := context.Background()
+
+	mockGitHubClient.On("GetRepository", mock.Anything, "owner", "repo").Return(&github.Repository{FullName: "owner/repo"}, nil)
+	mockGitHubClient.On("ListCommits", mock.Anything, "owner", "repo", github.CommitListOptions{}).Return([]*github.Commit{}, nil)
+	require.NoError(t, service.SyncRepository(ctx, models.SyncTriggerWorker, "owner", "repo", time.Time{}))
+	require.NoError(t, service.RefreshRepository(ctx, models.SyncTriggerWorker, "owner", "repo", time.Time{}))
+
+	// * A sync job queued before the purge runs after it
+	_, err := service.DeleteRepository(ctx, "owner/repo")
+	require.NoError(t, err)
+	require.NoError(t, service.PurgeRepository(ctx, "owner/repo"))
+
+	err = service.RefreshRepository(ctx, models.SyncTriggerWorker, "owner", "repo", time.Time{})
+	assert.Equal(t, "DB_REPOSITORY_INACTIVE", pkgerrors.Reference(err))
+	_, err = service.GetRepository(ctx, "owner/repo")
+	assert.Equal(t, "DB_REPOSITORY_NOT_FOUND", pkgerrors.Reference(err))
+}
+
+func TestSyncRepository_LockedElsewhere(t *testing.T) {
+	mockGitHubClient := new(MockGitHubClient)
+	memDB := db.NewMemoryDB()
+	service := NewRepositoryService(mockGitHubClient, memDB)
+	ctx := context.Background()
+
+	// * Another replica is syncing the repository
+	lock, err := memDB.TryLock(ctx, "sync:owner/repo")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+
+	err = service.SyncRepository(ctx, models.SyncTriggerWorker, "owner", "repo", time.Time{})
+	assert.Equal(t, "SYNC_IN_PROGRESS", pkgerrors.Reference(err))
+	mockGitHubClient.AssertNotCalled(t, "GetRepository", mock.Anything, "owner", "repo")
+
+	require.NoError(t, lock.Release())
+
+	mockGitHubClient.On("GetRepository", mock.Anything, "owner", "repo").Return(&github.Repository{FullName: "owner/repo"}, nil)
+	mockGitHubClient.On("ListCommits", mock.Anything, "owner", "repo", github.CommitListOptions{}).Return([]*github.Commit{}, nil)
+	require.NoError(t, service.SyncRepository(ctx, models.SyncTriggerWorker, "owner", "repo", time.Time{}))
+
+	// * The sync let go of the lock when it finished
+	lock, err = memDB.TryLock(ctx, "sync:owner/repo")
+	require.NoError(t, err)
+	assert.NotNil(t, lock)
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * PruneBatchSize bounds how many commits a single delete statement removes
+const PruneBatchSize = 1000
+
+type RetentionService struct {
+	db        models.Database
+	batchSize int
+	now       func() time.Time
+}
+
+func NewRetentionService(db models.Database) *RetentionService {
+	return &RetentionService{
+		db:        db,
+		batchSize: PruneBatchSize,
+		now:       time.Now,
+	}
+}
+
+// * SetPolicy stores the retention policy for repoName. Leaving both limits
+// * nil keeps every commit.
+func (s *RetentionService) SetPolicy(ctx context.Context, repoName string, maxAgeDays, maxCommits *int) (*models.RetentionPolicy, error) {
+	if err := validatePolicy(maxAgeDays, maxCommits); err != nil {
+		return nil, err
+	}
+
+	policy, err := s.db.SetRetentionPolicy(ctx, repoName, models.RetentionPolicy{
+		MaxAgeDays: maxAgeDays,
+		MaxCommits: maxCommits,
+	})
+	recordAudit(ctx, s.db, newAuditEntry(ctx, models.AuditRetentionSet, repoName, map[string]*int{
+		"max_age_days": maxAgeDays,
+		"max_commits":  maxCommits,
+	}), err)
+	return policy, err
+}
+
+func validatePolicy(maxAgeDays, maxCommits *int) error {
+	if (maxAgeDays != nil && *maxAgeDays < 1) || (maxCommits != nil && *maxCommits < 1) {
+		return errors.New(
+			"INVALID_RETENTION_POLICY",
+			"Invalid retention policy",
+			"max_age_days and max_commits must be positive when set",
+			nil,
+			errors.LevelError,
+		)
+	}
+	return nil
+}
+
+func (s *RetentionService) GetPolicy(ctx context.Context, repoName string) (*models.RetentionPolicy, error) {
+	return s.db.GetRetentionPolicy(ctx, repoName)
+}
+
+func (s *RetentionService) ListPolicies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	return s.db.GetRetentionPolicies(ctx)
+}
+
+// * Preview reports what pruning repoName would delete right now. A non-nil
+// * override is evaluated instead of the stored policy, so admins can try a
+// * policy before saving it.
+func (s *RetentionService) Preview(ctx context.Context, repoName string, override *models.RetentionPolicy) (*models.PrunePreview, error) {
+	policy := override
+	if policy == nil {
+		stored, err := s.db.GetRetentionPolicy(ctx, repoName)
+		if err != nil {
+			return nil, err
+		}
+		policy = stored
+	} else if err := validatePolicy(policy.MaxAgeDays, policy.MaxCommits); err != nil {
+		return nil, err
+	}
+
+	return s.db.PreviewPrune(ctx, repoName, *policy, s.now())
+}
+
+// * PreviewAll previews every stored policy
+func (s *RetentionService) PreviewAll(ctx context.Context) ([]models.PrunePreview, error) {
+	policies, err := s.db.GetRetentionPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	previews := make([]models.PrunePreview, 0, len(policies))
+	for _, policy := range policies {
+		preview, err := s.db.PreviewPrune(ctx, policy.RepositoryName, policy, now)
+		if err != nil {
+			return nil, err
+		}
+		previews = append(previews, *preview)
+	}
+	return previews, nil
+}
+
+// * Prune enforces policy on its repository, deleting in batches until no
+// * expired commits remain. It returns the number of commits deleted.
+func (s *RetentionService) Prune(ctx context.Context, policy models.RetentionPolicy) (int, error) {
+	if policy.IsEmpty() {
+		return 0, nil
+	}
+
+	// * Evaluate every batch at the same instant so the age cutoff is stable
+	now := s.now()
+	total := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		deleted, err := s.db.PruneCommits(ctx, policy.RepositoryName, policy, now, s.batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+
+		if deleted < s.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// * MaintainPartitions creates upcoming commit partitions where the
+// * database partitions commits
+func (s *RetentionService) MaintainPartitions(ctx context.Context) error {
+	return s.db.MaintainPartitions(ctx, s.now())
+}
+
+// * PruneAll enforces every stored policy. Months that have expired for
+// * every repository in them are dropped whole first, then the remaining
+// * expired commits are deleted per repository. A failing repository is
+// * logged and skipped so it cannot block the others.
+func (s *RetentionService) PruneAll(ctx context.Context) (int, error) {
+	policies, err := s.db.GetRetentionPolicies(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total, err := s.db.DropExpiredPartitions(ctx, s.now())
+	if err != nil {
+		if ctx.Err() != nil {
+			return total, err
+		}
+		logger.Error("failed to drop expired partitions: %v", err)
+	}
+
+	for _, policy := range policies {
+		deleted, err := s.Prune(ctx, policy)
+		total += deleted
+		if err != nil {
+			if ctx.Err() != nil {
+				return total, err
+			}
+			logger.Error("failed to prune repository %s: %v", policy.RepositoryName, err)
+			continue
+		}
+
+		if deleted > 0 {
+			logger.Info("Pruned %d expired commits from %s", deleted, policy.RepositoryName)
+		}
+	}
+	return total, nil
+}
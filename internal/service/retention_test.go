@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/db"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/github"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestSetPolicy_RejectsNonPositiveLimits(t *testing.T) {
+	mockDB := new(MockDatabase)
+	service := NewRetentionService(mockDB)
+
+	_, err := service.SetPolicy(context.Background(), "owner/repo", intPtr(0), nil)
+	assert.Error(t, err)
+
+	_, err = service.SetPolicy(context.Background(), "owner/repo", nil, intPtr(-5))
+	assert.Error(t, err)
+
+	mockDB.AssertNotCalled(t, "SetRetentionPolicy", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPrune_DeletesInBatchesUntilDone(t *testing.T) {
+	mockDB := new(MockDatabase)
+	service := NewRetentionService(mockDB)
+	service.batchSize = 2
+
+	policy := models.RetentionPolicy{RepositoryName: "owner/repo", MaxCommits: intPtr(1)}
+	mockDB.On("PruneCommits", mock.Anything, "owner/repo", policy, mock.Anything, 2).Return(2, nil).Twice()
+	mockDB.On("PruneCommits", mock.Anything, "owner/repo", policy, mock.Anything, 2).Return(1, nil).Once()
+
+	deleted, err := service.Prune(context.Background(), policy)
+	require.NoError(t, err)
+	assert.Equal(t, 5, deleted)
+	mockDB.AssertExpectations(t)
+
+	// * An empty policy never touches the database
+	deleted, err = service.Prune(context.Background(), models.RetentionPolicy{RepositoryName: "owner/repo"})
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	mockDB.AssertNumberOfCalls(t, "PruneCommits", 3)
+}
+
+func TestPruneAll_DropsPartitionsBeforeDeletingRows(t *testing.T) {
+	mockDB := new(MockDatabase)
+	service := NewRetentionService(mockDB)
+	service.batchSize = 10
+
+	policy := models.RetentionPolicy{RepositoryName: "owner/repo", MaxAgeDays: intPtr(30)}
+	mockDB.On("GetRetentionPolicies", mock.Anything).Return([]models.RetentionPolicy{policy}, nil)
+	mockDB.On("DropExpiredPartitions", mock.Anything, mock.Anything).Return(500, nil).Once()
+	mockDB.On("PruneCommits", mock.Anything, "owner/repo", policy, mock.Anything, 10).Return(3, nil).Once()
+
+	deleted, err := service.PruneAll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 503, deleted)
+	mockDB.AssertExpectations(t)
+}
+
+func TestRetention_MemoryDB(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryDB()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	service := NewRetentionService(store)
+	service.batchSize = 3
+	service.now = func() time.Time { return now }
+
+	repo := &models.Repository{Name: "owner/repo"}
+	require.NoError(t, store.UpsertRepository(ctx, repo))
+	// * One commit per day, the newest is a day old
+	for i := 1; i <= 10; i++ {
+		require.NoError(t, store.InsertCommit(ctx, &models.Commit{
+			SHA:          fmt.Sprintf("sha%d", i),
+			RepositoryID: repo.ID,
+			AuthorDate:   now.AddDate(0, 0, -i),
+		}))
+	}
+
+	_, err := service.Preview(ctx, "owner/repo", nil)
+	assert.Error(t, err, "no stored policy yet")
+
+	preview, err := service.Preview(ctx, "owner/repo", &models.RetentionPolicy{MaxCommits: intPtr(4)})
+	require.NoError(t, err)
+	assert.Equal(t, 6, preview.Commits)
+
+	_, err = service.SetPolicy(ctx, "owner/repo", intPtr(6), intPtr(8))
+	require.NoError(t, err)
+
+	previews, err := service.PreviewAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, previews, 1)
+	assert.Equal(t, 4, previews[0].Commits)
+	assert.Equal(t, now.AddDate(0, 0, -10), *previews[0].OldestDate)
+	assert.Equal(t, now.AddDate(0, 0, -7), *previews[0].NewestDate)
+
+	// * Nothing was deleted by previewing
+	page, err := store.GetCommits(ctx, "owner/repo", models.CommitFilter{})
+	require.NoError(t, err)
+	assert.Len(t, page.Commits, 10)
+
+	deleted, err := service.PruneAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, deleted)
+
+	page, err = store.GetCommits(ctx, "owner/repo", models.CommitFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Commits, 6)
+	assert.Equal(t, "sha6", page.Commits[5].SHA)
+
+	// * A pruned commit can be synced again
+	require.NoError(t, store.InsertCommit(ctx, &models.Commit{SHA: "sha10", RepositoryID: repo.ID, AuthorDate: now}))
+	page, err = store.GetCommits(ctx, "owner/repo", models.CommitFilter{})
+	require.NoError(t, err)
+	assert.Len(t, page.Commits, 7)
+}
+
+func TestSync_LeavesOutCommitsPrunedByAge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	mockGitHubClient := new(MockGitHubClient)
+	store := db.NewMemoryDB()
+	repos := NewRepositoryService(mockGitHubClient, store)
+	retention := NewRetentionService(store)
+
+	history := []*github.Commit{
+		newGitHubCommit("old", "alice", now.AddDate(0, 0, -40)),
+		newGitHubCommit("new", "bob", now.AddDate(0, 0, -1)),
+	}
+	mockGitHubClient.On("GetRepository", mock.Anything, "owner", "repo").Return(&github.Repository{FullName: "owner/repo"}, nil)
+	mockGitHubClient.On("ListCommits", mock.Anything, "owner", "repo", github.CommitListOptions{}).Return(history, nil)
+	require.NoError(t, repos.SyncRepository(ctx, models.SyncTriggerWorker, "owner", "repo", time.Time{}))
+
+	_, err := retention.SetPolicy(ctx, "owner/repo", intPtr(30), nil)
+	require.NoError(t, err)
+	deleted, err := retention.PruneAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	// * A restarted worker, or a reset of the whole history, syncs from
+	// * scratch; the pruned commit stays out
+	require.NoError(t, repos.RefreshRepository(ctx, models.SyncTriggerWorker, "owner", "repo", time.Time{}))
+
+	page, err := store.GetCommits(ctx, "owner/repo", models.CommitFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Commits, 1)
+	assert.Equal(t, "new", page.Commits[0].SHA)
+}
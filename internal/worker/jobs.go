@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/queue"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+const (
+	// * jobLease is how long a claimed job stays locked without a heartbeat
+	jobLease = 2 * time.Minute
+	// * jobHeartbeat renews the lease well before it runs out
+	jobHeartbeat = jobLease / 3
+	// * jobPoll is how long an idle runner waits before claiming again
+	jobPoll = time.Second
+)
+
+// * JobHandler runs one claimed job. Returning an error retries the job
+// * with backoff until it runs out of attempts.
+type JobHandler func(ctx context.Context, job *models.Job) error
+
+// * JobRunner claims jobs from the database job queue and runs them, up to
+// * concurrency at a time. Any number of replicas can run one: a job is only
+// * claimed by one of them, and its lease is renewed while it runs so a
+// * slow job is not handed to a second replica.
+type JobRunner struct {
+	jobs        *service.JobService
+	id          string
+	concurrency int
+	handlers    map[models.JobKind]JobHandler
+}
+
+// * NewJobRunner returns a runner that locks jobs under id, which must be
+// * unique across replicas
+func NewJobRunner(jobs *service.JobService, id string, concurrency int) *JobRunner {
+	return &JobRunner{
+		jobs:        jobs,
+		id:          id,
+		concurrency: max(concurrency, 1),
+		handlers:    make(map[models.JobKind]JobHandler),
+	}
+}
+
+// * Handle registers handler for jobs of kind; call it before Run
+func (r *JobRunner) Handle(kind models.JobKind, handler JobHandler) {
+	r.handlers[kind] = handler
+}
+
+func (r *JobRunner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range r.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.loop(ctx)
+		}()
+	}
+	wg.Wait()
+	logger.Info("stopping job runner")
+}
+
+func (r *JobRunner) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := r.jobs.Claim(ctx, r.id, jobLease)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("failed to claim job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-time.After(jobPoll):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		r.run(ctx, job)
+	}
+}
+
+// * run runs job while renewing its lease, then settles it. Settling uses a
+// * context that outlives shutdown so the job is never left locked.
+func (r *JobRunner) run(ctx context.Context, job *models.Job) {
+	settleCtx := context.WithoutCancel(ctx)
+
+	handler, ok := r.handlers[job.Kind]
+	if !ok {
+		err := errors.New(
+			"UNKNOWN_JOB_KIND",
+			"Unknown job kind",
+			"No handler is registered for jobs of kind "+string(job.Kind),
+			nil,
+			errors.LevelError,
+		)
+		if ferr := r.jobs.Fail(settleCtx, job, r.id, err); ferr != nil {
+			logger.Error("failed to settle job %d (%s): %v", job.ID, job.Kind, ferr)
+		}
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		r.heartbeat(jobCtx, cancel, job)
+	}()
+
+	err := handler(jobCtx, job)
+	cancel()
+	<-heartbeatDone
+
+	if ctx.Err() != nil {
+		if rerr := r.jobs.Release(settleCtx, job, r.id); rerr != nil {
+			logger.Error("failed to release job %d: %v", job.ID, rerr)
+		}
+		return
+	}
+	r.settle(settleCtx, job, err)
+}
+
+func (r *JobRunner) settle(ctx context.Context, job *models.Job, err error) {
+	if serr := r.jobs.Finish(ctx, job, r.id, err); serr != nil {
+		logger.Error("failed to settle job %d (%s): %v", job.ID, job.Kind, serr)
+	}
+}
+
+// * heartbeat renews the lease on job until ctx is done. Losing the lock
+// * means another replica owns the job now, so the run is cancelled.
+func (r *JobRunner) heartbeat(ctx context.Context, cancel context.CancelFunc, job *models.Job) {
+	ticker := time.NewTicker(jobHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := r.jobs.Extend(ctx, job, r.id, jobLease)
+			if errors.Reference(err) == "DB_JOB_LOCK_LOST" {
+				logger.Warn("lost the lock on job %d (%s), stopping it", job.ID, job.Kind)
+				cancel()
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				logger.Error("failed to renew the lease on job %d: %v", job.ID, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// * SyncJobHandler runs sync jobs enqueued by JobSync
+func SyncJobHandler(service *service.RepositoryService) JobHandler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload queue.SyncJob
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return errors.New(
+				"INVALID_JOB_PAYLOAD",
+				"Invalid job payload",
+				"The payload of a sync job is not a repository to sync",
+				err,
+				errors.LevelError,
+			)
+		}
+		return runSyncJob(ctx, service, payload)
+	}
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+type locker interface {
+	TryLock(ctx context.Context, name string) (models.Lock, error)
+}
+
+// * Leader elects one replica to run the scheduler. Leadership is a named
+// * lock: whoever holds it leads, and the others retry every so often so one
+// * of them takes over soon after the leader goes away.
+type Leader struct {
+	db    locker
+	name  string
+	retry time.Duration
+
+	mu      sync.Mutex
+	lock    models.Lock
+	leading atomic.Bool
+}
+
+func NewLeader(db locker, name string) *Leader {
+	return &Leader{
+		db:    db,
+		name:  name,
+		retry: 10 * time.Second,
+	}
+}
+
+// * IsLeader reports whether this replica led at the last check
+func (l *Leader) IsLeader() bool {
+	return l.leading.Load()
+}
+
+// * Check confirms this replica still holds leadership, or tries to take it
+func (l *Leader) Check(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lock != nil {
+		if l.lock.Held(ctx) {
+			return
+		}
+		// * The session behind the lock is gone; releasing only tidies up
+		logger.Warn("lost leadership of %s", l.name)
+		l.lock.Release()
+		l.lock = nil
+		l.leading.Store(false)
+	}
+
+	lock, err := l.db.TryLock(ctx, "leader:"+l.name)
+	if err != nil {
+		logger.Error("failed to take leadership of %s: %v", l.name, err)
+		return
+	}
+	if lock == nil {
+		return
+	}
+	l.lock = lock
+	l.leading.Store(true)
+	logger.Info("leading %s", l.name)
+}
+
+// * Run keeps checking leadership until ctx is done, then steps down
+func (l *Leader) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.retry)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.Check(ctx)
+		case <-ctx.Done():
+			l.stepDown()
+			return
+		}
+	}
+}
+
+func (l *Leader) stepDown() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.leading.Store(false)
+	if l.lock == nil {
+		return
+	}
+	if err := l.lock.Release(); err != nil {
+		logger.Error("failed to step down as leader of %s: %v", l.name, err)
+	}
+	l.lock = nil
+}
+
+// * LeaderOnly runs sync only while leader leads, so however many replicas
+// * run a scheduler each repository is scheduled once per interval
+func LeaderOnly(leader *Leader, sync SyncFunc) SyncFunc {
+	return func(ctx context.Context, owner, name string, since time.Time) error {
+		if !leader.IsLeader() {
+			logger.Debug("not the leader, skipping scheduled sync of %s/%s", owner, name)
+			return nil
+		}
+		return sync(ctx, owner, name, since)
+	}
+}
@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/config"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * SyncManager owns one SyncWorker per monitored repository so each can be
+// * stopped or restarted on its own, without restarting the process
+type SyncManager struct {
+	ctx      context.Context
+	service  *service.RepositoryService
+	sync     SyncFunc
+	interval time.Duration
+
+	mu      sync.Mutex
+	workers map[string]*runningWorker
+}
+
+type runningWorker struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// * NewSyncManager returns a manager whose workers all stop when ctx is done.
+// * Each worker runs its syncs through sync.
+func NewSyncManager(ctx context.Context, service *service.RepositoryService, sync SyncFunc, interval time.Duration) *SyncManager {
+	return &SyncManager{
+		ctx:      ctx,
+		service:  service,
+		sync:     sync,
+		interval: interval,
+		workers:  make(map[string]*runningWorker),
+	}
+}
+
+// * Start runs a sync worker for owner/name unless one is already running
+func (m *SyncManager) Start(owner, name string) {
+	fullName := owner + "/" + name
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workers[fullName]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	running := &runningWorker{cancel: cancel, done: make(chan struct{})}
+	m.workers[fullName] = running
+
+	go func() {
+		defer close(running.done)
+		NewSyncWorker(m.service, m.sync, m.interval, owner, name).Run(ctx)
+	}()
+	logger.Info("started sync worker for %s", fullName)
+}
+
+// * Stop cancels the worker for fullName and waits until it has exited, so
+// * no sync of that repository is still in flight when it returns
+func (m *SyncManager) Stop(fullName string) {
+	m.mu.Lock()
+	running, ok := m.workers[fullName]
+	delete(m.workers, fullName)
+	m.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	running.cancel()
+	<-running.done
+}
+
+// * Running reports whether a worker is syncing fullName
+func (m *SyncManager) Running(fullName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.workers[fullName]
+	return ok
+}
+
+// * Reconcile starts a worker for every active repository and stops the
+// * rest, picking up repositories added, archived or deleted through
+// * another replica
+func (m *SyncManager) Reconcile(ctx context.Context) error {
+	repositories, err := m.service.ListAllRepositories(ctx)
+	if err != nil {
+		return err
+	}
+
+	active := make(map[string]bool, len(repositories))
+	for _, repo := range repositories {
+		if repo.Status != models.RepositoryActive {
+			continue
+		}
+		owner, name, err := config.ParseRepository(repo.Name)
+		if err != nil {
+			logger.Error("skipping repository with invalid name %q: %v", repo.Name, err)
+			continue
+		}
+		active[repo.Name] = true
+		m.Start(owner, name)
+	}
+
+	m.mu.Lock()
+	var stale []string
+	for fullName := range m.workers {
+		if !active[fullName] {
+			stale = append(stale, fullName)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, fullName := range stale {
+		m.Stop(fullName)
+		logger.Info("stopped sync worker for %s", fullName)
+	}
+	return nil
+}
+
+// * Watch reconciles every interval until the manager's context is done
+func (m *SyncManager) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Reconcile(m.ctx); err != nil {
+				logger.Error("failed to reconcile sync workers: %v", err)
+			}
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * PruneWorker periodically creates upcoming commit partitions and deletes
+// * commits that fall outside their repository's retention policy. Only the
+// * leader prunes, when there is one.
+type PruneWorker struct {
+	service  *service.RetentionService
+	leader   *Leader
+	interval time.Duration
+}
+
+func NewPruneWorker(service *service.RetentionService, leader *Leader, interval time.Duration) *PruneWorker {
+	return &PruneWorker{
+		service:  service,
+		leader:   leader,
+		interval: interval,
+	}
+}
+
+func (w *PruneWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if w.leader != nil && !w.leader.IsLeader() {
+				continue
+			}
+
+			if err := w.service.MaintainPartitions(ctx); err != nil {
+				logger.Error("partition maintenance failed: %v", err)
+			}
+
+			deleted, err := w.service.PruneAll(ctx)
+			if err != nil {
+				logger.Error("prune failed: %v", err)
+			} else {
+				logger.Info("prune finished, %d expired commits deleted", deleted)
+			}
+
+		case <-ctx.Done():
+			logger.Info("stopping prune worker")
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/queue"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
+)
+
+// * QueueWorker runs the sync jobs published to the job queue, reconnecting
+// * to the broker whenever the connection is lost
+type QueueWorker struct {
+	service   *service.RepositoryService
+	queue     *queue.Queue
+	reconnect time.Duration
+}
+
+func NewQueueWorker(service *service.RepositoryService, queue *queue.Queue) *QueueWorker {
+	return &QueueWorker{
+		service:   service,
+		queue:     queue,
+		reconnect: 5 * time.Second,
+	}
+}
+
+func (w *QueueWorker) Run(ctx context.Context) {
+	for {
+		err := w.queue.Consume(ctx, w.handle)
+		if ctx.Err() != nil {
+			logger.Info("stopping queue worker")
+			return
+		}
+		logger.Error("job queue consumer stopped, reconnecting in %s: %v", w.reconnect, err)
+
+		select {
+		case <-time.After(w.reconnect):
+		case <-ctx.Done():
+			logger.Info("stopping queue worker")
+			return
+		}
+	}
+}
+
+// * handle runs a job from the job queue
+func (w *QueueWorker) handle(ctx context.Context, job queue.SyncJob) error {
+	return runSyncJob(ctx, w.service, job)
+}
+
+// * runSyncJob syncs the repository of job, from either job queue. Jobs for
+// * repositories that were archived, deleted or purged after the job was
+// * published are dropped, as are jobs for repositories another replica is
+// * syncing at the time.
+func runSyncJob(ctx context.Context, service *service.RepositoryService, job queue.SyncJob) error {
+	fullName := job.Owner + "/" + job.Name
+
+	// * A replica may not have seen the repository archived or deleted yet
+	repo, err := service.GetRepository(models.ReadPrimary(ctx), fullName)
+	if errors.Reference(err) == "DB_REPOSITORY_NOT_FOUND" {
+		logger.Warn("dropping sync job for unknown repository %s", fullName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if repo.Status != models.RepositoryActive {
+		logger.Info("dropping sync job for %s repository %s", repo.Status, fullName)
+		return nil
+	}
+
+	var since time.Time
+	if job.Since != nil {
+		since = *job.Since
+	}
+	trigger := job.Trigger
+	if trigger == "" {
+		trigger = models.SyncTriggerWorker
+	}
+
+	err = service.RefreshRepository(ctx, trigger, job.Owner, job.Name, since)
+	switch errors.Reference(err) {
+	case "SYNC_IN_PROGRESS":
+		// * Another replica is syncing it right now, which is what this job
+		// * wanted done
+		logger.Info("dropping sync job for %s, already syncing elsewhere", fullName)
+		return nil
+	case "DB_REPOSITORY_INACTIVE":
+		// * Archived, deleted or purged after the check above
+		logger.Info("dropping sync job for %s, no longer active", fullName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("successfully synced repository %s", fullName)
+	return nil
+}
@@ -4,20 +4,59 @@ import (
 	"context"
 	"time"
 
+	"github.com/KOFI-GYIMAH/github-monitor/internal/models"
+	"github.com/KOFI-GYIMAH/github-monitor/internal/queue"
 	"github.com/KOFI-GYIMAH/github-monitor/internal/service"
+	"github.com/KOFI-GYIMAH/github-monitor/pkg/errors"
 	"github.com/KOFI-GYIMAH/github-monitor/pkg/logger"
 )
 
+// * SyncFunc runs one scheduled sync of owner/name from since; a zero since
+// * syncs the full history
+type SyncFunc func(ctx context.Context, owner, name string, since time.Time) error
+
+// * JobSync enqueues each sync on the database job queue. A repository has
+// * at most one pending sync job, so replicas scheduling the same repository
+// * do not sync it twice.
+func JobSync(jobs *service.JobService, maxAttempts int) SyncFunc {
+	return func(ctx context.Context, owner, name string, since time.Time) error {
+		payload := queue.SyncJob{Owner: owner, Name: name, Trigger: models.SyncTriggerWorker}
+		if !since.IsZero() {
+			payload.Since = &since
+		}
+		_, err := jobs.Enqueue(ctx, &models.Job{
+			Kind:        models.JobSync,
+			DedupeKey:   "sync:" + owner + "/" + name,
+			Priority:    service.JobPriorityNormal,
+			MaxAttempts: maxAttempts,
+		}, payload)
+		return err
+	}
+}
+
+// * QueueSync publishes each sync to RabbitMQ for a worker process to run
+func QueueSync(q *queue.Queue) SyncFunc {
+	return func(ctx context.Context, owner, name string, since time.Time) error {
+		job := queue.SyncJob{Owner: owner, Name: name, Trigger: models.SyncTriggerWorker}
+		if !since.IsZero() {
+			job.Since = &since
+		}
+		return q.Publish(ctx, job)
+	}
+}
+
 type SyncWorker struct {
 	service  *service.RepositoryService
+	sync     SyncFunc
 	interval time.Duration
 	owner    string
 	repo     string
 }
 
-func NewSyncWorker(service *service.RepositoryService, interval time.Duration, owner, repo string) *SyncWorker {
+func NewSyncWorker(service *service.RepositoryService, sync SyncFunc, interval time.Duration, owner, repo string) *SyncWorker {
 	return &SyncWorker{
 		service:  service,
+		sync:     sync,
 		interval: interval,
 		owner:    owner,
 		repo:     repo,
@@ -25,7 +64,9 @@ func NewSyncWorker(service *service.RepositoryService, interval time.Duration, o
 }
 
 func (w *SyncWorker) Run(ctx context.Context) {
-	err := w.service.SyncRepository(ctx, w.owner, w.repo, time.Time{})
+	// * The first sync also carries on from the last fetch, so a restart
+	// * does not pull the whole history back in
+	err := w.sync(ctx, w.owner, w.repo, w.since(ctx))
 	if err != nil {
 		logger.Error("initial sync failed: %v", err)
 	}
@@ -36,24 +77,11 @@ func (w *SyncWorker) Run(ctx context.Context) {
 	for {
 		select {
 		case <-ticker.C:
-			// * Get last sync time from DB
-			fullRepoName := w.owner + "/" + w.repo
-			repo, err := w.service.GetRepository(ctx, fullRepoName)
-			if err != nil {
-				logger.Error("failed to get repository: %v", err)
-				continue
-			}
-
-			var since time.Time
-			if repo != nil && repo.LastCommitFetchedAt != nil {
-				since = *repo.LastCommitFetchedAt
-			}
-
-			err = w.service.SyncRepository(ctx, w.owner, w.repo, since)
+			err = w.sync(ctx, w.owner, w.repo, w.since(ctx))
 			if err != nil {
 				logger.Error("sync failed: %v", err)
 			} else {
-				logger.Info("successfully synced repository %s", fullRepoName)
+				logger.Debug("scheduled sync of repository %s/%s", w.owner, w.repo)
 			}
 
 		case <-ctx.Done():
@@ -62,3 +90,20 @@ func (w *SyncWorker) Run(ctx context.Context) {
 		}
 	}
 }
+
+// * since returns when the repository last fetched commits, or the zero time
+// * for a repository that was never synced
+func (w *SyncWorker) since(ctx context.Context) time.Time {
+	// * Get last sync time from DB
+	repo, err := w.service.GetRepository(ctx, w.owner+"/"+w.repo)
+	if err != nil {
+		if errors.Reference(err) != "DB_REPOSITORY_NOT_FOUND" {
+			logger.Error("failed to get repository: %v", err)
+		}
+		return time.Time{}
+	}
+	if repo == nil || repo.LastCommitFetchedAt == nil {
+		return time.Time{}
+	}
+	return *repo.LastCommitFetchedAt
+}
@@ -0,0 +1,2 @@
+-- supports keyset pagination in GetCommits: ORDER BY author_date DESC, id DESC per repository
+CREATE INDEX IF NOT EXISTS idx_commits_repo_date_id ON commits(repository_id, author_date DESC, id DESC);
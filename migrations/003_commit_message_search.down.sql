@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_commits_message_tsv;
+ALTER TABLE commits DROP COLUMN IF EXISTS message_tsv;
@@ -0,0 +1,5 @@
+-- full-text search over commit messages
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS message_tsv tsvector
+    GENERATED ALWAYS AS (to_tsvector('english', coalesce(message, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_commits_message_tsv ON commits USING GIN (message_tsv);
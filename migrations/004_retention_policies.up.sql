@@ -0,0 +1,7 @@
+-- per-repository retention; a NULL limit is not enforced
+CREATE TABLE IF NOT EXISTS retention_policies (
+    repository_id INTEGER PRIMARY KEY REFERENCES repositories(id) ON DELETE CASCADE,
+    max_age_days INTEGER CHECK (max_age_days > 0),
+    max_commits INTEGER CHECK (max_commits > 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
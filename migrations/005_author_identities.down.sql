@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_commits_author_identity_id;
+DROP INDEX IF EXISTS idx_commits_repo_author_id;
+ALTER TABLE commits DROP COLUMN IF EXISTS author_identity_id;
+ALTER TABLE commits DROP COLUMN IF EXISTS author_id;
+ALTER TABLE commits DROP COLUMN IF EXISTS author_github_id;
+ALTER TABLE commits DROP COLUMN IF EXISTS author_login;
+DROP TABLE IF EXISTS mailmap_entries;
+DROP TABLE IF EXISTS author_identities;
+DROP TABLE IF EXISTS authors;
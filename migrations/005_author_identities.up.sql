@@ -0,0 +1,39 @@
+-- one row per person; identities map GitHub IDs, emails and names onto it
+CREATE TABLE IF NOT EXISTS authors (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    email TEXT,
+    login TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS author_identities (
+    id SERIAL PRIMARY KEY,
+    author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('github_id', 'email', 'name')),
+    value TEXT NOT NULL,
+    CONSTRAINT unique_author_identity UNIQUE (kind, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_author_identities_author_id ON author_identities(author_id);
+
+-- user-supplied .mailmap, applied when resolving identities
+CREATE TABLE IF NOT EXISTS mailmap_entries (
+    id SERIAL PRIMARY KEY,
+    proper_name TEXT NOT NULL DEFAULT '',
+    proper_email TEXT NOT NULL DEFAULT '',
+    commit_name TEXT NOT NULL DEFAULT '',
+    commit_email TEXT NOT NULL
+);
+
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS author_login TEXT;
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS author_github_id BIGINT;
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS author_id INTEGER REFERENCES authors(id) ON DELETE SET NULL;
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS author_identity_id INTEGER REFERENCES author_identities(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_commits_repo_author_id ON commits(repository_id, author_id);
+CREATE INDEX IF NOT EXISTS idx_commits_author_identity_id ON commits(author_identity_id);
+
+-- author_name held the GitHub login before the git author name was stored;
+-- record it as the login of existing commits
+UPDATE commits SET author_login = author_name WHERE author_login IS NULL;
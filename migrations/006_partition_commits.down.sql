@@ -0,0 +1,42 @@
+ALTER TABLE commits RENAME TO commits_partitioned;
+
+CREATE TABLE commits (
+    id INTEGER NOT NULL DEFAULT nextval('commits_id_seq'),
+    sha TEXT NOT NULL,
+    repository_id INTEGER NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    author_name TEXT,
+    author_email TEXT,
+    author_date TIMESTAMP WITH TIME ZONE NOT NULL,
+    commit_url TEXT NOT NULL,
+    message_tsv tsvector GENERATED ALWAYS AS (to_tsvector('english', coalesce(message, ''))) STORED,
+    author_login TEXT,
+    author_github_id BIGINT,
+    author_id INTEGER REFERENCES authors(id) ON DELETE SET NULL,
+    author_identity_id INTEGER REFERENCES author_identities(id) ON DELETE SET NULL
+);
+
+INSERT INTO commits (
+    id, sha, repository_id, message, author_name, author_email, author_date, commit_url,
+    author_login, author_github_id, author_id, author_identity_id
+)
+SELECT
+    id, sha, repository_id, message, author_name, author_email, author_date, commit_url,
+    author_login, author_github_id, author_id, author_identity_id
+FROM commits_partitioned;
+
+ALTER SEQUENCE commits_id_seq OWNED BY NONE;
+DROP TABLE commits_partitioned;
+ALTER SEQUENCE commits_id_seq OWNED BY commits.id;
+DROP FUNCTION IF EXISTS create_commit_partition(TIMESTAMPTZ);
+
+ALTER TABLE commits ADD PRIMARY KEY (id);
+ALTER TABLE commits ADD CONSTRAINT unique_commit_per_repo UNIQUE (sha, repository_id);
+
+CREATE INDEX IF NOT EXISTS idx_commits_repository_id ON commits(repository_id);
+CREATE INDEX IF NOT EXISTS idx_commits_author_date ON commits(author_date);
+CREATE INDEX IF NOT EXISTS idx_commits_author_name ON commits(author_name);
+CREATE INDEX IF NOT EXISTS idx_commits_repo_date_id ON commits(repository_id, author_date DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_commits_message_tsv ON commits USING GIN (message_tsv);
+CREATE INDEX IF NOT EXISTS idx_commits_repo_author_id ON commits(repository_id, author_id);
+CREATE INDEX IF NOT EXISTS idx_commits_author_identity_id ON commits(author_identity_id);
@@ -0,0 +1,84 @@
+-- range-partition commits by month on author_date so queries and retention
+-- only touch the months they need. Rows for months without a partition land
+-- in commits_default until create_commit_partition moves them out.
+
+-- creates the partition for the UTC month containing for_month, moving any
+-- rows for that month out of the default partition first
+CREATE OR REPLACE FUNCTION create_commit_partition(for_month TIMESTAMPTZ) RETURNS TEXT AS $$
+DECLARE
+    lower_bound TIMESTAMPTZ := date_trunc('month', for_month AT TIME ZONE 'UTC') AT TIME ZONE 'UTC';
+    upper_bound TIMESTAMPTZ := (date_trunc('month', for_month AT TIME ZONE 'UTC') + INTERVAL '1 month') AT TIME ZONE 'UTC';
+    partition_name TEXT := 'commits_' || to_char(for_month AT TIME ZONE 'UTC', 'YYYY_MM');
+    column_list TEXT;
+BEGIN
+    -- concurrent callers would otherwise race to create the same table
+    PERFORM pg_advisory_xact_lock(hashtext('create_commit_partition'));
+
+    IF to_regclass(partition_name) IS NOT NULL THEN
+        RETURN partition_name;
+    END IF;
+
+    SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position) INTO column_list
+    FROM information_schema.columns
+    WHERE table_schema = current_schema() AND table_name = 'commits' AND is_generated = 'NEVER';
+
+    EXECUTE format(
+        'CREATE TEMP TABLE commit_partition_rows AS SELECT %s FROM commits_default WHERE author_date >= %L AND author_date < %L',
+        column_list, lower_bound, upper_bound);
+    EXECUTE format('DELETE FROM commits_default WHERE author_date >= %L AND author_date < %L', lower_bound, upper_bound);
+    EXECUTE format('CREATE TABLE %I PARTITION OF commits FOR VALUES FROM (%L) TO (%L)', partition_name, lower_bound, upper_bound);
+    EXECUTE format('INSERT INTO commits (%s) SELECT %s FROM commit_partition_rows', column_list, column_list);
+    DROP TABLE commit_partition_rows;
+
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE commits RENAME TO commits_unpartitioned;
+
+-- unique constraints on a partitioned table must include author_date; a
+-- commit's SHA fixes its date, so duplicates are still rejected
+CREATE TABLE commits (
+    id INTEGER NOT NULL DEFAULT nextval('commits_id_seq'),
+    sha TEXT NOT NULL,
+    repository_id INTEGER NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    author_name TEXT,
+    author_email TEXT,
+    author_date TIMESTAMP WITH TIME ZONE NOT NULL,
+    commit_url TEXT NOT NULL,
+    message_tsv tsvector GENERATED ALWAYS AS (to_tsvector('english', coalesce(message, ''))) STORED,
+    author_login TEXT,
+    author_github_id BIGINT,
+    author_id INTEGER REFERENCES authors(id) ON DELETE SET NULL,
+    author_identity_id INTEGER REFERENCES author_identities(id) ON DELETE SET NULL
+) PARTITION BY RANGE (author_date);
+
+CREATE TABLE commits_default PARTITION OF commits DEFAULT;
+
+SELECT create_commit_partition(month)
+FROM (SELECT DISTINCT date_trunc('month', author_date AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS month FROM commits_unpartitioned) months;
+
+INSERT INTO commits (
+    id, sha, repository_id, message, author_name, author_email, author_date, commit_url,
+    author_login, author_github_id, author_id, author_identity_id
+)
+SELECT
+    id, sha, repository_id, message, author_name, author_email, author_date, commit_url,
+    author_login, author_github_id, author_id, author_identity_id
+FROM commits_unpartitioned;
+
+ALTER SEQUENCE commits_id_seq OWNED BY NONE;
+DROP TABLE commits_unpartitioned;
+ALTER SEQUENCE commits_id_seq OWNED BY commits.id;
+
+ALTER TABLE commits ADD PRIMARY KEY (id, author_date);
+ALTER TABLE commits ADD CONSTRAINT unique_commit_per_repo UNIQUE (sha, repository_id, author_date);
+
+CREATE INDEX IF NOT EXISTS idx_commits_repository_id ON commits(repository_id);
+CREATE INDEX IF NOT EXISTS idx_commits_author_date ON commits(author_date);
+CREATE INDEX IF NOT EXISTS idx_commits_author_name ON commits(author_name);
+CREATE INDEX IF NOT EXISTS idx_commits_repo_date_id ON commits(repository_id, author_date DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_commits_message_tsv ON commits USING GIN (message_tsv);
+CREATE INDEX IF NOT EXISTS idx_commits_repo_author_id ON commits(repository_id, author_id);
+CREATE INDEX IF NOT EXISTS idx_commits_author_identity_id ON commits(author_identity_id);
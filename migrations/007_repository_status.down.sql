@@ -0,0 +1,2 @@
+ALTER TABLE repositories DROP COLUMN IF EXISTS status_changed_at;
+ALTER TABLE repositories DROP COLUMN IF EXISTS status;
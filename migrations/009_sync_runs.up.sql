@@ -0,0 +1,20 @@
+-- one row per sync attempt; the repository is kept by name so the history
+-- also covers syncs of repositories that were never stored or were purged
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id BIGSERIAL PRIMARY KEY,
+    repository_name TEXT NOT NULL,
+    triggered_by TEXT NOT NULL CHECK (triggered_by IN ('worker', 'api', 'webhook')),
+    since TIMESTAMP WITH TIME ZONE,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    pages_fetched INTEGER NOT NULL DEFAULT 0,
+    commits_seen INTEGER NOT NULL DEFAULT 0,
+    commits_inserted INTEGER NOT NULL DEFAULT 0,
+    api_calls INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL CHECK (status IN ('running', 'success', 'failure')),
+    error_reference TEXT,
+    error_message TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_runs_repository ON sync_runs(repository_name, id DESC);
+CREATE INDEX IF NOT EXISTS idx_sync_runs_failures ON sync_runs(id DESC) WHERE status = 'failure';
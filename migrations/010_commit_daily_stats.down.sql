@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS commit_daily_stats_maintain ON commits;
+DROP FUNCTION IF EXISTS commit_daily_stats_maintain();
+DROP FUNCTION IF EXISTS commit_daily_stats_apply(INTEGER, TIMESTAMPTZ, INTEGER, TEXT, INTEGER);
+DROP TABLE IF EXISTS commit_daily_stats;
@@ -0,0 +1,65 @@
+-- commit counts per repository, UTC day and author, kept in step with commits
+-- by triggers so analytics never scan commits. Resolved authors are keyed by
+-- author_id with an empty author_name; unresolved commits by their recorded
+-- name under author_id 0.
+CREATE TABLE IF NOT EXISTS commit_daily_stats (
+    repository_id INTEGER NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    author_id INTEGER NOT NULL DEFAULT 0,
+    author_name TEXT NOT NULL DEFAULT '',
+    commit_count INTEGER NOT NULL CHECK (commit_count > 0),
+    PRIMARY KEY (repository_id, day, author_id, author_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_commit_daily_stats_author ON commit_daily_stats(author_id, day);
+
+-- adds delta commits to the bucket of one commit, removing emptied buckets
+CREATE OR REPLACE FUNCTION commit_daily_stats_apply(repo INTEGER, authored TIMESTAMPTZ, author INTEGER, name TEXT, delta INTEGER) RETURNS VOID AS $$
+DECLARE
+    stat_day DATE := (authored AT TIME ZONE 'UTC')::date;
+    stat_author INTEGER := COALESCE(author, 0);
+    stat_name TEXT := CASE WHEN author IS NULL THEN COALESCE(name, '') ELSE '' END;
+BEGIN
+    IF delta > 0 THEN
+        INSERT INTO commit_daily_stats (repository_id, day, author_id, author_name, commit_count)
+        VALUES (repo, stat_day, stat_author, stat_name, delta)
+        ON CONFLICT (repository_id, day, author_id, author_name)
+        DO UPDATE SET commit_count = commit_daily_stats.commit_count + EXCLUDED.commit_count;
+        RETURN;
+    END IF;
+
+    DELETE FROM commit_daily_stats
+    WHERE repository_id = repo AND day = stat_day AND author_id = stat_author AND author_name = stat_name
+        AND commit_count <= -delta;
+    IF NOT FOUND THEN
+        UPDATE commit_daily_stats SET commit_count = commit_count + delta
+        WHERE repository_id = repo AND day = stat_day AND author_id = stat_author AND author_name = stat_name;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION commit_daily_stats_maintain() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM commit_daily_stats_apply(OLD.repository_id, OLD.author_date, OLD.author_id, OLD.author_name, -1);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM commit_daily_stats_apply(NEW.repository_id, NEW.author_date, NEW.author_id, NEW.author_name, 1);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- also fires on every partition, including rows moved out of commits_default.
+-- author_date is left out: a commit's date is never rewritten, and a change
+-- would move the row between partitions as a delete and an insert anyway.
+CREATE TRIGGER commit_daily_stats_maintain
+    AFTER INSERT OR DELETE OR UPDATE OF author_id, author_name ON commits
+    FOR EACH ROW EXECUTE FUNCTION commit_daily_stats_maintain();
+
+INSERT INTO commit_daily_stats (repository_id, day, author_id, author_name, commit_count)
+SELECT repository_id, (author_date AT TIME ZONE 'UTC')::date, COALESCE(author_id, 0),
+    CASE WHEN author_id IS NULL THEN COALESCE(author_name, '') ELSE '' END, COUNT(*)
+FROM commits
+GROUP BY 1, 2, 3, 4
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS bot_rules;
+ALTER TABLE authors DROP COLUMN IF EXISTS is_bot;
+ALTER TABLE authors DROP COLUMN IF EXISTS account_type;
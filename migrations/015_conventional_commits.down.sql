@@ -0,0 +1,4 @@
+ALTER TABLE commits DROP COLUMN IF EXISTS conventional_subject;
+ALTER TABLE commits DROP COLUMN IF EXISTS conventional_breaking;
+ALTER TABLE commits DROP COLUMN IF EXISTS conventional_scope;
+ALTER TABLE commits DROP COLUMN IF EXISTS conventional_type;
@@ -0,0 +1,8 @@
+-- Conventional Commits fields parsed from the message header when the commit
+-- is stored. conventional_type is NULL when the message does not follow the
+-- convention; conventional_breaking is NULL for commits synced before they
+-- were parsed, which are parsed when read instead.
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS conventional_type TEXT;
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS conventional_scope TEXT;
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS conventional_breaking BOOLEAN;
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS conventional_subject TEXT;
@@ -0,0 +1,15 @@
+// Package migrations embeds the SQL schema migrations so the binary does
+// not depend on the working directory it is started from.
+package migrations
+
+import "embed"
+
+// * Postgres holds the PostgreSQL migrations at the root of the FS
+//
+//go:embed *.sql
+var Postgres embed.FS
+
+// * SQLite holds the SQLite migrations under the sqlite/ directory
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
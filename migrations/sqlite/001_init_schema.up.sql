@@ -0,0 +1,34 @@
+-- repositories table
+CREATE TABLE IF NOT EXISTS repositories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT,
+    url TEXT NOT NULL,
+    language TEXT,
+    forks_count INTEGER,
+    stars_count INTEGER,
+    open_issues_count INTEGER,
+    watchers_count INTEGER,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    last_commit_fetched_at TIMESTAMP,
+    CONSTRAINT unique_repo_name UNIQUE (name)
+);
+
+-- commits table
+CREATE TABLE IF NOT EXISTS commits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sha TEXT NOT NULL,
+    repository_id INTEGER NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    author_name TEXT,
+    author_email TEXT,
+    author_date TIMESTAMP NOT NULL,
+    commit_url TEXT NOT NULL,
+    CONSTRAINT unique_commit_per_repo UNIQUE (sha, repository_id)
+);
+
+
+CREATE INDEX IF NOT EXISTS idx_commits_repository_id ON commits(repository_id);
+CREATE INDEX IF NOT EXISTS idx_commits_author_date ON commits(author_date);
+CREATE INDEX IF NOT EXISTS idx_commits_author_name ON commits(author_name);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_commits_repo_date_id;
@@ -0,0 +1,22 @@
+-- full-text search over commit messages, kept in sync with commits by triggers
+CREATE VIRTUAL TABLE IF NOT EXISTS commits_fts USING fts5(
+    message,
+    content = 'commits',
+    content_rowid = 'id',
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS commits_fts_insert AFTER INSERT ON commits BEGIN
+    INSERT INTO commits_fts(rowid, message) VALUES (new.id, new.message);
+END;
+
+CREATE TRIGGER IF NOT EXISTS commits_fts_delete AFTER DELETE ON commits BEGIN
+    INSERT INTO commits_fts(commits_fts, rowid, message) VALUES ('delete', old.id, old.message);
+END;
+
+CREATE TRIGGER IF NOT EXISTS commits_fts_update AFTER UPDATE OF message ON commits BEGIN
+    INSERT INTO commits_fts(commits_fts, rowid, message) VALUES ('delete', old.id, old.message);
+    INSERT INTO commits_fts(rowid, message) VALUES (new.id, new.message);
+END;
+
+INSERT INTO commits_fts(commits_fts) VALUES ('rebuild');
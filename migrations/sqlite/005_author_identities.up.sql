@@ -0,0 +1,41 @@
+-- one row per person; identities map GitHub IDs, emails and names onto it
+CREATE TABLE IF NOT EXISTS authors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    email TEXT,
+    login TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS author_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('github_id', 'email', 'name')),
+    value TEXT NOT NULL,
+    CONSTRAINT unique_author_identity UNIQUE (kind, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_author_identities_author_id ON author_identities(author_id);
+
+-- user-supplied .mailmap, applied when resolving identities
+CREATE TABLE IF NOT EXISTS mailmap_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    proper_name TEXT NOT NULL DEFAULT '',
+    proper_email TEXT NOT NULL DEFAULT '',
+    commit_name TEXT NOT NULL DEFAULT '',
+    commit_email TEXT NOT NULL
+);
+
+-- SQLite cannot drop columns that carry foreign keys, so author references
+-- on commits are maintained by the application
+ALTER TABLE commits ADD COLUMN author_login TEXT;
+ALTER TABLE commits ADD COLUMN author_github_id INTEGER;
+ALTER TABLE commits ADD COLUMN author_id INTEGER;
+ALTER TABLE commits ADD COLUMN author_identity_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_commits_repo_author_id ON commits(repository_id, author_id);
+CREATE INDEX IF NOT EXISTS idx_commits_author_identity_id ON commits(author_identity_id);
+
+-- author_name held the GitHub login before the git author name was stored;
+-- record it as the login of existing commits
+UPDATE commits SET author_login = author_name WHERE author_login IS NULL;
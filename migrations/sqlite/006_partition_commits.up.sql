@@ -0,0 +1,3 @@
+-- SQLite has no table partitioning; this version only keeps the schema
+-- versions of both engines aligned
+SELECT 1;
@@ -0,0 +1,2 @@
+ALTER TABLE repositories DROP COLUMN status_changed_at;
+ALTER TABLE repositories DROP COLUMN status;
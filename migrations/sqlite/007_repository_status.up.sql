@@ -0,0 +1,4 @@
+-- archived and deleted repositories keep their commits but are not synced
+ALTER TABLE repositories ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'archived', 'deleted'));
+ALTER TABLE repositories ADD COLUMN status_changed_at TIMESTAMP;
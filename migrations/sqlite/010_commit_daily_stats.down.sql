@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS commit_daily_stats_insert;
+DROP TRIGGER IF EXISTS commit_daily_stats_delete;
+DROP TRIGGER IF EXISTS commit_daily_stats_update;
+DROP TABLE IF EXISTS commit_daily_stats;
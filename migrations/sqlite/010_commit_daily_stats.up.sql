@@ -0,0 +1,52 @@
+-- commit counts per repository, UTC day and author, kept in step with commits
+-- by triggers so analytics never scan commits. Resolved authors are keyed by
+-- author_id with an empty author_name; unresolved commits by their recorded
+-- name under author_id 0.
+CREATE TABLE IF NOT EXISTS commit_daily_stats (
+    repository_id INTEGER NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    day TEXT NOT NULL,
+    author_id INTEGER NOT NULL DEFAULT 0,
+    author_name TEXT NOT NULL DEFAULT '',
+    commit_count INTEGER NOT NULL CHECK (commit_count > 0),
+    PRIMARY KEY (repository_id, day, author_id, author_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_commit_daily_stats_author ON commit_daily_stats(author_id, day);
+
+CREATE TRIGGER IF NOT EXISTS commit_daily_stats_insert AFTER INSERT ON commits BEGIN
+    INSERT INTO commit_daily_stats (repository_id, day, author_id, author_name, commit_count)
+    VALUES (new.repository_id, date(new.author_date), COALESCE(new.author_id, 0),
+        CASE WHEN new.author_id IS NULL THEN COALESCE(new.author_name, '') ELSE '' END, 1)
+    ON CONFLICT (repository_id, day, author_id, author_name) DO UPDATE SET commit_count = commit_count + 1;
+END;
+
+-- an emptied bucket is deleted before the decrement, which then matches nothing
+CREATE TRIGGER IF NOT EXISTS commit_daily_stats_delete AFTER DELETE ON commits BEGIN
+    DELETE FROM commit_daily_stats WHERE repository_id = old.repository_id AND day = date(old.author_date)
+        AND author_id = COALESCE(old.author_id, 0)
+        AND author_name = CASE WHEN old.author_id IS NULL THEN COALESCE(old.author_name, '') ELSE '' END
+        AND commit_count <= 1;
+    UPDATE commit_daily_stats SET commit_count = commit_count - 1 WHERE repository_id = old.repository_id AND day = date(old.author_date)
+        AND author_id = COALESCE(old.author_id, 0)
+        AND author_name = CASE WHEN old.author_id IS NULL THEN COALESCE(old.author_name, '') ELSE '' END;
+END;
+
+CREATE TRIGGER IF NOT EXISTS commit_daily_stats_update AFTER UPDATE OF author_id, author_name, author_date ON commits BEGIN
+    DELETE FROM commit_daily_stats WHERE repository_id = old.repository_id AND day = date(old.author_date)
+        AND author_id = COALESCE(old.author_id, 0)
+        AND author_name = CASE WHEN old.author_id IS NULL THEN COALESCE(old.author_name, '') ELSE '' END
+        AND commit_count <= 1;
+    UPDATE commit_daily_stats SET commit_count = commit_count - 1 WHERE repository_id = old.repository_id AND day = date(old.author_date)
+        AND author_id = COALESCE(old.author_id, 0)
+        AND author_name = CASE WHEN old.author_id IS NULL THEN COALESCE(old.author_name, '') ELSE '' END;
+    INSERT INTO commit_daily_stats (repository_id, day, author_id, author_name, commit_count)
+    VALUES (new.repository_id, date(new.author_date), COALESCE(new.author_id, 0),
+        CASE WHEN new.author_id IS NULL THEN COALESCE(new.author_name, '') ELSE '' END, 1)
+    ON CONFLICT (repository_id, day, author_id, author_name) DO UPDATE SET commit_count = commit_count + 1;
+END;
+
+INSERT INTO commit_daily_stats (repository_id, day, author_id, author_name, commit_count)
+SELECT repository_id, date(author_date), COALESCE(author_id, 0),
+    CASE WHEN author_id IS NULL THEN COALESCE(author_name, '') ELSE '' END, COUNT(*)
+FROM commits
+GROUP BY 1, 2, 3, 4;
@@ -0,0 +1,19 @@
+-- background resets started through the API, polled by id. The repository is
+-- kept by name like sync_runs.
+CREATE TABLE IF NOT EXISTS reset_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_name TEXT NOT NULL,
+    since TIMESTAMP,
+    actor TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('queued', 'deleting', 'syncing', 'succeeded', 'failed')),
+    commits_deleted INTEGER NOT NULL DEFAULT 0,
+    commits_inserted INTEGER NOT NULL DEFAULT 0,
+    sync_run_id INTEGER REFERENCES sync_runs(id) ON DELETE SET NULL,
+    error_reference TEXT,
+    error_message TEXT,
+    created_at TIMESTAMP NOT NULL,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reset_jobs_repository ON reset_jobs(repository_name, id DESC);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS bot_rules;
+ALTER TABLE authors DROP COLUMN is_bot;
+ALTER TABLE authors DROP COLUMN account_type;
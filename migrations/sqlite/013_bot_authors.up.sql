@@ -0,0 +1,16 @@
+-- account_type is the GitHub account type ('User', 'Bot', ...) seen when the
+-- author was created; is_bot applies the bot rules on top of it
+ALTER TABLE authors ADD COLUMN account_type TEXT;
+ALTER TABLE authors ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- configured allow (bot = FALSE) and deny (bot = TRUE) patterns
+CREATE TABLE IF NOT EXISTS bot_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pattern TEXT NOT NULL,
+    bot BOOLEAN NOT NULL
+);
+
+-- the account type of existing authors is unknown, so only the [bot] suffix
+-- GitHub Apps commit under classifies them
+UPDATE authors SET is_bot = TRUE
+WHERE LOWER(login) LIKE '%[bot]' OR LOWER(name) LIKE '%[bot]' OR LOWER(email) LIKE '%[bot]@%';
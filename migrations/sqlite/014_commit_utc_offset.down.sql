@@ -0,0 +1 @@
+ALTER TABLE commits DROP COLUMN author_utc_offset;
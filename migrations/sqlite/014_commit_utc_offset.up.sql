@@ -0,0 +1,3 @@
+-- the UTC offset, in seconds, of the author date as reported to us; the
+-- stored timestamp loses it. NULL for commits synced before it was kept.
+ALTER TABLE commits ADD COLUMN author_utc_offset INTEGER;
@@ -0,0 +1,4 @@
+ALTER TABLE commits DROP COLUMN conventional_subject;
+ALTER TABLE commits DROP COLUMN conventional_breaking;
+ALTER TABLE commits DROP COLUMN conventional_scope;
+ALTER TABLE commits DROP COLUMN conventional_type;
@@ -138,3 +138,13 @@ func WriteHTTPError(w http.ResponseWriter, err error) {
 	w.WriteHeader(resp.Status)
 	json.NewEncoder(w).Encode(resp)
 }
+
+// * Reference returns the reference of the first ApplicationError in err's
+// * chain, or an empty string when there is none
+func Reference(err error) string {
+	var appErr *ApplicationError
+	if errors.As(err, &appErr) {
+		return appErr.Reference
+	}
+	return ""
+}